@@ -1,33 +1,199 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Config is the typed server configuration. Every value is resolved from, in
+// increasing precedence, its default, the optional CONFIG_FILE, its
+// environment variable and, for secrets, the file named by the variable's
+// *_FILE form.
 type Config struct {
-	DatabaseURL string
-	Port        string
-	GinMode     string
+	Server    ServerConfig
+	Database  DatabaseConfig
+	RateLimit RateLimitConfig
+	CORS      CORSConfig
+	CSRF      CSRFConfig
+	Storage   StorageConfig
+	Trash     TrashConfig
+	Webhooks  WebhooksConfig
+	Auth      AuthConfig
+	Tracing   TracingConfig
+
+	// effective records where each value came from, for LogValue
+	effective []effectiveValue
+}
+
+type ServerConfig struct {
+	Port    string
+	GinMode string
+
+	// WriteTimeout bounds the slowest request, so it must leave room for
+	// batch imports and restores.
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // How long in-flight requests may take to finish on SIGTERM
+	MaxRequestBytes int64
+}
+
+type DatabaseConfig struct {
+	URL             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+type RateLimitConfig struct {
+	RedisURL          string // Shared rate limit store; limits are kept in memory when empty
+	RequestsPerMinute int    // Default budget per client
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string // "*" allows any origin
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool // Lets browsers send cookies; needs explicit origins
+	MaxAge           time.Duration
+}
+
+// CSRFConfig lists the origins besides the API's own that may send
+// state-changing requests. It is kept apart from CORS so that letting any site
+// read the API never lets any site write to it.
+type CSRFConfig struct {
+	TrustedOrigins []string // Explicit origins only; "*" is rejected
+}
+
+type StorageConfig struct {
+	MediaDir string // Directory for uploaded files such as certification card images
+}
+
+// TrashConfig controls how long deleted dives, trips, tags and dive sites
+// can be restored before the retention job purges them.
+type TrashConfig struct {
+	RetentionDays int
+	PurgeInterval time.Duration // How often the retention job runs
+}
+
+type WebhooksConfig struct {
+	PollInterval time.Duration // How often the dispatcher checks for due deliveries
+}
+
+type AuthConfig struct {
+	SessionSecret string // Signs session cookies and CSRF tokens
+	SessionCookie string // Requests carrying this cookie must pass CSRF token checks
 }
 
+// TracingConfig is configured with the standard OpenTelemetry variables.
+type TracingConfig struct {
+	Exporter     string // "otlp", "console" or "none"
+	OTLPEndpoint string // Full OTLP/HTTP traces URL
+	OTLPHeaders  map[string]string
+	ServiceName  string
+}
+
+type effectiveValue struct {
+	key    string
+	value  string
+	source string
+}
+
+// Load reads the configuration and validates it. The error lists every
+// invalid setting, not just the first.
 func Load() (*Config, error) {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		// Not a fatal error - .env file is optional
 	}
 
-	return &Config{
-		DatabaseURL: os.Getenv("DATABASE_URL"),
-		Port:        getEnvWithDefault("PORT", "8080"),
-		GinMode:     os.Getenv("GIN_MODE"),
-	}, nil
+	configFile := os.Getenv("CONFIG_FILE")
+	fileValues, err := loadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	var problems []error
+	for _, setting := range settings {
+		value, source, err := resolve(setting, fileValues, configFile)
+		if err == nil {
+			err = setting.apply(cfg, value)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s (%s): %w", setting.key, source, err))
+			continue
+		}
+		if setting.redact != nil {
+			value = setting.redact(value)
+		}
+		cfg.effective = append(cfg.effective, effectiveValue{key: setting.key, value: value, source: source})
+	}
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(problems...))
+	}
+	return cfg, nil
+}
+
+// resolve picks the value of one setting and names where it came from.
+// Empty environment variables count as unset.
+func resolve(setting setting, fileValues map[string]string, configFile string) (string, string, error) {
+	value, source := setting.def, "default"
+	if fileValue, ok := fileValues[setting.key]; ok {
+		value, source = fileValue, configFile
+	}
+	if envValue := os.Getenv(setting.env); envValue != "" {
+		value, source = envValue, setting.env
+	}
+	if setting.redact == nil {
+		return value, source, nil
+	}
+	secretFile := os.Getenv(setting.env + "_FILE")
+	if secretFile == "" {
+		return value, source, nil
+	}
+	if os.Getenv(setting.env) != "" {
+		return "", setting.env + "_FILE", fmt.Errorf("set either %s or %s_FILE, not both", setting.env, setting.env)
+	}
+	secret, err := os.ReadFile(secretFile)
+	if err != nil {
+		return "", setting.env + "_FILE", fmt.Errorf("read secret: %w", err)
+	}
+	return strings.TrimRight(string(secret), "\r\n"), setting.env + "_FILE", nil
+}
+
+// validate checks rules that span several settings.
+func (c *Config) validate() []error {
+	var problems []error
+	if c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		problems = append(problems, fmt.Errorf("database.max_idle_conns (%d) must not exceed database.max_open_conns (%d)",
+			c.Database.MaxIdleConns, c.Database.MaxOpenConns))
+	}
+	if contains(c.CORS.AllowedOrigins, "*") {
+		if len(c.CORS.AllowedOrigins) > 1 {
+			problems = append(problems, errors.New("cors.allowed_origins: \"*\" cannot be combined with other origins"))
+		}
+		if c.CORS.AllowCredentials {
+			problems = append(problems, errors.New("cors.allow_credentials requires explicit cors.allowed_origins, not \"*\""))
+		}
+	}
+	return problems
 }
 
-func getEnvWithDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// LogValue lists the effective configuration with the source of every value.
+// Secrets are redacted, so the whole Config can be logged at startup.
+func (c *Config) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(c.effective))
+	for _, value := range c.effective {
+		attrs = append(attrs, slog.String(value.key, fmt.Sprintf("%q from %s", value.value, value.source)))
 	}
-	return defaultValue
+	return slog.GroupValue(attrs...)
 }
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile reads a YAML or TOML config file, chosen by extension, into
+// values keyed by setting path such as "server.port". It returns nothing
+// when path is empty.
+func loadFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	document := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format; use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flatten(document, "", values); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flatten walks nested tables down to known settings and rejects any key
+// that is not one, so typos fail at startup instead of being ignored.
+func flatten(document map[string]interface{}, prefix string, values map[string]string) error {
+	names := make([]string, 0, len(document))
+	for name := range document {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := prefix + name
+		if isSetting(key) {
+			values[key] = fileValue(document[name])
+			continue
+		}
+		table, ok := document[name].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		if err := flatten(table, key+".", values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileValue renders a file value the way the environment variable would
+// spell it: lists comma-separated and tables as key=value pairs.
+func fileValue(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(typed))
+		for i, item := range typed {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		pairs := make([]string, 0, len(typed))
+		for key, item := range typed {
+			pairs = append(pairs, key+"="+url.QueryEscape(fmt.Sprint(item)))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(typed)
+	}
+}
+
+func isSetting(key string) bool {
+	for _, setting := range settings {
+		if setting.key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -3,84 +3,276 @@ package database
 import (
 	"context"
 	"database/sql"
+	"divelog-backend/utils"
+	"embed"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
 )
 
-// RunMigrations applies the small, idempotent schema migrations that are
-// required when an existing Docker volume outlives init.sql. PostgreSQL only
-// executes init.sql for a brand-new data directory.
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// migrationLockID is the pg_advisory_lock key that serializes migrations when
+// several replicas boot at once.
+const migrationLockID int64 = 7_104_213_880
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change with its optional rollback.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// LoadMigrations reads NNNN_name.up.sql / NNNN_name.down.sql pairs from fsys
+// and returns them ordered by version.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version <= 0 {
+			return nil, fmt.Errorf("migration %q must have a positive version", entry.Name())
+		}
+		contents, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %q: %w", entry.Name(), err)
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %q and %q", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies and rolls back the embedded migrations. Applied versions are
+// recorded in schema_migrations; each migration and its bookkeeping row commit
+// together, so a failed migration leaves no partial version behind.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for the migrations embedded in the binary.
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := LoadMigrations(embeddedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// RunMigrations applies every pending migration. It is called on server start.
 func RunMigrations(ctx context.Context, db *sql.DB) error {
-	const migration = `
-		CREATE TABLE IF NOT EXISTS trips (
-			id SERIAL PRIMARY KEY,
-			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up(ctx)
+	return err
+}
+
+// Up applies all pending migrations in version order and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, migration.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			utils.LogInfo(ctx, "Applied database migration",
+				slog.Int("version", migration.Version), slog.String("name", migration.Name))
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("down steps must be positive")
+	}
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %04d_%s cannot be rolled back", migration.Version, migration.Name)
+			}
+			if err := runMigration(ctx, conn, migration.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, migration.Version); err != nil {
+				return fmt.Errorf("roll back migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			utils.LogInfo(ctx, "Rolled back database migration",
+				slog.Int("version", migration.Version), slog.String("name", migration.Name))
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration with its applied time, if any.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		statuses = make([]MigrationStatus, 0, len(m.migrations))
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := versions[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// Pending lists the embedded migrations that have not been applied yet. It
+// reads schema_migrations without taking the migration lock, so readiness
+// probes never queue behind a running migration.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	versions, err := appliedVersions(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := versions[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// withLock runs operation on a dedicated connection holding the migration
+// advisory lock. Session-level advisory locks belong to a connection, so the
+// lock, the work and the unlock must all use the same one.
+func (m *Migrator) withLock(ctx context.Context, operation func(*sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
 			name VARCHAR(255) NOT NULL,
-			location VARCHAR(255),
-			start_date DATE,
-			end_date DATE,
-			notes TEXT,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			CHECK (end_date IS NULL OR start_date IS NULL OR end_date >= start_date)
-		);
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_trips_user_name ON trips(user_id, lower(name));
-
-		CREATE TABLE IF NOT EXISTS tags (
-			id SERIAL PRIMARY KEY,
-			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			name VARCHAR(100) NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
-		);
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_user_name ON tags(user_id, lower(name));
-
-		ALTER TABLE dives ADD COLUMN IF NOT EXISTS dive_number INTEGER;
-		ALTER TABLE dives ADD COLUMN IF NOT EXISTS trip_id INTEGER REFERENCES trips(id) ON DELETE SET NULL;
-		ALTER TABLE dives ADD COLUMN IF NOT EXISTS mean_depth DECIMAL(5, 2);
-		ALTER TABLE dives ADD COLUMN IF NOT EXISTS dive_mode VARCHAR(10);
-		ALTER TABLE dives ADD COLUMN IF NOT EXISTS computer_metadata JSONB;
-		DO $$ BEGIN
-			ALTER TABLE dives ADD CONSTRAINT dives_dive_mode_check CHECK (dive_mode IN ('OC', 'freedive', 'CCR', 'pSCR'));
-		EXCEPTION WHEN duplicate_object THEN NULL;
-		END $$;
-		CREATE INDEX IF NOT EXISTS idx_dives_trip_id ON dives(trip_id);
-		CREATE INDEX IF NOT EXISTS idx_dives_dive_mode ON dives(dive_mode);
-		CREATE INDEX IF NOT EXISTS idx_dives_user_number ON dives(user_id, dive_number);
-
-		CREATE TABLE IF NOT EXISTS dive_tags (
-			dive_id INTEGER NOT NULL REFERENCES dives(id) ON DELETE CASCADE,
-			tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
-			PRIMARY KEY (dive_id, tag_id)
-		);
-		CREATE INDEX IF NOT EXISTS idx_dive_tags_tag_id ON dive_tags(tag_id);
-
-		CREATE TABLE IF NOT EXISTS bulk_operations (
-			id VARCHAR(32) PRIMARY KEY,
-			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			operation_type VARCHAR(50) NOT NULL,
-			before_state JSONB NOT NULL,
-			affected_count INTEGER NOT NULL,
-			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
-			undone_at TIMESTAMP WITH TIME ZONE
-		);
-		CREATE INDEX IF NOT EXISTS idx_bulk_operations_user_created ON bulk_operations(user_id, created_at DESC);
-
-		WITH numbered AS (
-			SELECT d.id,
-			       COALESCE((SELECT MAX(existing.dive_number) FROM dives existing WHERE existing.user_id = d.user_id), 0)
-			       + ROW_NUMBER() OVER (PARTITION BY d.user_id ORDER BY d.dive_datetime, d.id)::INTEGER AS number
-			FROM dives d WHERE d.dive_number IS NULL
-		)
-		UPDATE dives d SET dive_number = numbered.number FROM numbered WHERE d.id = numbered.id;
-
-		UPDATE dives SET mean_depth = profile.mean_depth
-		FROM (
-			SELECT id, (SELECT AVG((sample->>'depth')::numeric) FROM jsonb_array_elements(samples) sample) AS mean_depth
-			FROM dives WHERE mean_depth IS NULL AND jsonb_typeof(samples) = 'array' AND jsonb_array_length(samples) > 0
-		) profile WHERE dives.id = profile.id AND profile.mean_depth IS NOT NULL;
-	`
-	if _, err := db.ExecContext(ctx, migration); err != nil {
-		return fmt.Errorf("apply logbook organization migration: %w", err)
-	}
-	return nil
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return operation(conn)
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Conn.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func appliedVersions(ctx context.Context, conn queryer) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	versions := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %w", err)
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+func runMigration(ctx context.Context, conn *sql.Conn, script, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS bulk_operations;
+DROP TABLE IF EXISTS dive_tags;
+DROP INDEX IF EXISTS idx_dives_user_number;
+DROP INDEX IF EXISTS idx_dives_dive_mode;
+DROP INDEX IF EXISTS idx_dives_trip_id;
+ALTER TABLE dives DROP CONSTRAINT IF EXISTS dives_dive_mode_check;
+ALTER TABLE dives
+    DROP COLUMN IF EXISTS computer_metadata,
+    DROP COLUMN IF EXISTS dive_mode,
+    DROP COLUMN IF EXISTS mean_depth,
+    DROP COLUMN IF EXISTS trip_id,
+    DROP COLUMN IF EXISTS dive_number;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS trips;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_dives_extra_data;
+ALTER TABLE dives DROP COLUMN IF EXISTS extra_data;
@@ -0,0 +1,3 @@
+-- Vendor-specific import fields without a dedicated column.
+ALTER TABLE dives ADD COLUMN IF NOT EXISTS extra_data JSONB;
+CREATE INDEX IF NOT EXISTS idx_dives_extra_data ON dives USING GIN (extra_data);
@@ -0,0 +1,17 @@
+DROP TRIGGER IF EXISTS dive_sites_search_vector ON dive_sites;
+DROP TRIGGER IF EXISTS trips_search_vector ON trips;
+DROP TRIGGER IF EXISTS tags_search_vector ON tags;
+DROP TRIGGER IF EXISTS dive_tags_search_vector ON dive_tags;
+DROP TRIGGER IF EXISTS dives_search_vector ON dives;
+DROP FUNCTION IF EXISTS dive_sites_touch_dives();
+DROP FUNCTION IF EXISTS trips_touch_dives();
+DROP FUNCTION IF EXISTS tags_touch_dives();
+DROP FUNCTION IF EXISTS dive_tags_touch_dive();
+DROP FUNCTION IF EXISTS dives_set_search_vector();
+DROP FUNCTION IF EXISTS dive_search_document(dives);
+DROP INDEX IF EXISTS idx_dives_search;
+ALTER TABLE dives DROP COLUMN IF EXISTS search_vector;
+DROP INDEX IF EXISTS idx_trips_search;
+ALTER TABLE trips DROP COLUMN IF EXISTS search_vector;
+DROP INDEX IF EXISTS idx_dive_sites_search;
+ALTER TABLE dive_sites DROP COLUMN IF EXISTS search_vector;
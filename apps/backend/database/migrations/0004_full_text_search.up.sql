@@ -0,0 +1,100 @@
+-- Full-text search. Dive sites and trips index their own columns through
+-- generated columns. A dive's document also includes its tags, trip name and
+-- site, which live in other tables, so dives.search_vector is maintained by
+-- triggers: the dives trigger rebuilds the vector on every insert or update,
+-- and the triggers on related tables touch the affected dives so that tag,
+-- trip and site changes (including bulk edits) are reflected immediately.
+
+ALTER TABLE dive_sites ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_dive_sites_search ON dive_sites USING GIN (search_vector);
+
+ALTER TABLE trips ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(location, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(notes, '')), 'C')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_trips_search ON trips USING GIN (search_vector);
+
+ALTER TABLE dives ADD COLUMN IF NOT EXISTS search_vector tsvector;
+CREATE INDEX IF NOT EXISTS idx_dives_search ON dives USING GIN (search_vector);
+
+CREATE OR REPLACE FUNCTION dive_search_document(dive dives) RETURNS tsvector
+LANGUAGE sql STABLE AS $$
+    SELECT setweight(to_tsvector('english', coalesce(dive.location, '')), 'A')
+        || setweight(to_tsvector('english', coalesce(dive.buddy, '')), 'B')
+        || setweight(to_tsvector('english', coalesce((
+               SELECT string_agg(t.name, ' ') FROM dive_tags dt JOIN tags t ON t.id = dt.tag_id
+               WHERE dt.dive_id = dive.id), '')), 'B')
+        || setweight(to_tsvector('english', coalesce((SELECT tr.name FROM trips tr WHERE tr.id = dive.trip_id), '')), 'B')
+        || setweight(to_tsvector('english', coalesce(dive.notes, '')), 'C')
+        || setweight(to_tsvector('english', coalesce((
+               SELECT concat_ws(' ', ds.name, ds.description) FROM dive_sites ds WHERE ds.id = dive.dive_site_id), '')), 'D')
+$$;
+
+CREATE OR REPLACE FUNCTION dives_set_search_vector() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.search_vector := dive_search_document(NEW);
+    RETURN NEW;
+END $$;
+
+CREATE OR REPLACE FUNCTION dive_tags_touch_dive() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE dives SET search_vector = NULL WHERE id = NEW.dive_id;
+    END IF;
+    IF TG_OP IN ('DELETE', 'UPDATE') THEN
+        UPDATE dives SET search_vector = NULL WHERE id = OLD.dive_id;
+    END IF;
+    RETURN NULL;
+END $$;
+
+CREATE OR REPLACE FUNCTION tags_touch_dives() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE dives SET search_vector = NULL
+    WHERE id IN (SELECT dive_id FROM dive_tags WHERE tag_id = NEW.id);
+    RETURN NULL;
+END $$;
+
+CREATE OR REPLACE FUNCTION trips_touch_dives() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE dives SET search_vector = NULL WHERE trip_id = NEW.id;
+    RETURN NULL;
+END $$;
+
+CREATE OR REPLACE FUNCTION dive_sites_touch_dives() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE dives SET search_vector = NULL WHERE dive_site_id = NEW.id;
+    RETURN NULL;
+END $$;
+
+DROP TRIGGER IF EXISTS dives_search_vector ON dives;
+CREATE TRIGGER dives_search_vector BEFORE INSERT OR UPDATE ON dives
+    FOR EACH ROW EXECUTE FUNCTION dives_set_search_vector();
+
+DROP TRIGGER IF EXISTS dive_tags_search_vector ON dive_tags;
+CREATE TRIGGER dive_tags_search_vector AFTER INSERT OR UPDATE OR DELETE ON dive_tags
+    FOR EACH ROW EXECUTE FUNCTION dive_tags_touch_dive();
+
+DROP TRIGGER IF EXISTS tags_search_vector ON tags;
+CREATE TRIGGER tags_search_vector AFTER UPDATE OF name ON tags
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name) EXECUTE FUNCTION tags_touch_dives();
+
+DROP TRIGGER IF EXISTS trips_search_vector ON trips;
+CREATE TRIGGER trips_search_vector AFTER UPDATE OF name ON trips
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name) EXECUTE FUNCTION trips_touch_dives();
+
+DROP TRIGGER IF EXISTS dive_sites_search_vector ON dive_sites;
+CREATE TRIGGER dive_sites_search_vector AFTER UPDATE OF name, description ON dive_sites
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name OR OLD.description IS DISTINCT FROM NEW.description)
+    EXECUTE FUNCTION dive_sites_touch_dives();
+
+-- Build vectors for existing dives through the dives trigger.
+UPDATE dives SET search_vector = NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_dive_sites_lat_lng;
+DROP INDEX IF EXISTS idx_dive_sites_geohash;
+ALTER TABLE dive_sites DROP COLUMN IF EXISTS geohash;
+DROP FUNCTION IF EXISTS geohash_encode(DOUBLE PRECISION, DOUBLE PRECISION, INTEGER);
@@ -0,0 +1,53 @@
+-- Spatial lookup without PostGIS. Nearby and bounding-box queries use the
+-- (latitude, longitude) index; map clustering groups by geohash prefixes.
+
+CREATE OR REPLACE FUNCTION geohash_encode(lat DOUBLE PRECISION, lng DOUBLE PRECISION, chars INTEGER)
+RETURNS TEXT LANGUAGE plpgsql IMMUTABLE STRICT PARALLEL SAFE AS $$
+DECLARE
+    alphabet CONSTANT TEXT := '0123456789bcdefghjkmnpqrstuvwxyz';
+    lat_min DOUBLE PRECISION := -90;
+    lat_max DOUBLE PRECISION := 90;
+    lng_min DOUBLE PRECISION := -180;
+    lng_max DOUBLE PRECISION := 180;
+    mid DOUBLE PRECISION;
+    bits INTEGER := 0;
+    cell INTEGER := 0;
+    even BOOLEAN := true;
+    result TEXT := '';
+BEGIN
+    WHILE length(result) < chars LOOP
+        IF even THEN
+            mid := (lng_min + lng_max) / 2;
+            IF lng >= mid THEN
+                cell := cell * 2 + 1;
+                lng_min := mid;
+            ELSE
+                cell := cell * 2;
+                lng_max := mid;
+            END IF;
+        ELSE
+            mid := (lat_min + lat_max) / 2;
+            IF lat >= mid THEN
+                cell := cell * 2 + 1;
+                lat_min := mid;
+            ELSE
+                cell := cell * 2;
+                lat_max := mid;
+            END IF;
+        END IF;
+        even := NOT even;
+        bits := bits + 1;
+        IF bits = 5 THEN
+            result := result || substr(alphabet, cell + 1, 1);
+            bits := 0;
+            cell := 0;
+        END IF;
+    END LOOP;
+    RETURN result;
+END $$;
+
+ALTER TABLE dive_sites ADD COLUMN IF NOT EXISTS geohash VARCHAR(12) GENERATED ALWAYS AS (
+    geohash_encode(latitude::DOUBLE PRECISION, longitude::DOUBLE PRECISION, 12)
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_dive_sites_geohash ON dive_sites(geohash text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_dive_sites_lat_lng ON dive_sites(latitude, longitude);
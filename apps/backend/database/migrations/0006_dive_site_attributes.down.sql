@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_dives_dive_site_id;
+DROP INDEX IF EXISTS idx_dive_sites_environment;
+DROP INDEX IF EXISTS idx_dive_sites_location_hierarchy;
+ALTER TABLE dive_sites
+    DROP COLUMN IF EXISTS hazards,
+    DROP COLUMN IF EXISTS access_notes,
+    DROP COLUMN IF EXISTS area,
+    DROP COLUMN IF EXISTS region,
+    DROP COLUMN IF EXISTS country,
+    DROP COLUMN IF EXISTS water_type,
+    DROP COLUMN IF EXISTS environment,
+    DROP COLUMN IF EXISTS entry_type,
+    DROP COLUMN IF EXISTS typical_depth,
+    DROP COLUMN IF EXISTS max_depth;
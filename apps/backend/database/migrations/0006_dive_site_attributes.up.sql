@@ -0,0 +1,19 @@
+ALTER TABLE dive_sites
+    ADD COLUMN IF NOT EXISTS max_depth DECIMAL(5, 2) CHECK (max_depth > 0), -- stored in meters
+    ADD COLUMN IF NOT EXISTS typical_depth DECIMAL(5, 2) CHECK (typical_depth > 0),
+    ADD COLUMN IF NOT EXISTS entry_type VARCHAR(10) CHECK (entry_type IN ('shore', 'boat')),
+    ADD COLUMN IF NOT EXISTS environment VARCHAR(20) CHECK (environment IN ('reef', 'wreck', 'cave', 'lake', 'quarry')),
+    ADD COLUMN IF NOT EXISTS water_type VARCHAR(10) CHECK (water_type IN ('salt', 'fresh', 'brackish')),
+    ADD COLUMN IF NOT EXISTS country VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS region VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS area VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS access_notes TEXT,
+    ADD COLUMN IF NOT EXISTS hazards TEXT,
+    ADD CONSTRAINT dive_sites_typical_depth_within_max CHECK (typical_depth <= max_depth),
+    ADD CONSTRAINT dive_sites_area_requires_region CHECK (area IS NULL OR region IS NOT NULL),
+    ADD CONSTRAINT dive_sites_region_requires_country CHECK (region IS NULL OR country IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS idx_dive_sites_location_hierarchy
+    ON dive_sites (LOWER(country), LOWER(region), LOWER(area));
+CREATE INDEX IF NOT EXISTS idx_dive_sites_environment ON dive_sites (environment);
+CREATE INDEX IF NOT EXISTS idx_dives_dive_site_id ON dives (dive_site_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_dive_sites_country_code;
+ALTER TABLE dive_sites DROP COLUMN IF EXISTS country_code;
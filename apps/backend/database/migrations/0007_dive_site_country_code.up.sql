@@ -0,0 +1,6 @@
+-- ISO 3166-1 alpha-2 code, filled by reverse geocoding so statistics can
+-- group by country regardless of how the country name was spelled.
+ALTER TABLE dive_sites
+    ADD COLUMN IF NOT EXISTS country_code CHAR(2) CHECK (country_code ~ '^[A-Z]{2}$');
+
+CREATE INDEX IF NOT EXISTS idx_dive_sites_country_code ON dive_sites (country_code);
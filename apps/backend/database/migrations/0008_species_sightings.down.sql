@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS dive_sightings;
+DROP TABLE IF EXISTS species;
@@ -0,0 +1,101 @@
+-- Shared marine-life catalog and per-dive sightings. The catalog is seeded
+-- with common reef and pelagic species; users can add more.
+CREATE TABLE IF NOT EXISTS species (
+    id SERIAL PRIMARY KEY,
+    common_name VARCHAR(200) NOT NULL,
+    scientific_name VARCHAR(200),
+    category VARCHAR(20) NOT NULL CHECK (category IN (
+        'fish', 'shark', 'ray', 'reptile', 'mammal', 'cephalopod',
+        'crustacean', 'mollusc', 'echinoderm', 'cnidarian', 'other'
+    )),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_species_common_name ON species (lower(common_name));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_species_scientific_name ON species (lower(scientific_name))
+    WHERE scientific_name IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_species_category ON species (category);
+
+CREATE TABLE IF NOT EXISTS dive_sightings (
+    dive_id INTEGER NOT NULL REFERENCES dives(id) ON DELETE CASCADE,
+    species_id INTEGER NOT NULL REFERENCES species(id) ON DELETE RESTRICT,
+    count INTEGER NOT NULL DEFAULT 1 CHECK (count > 0),
+    notes TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (dive_id, species_id)
+);
+CREATE INDEX IF NOT EXISTS idx_dive_sightings_species_id ON dive_sightings (species_id);
+
+INSERT INTO species (common_name, scientific_name, category) VALUES
+    ('Whale shark', 'Rhincodon typus', 'shark'),
+    ('Great white shark', 'Carcharodon carcharias', 'shark'),
+    ('Tiger shark', 'Galeocerdo cuvier', 'shark'),
+    ('Scalloped hammerhead', 'Sphyrna lewini', 'shark'),
+    ('Great hammerhead', 'Sphyrna mokarran', 'shark'),
+    ('Oceanic whitetip shark', 'Carcharhinus longimanus', 'shark'),
+    ('Grey reef shark', 'Carcharhinus amblyrhynchos', 'shark'),
+    ('Blacktip reef shark', 'Carcharhinus melanopterus', 'shark'),
+    ('Whitetip reef shark', 'Triaenodon obesus', 'shark'),
+    ('Caribbean reef shark', 'Carcharhinus perezi', 'shark'),
+    ('Bull shark', 'Carcharhinus leucas', 'shark'),
+    ('Nurse shark', 'Ginglymostoma cirratum', 'shark'),
+    ('Tawny nurse shark', 'Nebrius ferrugineus', 'shark'),
+    ('Zebra shark', 'Stegostoma tigrinum', 'shark'),
+    ('Pelagic thresher shark', 'Alopias pelagicus', 'shark'),
+    ('Wobbegong', 'Orectolobus maculatus', 'shark'),
+    ('Reef manta ray', 'Mobula alfredi', 'ray'),
+    ('Oceanic manta ray', 'Mobula birostris', 'ray'),
+    ('Spotted eagle ray', 'Aetobatus narinari', 'ray'),
+    ('Southern stingray', 'Hypanus americanus', 'ray'),
+    ('Blue-spotted ribbontail ray', 'Taeniura lymma', 'ray'),
+    ('Bat ray', 'Myliobatis californica', 'ray'),
+    ('Green sea turtle', 'Chelonia mydas', 'reptile'),
+    ('Hawksbill sea turtle', 'Eretmochelys imbricata', 'reptile'),
+    ('Loggerhead sea turtle', 'Caretta caretta', 'reptile'),
+    ('Banded sea krait', 'Laticauda colubrina', 'reptile'),
+    ('Bottlenose dolphin', 'Tursiops truncatus', 'mammal'),
+    ('Spinner dolphin', 'Stenella longirostris', 'mammal'),
+    ('Dugong', 'Dugong dugon', 'mammal'),
+    ('West Indian manatee', 'Trichechus manatus', 'mammal'),
+    ('California sea lion', 'Zalophus californianus', 'mammal'),
+    ('Harbor seal', 'Phoca vitulina', 'mammal'),
+    ('Humpback whale', 'Megaptera novaeangliae', 'mammal'),
+    ('Ocean sunfish', 'Mola mola', 'fish'),
+    ('Giant trevally', 'Caranx ignobilis', 'fish'),
+    ('Great barracuda', 'Sphyraena barracuda', 'fish'),
+    ('Napoleon wrasse', 'Cheilinus undulatus', 'fish'),
+    ('Bumphead parrotfish', 'Bolbometopon muricatum', 'fish'),
+    ('Goliath grouper', 'Epinephelus itajara', 'fish'),
+    ('Potato grouper', 'Epinephelus tukula', 'fish'),
+    ('Clark''s anemonefish', 'Amphiprion clarkii', 'fish'),
+    ('Ocellaris clownfish', 'Amphiprion ocellaris', 'fish'),
+    ('Red Sea anemonefish', 'Amphiprion bicinctus', 'fish'),
+    ('Red lionfish', 'Pterois volitans', 'fish'),
+    ('Stonefish', 'Synanceia verrucosa', 'fish'),
+    ('Frogfish', 'Antennarius commerson', 'fish'),
+    ('Leafy seadragon', 'Phycodurus eques', 'fish'),
+    ('Pygmy seahorse', 'Hippocampus bargibanti', 'fish'),
+    ('Yellow boxfish', 'Ostracion cubicus', 'fish'),
+    ('Titan triggerfish', 'Balistoides viridescens', 'fish'),
+    ('Moorish idol', 'Zanclus cornutus', 'fish'),
+    ('Garibaldi', 'Hypsypops rubicundus', 'fish'),
+    ('Giant moray', 'Gymnothorax javanicus', 'fish'),
+    ('Green moray', 'Gymnothorax funebris', 'fish'),
+    ('Spotted garden eel', 'Heteroconger hassi', 'fish'),
+    ('Mandarinfish', 'Synchiropus splendidus', 'fish'),
+    ('Common octopus', 'Octopus vulgaris', 'cephalopod'),
+    ('Blue-ringed octopus', 'Hapalochlaena lunulata', 'cephalopod'),
+    ('Mimic octopus', 'Thaumoctopus mimicus', 'cephalopod'),
+    ('Giant cuttlefish', 'Sepia apama', 'cephalopod'),
+    ('Flamboyant cuttlefish', 'Ascarosepion pfefferi', 'cephalopod'),
+    ('Bigfin reef squid', 'Sepioteuthis lessoniana', 'cephalopod'),
+    ('Peacock mantis shrimp', 'Odontodactylus scyllarus', 'crustacean'),
+    ('Caribbean spiny lobster', 'Panulirus argus', 'crustacean'),
+    ('Harlequin shrimp', 'Hymenocera picta', 'crustacean'),
+    ('Spanish dancer', 'Hexabranchus sanguineus', 'mollusc'),
+    ('Giant clam', 'Tridacna gigas', 'mollusc'),
+    ('Crown-of-thorns starfish', 'Acanthaster planci', 'echinoderm'),
+    ('Long-spined sea urchin', 'Diadema setosum', 'echinoderm'),
+    ('Moon jellyfish', 'Aurelia aurita', 'cnidarian'),
+    ('Elkhorn coral', 'Acropora palmata', 'cnidarian'),
+    ('Magnificent sea anemone', 'Heteractis magnifica', 'cnidarian')
+ON CONFLICT DO NOTHING;
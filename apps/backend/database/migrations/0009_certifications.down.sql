@@ -0,0 +1,2 @@
+ALTER TABLE dives DROP COLUMN IF EXISTS certification_id;
+DROP TABLE IF EXISTS certifications;
@@ -0,0 +1,23 @@
+-- Diver certifications and courses in progress. A certification without a
+-- certified_on date is a course the diver is still working towards; training
+-- dives link to it through dives.certification_id.
+CREATE TABLE IF NOT EXISTS certifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    agency VARCHAR(50) NOT NULL,
+    level VARCHAR(100) NOT NULL,
+    certification_number VARCHAR(100),
+    certified_on DATE,
+    instructor VARCHAR(200),
+    notes TEXT,
+    prerequisites JSONB NOT NULL DEFAULT '[]',
+    card_image_key VARCHAR(255),
+    card_image_type VARCHAR(50),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_certifications_user_level
+    ON certifications (user_id, lower(agency), lower(level));
+
+ALTER TABLE dives ADD COLUMN IF NOT EXISTS certification_id INTEGER REFERENCES certifications(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_dives_certification_id ON dives (certification_id);
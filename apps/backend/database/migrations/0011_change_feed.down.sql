@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS dive_sites_change_feed ON dive_sites;
+DROP TRIGGER IF EXISTS user_settings_change_feed ON user_settings;
+DROP TRIGGER IF EXISTS tags_change_feed ON tags;
+DROP TRIGGER IF EXISTS trips_change_feed ON trips;
+DROP TRIGGER IF EXISTS dives_change_feed ON dives;
+DROP FUNCTION IF EXISTS dive_sites_record_change();
+DROP FUNCTION IF EXISTS dives_record_change();
+DROP FUNCTION IF EXISTS owned_rows_record_change();
+DROP FUNCTION IF EXISTS record_change(INTEGER, TEXT, INTEGER, TEXT);
+DROP TABLE IF EXISTS change_log;
+DROP TABLE IF EXISTS change_sequences;
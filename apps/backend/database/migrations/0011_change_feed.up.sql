@@ -0,0 +1,119 @@
+-- Per-user change feed for offline sync. Every write to a user's dives,
+-- trips, tags, settings or the dive sites their dives use takes the next
+-- number from change_sequences and records it in change_log. The log keeps
+-- only the latest entry per entity, so a client that is behind receives each
+-- changed entity once and deletes remain as tombstones. Taking the number
+-- locks the user's change_sequences row until commit, so entries commit in
+-- sequence order and a cursor never skips a change that commits late.
+
+CREATE TABLE IF NOT EXISTS change_sequences (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    last_seq BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS change_log (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL,
+    entity_type VARCHAR(20) NOT NULL CHECK (entity_type IN ('dive', 'trip', 'tag', 'dive_site', 'settings')),
+    entity_id INTEGER NOT NULL,
+    operation VARCHAR(10) NOT NULL CHECK (operation IN ('upsert', 'delete')),
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, seq)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_change_log_entity ON change_log (user_id, entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_change_log_dive_sites ON change_log (entity_id) WHERE entity_type = 'dive_site';
+
+CREATE OR REPLACE FUNCTION record_change(change_user_id INTEGER, change_entity TEXT, change_entity_id INTEGER, change_operation TEXT)
+RETURNS void LANGUAGE plpgsql AS $$
+DECLARE
+    next_seq BIGINT;
+BEGIN
+    -- Rows removed while their user is being deleted have no feed to join.
+    IF change_user_id IS NULL OR NOT EXISTS (SELECT 1 FROM users WHERE id = change_user_id) THEN
+        RETURN;
+    END IF;
+    INSERT INTO change_sequences (user_id, last_seq) VALUES (change_user_id, 1)
+    ON CONFLICT (user_id) DO UPDATE SET last_seq = change_sequences.last_seq + 1
+    RETURNING last_seq INTO next_seq;
+
+    INSERT INTO change_log (user_id, seq, entity_type, entity_id, operation)
+    VALUES (change_user_id, next_seq, change_entity, change_entity_id, change_operation)
+    ON CONFLICT (user_id, entity_type, entity_id) DO UPDATE
+    SET seq = EXCLUDED.seq, operation = EXCLUDED.operation, changed_at = EXCLUDED.changed_at;
+END $$;
+
+-- Trips, tags and settings: the entity type is the trigger argument.
+CREATE OR REPLACE FUNCTION owned_rows_record_change() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM record_change(OLD.user_id, TG_ARGV[0], OLD.id, 'delete');
+    ELSE
+        PERFORM record_change(NEW.user_id, TG_ARGV[0], NEW.id, 'upsert');
+    END IF;
+    RETURN NULL;
+END $$;
+
+-- A dive that moves to a site the client may not know yet also sends the site,
+-- ahead of the dive so it can be applied in order.
+CREATE OR REPLACE FUNCTION dives_record_change() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM record_change(OLD.user_id, 'dive', OLD.id, 'delete');
+        RETURN NULL;
+    END IF;
+    IF NEW.dive_site_id IS NOT NULL AND (TG_OP = 'INSERT' OR OLD.dive_site_id IS DISTINCT FROM NEW.dive_site_id) THEN
+        PERFORM record_change(NEW.user_id, 'dive_site', NEW.dive_site_id, 'upsert');
+    END IF;
+    PERFORM record_change(NEW.user_id, 'dive', NEW.id, 'upsert');
+    RETURN NULL;
+END $$;
+
+-- Dive sites are shared, so a change is recorded for every user who dived
+-- there or was already sent the site.
+CREATE OR REPLACE FUNCTION dive_sites_record_change() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+    site_user_id INTEGER;
+BEGIN
+    FOR site_user_id IN
+        SELECT user_id FROM dives WHERE dive_site_id = OLD.id AND user_id IS NOT NULL
+        UNION
+        SELECT user_id FROM change_log WHERE entity_type = 'dive_site' AND entity_id = OLD.id
+        ORDER BY 1
+    LOOP
+        PERFORM record_change(site_user_id, 'dive_site', OLD.id,
+            CASE WHEN TG_OP = 'DELETE' THEN 'delete' ELSE 'upsert' END);
+    END LOOP;
+    RETURN NULL;
+END $$;
+
+DROP TRIGGER IF EXISTS dives_change_feed ON dives;
+CREATE TRIGGER dives_change_feed AFTER INSERT OR UPDATE OR DELETE ON dives
+    FOR EACH ROW EXECUTE FUNCTION dives_record_change();
+
+DROP TRIGGER IF EXISTS trips_change_feed ON trips;
+CREATE TRIGGER trips_change_feed AFTER INSERT OR UPDATE OR DELETE ON trips
+    FOR EACH ROW EXECUTE FUNCTION owned_rows_record_change('trip');
+
+DROP TRIGGER IF EXISTS tags_change_feed ON tags;
+CREATE TRIGGER tags_change_feed AFTER INSERT OR UPDATE OR DELETE ON tags
+    FOR EACH ROW EXECUTE FUNCTION owned_rows_record_change('tag');
+
+DROP TRIGGER IF EXISTS user_settings_change_feed ON user_settings;
+CREATE TRIGGER user_settings_change_feed AFTER INSERT OR UPDATE OR DELETE ON user_settings
+    FOR EACH ROW EXECUTE FUNCTION owned_rows_record_change('settings');
+
+DROP TRIGGER IF EXISTS dive_sites_change_feed ON dive_sites;
+CREATE TRIGGER dive_sites_change_feed AFTER UPDATE OR DELETE ON dive_sites
+    FOR EACH ROW EXECUTE FUNCTION dive_sites_record_change();
+
+-- Seed the feed with existing data so a first sync from cursor 0 is complete.
+SELECT record_change(user_id, 'settings', id, 'upsert') FROM user_settings ORDER BY id;
+SELECT record_change(user_id, 'dive_site', dive_site_id, 'upsert')
+FROM (SELECT DISTINCT user_id, dive_site_id FROM dives WHERE dive_site_id IS NOT NULL) used_sites
+ORDER BY user_id, dive_site_id;
+SELECT record_change(user_id, 'trip', id, 'upsert') FROM trips ORDER BY id;
+SELECT record_change(user_id, 'tag', id, 'upsert') FROM tags ORDER BY id;
+SELECT record_change(user_id, 'dive', id, 'upsert') FROM dives ORDER BY id;
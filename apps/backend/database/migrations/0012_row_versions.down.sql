@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS user_settings_row_version ON user_settings;
+DROP TRIGGER IF EXISTS trips_row_version ON trips;
+DROP TRIGGER IF EXISTS dives_row_version ON dives;
+DROP FUNCTION IF EXISTS bump_row_version();
+ALTER TABLE user_settings DROP COLUMN IF EXISTS version;
+ALTER TABLE trips DROP COLUMN IF EXISTS version;
+ALTER TABLE dives DROP COLUMN IF EXISTS version;
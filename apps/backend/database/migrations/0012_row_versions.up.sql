@@ -0,0 +1,27 @@
+-- Row versions back the ETag and If-Match preconditions on dives, trips and
+-- settings. Every UPDATE bumps the version. That includes the search-vector
+-- touches from tag, trip and dive site changes, because those change how a
+-- dive is presented as well.
+
+ALTER TABLE dives ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE trips ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_row_version() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END $$;
+
+DROP TRIGGER IF EXISTS dives_row_version ON dives;
+CREATE TRIGGER dives_row_version BEFORE UPDATE ON dives
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
+
+DROP TRIGGER IF EXISTS trips_row_version ON trips;
+CREATE TRIGGER trips_row_version BEFORE UPDATE ON trips
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
+
+DROP TRIGGER IF EXISTS user_settings_row_version ON user_settings;
+CREATE TRIGGER user_settings_row_version BEFORE UPDATE ON user_settings
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
@@ -0,0 +1,15 @@
+-- Personal access tokens for scripts and integrations. Only the SHA-256 of a
+-- token is stored; token_prefix is its first characters, kept so users can
+-- tell their tokens apart.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_prefix VARCHAR(16) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens (user_id);
@@ -0,0 +1,12 @@
+DROP TRIGGER IF EXISTS dive_tags_audit ON dive_tags;
+DROP TRIGGER IF EXISTS dive_sites_audit ON dive_sites;
+DROP TRIGGER IF EXISTS user_settings_audit ON user_settings;
+DROP TRIGGER IF EXISTS tags_audit ON tags;
+DROP TRIGGER IF EXISTS trips_audit ON trips;
+DROP TRIGGER IF EXISTS dives_audit ON dives;
+DROP FUNCTION IF EXISTS dive_tags_audit();
+DROP FUNCTION IF EXISTS audit_row_change();
+DROP FUNCTION IF EXISTS record_audit(INTEGER, TEXT, INTEGER, TEXT, JSONB);
+DROP FUNCTION IF EXISTS audit_diff(JSONB, JSONB);
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
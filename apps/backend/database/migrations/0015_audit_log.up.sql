@@ -0,0 +1,140 @@
+-- Append-only audit log of every write to dives, trips, tags, settings and
+-- dive sites. Triggers write the entries, so they commit or roll back with the
+-- change itself and no code path can skip them. The application tags each
+-- write transaction with set_config('divelog.actor_user_id' / 'actor_token_id'
+-- / 'request_id', ..., true); changes made outside the API, such as by
+-- migrations or the geocoding command, have no actor.
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    -- Owner of the changed row; NULL for the shared dive sites. Neither user
+    -- column references users, so entries outlive the accounts they name.
+    user_id INTEGER,
+    actor_user_id INTEGER,
+    actor_token_id INTEGER,
+    request_id VARCHAR(64),
+    entity_type VARCHAR(20) NOT NULL CHECK (entity_type IN ('dive', 'trip', 'tag', 'dive_site', 'settings')),
+    entity_id INTEGER NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    -- {"column": {"before": ..., "after": ...}} for every column that changed
+    changes JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (user_id, entity_type, entity_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor_user_id, created_at) WHERE user_id IS NULL;
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END $$;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- audit_diff lists the columns whose values differ between two row images.
+-- Columns the database derives or bumps on every write are left out.
+CREATE OR REPLACE FUNCTION audit_diff(old_row JSONB, new_row JSONB) RETURNS JSONB
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT COALESCE(jsonb_object_agg(key, jsonb_build_object('before', old_row -> key, 'after', new_row -> key)), '{}'::jsonb)
+    FROM jsonb_object_keys(COALESCE(old_row, '{}'::jsonb) || COALESCE(new_row, '{}'::jsonb)) AS key
+    WHERE key NOT IN ('updated_at', 'version', 'search_vector', 'geohash')
+      AND COALESCE(old_row -> key, 'null'::jsonb) IS DISTINCT FROM COALESCE(new_row -> key, 'null'::jsonb)
+$$;
+
+CREATE OR REPLACE FUNCTION record_audit(owner_id INTEGER, audit_entity TEXT, audit_entity_id INTEGER, audit_action TEXT, audit_changes JSONB)
+RETURNS void LANGUAGE plpgsql AS $$
+BEGIN
+    INSERT INTO audit_log (user_id, actor_user_id, actor_token_id, request_id, entity_type, entity_id, action, changes)
+    VALUES (owner_id,
+            NULLIF(current_setting('divelog.actor_user_id', true), '')::int,
+            NULLIF(current_setting('divelog.actor_token_id', true), '')::int,
+            NULLIF(current_setting('divelog.request_id', true), ''),
+            audit_entity, audit_entity_id, audit_action, audit_changes);
+END $$;
+
+-- Dives, trips, tags, settings and dive sites: the entity type is the trigger
+-- argument. Updates that only touch derived columns, such as the search
+-- vector refreshed when a tag is renamed, are not recorded.
+CREATE OR REPLACE FUNCTION audit_row_change() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+    old_row JSONB;
+    new_row JSONB;
+    changes JSONB;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_row := to_jsonb(OLD);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_row := to_jsonb(NEW);
+    END IF;
+    changes := audit_diff(old_row, new_row);
+    IF changes = '{}'::jsonb THEN
+        RETURN NULL;
+    END IF;
+    PERFORM record_audit(
+        (COALESCE(new_row, old_row) ->> 'user_id')::int,
+        TG_ARGV[0],
+        (COALESCE(new_row, old_row) ->> 'id')::int,
+        CASE TG_OP WHEN 'INSERT' THEN 'create' WHEN 'UPDATE' THEN 'update' ELSE 'delete' END,
+        changes);
+    RETURN NULL;
+END $$;
+
+-- Tagging or untagging a dive is recorded as an update of the dive. Links
+-- removed along with their dive are covered by the dive's own entry.
+CREATE OR REPLACE FUNCTION dive_tags_audit() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+    link_dive_id INTEGER;
+    link_tag_id INTEGER;
+    owner_id INTEGER;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        link_dive_id := OLD.dive_id;
+        link_tag_id := OLD.tag_id;
+    ELSE
+        link_dive_id := NEW.dive_id;
+        link_tag_id := NEW.tag_id;
+    END IF;
+    SELECT user_id INTO owner_id FROM dives WHERE id = link_dive_id;
+    IF NOT FOUND THEN
+        RETURN NULL;
+    END IF;
+    PERFORM record_audit(owner_id, 'dive', link_dive_id, 'update',
+        jsonb_build_object('tag_id', jsonb_build_object(
+            'before', CASE WHEN TG_OP = 'DELETE' THEN to_jsonb(link_tag_id) END,
+            'after', CASE WHEN TG_OP = 'INSERT' THEN to_jsonb(link_tag_id) END)));
+    RETURN NULL;
+END $$;
+
+DROP TRIGGER IF EXISTS dives_audit ON dives;
+CREATE TRIGGER dives_audit AFTER INSERT OR UPDATE OR DELETE ON dives
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('dive');
+
+DROP TRIGGER IF EXISTS trips_audit ON trips;
+CREATE TRIGGER trips_audit AFTER INSERT OR UPDATE OR DELETE ON trips
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('trip');
+
+DROP TRIGGER IF EXISTS tags_audit ON tags;
+CREATE TRIGGER tags_audit AFTER INSERT OR UPDATE OR DELETE ON tags
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('tag');
+
+DROP TRIGGER IF EXISTS user_settings_audit ON user_settings;
+CREATE TRIGGER user_settings_audit AFTER INSERT OR UPDATE OR DELETE ON user_settings
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('settings');
+
+DROP TRIGGER IF EXISTS dive_sites_audit ON dive_sites;
+CREATE TRIGGER dive_sites_audit AFTER INSERT OR UPDATE OR DELETE ON dive_sites
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('dive_site');
+
+DROP TRIGGER IF EXISTS dive_tags_audit ON dive_tags;
+CREATE TRIGGER dive_tags_audit AFTER INSERT OR DELETE ON dive_tags
+    FOR EACH ROW EXECUTE FUNCTION dive_tags_audit();
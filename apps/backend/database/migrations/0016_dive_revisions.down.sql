@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS dive_revision_snapshot(dives);
+DROP TABLE IF EXISTS dive_revisions;
@@ -0,0 +1,29 @@
+-- Revision history of individual dives. Each revision is the dive as it was
+-- just before an edit, written by the application in the edit's own
+-- transaction, so the history together with the current row holds every
+-- version of the dive. Revisions are numbered from 1 per dive.
+
+CREATE TABLE IF NOT EXISTS dive_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    dive_id INTEGER NOT NULL REFERENCES dives(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    -- The edit that replaced this version of the dive
+    source VARCHAR(20) NOT NULL CHECK (source IN ('update', 'bulk_update', 'time_shift', 'undo', 'restore')),
+    dive_version INTEGER NOT NULL,
+    -- The dive row without its search vector, plus "tags": its tag names
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (dive_id, revision)
+);
+CREATE INDEX IF NOT EXISTS idx_dive_revisions_user ON dive_revisions (user_id, dive_id);
+
+-- dive_revision_snapshot is the image of a dive that revisions store and
+-- compare with audit_diff.
+CREATE OR REPLACE FUNCTION dive_revision_snapshot(dive dives) RETURNS JSONB
+LANGUAGE sql STABLE AS $$
+    SELECT (to_jsonb(dive) - 'search_vector') || jsonb_build_object('tags', COALESCE(
+        (SELECT jsonb_agg(t.name ORDER BY lower(t.name))
+         FROM dive_tags dt JOIN tags t ON t.id = dt.tag_id
+         WHERE dt.dive_id = dive.id), '[]'::jsonb))
+$$;
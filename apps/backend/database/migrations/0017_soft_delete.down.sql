@@ -0,0 +1,55 @@
+-- Trashed rows are purged first: without deleted_at they would come back.
+DELETE FROM dives WHERE deleted_at IS NOT NULL;
+DELETE FROM trips WHERE deleted_at IS NOT NULL;
+DELETE FROM tags WHERE deleted_at IS NOT NULL;
+DELETE FROM dive_sites WHERE deleted_at IS NOT NULL;
+
+DROP TRIGGER IF EXISTS dive_sites_search_vector ON dive_sites;
+CREATE TRIGGER dive_sites_search_vector AFTER UPDATE OF name, description ON dive_sites
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name OR OLD.description IS DISTINCT FROM NEW.description)
+    EXECUTE FUNCTION dive_sites_touch_dives();
+
+DROP TRIGGER IF EXISTS trips_search_vector ON trips;
+CREATE TRIGGER trips_search_vector AFTER UPDATE OF name ON trips
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name) EXECUTE FUNCTION trips_touch_dives();
+
+DROP TRIGGER IF EXISTS tags_search_vector ON tags;
+CREATE TRIGGER tags_search_vector AFTER UPDATE OF name ON tags
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name) EXECUTE FUNCTION tags_touch_dives();
+
+CREATE OR REPLACE FUNCTION dive_revision_snapshot(dive dives) RETURNS JSONB
+LANGUAGE sql STABLE AS $$
+    SELECT (to_jsonb(dive) - 'search_vector') || jsonb_build_object('tags', COALESCE(
+        (SELECT jsonb_agg(t.name ORDER BY lower(t.name))
+         FROM dive_tags dt JOIN tags t ON t.id = dt.tag_id
+         WHERE dt.dive_id = dive.id), '[]'::jsonb))
+$$;
+
+CREATE OR REPLACE FUNCTION dive_search_document(dive dives) RETURNS tsvector
+LANGUAGE sql STABLE AS $$
+    SELECT setweight(to_tsvector('english', coalesce(dive.location, '')), 'A')
+        || setweight(to_tsvector('english', coalesce(dive.buddy, '')), 'B')
+        || setweight(to_tsvector('english', coalesce((
+               SELECT string_agg(t.name, ' ') FROM dive_tags dt JOIN tags t ON t.id = dt.tag_id
+               WHERE dt.dive_id = dive.id), '')), 'B')
+        || setweight(to_tsvector('english', coalesce((SELECT tr.name FROM trips tr WHERE tr.id = dive.trip_id), '')), 'B')
+        || setweight(to_tsvector('english', coalesce(dive.notes, '')), 'C')
+        || setweight(to_tsvector('english', coalesce((
+               SELECT concat_ws(' ', ds.name, ds.description) FROM dive_sites ds WHERE ds.id = dive.dive_site_id), '')), 'D')
+$$;
+
+DROP INDEX IF EXISTS idx_tags_user_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_user_name ON tags (user_id, lower(name));
+DROP INDEX IF EXISTS idx_trips_user_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_trips_user_name ON trips (user_id, lower(name));
+
+DROP INDEX IF EXISTS idx_dive_sites_deleted_at;
+DROP INDEX IF EXISTS idx_tags_deleted_at;
+DROP INDEX IF EXISTS idx_trips_deleted_at;
+DROP INDEX IF EXISTS idx_dives_deleted_at;
+
+ALTER TABLE dive_sites DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE dive_sites DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE tags DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE trips DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE dives DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,68 @@
+-- Soft deletion for dives, trips, tags and dive sites. Deleting one sets
+-- deleted_at and moves it to the trash, from which it can be restored until it
+-- is purged by hand or by the retention job. Links to a trashed row are kept so
+-- a restore brings them back: a trashed trip or tag still holds its dives, and
+-- every read treats it as gone. Dive sites are shared, so deleted_by records
+-- whose trash a site went to.
+
+ALTER TABLE dives ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE trips ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE dive_sites ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE dive_sites ADD COLUMN IF NOT EXISTS deleted_by INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_dives_deleted_at ON dives (user_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_trips_deleted_at ON trips (user_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tags_deleted_at ON tags (user_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_dive_sites_deleted_at ON dive_sites (deleted_by, deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Names only need to be unique among live trips and tags, so a name can be
+-- reused while the old trip or tag waits in the trash.
+DROP INDEX IF EXISTS idx_trips_user_name;
+CREATE UNIQUE INDEX idx_trips_user_name ON trips (user_id, lower(name)) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_tags_user_name;
+CREATE UNIQUE INDEX idx_tags_user_name ON tags (user_id, lower(name)) WHERE deleted_at IS NULL;
+
+-- A dive's search document and revision snapshots leave out trashed tags,
+-- trips and sites.
+CREATE OR REPLACE FUNCTION dive_search_document(dive dives) RETURNS tsvector
+LANGUAGE sql STABLE AS $$
+    SELECT setweight(to_tsvector('english', coalesce(dive.location, '')), 'A')
+        || setweight(to_tsvector('english', coalesce(dive.buddy, '')), 'B')
+        || setweight(to_tsvector('english', coalesce((
+               SELECT string_agg(t.name, ' ') FROM dive_tags dt JOIN tags t ON t.id = dt.tag_id
+               WHERE dt.dive_id = dive.id AND t.deleted_at IS NULL), '')), 'B')
+        || setweight(to_tsvector('english', coalesce((
+               SELECT tr.name FROM trips tr WHERE tr.id = dive.trip_id AND tr.deleted_at IS NULL), '')), 'B')
+        || setweight(to_tsvector('english', coalesce(dive.notes, '')), 'C')
+        || setweight(to_tsvector('english', coalesce((
+               SELECT concat_ws(' ', ds.name, ds.description) FROM dive_sites ds
+               WHERE ds.id = dive.dive_site_id AND ds.deleted_at IS NULL), '')), 'D')
+$$;
+
+CREATE OR REPLACE FUNCTION dive_revision_snapshot(dive dives) RETURNS JSONB
+LANGUAGE sql STABLE AS $$
+    SELECT (to_jsonb(dive) - 'search_vector' - 'deleted_at') || jsonb_build_object('tags', COALESCE(
+        (SELECT jsonb_agg(t.name ORDER BY lower(t.name))
+         FROM dive_tags dt JOIN tags t ON t.id = dt.tag_id
+         WHERE dt.dive_id = dive.id AND t.deleted_at IS NULL), '[]'::jsonb))
+$$;
+
+-- Trashing or restoring a tag, trip or site changes how its dives are
+-- presented, so the dives are touched like on a rename. That also sends them
+-- through the change feed again.
+DROP TRIGGER IF EXISTS tags_search_vector ON tags;
+CREATE TRIGGER tags_search_vector AFTER UPDATE OF name, deleted_at ON tags
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name OR OLD.deleted_at IS DISTINCT FROM NEW.deleted_at)
+    EXECUTE FUNCTION tags_touch_dives();
+
+DROP TRIGGER IF EXISTS trips_search_vector ON trips;
+CREATE TRIGGER trips_search_vector AFTER UPDATE OF name, deleted_at ON trips
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name OR OLD.deleted_at IS DISTINCT FROM NEW.deleted_at)
+    EXECUTE FUNCTION trips_touch_dives();
+
+DROP TRIGGER IF EXISTS dive_sites_search_vector ON dive_sites;
+CREATE TRIGGER dive_sites_search_vector AFTER UPDATE OF name, description, deleted_at ON dive_sites
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name OR OLD.description IS DISTINCT FROM NEW.description
+                       OR OLD.deleted_at IS DISTINCT FROM NEW.deleted_at)
+    EXECUTE FUNCTION dive_sites_touch_dives();
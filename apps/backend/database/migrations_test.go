@@ -0,0 +1,53 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrationsOrdersByVersionAndPairsDownScripts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0010_later.up.sql":    {Data: []byte("SELECT 10;")},
+		"migrations/0002_second.up.sql":   {Data: []byte("SELECT 2;")},
+		"migrations/0002_second.down.sql": {Data: []byte("SELECT -2;")},
+		"migrations/0001_first.up.sql":    {Data: []byte("SELECT 1;")},
+	}
+
+	migrations, err := LoadMigrations(fsys, "migrations")
+
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+	assert.Equal(t, []int{1, 2, 10}, []int{migrations[0].Version, migrations[1].Version, migrations[2].Version})
+	assert.Equal(t, "second", migrations[1].Name)
+	assert.Equal(t, "SELECT -2;", migrations[1].Down)
+	assert.Empty(t, migrations[0].Down)
+}
+
+func TestLoadMigrationsRejectsInvalidSets(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad name":          {"migrations/first.sql": {Data: []byte("SELECT 1;")}},
+		"duplicate version": {"migrations/0001_a.up.sql": {Data: []byte("SELECT 1;")}, "migrations/0001_b.up.sql": {Data: []byte("SELECT 1;")}},
+		"missing up":        {"migrations/0001_a.down.sql": {Data: []byte("SELECT 1;")}},
+		"zero version":      {"migrations/0000_a.up.sql": {Data: []byte("SELECT 1;")}},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadMigrations(fsys, "migrations")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEmbeddedMigrationsAreContiguousAndReversible(t *testing.T) {
+	migrations, err := LoadMigrations(embeddedMigrations, "migrations")
+
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, migration := range migrations {
+		assert.Equal(t, i+1, migration.Version, "migration versions must not skip numbers")
+		assert.NotEmpty(t, migration.Down, "migration %04d_%s needs a down script", migration.Version, migration.Name)
+	}
+}
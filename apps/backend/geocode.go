@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"divelog-backend/geocoding"
+	"divelog-backend/repository"
+	"divelog-backend/services"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// runGeocodeCommand implements `geocode-sites [--overwrite]`, which fills in
+// the country and region of existing dive sites from the bundled boundaries.
+func runGeocodeCommand(ctx context.Context, db *sql.DB, geocoder *geocoding.Geocoder, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("geocode-sites", flag.ContinueOnError)
+	flags.SetOutput(out)
+	overwrite := flags.Bool("overwrite", false, "replace existing country and region values")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if geocoder.Len() == 0 {
+		return errors.New("no boundary data is bundled; see geocoding/data/README.md")
+	}
+
+	service := services.NewDiveSiteService(repository.NewDiveSiteRepository(db), repository.NewSQLTransactor(db), geocoder)
+	result, err := service.BackfillLocations(ctx, *overwrite)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "scanned %d dive sites: %d updated, %d unmatched\n", result.Scanned, result.Updated, result.Unmatched)
+	return nil
+}
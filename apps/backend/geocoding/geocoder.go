@@ -0,0 +1,300 @@
+// Package geocoding resolves coordinates to a country and first-level region
+// using boundary polygons bundled with the binary, without calling any
+// outside service.
+package geocoding
+
+import (
+	"divelog-backend/models"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed data
+var embeddedData embed.FS
+
+const (
+	// DefaultMaxOffshoreKM is how far outside every boundary a point may lie
+	// and still be assigned to the nearest one. Dive sites are usually in the
+	// water, beyond the coastline that land boundaries follow.
+	DefaultMaxOffshoreKM = 30
+	kilometersPerDegree  = 111.32
+	cellSizeDegrees      = 1.0
+)
+
+type point struct{ lng, lat float64 }
+
+// ring is a closed loop of points; polygon is an outer ring followed by holes.
+type ring []point
+type polygon []ring
+
+type bounds struct{ minLng, minLat, maxLng, maxLat float64 }
+
+func (b bounds) contains(p point) bool {
+	return p.lng >= b.minLng && p.lng <= b.maxLng && p.lat >= b.minLat && p.lat <= b.maxLat
+}
+
+type boundary struct {
+	place    models.Place
+	polygons []polygon
+	bounds   bounds
+}
+
+type cell struct{ lng, lat int }
+
+// Geocoder answers point lookups against a set of boundaries. It is safe for
+// concurrent use once loaded.
+type Geocoder struct {
+	boundaries    []boundary
+	cells         map[cell][]int
+	maxOffshoreKM float64
+}
+
+// LoadEmbedded returns a Geocoder for the boundary files bundled in data/.
+func LoadEmbedded() (*Geocoder, error) {
+	return Load(embeddedData, "data")
+}
+
+// Load reads every *.geojson file in dir. Features must be Polygon or
+// MultiPolygon geometries with country_code, country and optional region
+// properties.
+func Load(fsys fs.FS, dir string) (*Geocoder, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read boundary data: %w", err)
+	}
+	geocoder := &Geocoder{cells: map[cell][]int{}, maxOffshoreKM: DefaultMaxOffshoreKM}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".geojson") {
+			continue
+		}
+		contents, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read boundary file %q: %w", entry.Name(), err)
+		}
+		if err := geocoder.add(contents); err != nil {
+			return nil, fmt.Errorf("parse boundary file %q: %w", entry.Name(), err)
+		}
+	}
+	return geocoder, nil
+}
+
+// Len reports how many boundaries are loaded.
+func (g *Geocoder) Len() int {
+	return len(g.boundaries)
+}
+
+type boundaryCollection struct {
+	Features []struct {
+		Properties struct {
+			CountryCode string `json:"country_code"`
+			Country     string `json:"country"`
+			Region      string `json:"region"`
+		} `json:"properties"`
+		Geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+func (g *Geocoder) add(contents []byte) error {
+	var collection boundaryCollection
+	if err := json.Unmarshal(contents, &collection); err != nil {
+		return err
+	}
+	for i, feature := range collection.Features {
+		var coordinates [][][][2]float64
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var single [][][2]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &single); err != nil {
+				return fmt.Errorf("feature %d: %w", i, err)
+			}
+			coordinates = [][][][2]float64{single}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &coordinates); err != nil {
+				return fmt.Errorf("feature %d: %w", i, err)
+			}
+		default:
+			return fmt.Errorf("feature %d: unsupported geometry %q", i, feature.Geometry.Type)
+		}
+		properties := feature.Properties
+		if len(properties.CountryCode) != 2 || properties.Country == "" {
+			return fmt.Errorf("feature %d: country_code and country are required", i)
+		}
+
+		b := boundary{
+			place: models.Place{
+				CountryCode: strings.ToUpper(properties.CountryCode),
+				Country:     properties.Country,
+				Region:      properties.Region,
+			},
+			bounds: bounds{minLng: math.Inf(1), minLat: math.Inf(1), maxLng: math.Inf(-1), maxLat: math.Inf(-1)},
+		}
+		for _, rawPolygon := range coordinates {
+			var poly polygon
+			for _, rawRing := range rawPolygon {
+				r := make(ring, len(rawRing))
+				for j, coordinate := range rawRing {
+					r[j] = point{lng: coordinate[0], lat: coordinate[1]}
+					b.bounds.minLng = math.Min(b.bounds.minLng, coordinate[0])
+					b.bounds.maxLng = math.Max(b.bounds.maxLng, coordinate[0])
+					b.bounds.minLat = math.Min(b.bounds.minLat, coordinate[1])
+					b.bounds.maxLat = math.Max(b.bounds.maxLat, coordinate[1])
+				}
+				if len(r) >= 4 {
+					poly = append(poly, r)
+				}
+			}
+			if len(poly) > 0 {
+				b.polygons = append(b.polygons, poly)
+			}
+		}
+		if len(b.polygons) == 0 {
+			continue
+		}
+
+		index := len(g.boundaries)
+		g.boundaries = append(g.boundaries, b)
+		for lng := cellIndex(b.bounds.minLng); lng <= cellIndex(b.bounds.maxLng); lng++ {
+			for lat := cellIndex(b.bounds.minLat); lat <= cellIndex(b.bounds.maxLat); lat++ {
+				key := cell{lng: lng, lat: lat}
+				g.cells[key] = append(g.cells[key], index)
+			}
+		}
+	}
+	return nil
+}
+
+func cellIndex(degrees float64) int {
+	return int(math.Floor(degrees / cellSizeDegrees))
+}
+
+// Lookup returns the boundary containing the point or, for points offshore,
+// the nearest boundary within DefaultMaxOffshoreKM.
+func (g *Geocoder) Lookup(latitude, longitude float64) (models.Place, bool) {
+	p := point{lng: longitude, lat: latitude}
+	for _, index := range g.cells[cell{lng: cellIndex(longitude), lat: cellIndex(latitude)}] {
+		b := &g.boundaries[index]
+		if b.bounds.contains(p) && b.contains(p) {
+			return b.place, true
+		}
+	}
+
+	nearest, nearestKM := -1, g.maxOffshoreKM
+	for _, index := range g.candidatesNear(p, g.maxOffshoreKM) {
+		if distance := g.boundaries[index].distanceKM(p); distance <= nearestKM {
+			nearest, nearestKM = index, distance
+		}
+	}
+	if nearest < 0 {
+		return models.Place{}, false
+	}
+	return g.boundaries[nearest].place, true
+}
+
+// candidatesNear returns the boundaries indexed in cells within radiusKM of p,
+// in index order so ties resolve deterministically.
+func (g *Geocoder) candidatesNear(p point, radiusKM float64) []int {
+	deltaLat := radiusKM / kilometersPerDegree
+	deltaLng := 180.0
+	if cosine := math.Cos(p.lat * math.Pi / 180); cosine > 0.01 {
+		deltaLng = math.Min(180, radiusKM/(kilometersPerDegree*cosine))
+	}
+	seen := map[int]bool{}
+	var candidates []int
+	for lng := cellIndex(p.lng - deltaLng); lng <= cellIndex(p.lng+deltaLng); lng++ {
+		for lat := cellIndex(p.lat - deltaLat); lat <= cellIndex(p.lat+deltaLat); lat++ {
+			for _, index := range g.cells[cell{lng: wrapCell(lng), lat: lat}] {
+				if !seen[index] {
+					seen[index] = true
+					candidates = append(candidates, index)
+				}
+			}
+		}
+	}
+	sort.Ints(candidates)
+	return candidates
+}
+
+// wrapCell maps longitude cells past ±180° back into range.
+func wrapCell(lng int) int {
+	cells := int(360 / cellSizeDegrees)
+	first := cellIndex(-180)
+	return ((lng-first)%cells+cells)%cells + first
+}
+
+func (b *boundary) contains(p point) bool {
+	for _, poly := range b.polygons {
+		if !poly[0].contains(p) {
+			continue
+		}
+		inHole := false
+		for _, hole := range poly[1:] {
+			if hole.contains(p) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// contains uses the even-odd ray casting rule.
+func (r ring) contains(p point) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[i], r[j]
+		if (a.lat > p.lat) != (b.lat > p.lat) &&
+			p.lng < (b.lng-a.lng)*(p.lat-a.lat)/(b.lat-a.lat)+a.lng {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// distanceKM approximates the distance from p to the nearest boundary edge
+// with an equirectangular projection centred on p, which is accurate to well
+// under a percent at offshore distances.
+func (b *boundary) distanceKM(p point) float64 {
+	scale := math.Cos(p.lat * math.Pi / 180)
+	project := func(q point) (float64, float64) {
+		deltaLng := q.lng - p.lng
+		if deltaLng > 180 {
+			deltaLng -= 360
+		} else if deltaLng < -180 {
+			deltaLng += 360
+		}
+		return deltaLng * scale * kilometersPerDegree, (q.lat - p.lat) * kilometersPerDegree
+	}
+	nearest := math.Inf(1)
+	for _, poly := range b.polygons {
+		for _, r := range poly {
+			for i := 1; i < len(r); i++ {
+				ax, ay := project(r[i-1])
+				bx, by := project(r[i])
+				nearest = math.Min(nearest, distanceToSegment(ax, ay, bx, by))
+			}
+		}
+	}
+	return nearest
+}
+
+// distanceToSegment returns the distance from the origin to segment a-b.
+func distanceToSegment(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
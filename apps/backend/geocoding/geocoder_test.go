@@ -0,0 +1,67 @@
+package geocoding
+
+import (
+	"divelog-backend/models"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestGeocoder(t *testing.T) *Geocoder {
+	t.Helper()
+	geocoder, err := Load(os.DirFS("testdata"), ".")
+	require.NoError(t, err)
+	require.Equal(t, 3, geocoder.Len())
+	return geocoder
+}
+
+func TestGeocoderLookupInsideBoundaries(t *testing.T) {
+	geocoder := loadTestGeocoder(t)
+
+	place, ok := geocoder.Lookup(11.5, 11.5)
+	assert.True(t, ok)
+	assert.Equal(t, models.Place{CountryCode: "XA", Country: "Westland", Region: "North Coast"}, place)
+
+	place, ok = geocoder.Lookup(10.75, 10.75)
+	assert.True(t, ok)
+	assert.Equal(t, "Lake District", place.Region, "a point in a hole belongs to the polygon filling it")
+
+	place, ok = geocoder.Lookup(11, 13)
+	assert.True(t, ok)
+	assert.Equal(t, models.Place{CountryCode: "XB", Country: "Eastland"}, place)
+}
+
+func TestGeocoderLookupAssignsOffshorePointsToNearestBoundary(t *testing.T) {
+	geocoder := loadTestGeocoder(t)
+
+	// About 11 km south of Westland's coast.
+	place, ok := geocoder.Lookup(9.9, 11)
+	assert.True(t, ok)
+	assert.Equal(t, "XA", place.CountryCode)
+
+	// Just across the antimeridian from Eastland's island.
+	place, ok = geocoder.Lookup(-16.5, -179.9)
+	assert.True(t, ok)
+	assert.Equal(t, "XB", place.CountryCode)
+
+	_, ok = geocoder.Lookup(9, 11)
+	assert.False(t, ok, "points beyond the offshore distance stay unmatched")
+}
+
+func TestLoadRejectsUnsupportedGeometry(t *testing.T) {
+	fsys := fstest.MapFS{"data/bad.geojson": {Data: []byte(
+		`{"features":[{"properties":{"country_code":"XA","country":"Westland"},"geometry":{"type":"Point","coordinates":[1,2]}}]}`,
+	)}}
+
+	_, err := Load(fsys, "data")
+
+	assert.ErrorContains(t, err, `unsupported geometry "Point"`)
+}
+
+func TestLoadEmbeddedSucceeds(t *testing.T) {
+	_, err := LoadEmbedded()
+	assert.NoError(t, err)
+}
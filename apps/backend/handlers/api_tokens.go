@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"divelog-backend/middleware"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APITokenHandler struct {
+	service apiTokenService
+}
+
+func NewAPITokenHandler(service apiTokenService) *APITokenHandler {
+	return &APITokenHandler{service: service}
+}
+
+func (h *APITokenHandler) GetTokens(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	tokens, err := h.service.List(c.Request.Context(), userID)
+	if err != nil {
+		respondAPITokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateToken issues a personal access token. The response carries the token
+// itself, which is not shown again.
+func (h *APITokenHandler) CreateToken(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	var request models.APITokenRequest
+	if !middleware.BindAndValidateJSON(c, &request) {
+		return
+	}
+	token, err := h.service.Create(c.Request.Context(), userID, request)
+	if err != nil {
+		respondAPITokenError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, token)
+}
+
+// DeleteToken revokes a token immediately.
+func (h *APITokenHandler) DeleteToken(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	if err := h.service.Delete(c.Request.Context(), userID, id); err != nil {
+		respondAPITokenError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondAPITokenError(c *gin.Context, err error) {
+	switch err {
+	case utils.ErrAPITokenNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		utils.LogError(c.Request.Context(), "API token operation failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API token operation failed"})
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAPITokenService struct {
+	mock.Mock
+}
+
+func (m *mockAPITokenService) List(ctx context.Context, userID int) ([]models.APIToken, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.APIToken), args.Error(1)
+}
+func (m *mockAPITokenService) Create(ctx context.Context, userID int, request models.APITokenRequest) (*models.APIToken, error) {
+	args := m.Called(ctx, userID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIToken), args.Error(1)
+}
+func (m *mockAPITokenService) Delete(ctx context.Context, userID, id int) error {
+	return m.Called(ctx, userID, id).Error(0)
+}
+
+func TestAPITokenHandlerCreateReturnsTokenOnce(t *testing.T) {
+	service := new(mockAPITokenService)
+	handler := NewAPITokenHandler(service)
+	days := 90
+	request := models.APITokenRequest{Name: "Nightly export", Scopes: []string{models.ScopeExport}, ExpiresInDays: &days}
+	service.On("Create", mock.Anything, 1, request).
+		Return(&models.APIToken{ID: 3, Name: request.Name, Prefix: "dlp_0123abcd", Scopes: request.Scopes, Token: "dlp_0123abcdef"}, nil)
+
+	context, recorder := setupGinContext(http.MethodPost, "/tokens", map[string]interface{}{
+		"name": "Nightly export", "scopes": []string{"export"},
+	})
+	handler.CreateToken(context)
+
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+	assert.Contains(t, recorder.Body.String(), `"token":"dlp_0123abcdef"`)
+	service.AssertExpectations(t)
+}
+
+func TestAPITokenHandlerCreateValidatesRequest(t *testing.T) {
+	service := new(mockAPITokenService)
+	handler := NewAPITokenHandler(service)
+
+	context, recorder := setupGinContext(http.MethodPost, "/tokens", map[string]interface{}{
+		"name": "script", "scopes": []string{"dives:delete"},
+	})
+	handler.CreateToken(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"scopes[0]"`)
+	service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAPITokenHandlerListOmitsSecrets(t *testing.T) {
+	service := new(mockAPITokenService)
+	handler := NewAPITokenHandler(service)
+	service.On("List", mock.Anything, 1).Return([]models.APIToken{{ID: 3, Prefix: "dlp_0123abcd", Scopes: []string{models.ScopeDivesRead}}}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/tokens", nil)
+	handler.GetTokens(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"prefix":"dlp_0123abcd"`)
+	assert.NotContains(t, recorder.Body.String(), `"token"`)
+}
+
+func TestAPITokenHandlerDeleteReportsMissingToken(t *testing.T) {
+	service := new(mockAPITokenService)
+	handler := NewAPITokenHandler(service)
+	service.On("Delete", mock.Anything, 1, 9).Return(utils.ErrAPITokenNotFound)
+
+	context, recorder := setupGinContext(http.MethodDelete, "/tokens/9", nil)
+	context.Params = gin.Params{{Key: "id", Value: "9"}}
+	handler.DeleteToken(context)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"divelog-backend/middleware"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditLogHandler struct {
+	repository auditLogRepository
+}
+
+func NewAuditLogHandler(repository auditLogRepository) *AuditLogHandler {
+	return &AuditLogHandler{repository: repository}
+}
+
+// GetAuditLog lists who changed what in the user's logbook, optionally for
+// one entity type or entity and a time range.
+func (h *AuditLogHandler) GetAuditLog(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	var filter models.AuditLogFilter
+	if !middleware.BindAndValidateQuery(c, &filter) {
+		return
+	}
+	entries, err := h.repository.ListEntries(c.Request.Context(), userID, filter)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to read audit log", err, utils.UserID(userID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
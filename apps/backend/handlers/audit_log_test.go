@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"divelog-backend/models"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAuditLogRepository struct {
+	mock.Mock
+}
+
+func (m *mockAuditLogRepository) ListEntries(ctx context.Context, userID int, filter models.AuditLogFilter) ([]models.AuditEntry, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).([]models.AuditEntry), args.Error(1)
+}
+
+func TestAuditLogHandlerAppliesFilter(t *testing.T) {
+	repository := new(mockAuditLogRepository)
+	handler := NewAuditLogHandler(repository)
+	filter := models.AuditLogFilter{
+		EntityType: models.AuditEntityDive,
+		EntityID:   12,
+		From:       time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		To:         time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC),
+		Limit:      100,
+	}
+	actor := 4
+	repository.On("ListEntries", mock.Anything, 1, mock.MatchedBy(func(got models.AuditLogFilter) bool {
+		return got.EntityType == filter.EntityType && got.EntityID == filter.EntityID &&
+			got.From.Equal(filter.From) && got.To.Equal(filter.To) && got.Limit == filter.Limit
+	})).Return([]models.AuditEntry{{
+		ID: 31, ActorUserID: &actor, EntityType: "dive", EntityID: 12, Action: "update",
+		Changes: json.RawMessage(`{"buddy":{"before":"Ana","after":"Ben"}}`),
+	}}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet,
+		"/audit-log?entity_type=dive&entity_id=12&from=2026-10-01T00:00:00Z&to=2026-10-02T00:00:00Z", nil)
+	handler.GetAuditLog(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"changes":{"buddy":{"before":"Ana","after":"Ben"}}`)
+	repository.AssertExpectations(t)
+}
+
+func TestAuditLogHandlerRejectsInvalidFilter(t *testing.T) {
+	repository := new(mockAuditLogRepository)
+	handler := NewAuditLogHandler(repository)
+
+	context, recorder := setupGinContext(http.MethodGet, "/audit-log?from=yesterday", nil)
+	handler.GetAuditLog(context)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	context, recorder = setupGinContext(http.MethodGet, "/audit-log?entity_type=certification", nil)
+	handler.GetAuditLog(context)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"entity_type"`)
+
+	repository.AssertNotCalled(t, "ListEntries", mock.Anything, mock.Anything, mock.Anything)
+}
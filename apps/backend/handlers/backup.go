@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"divelog-backend/middleware"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BackupHandler struct {
+	service backupService
+}
+
+func NewBackupHandler(service backupService) *BackupHandler {
+	return &BackupHandler{service: service}
+}
+
+// GetBackup streams the user's archive as a downloadable JSON document.
+func (h *BackupHandler) GetBackup(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	sink := &jsonBackupSink{c: c}
+	if err := h.service.Export(c.Request.Context(), userID, sink); err != nil {
+		utils.LogError(c.Request.Context(), "Error exporting backup", err, utils.UserID(userID))
+		if !sink.started {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export backup"})
+			return
+		}
+		// The status line is already sent; abort so the client sees a
+		// truncated, unparseable document instead of a partial backup.
+		c.Abort()
+		return
+	}
+	if err := sink.finish(); err != nil {
+		utils.LogError(c.Request.Context(), "Error writing backup", err, utils.UserID(userID))
+	}
+}
+
+// Restore applies an uploaded archive. mode is merge (default) or replace and
+// dry_run=true reports what would change without saving anything. replace
+// replaces the logbook only: certifications, whose card images are not in the
+// archive, are merged even then.
+func (h *BackupHandler) Restore(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	options := models.RestoreOptions{Mode: c.DefaultQuery("mode", "merge")}
+	if value := c.Query("dry_run"); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dry_run"})
+			return
+		}
+		options.DryRun = dryRun
+	}
+	if !middleware.ValidateRequest(c, &options) {
+		return
+	}
+
+	var archive models.BackupArchive
+	if !middleware.BindAndValidateJSON(c, &archive) {
+		return
+	}
+
+	summary, err := h.service.Restore(c.Request.Context(), userID, &archive, options)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Error restoring backup", err, utils.UserID(userID))
+		switch err {
+		case utils.ErrTripNotFound, utils.ErrInvalidInput:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore backup"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// jsonBackupSink writes the archive header and then appends dives to the
+// "dives" array as they are read, so memory use does not grow with the logbook.
+type jsonBackupSink struct {
+	c       *gin.Context
+	started bool
+	dives   int
+}
+
+func (s *jsonBackupSink) WriteHeader(archive models.BackupArchive) error {
+	archive.Dives = []models.BackupDive{}
+	header, err := json.Marshal(archive)
+	if err != nil {
+		return err
+	}
+	// Dives is the last field, so the encoded header ends with `"dives":[]}`;
+	// leave the array open for WriteDive.
+	header = bytes.TrimSuffix(header, []byte("]}"))
+
+	filename := fmt.Sprintf("divelog-backup-%s.json", archive.CreatedAt.Format("2006-01-02"))
+	s.c.Header("Content-Type", "application/json")
+	s.c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	s.c.Status(http.StatusOK)
+	s.started = true
+	_, err = s.c.Writer.Write(header)
+	return err
+}
+
+func (s *jsonBackupSink) WriteDive(dive models.BackupDive) error {
+	encoded, err := json.Marshal(dive)
+	if err != nil {
+		return err
+	}
+	if s.dives > 0 {
+		if _, err := s.c.Writer.Write([]byte(",")); err != nil {
+			return err
+		}
+	}
+	s.dives++
+	if _, err := s.c.Writer.Write(encoded); err != nil {
+		return err
+	}
+	if s.dives%100 == 0 {
+		s.c.Writer.Flush()
+	}
+	return nil
+}
+
+func (s *jsonBackupSink) finish() error {
+	_, err := s.c.Writer.Write([]byte("]}"))
+	return err
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/services"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockBackupService struct {
+	mock.Mock
+}
+
+func (m *mockBackupService) Export(ctx context.Context, userID int, sink services.BackupSink) error {
+	args := m.Called(ctx, userID)
+	if archive, ok := args.Get(0).(*models.BackupArchive); ok {
+		if err := sink.WriteHeader(*archive); err != nil {
+			return err
+		}
+		for _, dive := range archive.Dives {
+			if err := sink.WriteDive(dive); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *mockBackupService) Restore(ctx context.Context, userID int, archive *models.BackupArchive, options models.RestoreOptions) (*models.RestoreSummary, error) {
+	args := m.Called(ctx, userID, archive, options)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RestoreSummary), args.Error(1)
+}
+
+func TestBackupHandlerStreamsArchive(t *testing.T) {
+	service := new(mockBackupService)
+	handler := NewBackupHandler(service)
+	archive := &models.BackupArchive{
+		Format: models.BackupFormat, Version: models.BackupVersion, Tags: []string{"kelp"},
+		Dives: []models.BackupDive{{ID: 1, DiveRequest: validDiveRequest()}, {ID: 2, DiveRequest: validDiveRequest()}},
+	}
+	service.On("Export", mock.Anything, 1).Return(archive, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/backup", nil)
+	handler.GetBackup(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Disposition"), "attachment")
+	var decoded models.BackupArchive
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &decoded))
+	assert.Equal(t, []string{"kelp"}, decoded.Tags)
+	assert.Len(t, decoded.Dives, 2)
+	assert.Equal(t, 2, decoded.Dives[1].ID)
+}
+
+func TestBackupHandlerRestorePassesOptions(t *testing.T) {
+	service := new(mockBackupService)
+	handler := NewBackupHandler(service)
+	archive := models.BackupArchive{Format: models.BackupFormat, Version: models.BackupVersion, Tags: []string{"kelp"}}
+	options := models.RestoreOptions{Mode: "replace", DryRun: true}
+	service.On("Restore", mock.Anything, 1, mock.Anything, options).
+		Return(&models.RestoreSummary{Mode: "replace", DryRun: true, Tags: models.RestoreCounts{Created: 1}}, nil)
+
+	context, recorder := setupGinContext(http.MethodPost, "/restore?mode=replace&dry_run=true", archive)
+	handler.Restore(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"dry_run":true`)
+	service.AssertExpectations(t)
+}
+
+func TestBackupHandlerRestoreRejectsInvalidArchive(t *testing.T) {
+	service := new(mockBackupService)
+	handler := NewBackupHandler(service)
+
+	context, recorder := setupGinContext(http.MethodPost, "/restore?mode=merge", models.BackupArchive{Format: "other", Version: 1})
+	handler.Restore(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"format"`)
+	service.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBackupHandlerRestoreRejectsUnknownMode(t *testing.T) {
+	service := new(mockBackupService)
+	handler := NewBackupHandler(service)
+
+	context, recorder := setupGinContext(http.MethodPost, "/restore?mode=overwrite", models.BackupArchive{})
+	handler.Restore(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"mode"`)
+}
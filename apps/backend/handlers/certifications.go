@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"divelog-backend/middleware"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCardImageBytes bounds a certification card upload.
+const maxCardImageBytes = 5 << 20
+
+type CertificationHandler struct {
+	service certificationService
+}
+
+func NewCertificationHandler(service certificationService) *CertificationHandler {
+	return &CertificationHandler{service: service}
+}
+
+func (h *CertificationHandler) GetCertifications(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	certifications, err := h.service.List(c.Request.Context(), userID)
+	if err != nil {
+		respondCertificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, certifications)
+}
+
+func (h *CertificationHandler) GetCertification(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	certification, err := h.service.Get(c.Request.Context(), userID, id)
+	if err != nil {
+		respondCertificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, certification)
+}
+
+func (h *CertificationHandler) CreateCertification(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	var request models.CertificationRequest
+	if !middleware.BindAndValidateJSON(c, &request) {
+		return
+	}
+	certification, err := h.service.Create(c.Request.Context(), userID, request)
+	if err != nil {
+		respondCertificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, certification)
+}
+
+func (h *CertificationHandler) UpdateCertification(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	var request models.CertificationRequest
+	if !middleware.BindAndValidateJSON(c, &request) {
+		return
+	}
+	certification, err := h.service.Update(c.Request.Context(), userID, id, request)
+	if err != nil {
+		respondCertificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, certification)
+}
+
+func (h *CertificationHandler) DeleteCertification(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	if err := h.service.Delete(c.Request.Context(), userID, id); err != nil {
+		respondCertificationError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// UploadCardImage stores the multipart "image" file as the certification's
+// card picture.
+func (h *CertificationHandler) UploadCardImage(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	header, err := c.FormFile("image")
+	if err != nil {
+		middleware.RespondValidationErrors(c, utils.ValidationErrors{"image": "must be uploaded as multipart form field image"})
+		return
+	}
+	if header.Size > maxCardImageBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Card image must be at most 5 MB"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		respondCertificationError(c, err)
+		return
+	}
+	defer file.Close()
+	certification, err := h.service.PutCardImage(c.Request.Context(), userID, id, io.LimitReader(file, maxCardImageBytes))
+	if err != nil {
+		respondCertificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, certification)
+}
+
+// GetCardImage streams the certification's card picture.
+func (h *CertificationHandler) GetCardImage(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	image, contentType, err := h.service.OpenCardImage(c.Request.Context(), userID, id)
+	if err != nil {
+		respondCertificationError(c, err)
+		return
+	}
+	defer image.Close()
+	c.Header("Cache-Control", "private, no-cache")
+	c.DataFromReader(http.StatusOK, -1, contentType, image, nil)
+}
+
+func (h *CertificationHandler) DeleteCardImage(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	if err := h.service.DeleteCardImage(c.Request.Context(), userID, id); err != nil {
+		respondCertificationError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetProgress reports logged dives against the certification's prerequisites.
+func (h *CertificationHandler) GetProgress(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	progress, err := h.service.Progress(c.Request.Context(), userID, id)
+	if err != nil {
+		respondCertificationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
+
+func respondCertificationError(c *gin.Context, err error) {
+	switch err {
+	case utils.ErrCertificationNotFound, utils.ErrCardImageNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case utils.ErrCertificationExists:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case utils.ErrUnsupportedImage:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	default:
+		utils.LogError(c.Request.Context(), "Certification operation failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Certification operation failed"})
+	}
+}
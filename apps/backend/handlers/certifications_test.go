@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockCertificationService struct {
+	mock.Mock
+}
+
+func (m *mockCertificationService) List(ctx context.Context, userID int) ([]models.Certification, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.Certification), args.Error(1)
+}
+func (m *mockCertificationService) Get(ctx context.Context, userID, id int) (*models.Certification, error) {
+	args := m.Called(ctx, userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Certification), args.Error(1)
+}
+func (m *mockCertificationService) Create(ctx context.Context, userID int, request models.CertificationRequest) (*models.Certification, error) {
+	args := m.Called(ctx, userID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Certification), args.Error(1)
+}
+func (m *mockCertificationService) Update(ctx context.Context, userID, id int, request models.CertificationRequest) (*models.Certification, error) {
+	args := m.Called(ctx, userID, id, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Certification), args.Error(1)
+}
+func (m *mockCertificationService) Delete(ctx context.Context, userID, id int) error {
+	return m.Called(ctx, userID, id).Error(0)
+}
+func (m *mockCertificationService) PutCardImage(ctx context.Context, userID, id int, image io.Reader) (*models.Certification, error) {
+	content, _ := io.ReadAll(image)
+	args := m.Called(ctx, userID, id, string(content))
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Certification), args.Error(1)
+}
+func (m *mockCertificationService) OpenCardImage(ctx context.Context, userID, id int) (io.ReadCloser, string, error) {
+	args := m.Called(ctx, userID, id)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return io.NopCloser(strings.NewReader(args.String(0))), args.String(1), args.Error(2)
+}
+func (m *mockCertificationService) DeleteCardImage(ctx context.Context, userID, id int) error {
+	return m.Called(ctx, userID, id).Error(0)
+}
+func (m *mockCertificationService) Progress(ctx context.Context, userID, id int) (*models.CertificationProgress, error) {
+	args := m.Called(ctx, userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CertificationProgress), args.Error(1)
+}
+
+func TestCertificationHandlerCreateValidatesPrerequisites(t *testing.T) {
+	service := new(mockCertificationService)
+	handler := NewCertificationHandler(service)
+
+	context, recorder := setupGinContext(http.MethodPost, "/certifications", map[string]interface{}{
+		"agency": "PADI", "level": "Deep Diver", "certified_on": "03/04/2026",
+		"prerequisites": []map[string]interface{}{{"min_dives": 0, "min_depth": -5}},
+	})
+	handler.CreateCertification(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"certified_on"`)
+	assert.Contains(t, recorder.Body.String(), `"prerequisites[0].min_dives"`)
+	assert.Contains(t, recorder.Body.String(), `"prerequisites[0].min_depth"`)
+	service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCertificationHandlerCreateReportsDuplicate(t *testing.T) {
+	service := new(mockCertificationService)
+	handler := NewCertificationHandler(service)
+	service.On("Create", mock.Anything, 1, models.CertificationRequest{Agency: "SSI", Level: "Open Water Diver"}).
+		Return(nil, utils.ErrCertificationExists)
+
+	context, recorder := setupGinContext(http.MethodPost, "/certifications", map[string]interface{}{
+		"agency": " SSI ", "level": "Open Water Diver",
+	})
+	handler.CreateCertification(context)
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	service.AssertExpectations(t)
+}
+
+func TestCertificationHandlerUploadCardImage(t *testing.T) {
+	service := new(mockCertificationService)
+	handler := NewCertificationHandler(service)
+	service.On("PutCardImage", mock.Anything, 1, 3, "\x89PNG card").
+		Return(&models.Certification{ID: 3, HasCardImage: true}, nil)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", "card.png")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("\x89PNG card"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	context, recorder := setupRawGinContext(http.MethodPut, "/certifications/3/card", body.Bytes())
+	context.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	context.Params = gin.Params{{Key: "id", Value: "3"}}
+	handler.UploadCardImage(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"has_card_image":true`)
+	service.AssertExpectations(t)
+}
+
+func TestCertificationHandlerUploadRejectsUnsupportedImage(t *testing.T) {
+	service := new(mockCertificationService)
+	handler := NewCertificationHandler(service)
+	service.On("PutCardImage", mock.Anything, 1, 3, "%PDF").Return(nil, utils.ErrUnsupportedImage)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", "card.pdf")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("%PDF"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	context, recorder := setupRawGinContext(http.MethodPut, "/certifications/3/card", body.Bytes())
+	context.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	context.Params = gin.Params{{Key: "id", Value: "3"}}
+	handler.UploadCardImage(context)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, recorder.Code)
+}
+
+func TestCertificationHandlerGetCardImageStreamsContent(t *testing.T) {
+	service := new(mockCertificationService)
+	handler := NewCertificationHandler(service)
+	service.On("OpenCardImage", mock.Anything, 1, 3).Return("jpeg bytes", "image/jpeg", nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/certifications/3/card", nil)
+	context.Params = gin.Params{{Key: "id", Value: "3"}}
+	handler.GetCardImage(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "image/jpeg", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "jpeg bytes", recorder.Body.String())
+}
+
+func TestCertificationHandlerGetCardImageReportsMissingImage(t *testing.T) {
+	service := new(mockCertificationService)
+	handler := NewCertificationHandler(service)
+	service.On("OpenCardImage", mock.Anything, 1, 3).Return(nil, "", utils.ErrCardImageNotFound)
+
+	context, recorder := setupGinContext(http.MethodGet, "/certifications/3/card", nil)
+	context.Params = gin.Params{{Key: "id", Value: "3"}}
+	handler.GetCardImage(context)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"divelog-backend/middleware"
+	"divelog-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DiveRevisionHandler struct {
+	service diveRevisionService
+}
+
+func NewDiveRevisionHandler(service diveRevisionService) *DiveRevisionHandler {
+	return &DiveRevisionHandler{service: service}
+}
+
+// GetRevisions lists the earlier versions of a dive, newest first, with what
+// each following edit changed.
+func (h *DiveRevisionHandler) GetRevisions(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	diveID, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	revisions, err := h.service.List(c.Request.Context(), userID, diveID)
+	if err != nil {
+		respondDiveRevisionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// RestoreRevision puts the dive back the way it was in revision :rev and
+// returns the restored dive.
+func (h *DiveRevisionHandler) RestoreRevision(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	diveID, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	revision, err := utils.ValidateIDParam(c, "rev")
+	if err != nil {
+		return
+	}
+	dive, err := h.service.Restore(c.Request.Context(), userID, diveID, revision)
+	if err != nil {
+		respondDiveRevisionError(c, err)
+		return
+	}
+	setETag(c, dive.Version)
+	c.JSON(http.StatusOK, dive)
+}
+
+func respondDiveRevisionError(c *gin.Context, err error) {
+	switch err {
+	case utils.ErrDiveNotFound, utils.ErrDiveRevisionNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case utils.ErrDuplicateDive:
+		c.JSON(http.StatusConflict, gin.H{"error": "Restoring this revision would duplicate another dive at the same date and location"})
+	default:
+		utils.LogError(c.Request.Context(), "Dive revision operation failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Dive revision operation failed"})
+	}
+}
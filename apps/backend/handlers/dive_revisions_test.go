@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockDiveRevisionService struct {
+	mock.Mock
+}
+
+func (m *mockDiveRevisionService) List(ctx context.Context, userID, diveID int) ([]models.DiveRevision, error) {
+	args := m.Called(ctx, userID, diveID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.DiveRevision), args.Error(1)
+}
+func (m *mockDiveRevisionService) Restore(ctx context.Context, userID, diveID, revision int) (*models.Dive, error) {
+	args := m.Called(ctx, userID, diveID, revision)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Dive), args.Error(1)
+}
+
+func TestDiveRevisionHandlerListsRevisionsWithChanges(t *testing.T) {
+	service := new(mockDiveRevisionService)
+	handler := NewDiveRevisionHandler(service)
+	service.On("List", mock.Anything, 1, 7).Return([]models.DiveRevision{{
+		Revision: 2, Source: models.DiveRevisionSourceUpdate, DiveVersion: 4,
+		Changes: json.RawMessage(`{"notes":{"before":"Calm","after":"Strong current"}}`),
+	}}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dives/7/revisions", nil)
+	context.Params = gin.Params{{Key: "id", Value: "7"}}
+	handler.GetRevisions(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"changes":{"notes":{"before":"Calm","after":"Strong current"}}`)
+	service.AssertExpectations(t)
+}
+
+func TestDiveRevisionHandlerRestoreReturnsDiveWithETag(t *testing.T) {
+	service := new(mockDiveRevisionService)
+	handler := NewDiveRevisionHandler(service)
+	service.On("Restore", mock.Anything, 1, 7, 2).Return(&models.Dive{ID: 7, Version: 9}, nil)
+
+	context, recorder := setupGinContext(http.MethodPost, "/dives/7/revisions/2/restore", nil)
+	context.Params = gin.Params{{Key: "id", Value: "7"}, {Key: "rev", Value: "2"}}
+	handler.RestoreRevision(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `"9"`, recorder.Header().Get("ETag"))
+	service.AssertExpectations(t)
+}
+
+func TestDiveRevisionHandlerRestoreMapsErrors(t *testing.T) {
+	for err, want := range map[error]int{
+		utils.ErrDiveNotFound:         http.StatusNotFound,
+		utils.ErrDiveRevisionNotFound: http.StatusNotFound,
+		utils.ErrDuplicateDive:        http.StatusConflict,
+		utils.ErrDatabaseError:        http.StatusInternalServerError,
+	} {
+		service := new(mockDiveRevisionService)
+		handler := NewDiveRevisionHandler(service)
+		service.On("Restore", mock.Anything, 1, 7, 3).Return(nil, err)
+
+		context, recorder := setupGinContext(http.MethodPost, "/dives/7/revisions/3/restore", nil)
+		context.Params = gin.Params{{Key: "id", Value: "7"}, {Key: "rev", Value: "3"}}
+		handler.RestoreRevision(context)
+
+		assert.Equal(t, want, recorder.Code, err.Error())
+	}
+
+	service := new(mockDiveRevisionService)
+	context, recorder := setupGinContext(http.MethodPost, "/dives/7/revisions/latest/restore", nil)
+	context.Params = gin.Params{{Key: "id", Value: "7"}, {Key: "rev", Value: "latest"}}
+	NewDiveRevisionHandler(service).RestoreRevision(context)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	service.AssertNotCalled(t, "Restore")
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"bytes"
 	"divelog-backend/middleware"
 	"divelog-backend/models"
 	"divelog-backend/utils"
@@ -18,9 +19,14 @@ func NewDiveSiteHandler(service diveSiteService) *DiveSiteHandler {
 	return &DiveSiteHandler{service: service}
 }
 
-// GetDiveSites returns all dive sites
+// GetDiveSites returns the dive sites matching the query filters
 func (h *DiveSiteHandler) GetDiveSites(c *gin.Context) {
-	sites, err := h.service.GetAll(c.Request.Context())
+	var filter models.DiveSiteFilter
+	if !middleware.BindAndValidateQuery(c, &filter) {
+		return
+	}
+
+	sites, err := h.service.GetAll(c.Request.Context(), filter)
 	if err != nil {
 		utils.LogError(c.Request.Context(), "Error getting dive sites", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dive sites"})
@@ -48,14 +54,184 @@ func (h *DiveSiteHandler) SearchDiveSites(c *gin.Context) {
 	c.JSON(http.StatusOK, sites)
 }
 
-// GetDiveSite returns a specific dive site
+// GetNearbyDiveSites returns sites within radius kilometers of lat/lng.
+func (h *DiveSiteHandler) GetNearbyDiveSites(c *gin.Context) {
+	if !middleware.RequireQueryParams(c, "lat", "lng", "radius") {
+		return
+	}
+	var request models.NearbyDiveSitesRequest
+	if !middleware.BindAndValidateQuery(c, &request) {
+		return
+	}
+
+	sites, err := h.service.Nearby(c.Request.Context(), request)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Error finding nearby dive sites", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dive sites"})
+		return
+	}
+	c.JSON(http.StatusOK, sites)
+}
+
+// GetDiveSitesInBoundingBox returns the sites inside a map viewport.
+func (h *DiveSiteHandler) GetDiveSitesInBoundingBox(c *gin.Context) {
+	if !middleware.RequireQueryParams(c, "south", "west", "north", "east") {
+		return
+	}
+	var request models.BoundingBoxDiveSitesRequest
+	if !middleware.BindAndValidateQuery(c, &request) {
+		return
+	}
+
+	sites, err := h.service.InBoundingBox(c.Request.Context(), request)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Error finding dive sites in bounding box", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dive sites"})
+		return
+	}
+	c.JSON(http.StatusOK, sites)
+}
+
+// GetDiveSiteClusters returns per-cell site counts for a viewport and zoom level.
+func (h *DiveSiteHandler) GetDiveSiteClusters(c *gin.Context) {
+	if !middleware.RequireQueryParams(c, "south", "west", "north", "east", "zoom") {
+		return
+	}
+	var request models.DiveSiteClustersRequest
+	if !middleware.BindAndValidateQuery(c, &request) {
+		return
+	}
+
+	clusters, err := h.service.Clusters(c.Request.Context(), request)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Error clustering dive sites", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cluster dive sites"})
+		return
+	}
+	c.JSON(http.StatusOK, clusters)
+}
+
+// ExportDiveSites downloads the located sites as KML, GeoJSON or GPX with the
+// user's dive count, max depth and last-dived date for each.
+func (h *DiveSiteHandler) ExportDiveSites(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	var request models.DiveSiteExportRequest
+	if !middleware.BindAndValidateQuery(c, &request) {
+		return
+	}
+
+	var body bytes.Buffer
+	if err := h.service.Export(c.Request.Context(), userID, request, &body); err != nil {
+		utils.LogError(c.Request.Context(), "Error exporting dive sites", err, utils.UserID(userID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export dive sites"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="dive-sites.`+request.Format+`"`)
+	c.Data(http.StatusOK, models.DiveSiteExportFormats[request.Format], body.Bytes())
+}
+
+// ImportDiveSites creates sites from a GeoJSON FeatureCollection of Points,
+// skipping features that duplicate an existing site.
+func (h *DiveSiteHandler) ImportDiveSites(c *gin.Context) {
+	var collection models.GeoJSONFeatureCollection
+	if !middleware.BindAndValidateJSON(c, &collection) {
+		return
+	}
+
+	result, err := h.service.Import(c.Request.Context(), &collection)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Error importing dive sites", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import dive sites"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetDuplicateDiveSites lists likely duplicate site pairs, best match first.
+func (h *DiveSiteHandler) GetDuplicateDiveSites(c *gin.Context) {
+	var request models.DiveSiteDuplicatesRequest
+	if !middleware.BindAndValidateQuery(c, &request) {
+		return
+	}
+
+	candidates, err := h.service.Duplicates(c.Request.Context(), request)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Error finding duplicate dive sites", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicate dive sites"})
+		return
+	}
+	c.JSON(http.StatusOK, candidates)
+}
+
+// MergeDiveSites moves the dives of the source sites to the site in the path
+// and deletes the sources.
+func (h *DiveSiteHandler) MergeDiveSites(c *gin.Context) {
+	targetID, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	var request models.MergeDiveSitesRequest
+	if !middleware.BindAndValidateJSON(c, &request) {
+		return
+	}
+
+	result, err := h.service.Merge(c.Request.Context(), targetID, request)
+	if err != nil {
+		switch err {
+		case utils.ErrDiveSiteNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dive site not found"})
+		case utils.ErrInvalidInput:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "source_site_ids must name a site other than the target"})
+		case utils.ErrDuplicateDive:
+			c.JSON(http.StatusConflict, gin.H{"error": "Merging these sites would duplicate a dive at the same date and location"})
+		case utils.ErrMissingUserID:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			utils.LogError(c.Request.Context(), "Error merging dive sites", err, slog.Int("dive_site_id", targetID))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge dive sites"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetCountryStatistics returns the user's dive counts and totals per country.
+func (h *DiveSiteHandler) GetCountryStatistics(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	statistics, err := h.service.CountryStatistics(c.Request.Context(), userID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Error getting country statistics", err, utils.UserID(userID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve country statistics"})
+		return
+	}
+	c.JSON(http.StatusOK, statistics)
+}
+
+// GetDiveSite returns a specific dive site. With user_id it also returns that
+// user's dive history at the site.
 func (h *DiveSiteHandler) GetDiveSite(c *gin.Context) {
 	id, err := utils.ValidateIDParam(c, "id")
 	if err != nil {
 		return
 	}
 
-	site, err := h.service.GetByID(c.Request.Context(), id)
+	var site interface{}
+	if c.Query("user_id") == "" {
+		site, err = h.service.GetByID(c.Request.Context(), id)
+	} else {
+		userID, validationErr := utils.ValidateUserID(c)
+		if validationErr != nil {
+			return
+		}
+		site, err = h.service.GetDetail(c.Request.Context(), id, userID)
+	}
 	if err != nil {
 		if err == utils.ErrDiveSiteNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Dive site not found"})
@@ -138,6 +314,10 @@ func (h *DiveSiteHandler) DeleteDiveSite(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Dive site not found"})
 			return
 		}
+		if err == utils.ErrMissingUserID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if err == utils.ErrDiveSiteInUse {
 			c.JSON(http.StatusConflict, gin.H{
 				"error": "Cannot delete dive site that has associated dives",
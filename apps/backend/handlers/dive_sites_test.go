@@ -57,3 +57,207 @@ func TestDiveSiteHandlerGetReturnsNotFound(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, recorder.Code)
 	repository.AssertExpectations(t)
 }
+
+func TestDiveSiteHandlerNearbyBindsQuery(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+	request := models.NearbyDiveSitesRequest{Latitude: 36.6, Longitude: -121.9, RadiusKM: 5, Limit: 200}
+	repository.On("Nearby", mock.Anything, request).Return([]models.NearbyDiveSite{
+		{DiveSite: models.DiveSite{ID: 4, Name: "Lovers Point"}, DistanceKM: 1.2},
+	}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dive-sites/nearby?lat=36.6&lng=-121.9&radius=5", nil)
+	handler.GetNearbyDiveSites(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"distance_km":1.2`)
+	repository.AssertExpectations(t)
+}
+
+func TestDiveSiteHandlerNearbyRequiresCoordinates(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dive-sites/nearby?radius=5", nil)
+	handler.GetNearbyDiveSites(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"lat":"is required"`)
+	repository.AssertNotCalled(t, "Nearby", mock.Anything, mock.Anything)
+}
+
+func TestDiveSiteHandlerClustersAcceptsAntimeridianViewport(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+	request := models.DiveSiteClustersRequest{
+		BoundingBox: models.BoundingBox{South: -25, West: 170, North: -10, East: -170}, Zoom: 5,
+	}
+	repository.On("Clusters", mock.Anything, request).Return([]models.DiveSiteCluster{{Geohash: "rs", Count: 12}}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dive-sites/clusters?south=-25&west=170&north=-10&east=-170&zoom=5", nil)
+	handler.GetDiveSiteClusters(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"count":12`)
+	repository.AssertExpectations(t)
+}
+
+func TestDiveSiteHandlerBoundingBoxRejectsInvertedLatitudes(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dive-sites/bbox?south=40&west=-125&north=30&east=-115", nil)
+	handler.GetDiveSitesInBoundingBox(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"north"`)
+}
+
+func TestDiveSiteHandlerExportSetsFormatHeaders(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+	repository.On("Export", mock.Anything, 1, models.DiveSiteExportRequest{Format: "gpx", DivedOnly: true}).Return("<gpx/>", nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dive-sites/export?format=gpx&dived_only=true", nil)
+	handler.ExportDiveSites(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/gpx+xml", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="dive-sites.gpx"`, recorder.Header().Get("Content-Disposition"))
+	assert.Equal(t, "<gpx/>", recorder.Body.String())
+	repository.AssertExpectations(t)
+}
+
+func TestDiveSiteHandlerExportRejectsUnknownFormat(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dive-sites/export?format=csv", nil)
+	handler.ExportDiveSites(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"format"`)
+	repository.AssertNotCalled(t, "Export", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDiveSiteHandlerImportReportsFeatureErrors(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+	body := []byte(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","geometry":{"type":"Point","coordinates":[-121.9,36.6]},"properties":{"name":"Lovers Point"}},
+		{"type":"Feature","geometry":{"type":"LineString","coordinates":[0,0]},"properties":{"name":"Track"}}
+	]}`)
+
+	context, recorder := setupRawGinContext(http.MethodPost, "/dive-sites/import", body)
+	handler.ImportDiveSites(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"features[1].geometry":"must be a Point"`)
+	repository.AssertNotCalled(t, "Import", mock.Anything, mock.Anything)
+}
+
+func TestDiveSiteHandlerMergeReturnsNotFound(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+	request := models.MergeDiveSitesRequest{SourceSiteIDs: []int{4}}
+	repository.On("Merge", mock.Anything, 3, request).Return(nil, utils.ErrDiveSiteNotFound)
+
+	context, recorder := setupGinContext(http.MethodPost, "/dive-sites/3/merge", request)
+	context.Params = gin.Params{{Key: "id", Value: "3"}}
+	handler.MergeDiveSites(context)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	repository.AssertExpectations(t)
+}
+
+func TestDiveSiteHandlerMergeReportsDuplicateDives(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+	request := models.MergeDiveSitesRequest{SourceSiteIDs: []int{4}}
+	repository.On("Merge", mock.Anything, 3, request).Return(nil, utils.ErrDuplicateDive)
+
+	context, recorder := setupGinContext(http.MethodPost, "/dive-sites/3/merge", request)
+	context.Params = gin.Params{{Key: "id", Value: "3"}}
+	handler.MergeDiveSites(context)
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	repository.AssertExpectations(t)
+}
+
+func TestDiveSiteHandlerDeleteWithoutUserIDIsBadRequest(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	repository.On("Delete", mock.Anything, 5).Return(utils.ErrMissingUserID)
+
+	context, recorder := setupGinContext(http.MethodDelete, "/dive-sites/5", nil)
+	context.Params = gin.Params{{Key: "id", Value: "5"}}
+	NewDiveSiteHandler(repository).DeleteDiveSite(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "user_id is required")
+}
+
+func TestDiveSiteHandlerDuplicatesAppliesDefaults(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+	repository.On("Duplicates", mock.Anything, models.DiveSiteDuplicatesRequest{MaxDistanceKM: 2, MinScore: 0.6, Limit: 50}).
+		Return([]models.DiveSiteDuplicateCandidate{{Score: 0.855}}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dive-sites/duplicates", nil)
+	handler.GetDuplicateDiveSites(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"score":0.855`)
+	repository.AssertExpectations(t)
+}
+
+func TestDiveSiteHandlerListBindsFilters(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+	minDepth := 20.0
+	filter := models.DiveSiteFilter{Country: "Egypt", EntryType: "shore", MinDepth: &minDepth}
+	repository.On("GetAll", mock.Anything, filter).Return([]models.DiveSite{{ID: 4, Name: "Lighthouse"}}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dive-sites?country=Egypt&entry_type=shore&min_depth=20", nil)
+	handler.GetDiveSites(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	repository.AssertExpectations(t)
+}
+
+func TestDiveSiteHandlerGetIncludesHistoryForUser(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+	detail := &models.DiveSiteDetail{
+		DiveSite:    models.DiveSite{ID: 4, Name: "Lighthouse"},
+		DiveHistory: &models.DiveSiteHistory{DiveCount: 3, TotalDuration: 150},
+	}
+	repository.On("GetDetail", mock.Anything, 4, 7).Return(detail, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dive-sites/4?user_id=7", nil)
+	context.Params = gin.Params{{Key: "id", Value: "4"}}
+	handler.GetDiveSite(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"dive_history":{"dive_count":3,"total_duration":150}`)
+	repository.AssertExpectations(t)
+}
+
+func TestDiveSiteHandlerCountryStatisticsUsesUserID(t *testing.T) {
+	repository := new(mockDiveSiteRepository)
+	handler := NewDiveSiteHandler(repository)
+	code, country := "EG", "Egypt"
+	repository.On("CountryStatistics", mock.Anything, 1).Return([]models.CountryStatistics{
+		{CountryCode: &code, Country: &country, DiveCount: 12, SiteCount: 4, TotalDuration: 540},
+		{DiveCount: 2, SiteCount: 1, TotalDuration: 80},
+	}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/statistics/countries?user_id=1", nil)
+	handler.GetCountryStatistics(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `[
+		{"country_code":"EG","country":"Egypt","dive_count":12,"site_count":4,"total_duration":540},
+		{"country_code":null,"country":null,"dive_count":2,"site_count":1,"total_duration":80}
+	]`, recorder.Body.String())
+	repository.AssertExpectations(t)
+}
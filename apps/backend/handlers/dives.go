@@ -4,6 +4,7 @@ import (
 	"divelog-backend/middleware"
 	"divelog-backend/models"
 	"divelog-backend/utils"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -25,7 +26,21 @@ func (h *DiveHandler) GetDives(c *gin.Context) {
 		return
 	}
 
-	dives, err := h.service.GetDives(c.Request.Context(), userID)
+	var dives []models.Dive
+	var err error
+	if key, filtered := c.GetQuery("extra_key"); filtered {
+		if !models.ValidExtraDataKey(key) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid extra_key"})
+			return
+		}
+		var value *string
+		if raw, ok := c.GetQuery("extra_value"); ok {
+			value = &raw
+		}
+		dives, err = h.service.GetDivesByExtraData(c.Request.Context(), userID, key, value)
+	} else {
+		dives, err = h.service.GetDives(c.Request.Context(), userID)
+	}
 	if err != nil {
 		utils.LogError(c.Request.Context(), "Error getting dives for user", err, utils.UserID(userID))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dives"})
@@ -34,6 +49,30 @@ func (h *DiveHandler) GetDives(c *gin.Context) {
 	c.JSON(http.StatusOK, dives)
 }
 
+func (h *DiveHandler) GetDive(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	diveID, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+
+	dive, err := h.service.GetDive(c.Request.Context(), userID, diveID)
+	if err != nil {
+		if err == utils.ErrDiveNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dive not found"})
+			return
+		}
+		utils.LogError(c.Request.Context(), "Error getting dive", err, utils.UserID(userID), utils.DiveID(diveID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dive"})
+		return
+	}
+	setETag(c, dive.Version)
+	c.JSON(http.StatusOK, dive)
+}
+
 func (h *DiveHandler) CreateDive(c *gin.Context) {
 	userID, ok := middleware.RequireUserID(c)
 	if !ok {
@@ -47,12 +86,15 @@ func (h *DiveHandler) CreateDive(c *gin.Context) {
 
 	dive, err := h.service.CreateDive(c.Request.Context(), userID, request)
 	if err != nil {
-		if err == utils.ErrDuplicateDive {
+		switch err {
+		case utils.ErrDuplicateDive:
 			respondDuplicateDive(c, request)
-			return
+		case utils.ErrTripNotFound, utils.ErrCertificationNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			utils.LogError(c.Request.Context(), "Error creating dive", err, utils.UserID(userID))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dive"})
 		}
-		utils.LogError(c.Request.Context(), "Error creating dive", err, utils.UserID(userID))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create dive"})
 		return
 	}
 	c.JSON(http.StatusCreated, dive)
@@ -107,24 +149,37 @@ func (h *DiveHandler) UpdateDive(c *gin.Context) {
 		return
 	}
 
+	expectedVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	var request models.DiveRequest
 	if !middleware.BindAndValidateJSON(c, &request) {
 		return
 	}
 
-	dive, err := h.service.UpdateDive(c.Request.Context(), diveID, userID, request)
+	dive, err := h.service.UpdateDive(c.Request.Context(), diveID, userID, request, expectedVersion)
 	if err != nil {
+		var conflict *models.VersionConflict
+		if errors.As(err, &conflict) {
+			respondVersionConflict(c, conflict)
+			return
+		}
 		switch err {
 		case utils.ErrDiveNotFound:
 			c.JSON(http.StatusNotFound, gin.H{"error": "Dive not found"})
 		case utils.ErrDuplicateDive:
 			respondDuplicateDive(c, request)
+		case utils.ErrTripNotFound, utils.ErrCertificationNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		default:
 			utils.LogError(c.Request.Context(), "Error updating dive", err, utils.UserID(userID), utils.DiveID(diveID))
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dive"})
 		}
 		return
 	}
+	setETag(c, dive.Version)
 	c.JSON(http.StatusOK, dive)
 }
 
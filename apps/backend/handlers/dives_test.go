@@ -6,6 +6,7 @@ import (
 	"divelog-backend/models"
 	"divelog-backend/services"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -24,6 +25,11 @@ func (m *mockDiveService) GetDives(ctx context.Context, userID int) ([]models.Di
 	return args.Get(0).([]models.Dive), args.Error(1)
 }
 
+func (m *mockDiveService) GetDivesByExtraData(ctx context.Context, userID int, key string, value *string) ([]models.Dive, error) {
+	args := m.Called(ctx, userID, key, value)
+	return args.Get(0).([]models.Dive), args.Error(1)
+}
+
 func (m *mockDiveService) CreateDive(ctx context.Context, userID int, request models.DiveRequest) (*models.Dive, error) {
 	args := m.Called(ctx, userID, request)
 	if args.Get(0) == nil {
@@ -40,8 +46,16 @@ func (m *mockDiveService) CreateMultipleDives(ctx context.Context, userID int, r
 	return args.Get(0).(*services.BatchCreateResult), args.Error(1)
 }
 
-func (m *mockDiveService) UpdateDive(ctx context.Context, diveID, userID int, request models.DiveRequest) (*models.Dive, error) {
-	args := m.Called(ctx, diveID, userID, request)
+func (m *mockDiveService) GetDive(ctx context.Context, userID, diveID int) (*models.Dive, error) {
+	args := m.Called(ctx, userID, diveID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Dive), args.Error(1)
+}
+
+func (m *mockDiveService) UpdateDive(ctx context.Context, diveID, userID int, request models.DiveRequest, expectedVersion *int) (*models.Dive, error) {
+	args := m.Called(ctx, diveID, userID, request, expectedVersion)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -61,11 +75,24 @@ type mockDiveSiteRepository struct {
 	mock.Mock
 }
 
-func (m *mockDiveSiteRepository) GetAll(ctx context.Context) ([]models.DiveSite, error) {
-	args := m.Called(ctx)
+func (m *mockDiveSiteRepository) GetAll(ctx context.Context, filter models.DiveSiteFilter) ([]models.DiveSite, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]models.DiveSite), args.Error(1)
 }
 
+func (m *mockDiveSiteRepository) GetDetail(ctx context.Context, id, userID int) (*models.DiveSiteDetail, error) {
+	args := m.Called(ctx, id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DiveSiteDetail), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) CountryStatistics(ctx context.Context, userID int) ([]models.CountryStatistics, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.CountryStatistics), args.Error(1)
+}
+
 func (m *mockDiveSiteRepository) Search(ctx context.Context, query string) ([]models.DiveSite, error) {
 	args := m.Called(ctx, query)
 	return args.Get(0).([]models.DiveSite), args.Error(1)
@@ -99,6 +126,50 @@ func (m *mockDiveSiteRepository) Delete(ctx context.Context, id int) error {
 	return m.Called(ctx, id).Error(0)
 }
 
+func (m *mockDiveSiteRepository) Nearby(ctx context.Context, request models.NearbyDiveSitesRequest) ([]models.NearbyDiveSite, error) {
+	args := m.Called(ctx, request)
+	return args.Get(0).([]models.NearbyDiveSite), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) InBoundingBox(ctx context.Context, request models.BoundingBoxDiveSitesRequest) ([]models.DiveSite, error) {
+	args := m.Called(ctx, request)
+	return args.Get(0).([]models.DiveSite), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) Clusters(ctx context.Context, request models.DiveSiteClustersRequest) ([]models.DiveSiteCluster, error) {
+	args := m.Called(ctx, request)
+	return args.Get(0).([]models.DiveSiteCluster), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) Export(ctx context.Context, userID int, request models.DiveSiteExportRequest, w io.Writer) error {
+	args := m.Called(ctx, userID, request)
+	if body, ok := args.Get(0).(string); ok {
+		_, _ = io.WriteString(w, body)
+	}
+	return args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) Merge(ctx context.Context, targetID int, request models.MergeDiveSitesRequest) (*models.DiveSiteMergeResult, error) {
+	args := m.Called(ctx, targetID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DiveSiteMergeResult), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) Duplicates(ctx context.Context, request models.DiveSiteDuplicatesRequest) ([]models.DiveSiteDuplicateCandidate, error) {
+	args := m.Called(ctx, request)
+	return args.Get(0).([]models.DiveSiteDuplicateCandidate), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) Import(ctx context.Context, collection *models.GeoJSONFeatureCollection) (*models.DiveSiteImportResult, error) {
+	args := m.Called(ctx, collection)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DiveSiteImportResult), args.Error(1)
+}
+
 func setupGinContext(method, url string, body interface{}) (*gin.Context, *httptest.ResponseRecorder) {
 	jsonBody, _ := json.Marshal(body)
 	return setupRawGinContext(method, url, jsonBody)
@@ -222,3 +293,133 @@ func TestDiveHandlerDeleteAllDivesReportsRepositoryFailure(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
 	service.AssertExpectations(t)
 }
+
+func TestDiveHandlerGetDivesFiltersByExtraData(t *testing.T) {
+	service := new(mockDiveService)
+	handler := NewDiveHandler(service)
+	value := "72%"
+	service.On("GetDivesByExtraData", mock.Anything, 1, "suunto.battery", &value).
+		Return([]models.Dive{{ID: 3, ExtraData: map[string]string{"suunto.battery": value}}}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dives?extra_key=suunto.battery&extra_value=72%25", nil)
+	handler.GetDives(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"extra_data":{"suunto.battery":"72%"}`)
+	service.AssertExpectations(t)
+}
+
+func TestDiveHandlerGetDivesRejectsInvalidExtraKey(t *testing.T) {
+	service := new(mockDiveService)
+	handler := NewDiveHandler(service)
+
+	context, recorder := setupGinContext(http.MethodGet, "/dives?extra_key=bad%20key", nil)
+	handler.GetDives(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	service.AssertNotCalled(t, "GetDivesByExtraData", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDiveHandlerGetDiveSetsETag(t *testing.T) {
+	service := new(mockDiveService)
+	handler := NewDiveHandler(service)
+	service.On("GetDive", mock.Anything, 1, 7).Return(&models.Dive{ID: 7, Version: 3}, nil).Once()
+	context, recorder := setupGinContext(http.MethodGet, "/dives/7", nil)
+	context.Params = gin.Params{{Key: "id", Value: "7"}}
+
+	handler.GetDive(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `"3"`, recorder.Header().Get("ETag"))
+}
+
+func TestDiveHandlerUpdateDivePassesIfMatchVersion(t *testing.T) {
+	service := new(mockDiveService)
+	handler := NewDiveHandler(service)
+	request := validDiveRequest()
+	expected := 3
+	service.On("UpdateDive", mock.Anything, 7, 1, request, &expected).Return(&models.Dive{ID: 7, Version: 4}, nil).Once()
+	context, recorder := setupGinContext(http.MethodPut, "/dives/7", request)
+	context.Params = gin.Params{{Key: "id", Value: "7"}}
+	context.Request.Header.Set("If-Match", `"3"`)
+
+	handler.UpdateDive(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `"4"`, recorder.Header().Get("ETag"))
+	service.AssertExpectations(t)
+}
+
+func TestDiveHandlerUpdateDiveWithoutIfMatchIsUnconditional(t *testing.T) {
+	service := new(mockDiveService)
+	handler := NewDiveHandler(service)
+	request := validDiveRequest()
+	service.On("UpdateDive", mock.Anything, 7, 1, request, (*int)(nil)).Return(&models.Dive{ID: 7, Version: 2}, nil).Twice()
+
+	for _, header := range []string{"", "*"} {
+		context, recorder := setupGinContext(http.MethodPut, "/dives/7", request)
+		context.Params = gin.Params{{Key: "id", Value: "7"}}
+		context.Request.Header.Set("If-Match", header)
+
+		handler.UpdateDive(context)
+
+		assert.Equal(t, http.StatusOK, recorder.Code, "If-Match %q", header)
+	}
+	service.AssertExpectations(t)
+}
+
+func TestDiveHandlerUpdateDiveTreatsUnknownETagAsStale(t *testing.T) {
+	service := new(mockDiveService)
+	handler := NewDiveHandler(service)
+	request := validDiveRequest()
+	zero := 0
+	service.On("UpdateDive", mock.Anything, 7, 1, request, &zero).Return(nil, models.NewVersionConflict(5, &models.Dive{ID: 7, Version: 5}, nil, nil)).Once()
+	context, recorder := setupGinContext(http.MethodPut, "/dives/7", request)
+	context.Params = gin.Params{{Key: "id", Value: "7"}}
+	context.Request.Header.Set("If-Match", `W/"5"`)
+
+	handler.UpdateDive(context)
+
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+	service.AssertExpectations(t)
+}
+
+func TestDiveHandlerUpdateDiveReturnsConflictWithCurrentStateAndDiff(t *testing.T) {
+	service := new(mockDiveService)
+	handler := NewDiveHandler(service)
+	request := validDiveRequest()
+	current := &models.Dive{ID: 7, Location: "Monterey Bay", MaxDepth: 28, Version: 5}
+	conflict := &models.VersionConflict{Version: 5, Current: current, Diff: []models.FieldDiff{{Field: "depth", Server: 28.0, Client: 30.0}}}
+	service.On("UpdateDive", mock.Anything, 7, 1, request, mock.Anything).Return(nil, conflict).Once()
+	context, recorder := setupGinContext(http.MethodPut, "/dives/7", request)
+	context.Params = gin.Params{{Key: "id", Value: "7"}}
+	context.Request.Header.Set("If-Match", `"4"`)
+
+	handler.UpdateDive(context)
+
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+	assert.Equal(t, `"5"`, recorder.Header().Get("ETag"))
+	var response struct {
+		Error   string             `json:"error"`
+		Current models.Dive        `json:"current"`
+		Diff    []models.FieldDiff `json:"diff"`
+	}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.NotEmpty(t, response.Error)
+	assert.Equal(t, 5, response.Current.Version)
+	assert.Equal(t, 28.0, response.Current.MaxDepth)
+	assert.Equal(t, []models.FieldDiff{{Field: "depth", Server: 28.0, Client: 30.0}}, response.Diff)
+}
+
+func TestDiveHandlerUpdateDiveRejectsETagLists(t *testing.T) {
+	service := new(mockDiveService)
+	handler := NewDiveHandler(service)
+	context, recorder := setupGinContext(http.MethodPut, "/dives/7", validDiveRequest())
+	context.Params = gin.Params{{Key: "id", Value: "7"}}
+	context.Request.Header.Set("If-Match", `"4", "5"`)
+
+	handler.UpdateDive(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	service.AssertNotCalled(t, "UpdateDive", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
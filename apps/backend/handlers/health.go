@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"divelog-backend/utils"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds each dependency check so a hung database fails the
+// probe instead of stalling it.
+const readinessTimeout = 5 * time.Second
+
+// HealthHandler answers the liveness and readiness probes.
+type HealthHandler struct {
+	db         databasePinger
+	migrations migrationStatus
+	draining   atomic.Bool
+}
+
+func NewHealthHandler(db databasePinger, migrations migrationStatus) *HealthHandler {
+	return &HealthHandler{db: db, migrations: migrations}
+}
+
+// Drain makes the readiness probe fail from now on, so load balancers stop
+// sending new requests while in-flight ones finish.
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// Livez reports that the process is up. It checks no dependencies, so a
+// database outage never gets the server restarted.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "service": "divelog-backend"})
+}
+
+// Readyz reports whether the server should receive traffic: the database
+// answers, every migration is applied and shutdown has not started.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := gin.H{"database": "ok", "migrations": "ok"}
+	ready := true
+	if err := h.db.PingContext(ctx); err != nil {
+		utils.LogError(ctx, "Database readiness check failed", err,
+			slog.String("request_id", c.GetString("request_id")))
+		checks["database"] = "unhealthy"
+		ready = false
+	}
+	if pending, err := h.migrations.Pending(ctx); err != nil {
+		utils.LogError(ctx, "Migration readiness check failed", err,
+			slog.String("request_id", c.GetString("request_id")))
+		checks["migrations"] = "unknown"
+		ready = false
+	} else if len(pending) > 0 {
+		checks["migrations"] = fmt.Sprintf("%d pending", len(pending))
+		ready = false
+	}
+
+	status := "ready"
+	switch {
+	case h.draining.Load():
+		status = "draining"
+		ready = false
+	case !ready:
+		status = "not ready"
+	}
+
+	response := gin.H{"status": status, "service": "divelog-backend", "checks": checks}
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
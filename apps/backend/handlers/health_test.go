@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"divelog-backend/database"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockDatabasePinger struct {
+	mock.Mock
+}
+
+func (m *mockDatabasePinger) PingContext(ctx context.Context) error {
+	return m.Called(ctx).Error(0)
+}
+
+type mockMigrationStatus struct {
+	mock.Mock
+}
+
+func (m *mockMigrationStatus) Pending(ctx context.Context) ([]database.Migration, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]database.Migration), args.Error(1)
+}
+
+func readyzResponse(t *testing.T, handler *HealthHandler) (int, map[string]interface{}) {
+	context, recorder := setupGinContext(http.MethodGet, "/readyz", nil)
+	handler.Readyz(context)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	return recorder.Code, body
+}
+
+func TestHealthHandlerLivezChecksNoDependencies(t *testing.T) {
+	db := new(mockDatabasePinger)
+	migrations := new(mockMigrationStatus)
+	handler := NewHealthHandler(db, migrations)
+
+	context, recorder := setupGinContext(http.MethodGet, "/livez", nil)
+	handler.Livez(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"status":"ok","service":"divelog-backend"}`, recorder.Body.String())
+	db.AssertNotCalled(t, "PingContext", mock.Anything)
+}
+
+func TestHealthHandlerReadyz(t *testing.T) {
+	db := new(mockDatabasePinger)
+	migrations := new(mockMigrationStatus)
+	db.On("PingContext", mock.Anything).Return(nil)
+	migrations.On("Pending", mock.Anything).Return([]database.Migration{}, nil)
+
+	code, body := readyzResponse(t, NewHealthHandler(db, migrations))
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", body["status"])
+	assert.Equal(t, map[string]interface{}{"database": "ok", "migrations": "ok"}, body["checks"])
+}
+
+func TestHealthHandlerReadyzFailsWithPendingMigrations(t *testing.T) {
+	db := new(mockDatabasePinger)
+	migrations := new(mockMigrationStatus)
+	db.On("PingContext", mock.Anything).Return(nil)
+	migrations.On("Pending", mock.Anything).Return([]database.Migration{{Version: 13}, {Version: 14}}, nil)
+
+	code, body := readyzResponse(t, NewHealthHandler(db, migrations))
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ready", body["status"])
+	assert.Equal(t, map[string]interface{}{"database": "ok", "migrations": "2 pending"}, body["checks"])
+}
+
+func TestHealthHandlerReadyzFailsWhenDatabaseIsDown(t *testing.T) {
+	db := new(mockDatabasePinger)
+	migrations := new(mockMigrationStatus)
+	db.On("PingContext", mock.Anything).Return(errors.New("connection refused"))
+	migrations.On("Pending", mock.Anything).Return(nil, errors.New("connection refused"))
+
+	code, body := readyzResponse(t, NewHealthHandler(db, migrations))
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, map[string]interface{}{"database": "unhealthy", "migrations": "unknown"}, body["checks"])
+}
+
+func TestHealthHandlerReadyzFailsWhileDraining(t *testing.T) {
+	db := new(mockDatabasePinger)
+	migrations := new(mockMigrationStatus)
+	db.On("PingContext", mock.Anything).Return(nil)
+	migrations.On("Pending", mock.Anything).Return([]database.Migration{}, nil)
+	handler := NewHealthHandler(db, migrations)
+
+	handler.Drain()
+	code, body := readyzResponse(t, handler)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "draining", body["status"])
+}
@@ -4,6 +4,7 @@ import (
 	"divelog-backend/middleware"
 	"divelog-backend/models"
 	"divelog-backend/utils"
+	"errors"
 	"net/http"
 	"strings"
 
@@ -118,15 +119,20 @@ func (h *LogbookHandler) UpdateTrip(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	expectedVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
 	var request models.TripRequest
 	if !middleware.BindAndValidateJSON(c, &request) {
 		return
 	}
-	item, err := h.service.UpdateTrip(c.Request.Context(), userID, id, request)
+	item, err := h.service.UpdateTrip(c.Request.Context(), userID, id, request, expectedVersion)
 	if err != nil {
 		respondLogbookError(c, err)
 		return
 	}
+	setETag(c, item.Version)
 	c.JSON(http.StatusOK, item)
 }
 func (h *LogbookHandler) DeleteTrip(c *gin.Context) {
@@ -287,6 +293,11 @@ func (h *LogbookHandler) UndoBulkOperation(c *gin.Context) {
 }
 
 func respondLogbookError(c *gin.Context, err error) {
+	var conflict *models.VersionConflict
+	if errors.As(err, &conflict) {
+		respondVersionConflict(c, conflict)
+		return
+	}
 	switch err {
 	case utils.ErrTagNotFound, utils.ErrTripNotFound, utils.ErrDiveNotFound:
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
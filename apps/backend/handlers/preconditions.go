@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"divelog-backend/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setETag labels a response with the version of the record it carries.
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", `"`+strconv.Itoa(version)+`"`)
+}
+
+// ifMatchVersion reads the If-Match precondition of a write. A missing header
+// or "*" yields nil, so the write is unconditional. Otherwise the header must
+// hold exactly one ETag. A weak ETag, or one this API never issued, yields 0.
+// No record has version 0, so that write is rejected as a conflict and the
+// client still receives the current state.
+func ifMatchVersion(c *gin.Context) (*int, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+	if strings.Contains(header, ",") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "If-Match must name a single ETag"})
+		return nil, false
+	}
+	version := 0
+	if len(header) > 2 && strings.HasPrefix(header, `"`) && strings.HasSuffix(header, `"`) {
+		if parsed, err := strconv.Atoi(header[1 : len(header)-1]); err == nil && parsed > 0 {
+			version = parsed
+		}
+	}
+	return &version, true
+}
+
+// respondVersionConflict answers a write whose If-Match precondition failed.
+// The body carries the current record and a field-level diff against the
+// rejected write, and the ETag header names the version to retry against.
+func respondVersionConflict(c *gin.Context, conflict *models.VersionConflict) {
+	setETag(c, conflict.Version)
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error":   conflict.Error(),
+		"current": conflict.Current,
+		"diff":    conflict.Diff,
+	})
+}
@@ -2,32 +2,98 @@ package handlers
 
 import (
 	"context"
+	"divelog-backend/database"
 	"divelog-backend/models"
 	"divelog-backend/services"
+	"io"
 )
 
 type diveService interface {
 	GetDives(context.Context, int) ([]models.Dive, error)
+	GetDive(context.Context, int, int) (*models.Dive, error)
+	GetDivesByExtraData(context.Context, int, string, *string) ([]models.Dive, error)
 	CreateDive(context.Context, int, models.DiveRequest) (*models.Dive, error)
 	CreateMultipleDives(context.Context, int, []models.DiveRequest) (*services.BatchCreateResult, error)
-	UpdateDive(context.Context, int, int, models.DiveRequest) (*models.Dive, error)
+	UpdateDive(context.Context, int, int, models.DiveRequest, *int) (*models.Dive, error)
 	DeleteDive(context.Context, int, int) error
 	DeleteAllDives(context.Context, int) (int64, error)
 }
 
+type diveRevisionService interface {
+	List(context.Context, int, int) ([]models.DiveRevision, error)
+	Restore(context.Context, int, int, int) (*models.Dive, error)
+}
+
+type trashService interface {
+	List(context.Context, int, models.TrashFilter) ([]models.TrashItem, error)
+	Restore(context.Context, int, string, int) error
+	Purge(context.Context, int, string, int) error
+	Empty(context.Context, int) (*models.TrashPurgeResult, error)
+}
+
 type diveSiteService interface {
-	GetAll(context.Context) ([]models.DiveSite, error)
+	GetAll(context.Context, models.DiveSiteFilter) ([]models.DiveSite, error)
 	Search(context.Context, string) ([]models.DiveSite, error)
+	Nearby(context.Context, models.NearbyDiveSitesRequest) ([]models.NearbyDiveSite, error)
+	InBoundingBox(context.Context, models.BoundingBoxDiveSitesRequest) ([]models.DiveSite, error)
+	Clusters(context.Context, models.DiveSiteClustersRequest) ([]models.DiveSiteCluster, error)
+	Export(context.Context, int, models.DiveSiteExportRequest, io.Writer) error
+	Import(context.Context, *models.GeoJSONFeatureCollection) (*models.DiveSiteImportResult, error)
+	Merge(context.Context, int, models.MergeDiveSitesRequest) (*models.DiveSiteMergeResult, error)
+	Duplicates(context.Context, models.DiveSiteDuplicatesRequest) ([]models.DiveSiteDuplicateCandidate, error)
 	GetByID(context.Context, int) (*models.DiveSite, error)
+	GetDetail(context.Context, int, int) (*models.DiveSiteDetail, error)
+	CountryStatistics(context.Context, int) ([]models.CountryStatistics, error)
 	Create(context.Context, *models.DiveSiteRequest) (*models.DiveSite, error)
 	Update(context.Context, int, *models.DiveSiteRequest) (*models.DiveSite, error)
 	Delete(context.Context, int) error
 }
 
+type speciesService interface {
+	ListSpecies(context.Context, models.SpeciesFilter) ([]models.Species, error)
+	CreateSpecies(context.Context, models.SpeciesRequest) (*models.Species, error)
+	GetDiveSightings(context.Context, int, int) ([]models.Sighting, error)
+	ReplaceDiveSightings(context.Context, int, int, models.DiveSightingsRequest) ([]models.Sighting, error)
+	Sightings(context.Context, int, int) (*models.SpeciesSightings, error)
+	LifeList(context.Context, int, models.SpeciesFilter) ([]models.LifeListEntry, error)
+}
+
+type certificationService interface {
+	List(context.Context, int) ([]models.Certification, error)
+	Get(context.Context, int, int) (*models.Certification, error)
+	Create(context.Context, int, models.CertificationRequest) (*models.Certification, error)
+	Update(context.Context, int, int, models.CertificationRequest) (*models.Certification, error)
+	Delete(context.Context, int, int) error
+	PutCardImage(context.Context, int, int, io.Reader) (*models.Certification, error)
+	OpenCardImage(context.Context, int, int) (io.ReadCloser, string, error)
+	DeleteCardImage(context.Context, int, int) error
+	Progress(context.Context, int, int) (*models.CertificationProgress, error)
+}
+
+type webhookService interface {
+	List(context.Context, int) ([]models.WebhookSubscription, error)
+	Get(context.Context, int, int) (*models.WebhookSubscription, error)
+	Create(context.Context, int, models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error)
+	Update(context.Context, int, int, models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error)
+	RotateSecret(context.Context, int, int) (*models.WebhookSubscription, error)
+	Delete(context.Context, int, int) error
+	Deliveries(context.Context, int, int, models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+}
+
+type apiTokenService interface {
+	List(context.Context, int) ([]models.APIToken, error)
+	Create(context.Context, int, models.APITokenRequest) (*models.APIToken, error)
+	Delete(context.Context, int, int) error
+}
+
+type auditLogRepository interface {
+	ListEntries(context.Context, int, models.AuditLogFilter) ([]models.AuditEntry, error)
+}
+
 type settingsRepository interface {
 	GetOrCreateDefault(context.Context, int) (*models.UserSettings, error)
 	GetByUserID(context.Context, int) (*models.UserSettings, error)
-	Update(context.Context, *models.UserSettings) error
+	Update(context.Context, *models.UserSettings, *int) error
 }
 
 type logbookService interface {
@@ -37,7 +103,7 @@ type logbookService interface {
 	DeleteTag(context.Context, int, int) error
 	GetTrips(context.Context, int) ([]models.Trip, error)
 	CreateTrip(context.Context, int, models.TripRequest) (*models.Trip, error)
-	UpdateTrip(context.Context, int, int, models.TripRequest) (*models.Trip, error)
+	UpdateTrip(context.Context, int, int, models.TripRequest, *int) (*models.Trip, error)
 	DeleteTrip(context.Context, int, int) error
 	MergeTrips(context.Context, int, int, models.MergeTripsRequest) error
 	SplitTrip(context.Context, int, int, models.SplitTripRequest) (*models.Trip, error)
@@ -48,3 +114,24 @@ type logbookService interface {
 	LatestUndoableOperation(context.Context, int) (*models.BulkOperation, error)
 	UndoBulkOperation(context.Context, int, string) (*models.BulkOperation, error)
 }
+
+type backupService interface {
+	Export(context.Context, int, services.BackupSink) error
+	Restore(context.Context, int, *models.BackupArchive, models.RestoreOptions) (*models.RestoreSummary, error)
+}
+
+type searchService interface {
+	Search(context.Context, int, models.SearchRequest) (*models.SearchResults, error)
+}
+
+type syncService interface {
+	Changes(context.Context, int, models.SyncRequest) (*models.SyncResponse, error)
+}
+
+type databasePinger interface {
+	PingContext(context.Context) error
+}
+
+type migrationStatus interface {
+	Pending(context.Context) ([]database.Migration, error)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"divelog-backend/middleware"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	service searchService
+}
+
+func NewSearchHandler(service searchService) *SearchHandler {
+	return &SearchHandler{service: service}
+}
+
+// Search runs a full-text query over dives, dive sites and trips.
+func (h *SearchHandler) Search(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	request := models.SearchRequest{Query: c.Query("q")}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			middleware.RespondValidationErrors(c, utils.ValidationErrors{"limit": "must be an integer"})
+			return
+		}
+		request.Limit = limit
+	}
+	if !middleware.ValidateRequest(c, &request) {
+		return
+	}
+
+	results, err := h.service.Search(c.Request.Context(), userID, request)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Error searching logbook", err, utils.UserID(userID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search"})
+		return
+	}
+	c.JSON(http.StatusOK, results)
+}
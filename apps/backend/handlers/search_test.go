@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"divelog-backend/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSearchService struct {
+	mock.Mock
+}
+
+func (m *mockSearchService) Search(ctx context.Context, userID int, request models.SearchRequest) (*models.SearchResults, error) {
+	args := m.Called(ctx, userID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SearchResults), args.Error(1)
+}
+
+func TestSearchHandlerReturnsGroupedHits(t *testing.T) {
+	service := new(mockSearchService)
+	handler := NewSearchHandler(service)
+	service.On("Search", mock.Anything, 1, models.SearchRequest{Query: "manta ray", Limit: 5}).Return(&models.SearchResults{
+		Query:     "manta ray",
+		Dives:     []models.SearchHit{{ID: 3, Title: "Manta Point", Headline: "<mark>manta</mark>"}},
+		DiveSites: []models.SearchHit{},
+		Trips:     []models.SearchHit{},
+	}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/search?q=+manta+ray+&limit=5", nil)
+	handler.Search(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"dives":[{"id":3,"title":"Manta Point"`)
+	service.AssertExpectations(t)
+}
+
+func TestSearchHandlerRequiresQuery(t *testing.T) {
+	service := new(mockSearchService)
+	handler := NewSearchHandler(service)
+
+	context, recorder := setupGinContext(http.MethodGet, "/search?q=&limit=500", nil)
+	handler.Search(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"q"`)
+	assert.Contains(t, recorder.Body.String(), `"limit"`)
+	service.AssertNotCalled(t, "Search", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -36,6 +36,7 @@ func (h *SettingsHandler) GetSettings(c *gin.Context) {
 		return
 	}
 
+	setETag(c, settings.Version)
 	c.JSON(http.StatusOK, settings.ToFrontendFormat())
 }
 
@@ -48,6 +49,11 @@ func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
 		return
 	}
 
+	expectedVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	var req models.SettingsRequest
 	if !middleware.BindAndValidateJSON(c, &req) {
 		return
@@ -55,7 +61,17 @@ func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
 
 	settings := req.ToUserSettings(userID)
 
-	err = h.settingsRepo.Update(c.Request.Context(), settings)
+	err = h.settingsRepo.Update(c.Request.Context(), settings, expectedVersion)
+	if err == utils.ErrVersionConflict {
+		current, err := h.settingsRepo.GetByUserID(c.Request.Context(), userID)
+		if err != nil {
+			utils.LogError(c.Request.Context(), "Error retrieving conflicting settings for user", err, utils.UserID(userID))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve settings"})
+			return
+		}
+		respondVersionConflict(c, models.NewVersionConflict(current.Version, current.ToFrontendFormat(), current.ToRequest(), req))
+		return
+	}
 	if err != nil {
 		utils.LogError(c.Request.Context(), "Error updating settings for user", err, utils.UserID(userID))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
@@ -70,5 +86,6 @@ func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
 		return
 	}
 
+	setETag(c, updatedSettings.Version)
 	c.JSON(http.StatusOK, updatedSettings.ToFrontendFormat())
 }
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"divelog-backend/models"
+	"divelog-backend/utils"
 	"encoding/json"
 	"net/http"
 	"testing"
@@ -31,8 +32,8 @@ func (m *mockSettingsRepository) GetByUserID(ctx context.Context, userID int) (*
 	return args.Get(0).(*models.UserSettings), args.Error(1)
 }
 
-func (m *mockSettingsRepository) Update(ctx context.Context, settings *models.UserSettings) error {
-	return m.Called(ctx, settings).Error(0)
+func (m *mockSettingsRepository) Update(ctx context.Context, settings *models.UserSettings, expectedVersion *int) error {
+	return m.Called(ctx, settings, expectedVersion).Error(0)
 }
 
 func validSettingsRequest() models.SettingsRequest {
@@ -57,7 +58,7 @@ func TestSettingsHandlerUpdateSettings(t *testing.T) {
 	handler := NewSettingsHandler(repository)
 	request := validSettingsRequest()
 	updated := request.ToUserSettings(1)
-	repository.On("Update", mock.Anything, mock.AnythingOfType("*models.UserSettings")).Return(nil)
+	repository.On("Update", mock.Anything, mock.AnythingOfType("*models.UserSettings"), (*int)(nil)).Return(nil)
 	repository.On("GetByUserID", mock.Anything, 1).Return(updated, nil)
 
 	context, recorder := setupGinContext(http.MethodPut, "/settings?user_id=1", request)
@@ -88,7 +89,7 @@ func TestSettingsHandlerUpdateReturnsFieldErrors(t *testing.T) {
 	assert.Contains(t, response.Fields, "unitPreference")
 	assert.Contains(t, response.Fields, "units.depth")
 	assert.Contains(t, response.Fields, "dive.maxDepthWarning")
-	repository.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	repository.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestSettingsHandlerUpdateRejectsMalformedJSON(t *testing.T) {
@@ -101,3 +102,46 @@ func TestSettingsHandlerUpdateRejectsMalformedJSON(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, recorder.Code)
 	assert.JSONEq(t, `{"error":"invalid_request","message":"Request body must contain valid JSON"}`, recorder.Body.String())
 }
+
+func TestSettingsHandlerGetSettingsSetsETag(t *testing.T) {
+	repository := new(mockSettingsRepository)
+	handler := NewSettingsHandler(repository)
+	settings := validSettingsRequest()
+	stored := settings.ToUserSettings(1)
+	stored.Version = 6
+	repository.On("GetOrCreateDefault", mock.Anything, 1).Return(stored, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/settings?user_id=1", nil)
+	handler.GetSettings(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `"6"`, recorder.Header().Get("ETag"))
+}
+
+func TestSettingsHandlerUpdateReturnsConflictForStaleVersion(t *testing.T) {
+	repository := new(mockSettingsRepository)
+	handler := NewSettingsHandler(repository)
+	request := validSettingsRequest()
+	request.Units.Depth = "feet"
+	storedRequest := validSettingsRequest()
+	stored := storedRequest.ToUserSettings(1)
+	stored.Version = 3
+	expected := 2
+	repository.On("Update", mock.Anything, mock.AnythingOfType("*models.UserSettings"), &expected).Return(utils.ErrVersionConflict).Once()
+	repository.On("GetByUserID", mock.Anything, 1).Return(stored, nil).Once()
+
+	context, recorder := setupGinContext(http.MethodPut, "/settings?user_id=1", request)
+	context.Request.Header.Set("If-Match", `"2"`)
+	handler.UpdateSettings(context)
+
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+	assert.Equal(t, `"3"`, recorder.Header().Get("ETag"))
+	var response struct {
+		Current map[string]interface{} `json:"current"`
+		Diff    []models.FieldDiff     `json:"diff"`
+	}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "meters", response.Current["units"].(map[string]interface{})["depth"])
+	assert.Equal(t, []models.FieldDiff{{Field: "units.depth", Server: "meters", Client: "feet"}}, response.Diff)
+	repository.AssertExpectations(t)
+}
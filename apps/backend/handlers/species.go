@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"divelog-backend/middleware"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SpeciesHandler struct {
+	service speciesService
+}
+
+func NewSpeciesHandler(service speciesService) *SpeciesHandler {
+	return &SpeciesHandler{service: service}
+}
+
+// ListSpecies returns the shared species catalog, optionally filtered by name
+// and category.
+func (h *SpeciesHandler) ListSpecies(c *gin.Context) {
+	var filter models.SpeciesFilter
+	if !middleware.BindAndValidateQuery(c, &filter) {
+		return
+	}
+	catalog, err := h.service.ListSpecies(c.Request.Context(), filter)
+	if err != nil {
+		respondSpeciesError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, catalog)
+}
+
+// CreateSpecies adds a species to the shared catalog.
+func (h *SpeciesHandler) CreateSpecies(c *gin.Context) {
+	var request models.SpeciesRequest
+	if !middleware.BindAndValidateJSON(c, &request) {
+		return
+	}
+	species, err := h.service.CreateSpecies(c.Request.Context(), request)
+	if err != nil {
+		respondSpeciesError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, species)
+}
+
+// GetDiveSightings returns the species logged on a dive.
+func (h *SpeciesHandler) GetDiveSightings(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	diveID, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	sightings, err := h.service.GetDiveSightings(c.Request.Context(), userID, diveID)
+	if err != nil {
+		respondSpeciesError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sightings)
+}
+
+// ReplaceDiveSightings sets the complete list of species logged on a dive.
+func (h *SpeciesHandler) ReplaceDiveSightings(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	diveID, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	var request models.DiveSightingsRequest
+	if !middleware.BindAndValidateJSON(c, &request) {
+		return
+	}
+	sightings, err := h.service.ReplaceDiveSightings(c.Request.Context(), userID, diveID, request)
+	if err != nil {
+		respondSpeciesError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sightings)
+}
+
+// GetSpeciesSightings reports the sites where the user has seen a species.
+func (h *SpeciesHandler) GetSpeciesSightings(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	speciesID, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	report, err := h.service.Sightings(c.Request.Context(), userID, speciesID)
+	if err != nil {
+		respondSpeciesError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetLifeList returns every species the user has logged.
+func (h *SpeciesHandler) GetLifeList(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	var filter models.SpeciesFilter
+	if !middleware.BindAndValidateQuery(c, &filter) {
+		return
+	}
+	entries, err := h.service.LifeList(c.Request.Context(), userID, filter)
+	if err != nil {
+		respondSpeciesError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func respondSpeciesError(c *gin.Context, err error) {
+	switch err {
+	case utils.ErrSpeciesNotFound, utils.ErrDiveNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case utils.ErrDuplicateSpecies:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		utils.LogError(c.Request.Context(), "Species operation failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Species operation failed"})
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSpeciesService struct {
+	mock.Mock
+}
+
+func (m *mockSpeciesService) ListSpecies(ctx context.Context, filter models.SpeciesFilter) ([]models.Species, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]models.Species), args.Error(1)
+}
+func (m *mockSpeciesService) CreateSpecies(ctx context.Context, request models.SpeciesRequest) (*models.Species, error) {
+	args := m.Called(ctx, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Species), args.Error(1)
+}
+func (m *mockSpeciesService) GetDiveSightings(ctx context.Context, userID, diveID int) ([]models.Sighting, error) {
+	args := m.Called(ctx, userID, diveID)
+	return args.Get(0).([]models.Sighting), args.Error(1)
+}
+func (m *mockSpeciesService) ReplaceDiveSightings(ctx context.Context, userID, diveID int, request models.DiveSightingsRequest) ([]models.Sighting, error) {
+	args := m.Called(ctx, userID, diveID, request)
+	return args.Get(0).([]models.Sighting), args.Error(1)
+}
+func (m *mockSpeciesService) Sightings(ctx context.Context, userID, speciesID int) (*models.SpeciesSightings, error) {
+	args := m.Called(ctx, userID, speciesID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SpeciesSightings), args.Error(1)
+}
+func (m *mockSpeciesService) LifeList(ctx context.Context, userID int, filter models.SpeciesFilter) ([]models.LifeListEntry, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).([]models.LifeListEntry), args.Error(1)
+}
+
+func TestSpeciesHandlerReplaceSightingsDefaultsCount(t *testing.T) {
+	service := new(mockSpeciesService)
+	handler := NewSpeciesHandler(service)
+	notes := "cleaning station"
+	expected := models.DiveSightingsRequest{Sightings: []models.SightingRequest{
+		{SpeciesID: 17, Count: 4, Notes: &notes},
+		{SpeciesID: 23, Count: 1},
+	}}
+	service.On("ReplaceDiveSightings", mock.Anything, 1, 9, expected).Return([]models.Sighting{}, nil)
+
+	context, recorder := setupGinContext(http.MethodPut, "/dives/9/sightings", map[string]interface{}{
+		"sightings": []map[string]interface{}{
+			{"species_id": 17, "count": 4, "notes": notes},
+			{"species_id": 23},
+		},
+	})
+	context.Params = gin.Params{{Key: "id", Value: "9"}}
+	handler.ReplaceDiveSightings(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	service.AssertExpectations(t)
+}
+
+func TestSpeciesHandlerReplaceSightingsRejectsDuplicates(t *testing.T) {
+	service := new(mockSpeciesService)
+	handler := NewSpeciesHandler(service)
+
+	context, recorder := setupGinContext(http.MethodPut, "/dives/9/sightings", map[string]interface{}{
+		"sightings": []map[string]interface{}{{"species_id": 17}, {"species_id": 17, "count": -2}},
+	})
+	context.Params = gin.Params{{Key: "id", Value: "9"}}
+	handler.ReplaceDiveSightings(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"sightings[1].species_id"`)
+	assert.Contains(t, recorder.Body.String(), `"sightings[1].count"`)
+	service.AssertNotCalled(t, "ReplaceDiveSightings", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSpeciesHandlerSightingsReturnsNotFound(t *testing.T) {
+	service := new(mockSpeciesService)
+	handler := NewSpeciesHandler(service)
+	service.On("Sightings", mock.Anything, 1, 999).Return(nil, utils.ErrSpeciesNotFound)
+
+	context, recorder := setupGinContext(http.MethodGet, "/species/999/sightings", nil)
+	context.Params = gin.Params{{Key: "id", Value: "999"}}
+	handler.GetSpeciesSightings(context)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestSpeciesHandlerLifeListBindsCategory(t *testing.T) {
+	service := new(mockSpeciesService)
+	handler := NewSpeciesHandler(service)
+	service.On("LifeList", mock.Anything, 1, models.SpeciesFilter{Category: "shark"}).Return([]models.LifeListEntry{}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/species/life-list?category=shark", nil)
+	handler.GetLifeList(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `[]`, recorder.Body.String())
+	service.AssertExpectations(t)
+}
+
+func TestSpeciesHandlerCreateReportsDuplicate(t *testing.T) {
+	service := new(mockSpeciesService)
+	handler := NewSpeciesHandler(service)
+	service.On("CreateSpecies", mock.Anything, models.SpeciesRequest{CommonName: "Garibaldi", Category: "fish"}).
+		Return(nil, utils.ErrDuplicateSpecies)
+
+	context, recorder := setupGinContext(http.MethodPost, "/species", map[string]interface{}{
+		"common_name": " Garibaldi ", "category": "fish",
+	})
+	handler.CreateSpecies(context)
+
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"divelog-backend/middleware"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SyncHandler struct {
+	service syncService
+}
+
+func NewSyncHandler(service syncService) *SyncHandler {
+	return &SyncHandler{service: service}
+}
+
+// GetChanges returns the changes after the since cursor. A cursor the server
+// has never issued, for example after a database restore, answers 410 so the
+// client discards its copy and syncs again from 0.
+func (h *SyncHandler) GetChanges(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	var request models.SyncRequest
+	if !middleware.BindAndValidateQuery(c, &request) {
+		return
+	}
+	response, err := h.service.Changes(c.Request.Context(), userID, request)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, response)
+	case utils.ErrSyncCursorUnknown:
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	default:
+		utils.LogError(c.Request.Context(), "Sync failed", err, utils.UserID(userID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read changes"})
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSyncService struct {
+	mock.Mock
+}
+
+func (m *mockSyncService) Changes(ctx context.Context, userID int, request models.SyncRequest) (*models.SyncResponse, error) {
+	args := m.Called(ctx, userID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SyncResponse), args.Error(1)
+}
+
+func TestSyncHandlerReturnsChangesAfterCursor(t *testing.T) {
+	service := new(mockSyncService)
+	handler := NewSyncHandler(service)
+	service.On("Changes", mock.Anything, 1, models.SyncRequest{Since: 42, Limit: 500}).Return(&models.SyncResponse{
+		Changes: []models.SyncChange{{Seq: 43, EntityType: models.SyncEntityDive, EntityID: 7, Operation: models.SyncOperationDelete}},
+		Cursor:  43,
+	}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/sync?since=42", nil)
+	handler.GetChanges(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"cursor":43`)
+	assert.Contains(t, recorder.Body.String(), `"operation":"delete"`)
+	assert.NotContains(t, recorder.Body.String(), `"data"`)
+	service.AssertExpectations(t)
+}
+
+func TestSyncHandlerReportsUnknownCursorAsGone(t *testing.T) {
+	service := new(mockSyncService)
+	handler := NewSyncHandler(service)
+	service.On("Changes", mock.Anything, 1, models.SyncRequest{Since: 900, Limit: 500}).Return(nil, utils.ErrSyncCursorUnknown)
+
+	context, recorder := setupGinContext(http.MethodGet, "/sync?since=900", nil)
+	handler.GetChanges(context)
+
+	assert.Equal(t, http.StatusGone, recorder.Code)
+}
+
+func TestSyncHandlerValidatesQuery(t *testing.T) {
+	service := new(mockSyncService)
+	handler := NewSyncHandler(service)
+
+	context, recorder := setupGinContext(http.MethodGet, "/sync?since=-1&limit=5000", nil)
+	handler.GetChanges(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"since"`)
+	assert.Contains(t, recorder.Body.String(), `"limit"`)
+	service.AssertNotCalled(t, "Changes", mock.Anything, mock.Anything, mock.Anything)
+}
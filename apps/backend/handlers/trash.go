@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"divelog-backend/middleware"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashHandler struct {
+	service trashService
+}
+
+func NewTrashHandler(service trashService) *TrashHandler {
+	return &TrashHandler{service: service}
+}
+
+// GetTrash lists the user's deleted dives, trips, tags and dive sites, most
+// recently deleted first, optionally of one type.
+func (h *TrashHandler) GetTrash(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	var filter models.TrashFilter
+	if !middleware.BindAndValidateQuery(c, &filter) {
+		return
+	}
+	items, err := h.service.List(c.Request.Context(), userID, filter)
+	if err != nil {
+		respondTrashError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// RestoreTrashItem takes the :type item :id out of the trash.
+func (h *TrashHandler) RestoreTrashItem(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	itemType, id, ok := trashItemParams(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Restore(c.Request.Context(), userID, itemType, id); err != nil {
+		respondTrashError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// PurgeTrashItem permanently deletes the :type item :id from the trash.
+func (h *TrashHandler) PurgeTrashItem(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	itemType, id, ok := trashItemParams(c)
+	if !ok {
+		return
+	}
+	if err := h.service.Purge(c.Request.Context(), userID, itemType, id); err != nil {
+		respondTrashError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// EmptyTrash permanently deletes everything in the user's trash.
+func (h *TrashHandler) EmptyTrash(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	result, err := h.service.Empty(c.Request.Context(), userID)
+	if err != nil {
+		respondTrashError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// trashItemParams reads and validates the :type and :id of a trash item route.
+func trashItemParams(c *gin.Context) (string, int, bool) {
+	itemType := c.Param("type")
+	fields := utils.ValidationErrors{}
+	utils.OneOf(fields, "type", itemType, models.TrashItemTypes...)
+	if !middleware.RespondValidationErrors(c, fields) {
+		return "", 0, false
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return "", 0, false
+	}
+	return itemType, id, true
+}
+
+func respondTrashError(c *gin.Context, err error) {
+	switch err {
+	case utils.ErrTrashItemNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case utils.ErrDuplicateDive:
+		c.JSON(http.StatusConflict, gin.H{"error": "Restoring this dive would duplicate another dive at the same date and location"})
+	case utils.ErrOrganizationConflict:
+		c.JSON(http.StatusConflict, gin.H{"error": "Another trip or tag already has this name"})
+	default:
+		utils.LogError(c.Request.Context(), "Trash operation failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Trash operation failed"})
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockTrashService struct {
+	mock.Mock
+}
+
+func (m *mockTrashService) List(ctx context.Context, userID int, filter models.TrashFilter) ([]models.TrashItem, error) {
+	args := m.Called(ctx, userID, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.TrashItem), args.Error(1)
+}
+func (m *mockTrashService) Restore(ctx context.Context, userID int, itemType string, id int) error {
+	return m.Called(ctx, userID, itemType, id).Error(0)
+}
+func (m *mockTrashService) Purge(ctx context.Context, userID int, itemType string, id int) error {
+	return m.Called(ctx, userID, itemType, id).Error(0)
+}
+func (m *mockTrashService) Empty(ctx context.Context, userID int) (*models.TrashPurgeResult, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TrashPurgeResult), args.Error(1)
+}
+
+func TestTrashHandlerListsItemsOfType(t *testing.T) {
+	service := new(mockTrashService)
+	deletedAt := time.Date(2026, time.October, 1, 8, 0, 0, 0, time.UTC)
+	service.On("List", mock.Anything, 1, models.TrashFilter{Type: "tag"}).Return([]models.TrashItem{{
+		Type: "tag", ID: 5, Name: "Night", DeletedAt: deletedAt, PurgeAt: deletedAt.AddDate(0, 0, 30),
+	}}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/trash?type=tag", nil)
+	NewTrashHandler(service).GetTrash(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"purge_at":"2026-10-31T08:00:00Z"`)
+	service.AssertExpectations(t)
+}
+
+func TestTrashHandlerRejectsUnknownType(t *testing.T) {
+	service := new(mockTrashService)
+
+	context, recorder := setupGinContext(http.MethodGet, "/trash?type=certification", nil)
+	NewTrashHandler(service).GetTrash(context)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	context, recorder = setupGinContext(http.MethodPost, "/trash/certification/3/restore", nil)
+	context.Params = gin.Params{{Key: "type", Value: "certification"}, {Key: "id", Value: "3"}}
+	NewTrashHandler(service).RestoreTrashItem(context)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	service.AssertNotCalled(t, "List", mock.Anything, mock.Anything, mock.Anything)
+	service.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTrashHandlerRestoreMapsErrors(t *testing.T) {
+	for err, want := range map[error]int{
+		nil:                           http.StatusNoContent,
+		utils.ErrTrashItemNotFound:    http.StatusNotFound,
+		utils.ErrDuplicateDive:        http.StatusConflict,
+		utils.ErrOrganizationConflict: http.StatusConflict,
+		utils.ErrDatabaseError:        http.StatusInternalServerError,
+	} {
+		service := new(mockTrashService)
+		service.On("Restore", mock.Anything, 1, "dive", 7).Return(err)
+
+		context, _ := setupGinContext(http.MethodPost, "/trash/dive/7/restore", nil)
+		context.Params = gin.Params{{Key: "type", Value: "dive"}, {Key: "id", Value: "7"}}
+		NewTrashHandler(service).RestoreTrashItem(context)
+
+		// Gin writes a bodyless status only when the response is flushed
+		assert.Equal(t, want, context.Writer.Status(), "%v", err)
+	}
+}
+
+func TestTrashHandlerPurgesItem(t *testing.T) {
+	service := new(mockTrashService)
+	service.On("Purge", mock.Anything, 1, "dive_site", 9).Return(nil).Once()
+
+	context, _ := setupGinContext(http.MethodDelete, "/trash/dive_site/9", nil)
+	context.Params = gin.Params{{Key: "type", Value: "dive_site"}, {Key: "id", Value: "9"}}
+	NewTrashHandler(service).PurgeTrashItem(context)
+
+	assert.Equal(t, http.StatusNoContent, context.Writer.Status())
+	service.AssertExpectations(t)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"divelog-backend/middleware"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	service webhookService
+}
+
+func NewWebhookHandler(service webhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	subscriptions, err := h.service.List(c.Request.Context(), userID)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	subscription, err := h.service.Get(c.Request.Context(), userID, id)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// CreateWebhook subscribes a URL to events. The response carries the signing
+// secret, which is not shown again.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	var request models.WebhookSubscriptionRequest
+	if !middleware.BindAndValidateJSON(c, &request) {
+		return
+	}
+	subscription, err := h.service.Create(c.Request.Context(), userID, request)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, subscription)
+}
+
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	var request models.WebhookSubscriptionRequest
+	if !middleware.BindAndValidateJSON(c, &request) {
+		return
+	}
+	subscription, err := h.service.Update(c.Request.Context(), userID, id, request)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// RotateWebhookSecret issues a new signing secret and returns it once.
+func (h *WebhookHandler) RotateWebhookSecret(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	subscription, err := h.service.RotateSecret(c.Request.Context(), userID, id)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	if err := h.service.Delete(c.Request.Context(), userID, id); err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetDeliveries returns the subscription's delivery log, optionally filtered
+// by status.
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	userID, ok := middleware.RequireUserID(c)
+	if !ok {
+		return
+	}
+	id, err := utils.ValidateIDParam(c, "id")
+	if err != nil {
+		return
+	}
+	var filter models.WebhookDeliveryFilter
+	if !middleware.BindAndValidateQuery(c, &filter) {
+		return
+	}
+	deliveries, err := h.service.Deliveries(c.Request.Context(), userID, id, filter)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func respondWebhookError(c *gin.Context, err error) {
+	switch err {
+	case utils.ErrWebhookNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case utils.ErrWebhookURLNotAllowed:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		utils.LogError(c.Request.Context(), "Webhook operation failed", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Webhook operation failed"})
+	}
+}
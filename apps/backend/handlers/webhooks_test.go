@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockWebhookService struct {
+	mock.Mock
+}
+
+func (m *mockWebhookService) List(ctx context.Context, userID int) ([]models.WebhookSubscription, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.WebhookSubscription), args.Error(1)
+}
+func (m *mockWebhookService) Get(ctx context.Context, userID, id int) (*models.WebhookSubscription, error) {
+	args := m.Called(ctx, userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSubscription), args.Error(1)
+}
+func (m *mockWebhookService) Create(ctx context.Context, userID int, request models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	args := m.Called(ctx, userID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSubscription), args.Error(1)
+}
+func (m *mockWebhookService) Update(ctx context.Context, userID, id int, request models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	args := m.Called(ctx, userID, id, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSubscription), args.Error(1)
+}
+func (m *mockWebhookService) RotateSecret(ctx context.Context, userID, id int) (*models.WebhookSubscription, error) {
+	args := m.Called(ctx, userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSubscription), args.Error(1)
+}
+func (m *mockWebhookService) Delete(ctx context.Context, userID, id int) error {
+	return m.Called(ctx, userID, id).Error(0)
+}
+func (m *mockWebhookService) Deliveries(ctx context.Context, userID, id int, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	args := m.Called(ctx, userID, id, filter)
+	return args.Get(0).([]models.WebhookDelivery), args.Error(1)
+}
+
+func TestWebhookHandlerCreateReturnsSecret(t *testing.T) {
+	service := new(mockWebhookService)
+	handler := NewWebhookHandler(service)
+	active := true
+	request := models.WebhookSubscriptionRequest{
+		URL: "https://example.com/hooks", Events: []string{models.WebhookEventDiveCreated}, Active: &active,
+	}
+	service.On("Create", mock.Anything, 1, request).
+		Return(&models.WebhookSubscription{ID: 3, URL: request.URL, Events: request.Events, Active: true, Secret: "whsec_abc"}, nil)
+
+	context, recorder := setupGinContext(http.MethodPost, "/webhooks", map[string]interface{}{
+		"url": " https://example.com/hooks ", "events": []string{"dive.created"},
+	})
+	handler.CreateWebhook(context)
+
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"secret":"whsec_abc"`)
+	service.AssertExpectations(t)
+}
+
+func TestWebhookHandlerCreateValidatesRequest(t *testing.T) {
+	service := new(mockWebhookService)
+	handler := NewWebhookHandler(service)
+
+	context, recorder := setupGinContext(http.MethodPost, "/webhooks", map[string]interface{}{
+		"url": "ftp://example.com", "events": []string{"dive.created", "dive.exploded"},
+	})
+	handler.CreateWebhook(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"url"`)
+	assert.Contains(t, recorder.Body.String(), `"events[1]"`)
+	service.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWebhookHandlerCreateRejectsPrivateURL(t *testing.T) {
+	service := new(mockWebhookService)
+	service.On("Create", mock.Anything, 1, mock.Anything).Return(nil, utils.ErrWebhookURLNotAllowed)
+
+	context, recorder := setupGinContext(http.MethodPost, "/webhooks", map[string]interface{}{
+		"url": "https://internal.example.com/hooks", "events": []string{"dive.created"},
+	})
+	NewWebhookHandler(service).CreateWebhook(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), utils.ErrWebhookURLNotAllowed.Error())
+}
+
+func TestWebhookHandlerGetReportsMissingSubscription(t *testing.T) {
+	service := new(mockWebhookService)
+	handler := NewWebhookHandler(service)
+	service.On("Get", mock.Anything, 1, 9).Return(nil, utils.ErrWebhookNotFound)
+
+	context, recorder := setupGinContext(http.MethodGet, "/webhooks/9", nil)
+	context.Params = gin.Params{{Key: "id", Value: "9"}}
+	handler.GetWebhook(context)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestWebhookHandlerGetDeliveriesAppliesFilter(t *testing.T) {
+	service := new(mockWebhookService)
+	handler := NewWebhookHandler(service)
+	service.On("Deliveries", mock.Anything, 1, 3, models.WebhookDeliveryFilter{Status: "failed", Limit: 50}).
+		Return([]models.WebhookDelivery{{ID: 11, SubscriptionID: 3, Status: "failed", Payload: []byte(`{"id":"evt_1"}`)}}, nil)
+
+	context, recorder := setupGinContext(http.MethodGet, "/webhooks/3/deliveries?status=failed", nil)
+	context.Params = gin.Params{{Key: "id", Value: "3"}}
+	handler.GetDeliveries(context)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"payload":{"id":"evt_1"}`)
+	service.AssertExpectations(t)
+}
+
+func TestWebhookHandlerGetDeliveriesRejectsUnknownStatus(t *testing.T) {
+	service := new(mockWebhookService)
+	handler := NewWebhookHandler(service)
+
+	context, recorder := setupGinContext(http.MethodGet, "/webhooks/3/deliveries?status=lost", nil)
+	context.Params = gin.Params{{Key: "id", Value: "3"}}
+	handler.GetDeliveries(context)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"status"`)
+}
@@ -4,117 +4,275 @@ import (
 	"context"
 	"divelog-backend/config"
 	"divelog-backend/database"
+	"divelog-backend/geocoding"
 	"divelog-backend/handlers"
+	"divelog-backend/media"
+	"divelog-backend/metrics"
 	"divelog-backend/middleware"
+	"divelog-backend/models"
+	"divelog-backend/ratelimit"
 	"divelog-backend/repository"
 	"divelog-backend/services"
+	"divelog-backend/tracing"
 	"divelog-backend/utils"
 	"log"
 	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run starts the server, or runs a command, and returns the exit code. Keeping
+// os.Exit out of it lets the deferred trace flush, database close and rate
+// limiter close run on every return, failures included.
+func run() int {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatal("Failed to load configuration:", err)
+		log.Println("Failed to load configuration:", err)
+		return 1
 	}
 
 	// Initialize structured logging
-	utils.InitLogger(cfg.GinMode)
+	utils.InitLogger(cfg.Server.GinMode)
+	utils.LogInfo(nil, "Configuration loaded", slog.Any("config", cfg))
+
+	// Tracing is off unless OTEL_TRACES_EXPORTER names an exporter
+	traceProvider, err := newTraceProvider(cfg)
+	if err != nil {
+		utils.LogError(nil, "Tracing initialization failed", err)
+		return 1
+	}
+	if traceProvider != nil {
+		tracing.SetProvider(traceProvider)
+		defer traceProvider.Shutdown(context.Background())
+	}
 
 	// Initialize database with improved connection pooling
-	if err := database.InitDBWithConfig(nil); err != nil {
+	if err := database.InitDBWithConfig(&database.Config{
+		URL:             cfg.Database.URL,
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+	}); err != nil {
 		utils.LogError(nil, "Failed to initialize database", err)
-		log.Fatal("Database initialization failed:", err)
+		return 1
 	}
 	defer database.CloseDB()
+
+	// `divelog-backend migrate ...` manages the schema and exits without
+	// starting the server.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(context.Background(), database.DB, os.Args[2:], os.Stdout); err != nil {
+			utils.LogError(nil, "Migration command failed", err)
+			return 1
+		}
+		return 0
+	}
+
 	if err := database.RunMigrations(context.Background(), database.DB); err != nil {
 		utils.LogError(nil, "Failed to migrate database", err)
-		log.Fatal("Database migration failed:", err)
+		return 1
+	}
+
+	geocoder, err := geocoding.LoadEmbedded()
+	if err != nil {
+		utils.LogError(nil, "Failed to load reverse-geocoding boundaries", err)
+		return 1
+	}
+	if geocoder.Len() == 0 {
+		utils.LogWarn(nil, "No reverse-geocoding boundaries bundled; new dive sites will not get a country")
+	}
+
+	// `divelog-backend geocode-sites [--overwrite]` backfills the country and
+	// region of existing dive sites and exits.
+	if len(os.Args) > 1 && os.Args[1] == "geocode-sites" {
+		if err := runGeocodeCommand(context.Background(), database.DB, geocoder, os.Args[2:], os.Stdout); err != nil {
+			utils.LogError(nil, "Geocode command failed", err)
+			return 1
+		}
+		return 0
 	}
 
 	// Set Gin mode
-	if cfg.GinMode == "release" {
+	if cfg.Server.GinMode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	mediaStore, err := media.NewLocalStore(cfg.Storage.MediaDir)
+	if err != nil {
+		utils.LogError(nil, "Failed to open media directory", err)
+		return 1
+	}
+
+	// Rate limits are shared between replicas through Redis when configured
+	var limiter ratelimit.Limiter = ratelimit.NewMemoryLimiter()
+	if cfg.RateLimit.RedisURL != "" {
+		redisLimiter, err := ratelimit.NewRedisLimiter(cfg.RateLimit.RedisURL)
+		if err != nil {
+			utils.LogError(nil, "Failed to configure Redis rate limiter", err)
+			return 1
+		}
+		defer redisLimiter.Close()
+		limiter = redisLimiter
+	}
+
 	// Create repositories
 	diveRepo := repository.NewDiveRepository(database.DB)
 	diveSiteRepo := repository.NewDiveSiteRepository(database.DB)
 	settingsRepo := repository.NewSettingsRepository(database.DB)
 	logbookRepo := repository.NewLogbookRepository(database.DB)
 	transactor := repository.NewSQLTransactor(database.DB)
+	webhookRepo := repository.NewWebhookRepository(database.DB)
+	idempotencyRepo := repository.NewIdempotencyRepository(database.DB)
+	apiTokenRepo := repository.NewAPITokenRepository(database.DB)
 
 	// Create services and handlers
-	diveService := services.NewDiveService(diveRepo, transactor)
-	diveSiteService := services.NewDiveSiteService(diveSiteRepo, transactor)
+	webhookService := services.NewWebhookService(webhookRepo)
+	apiTokenService := services.NewAPITokenService(apiTokenRepo)
+	diveService := services.NewDiveService(diveRepo, transactor, geocoder)
+	diveSiteService := services.NewDiveSiteService(diveSiteRepo, transactor, geocoder)
 	diveHandler := handlers.NewDiveHandler(diveService)
+	diveRevisionHandler := handlers.NewDiveRevisionHandler(services.NewDiveRevisionService(repository.NewDiveRevisionRepository(database.DB)))
 	diveSiteHandler := handlers.NewDiveSiteHandler(diveSiteService)
 	settingsHandler := handlers.NewSettingsHandler(settingsRepo)
 	logbookHandler := handlers.NewLogbookHandler(services.NewLogbookService(logbookRepo))
+	backupHandler := handlers.NewBackupHandler(services.NewBackupService(transactor, geocoder))
+	speciesHandler := handlers.NewSpeciesHandler(services.NewSpeciesService(repository.NewSpeciesRepository(database.DB)))
+	certificationHandler := handlers.NewCertificationHandler(services.NewCertificationService(repository.NewCertificationRepository(database.DB), mediaStore))
+	searchHandler := handlers.NewSearchHandler(services.NewSearchService(repository.NewSearchRepository(database.DB)))
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	syncHandler := handlers.NewSyncHandler(services.NewSyncService(transactor))
+	apiTokenHandler := handlers.NewAPITokenHandler(apiTokenService)
+	auditLogHandler := handlers.NewAuditLogHandler(repository.NewAuditRepository(database.DB))
+	trashService := services.NewTrashService(repository.NewTrashRepository(database.DB), time.Duration(cfg.Trash.RetentionDays)*24*time.Hour)
+	trashHandler := handlers.NewTrashHandler(trashService)
+
+	migrator, err := database.NewMigrator(database.DB)
+	if err != nil {
+		utils.LogError(nil, "Failed to load migrations", err)
+		return 1
+	}
+	healthHandler := handlers.NewHealthHandler(database.DB, migrator)
+
+	// Deliver queued webhook events in the background until shutdown
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	dispatcherDone := make(chan struct{})
+	go func() {
+		defer close(dispatcherDone)
+		services.NewWebhookDispatcher(webhookRepo, cfg.Webhooks.PollInterval).Run(backgroundCtx)
+	}()
+
+	// Purge items that have been in the trash past the retention period
+	retentionDone := make(chan struct{})
+	go func() {
+		defer close(retentionDone)
+		trashService.RunRetention(backgroundCtx, cfg.Trash.PurgeInterval)
+	}()
 
 	// Create Gin router
 	r := gin.Default()
 
 	// Add global middleware
+	r.Use(middleware.Metrics())
 	r.Use(middleware.RequestID())
+	r.Use(middleware.Tracing())
 	r.Use(middleware.SecurityHeaders())
-	r.Use(middleware.RequestSizeLimit(10 << 20)) // 10MB limit
-	r.Use(middleware.RateLimit(100))             // 100 requests per minute
+	r.Use(middleware.RequestSizeLimit(cfg.Server.MaxRequestBytes))
+	// Bearer tokens fill in user_id, so this runs before anything reads it
+	r.Use(middleware.Authenticate(apiTokenService))
+	// Writes record who made them and in which request in the audit log
+	r.Use(middleware.AuditActor())
+	r.Use(middleware.RateLimit(limiter, middleware.RateLimitPolicy{
+		Default: ratelimit.PerMinute(cfg.RateLimit.RequestsPerMinute),
+		// Bulk writes spend more of the budget than single requests
+		Routes: map[string]middleware.RateLimitRule{
+			"POST /api/v1/dives/batch":       {Cost: 10},
+			"POST /api/v1/dive-sites/import": {Cost: 10},
+			"POST /api/v1/restore":           {Cost: 20, Limit: ratelimit.Limit{Requests: 5, Period: time.Hour}},
+		},
+	}))
 	r.Use(middleware.RequestResponseLogger())
-	r.Use(middleware.CORS())
-
-	// Health check endpoint with database check
-	r.GET("/health", func(c *gin.Context) {
-		dbHealth := "ok"
-		if err := database.HealthCheck(); err != nil {
-			dbHealth = "unhealthy"
-			utils.LogError(c.Request.Context(), "Database health check failed", err,
-				slog.String("request_id", c.GetString("request_id")))
-		}
+	r.Use(middleware.CORS(middleware.CORSPolicy{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}))
 
-		response := gin.H{
-			"status":   "ok",
-			"service":  "divelog-backend",
-			"database": dbHealth,
-		}
+	// Prometheus scrape endpoint
+	metrics.RegisterDBStats(metrics.Default, database.DB)
+	r.GET("/metrics", gin.WrapH(metrics.Default.Handler()))
 
-		if dbHealth == "unhealthy" {
-			c.JSON(503, response)
-		} else {
-			c.JSON(200, response)
-		}
-	})
+	// Liveness only says the process is up; readiness also needs the
+	// database and an up-to-date schema, and fails once shutdown starts.
+	// /health is kept for older clients.
+	r.GET("/livez", healthHandler.Livez)
+	r.GET("/readyz", healthHandler.Readyz)
+	r.GET("/health", healthHandler.Readyz)
 
-	// API routes
+	// API routes. State-changing requests must come from the API's own
+	// origin or a trusted one, and cookie sessions must also send a token from
+	// /csrf-token.
+	csrfPolicy := middleware.CSRFPolicy{
+		TrustedOrigins: cfg.CSRF.TrustedOrigins,
+		SessionCookie:  cfg.Auth.SessionCookie,
+		Secret:         sessionSecret(cfg),
+	}
+
+	// API tokens are limited to the scopes they were granted: dives:read and
+	// dives:write for the logbook, export for bulk downloads and admin for
+	// restores, webhooks, the audit log and token management. Scopes only
+	// narrow what a token may do: requests without a token pass these guards
+	// and act as their user_id, as the web client does (see the README).
+	diveScopes := middleware.RequireScopeByMethod(models.ScopeDivesRead, models.ScopeDivesWrite)
+	exportScope := middleware.RequireScope(models.ScopeExport)
+	adminScope := middleware.RequireScope(models.ScopeAdmin)
 	api := r.Group("/api/v1")
+	api.Use(middleware.CSRF(csrfPolicy))
 	{
+		api.GET("/csrf-token", middleware.CSRFToken(csrfPolicy))
+
 		// Settings endpoints
-		api.GET("/settings", settingsHandler.GetSettings)
-		api.PUT("/settings", settingsHandler.UpdateSettings)
+		api.GET("/settings", diveScopes, settingsHandler.GetSettings)
+		api.PUT("/settings", diveScopes, settingsHandler.UpdateSettings)
 
 		// Dive endpoints with middleware
 		diveRoutes := api.Group("/dives")
-		diveRoutes.Use(middleware.UserIDMiddleware())
+		diveRoutes.Use(diveScopes, middleware.UserIDMiddleware())
 		{
 			diveRoutes.GET("", diveHandler.GetDives)
-			diveRoutes.POST("", diveHandler.CreateDive)
-			diveRoutes.POST("/batch", diveHandler.CreateMultipleDives)
+			// Retried creates with the same Idempotency-Key replay the first
+			// response for 24 hours instead of creating the dives again.
+			idempotent := middleware.Idempotency(idempotencyRepo, 24*time.Hour)
+			diveRoutes.POST("", idempotent, diveHandler.CreateDive)
+			diveRoutes.POST("/batch", idempotent, diveHandler.CreateMultipleDives)
+			diveRoutes.GET("/:id", diveHandler.GetDive)
 			diveRoutes.PUT("/:id", diveHandler.UpdateDive)
 			diveRoutes.DELETE("/:id", diveHandler.DeleteDive)
+			diveRoutes.GET("/:id/sightings", speciesHandler.GetDiveSightings)
+			diveRoutes.PUT("/:id/sightings", speciesHandler.ReplaceDiveSightings)
+			diveRoutes.GET("/:id/revisions", diveRevisionHandler.GetRevisions)
+			diveRoutes.POST("/:id/revisions/:rev/restore", diveRevisionHandler.RestoreRevision)
 
 			// Development-only helper for wiping test data. Deliberately not
 			// registered in release mode, so it cannot be reached in production.
-			if cfg.GinMode != "release" {
+			if cfg.Server.GinMode != "release" {
 				diveRoutes.DELETE("", diveHandler.DeleteAllDives)
 			}
 		}
 
 		organizationRoutes := api.Group("")
-		organizationRoutes.Use(middleware.UserIDMiddleware())
+		organizationRoutes.Use(diveScopes, middleware.UserIDMiddleware())
 		{
 			organizationRoutes.GET("/tags", logbookHandler.GetTags)
 			organizationRoutes.POST("/tags", logbookHandler.CreateTag)
@@ -134,22 +292,121 @@ func main() {
 			organizationRoutes.POST("/dives/bulk-operations/:id/undo", logbookHandler.UndoBulkOperation)
 		}
 
+		// Deleted dives, trips, tags and dive sites wait here until restored
+		// or purged.
+		trashRoutes := api.Group("/trash")
+		trashRoutes.Use(diveScopes, middleware.UserIDMiddleware())
+		{
+			trashRoutes.GET("", trashHandler.GetTrash)
+			trashRoutes.DELETE("", trashHandler.EmptyTrash)
+			trashRoutes.POST("/:type/:id/restore", trashHandler.RestoreTrashItem)
+			trashRoutes.DELETE("/:type/:id", trashHandler.PurgeTrashItem)
+		}
+
+		exportRoutes := api.Group("")
+		exportRoutes.Use(exportScope, middleware.UserIDMiddleware())
+		{
+			exportRoutes.GET("/backup", backupHandler.GetBackup)
+			exportRoutes.GET("/dive-sites/export", diveSiteHandler.ExportDiveSites)
+		}
+
+		api.POST("/restore", adminScope, middleware.UserIDMiddleware(), backupHandler.Restore)
+
+		certificationRoutes := api.Group("/certifications")
+		certificationRoutes.Use(diveScopes, middleware.UserIDMiddleware())
+		{
+			certificationRoutes.GET("", certificationHandler.GetCertifications)
+			certificationRoutes.POST("", certificationHandler.CreateCertification)
+			certificationRoutes.GET("/:id", certificationHandler.GetCertification)
+			certificationRoutes.PUT("/:id", certificationHandler.UpdateCertification)
+			certificationRoutes.DELETE("/:id", certificationHandler.DeleteCertification)
+			certificationRoutes.GET("/:id/card", certificationHandler.GetCardImage)
+			certificationRoutes.PUT("/:id/card", certificationHandler.UploadCardImage)
+			certificationRoutes.DELETE("/:id/card", certificationHandler.DeleteCardImage)
+			certificationRoutes.GET("/:id/progress", certificationHandler.GetProgress)
+		}
+
+		api.GET("/sync", diveScopes, middleware.UserIDMiddleware(), syncHandler.GetChanges)
+
+		webhookRoutes := api.Group("/webhooks")
+		webhookRoutes.Use(adminScope, middleware.UserIDMiddleware())
+		{
+			webhookRoutes.GET("", webhookHandler.GetWebhooks)
+			webhookRoutes.POST("", webhookHandler.CreateWebhook)
+			webhookRoutes.GET("/:id", webhookHandler.GetWebhook)
+			webhookRoutes.PUT("/:id", webhookHandler.UpdateWebhook)
+			webhookRoutes.DELETE("/:id", webhookHandler.DeleteWebhook)
+			webhookRoutes.POST("/:id/rotate-secret", webhookHandler.RotateWebhookSecret)
+			webhookRoutes.GET("/:id/deliveries", webhookHandler.GetDeliveries)
+		}
+
+		api.GET("/audit-log", adminScope, middleware.UserIDMiddleware(), auditLogHandler.GetAuditLog)
+
+		tokenRoutes := api.Group("/tokens")
+		tokenRoutes.Use(adminScope, middleware.UserIDMiddleware())
+		{
+			tokenRoutes.GET("", apiTokenHandler.GetTokens)
+			tokenRoutes.POST("", apiTokenHandler.CreateToken)
+			tokenRoutes.DELETE("/:id", apiTokenHandler.DeleteToken)
+		}
+
+		searchRoutes := api.Group("/search")
+		searchRoutes.Use(diveScopes, middleware.UserIDMiddleware())
+		{
+			searchRoutes.GET("", searchHandler.Search)
+		}
+
+		// The species catalog is shared; sightings reports are per user.
+		speciesRoutes := api.Group("/species")
+		speciesRoutes.Use(diveScopes)
+		{
+			speciesRoutes.GET("", speciesHandler.ListSpecies)
+			speciesRoutes.POST("", speciesHandler.CreateSpecies)
+			speciesRoutes.GET("/life-list", middleware.UserIDMiddleware(), speciesHandler.GetLifeList)
+			speciesRoutes.GET("/:id/sightings", middleware.UserIDMiddleware(), speciesHandler.GetSpeciesSightings)
+		}
+
+		statisticsRoutes := api.Group("/statistics")
+		statisticsRoutes.Use(diveScopes, middleware.UserIDMiddleware())
+		{
+			statisticsRoutes.GET("/countries", diveSiteHandler.GetCountryStatistics)
+		}
+
 		// Dive site endpoints (no user validation needed for these)
 		diveSiteRoutes := api.Group("/dive-sites")
+		diveSiteRoutes.Use(diveScopes)
 		{
 			diveSiteRoutes.GET("", diveSiteHandler.GetDiveSites)
 			diveSiteRoutes.GET("/search", diveSiteHandler.SearchDiveSites)
+			diveSiteRoutes.GET("/nearby", diveSiteHandler.GetNearbyDiveSites)
+			diveSiteRoutes.GET("/bbox", diveSiteHandler.GetDiveSitesInBoundingBox)
+			diveSiteRoutes.GET("/clusters", diveSiteHandler.GetDiveSiteClusters)
+			diveSiteRoutes.POST("/import", diveSiteHandler.ImportDiveSites)
+			diveSiteRoutes.GET("/duplicates", diveSiteHandler.GetDuplicateDiveSites)
 			diveSiteRoutes.GET("/:id", diveSiteHandler.GetDiveSite)
 			diveSiteRoutes.POST("", diveSiteHandler.CreateDiveSite)
 			diveSiteRoutes.PUT("/:id", diveSiteHandler.UpdateDiveSite)
-			diveSiteRoutes.DELETE("/:id", diveSiteHandler.DeleteDiveSite)
+			// Deleted sites go to the deleting user's trash
+			diveSiteRoutes.DELETE("/:id", middleware.UserIDMiddleware(), diveSiteHandler.DeleteDiveSite)
+			diveSiteRoutes.POST("/:id/merge", middleware.UserIDMiddleware(), diveSiteHandler.MergeDiveSites)
 		}
 	}
 
-	// Start server
-	utils.LogInfo(nil, "Server starting", slog.String("port", cfg.Port))
-	if err := r.Run(":" + cfg.Port); err != nil {
-		utils.LogError(nil, "Failed to start server", err)
-		log.Fatal("Server startup failed:", err)
+	// Start server; SIGTERM or SIGINT drains it
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	utils.LogInfo(nil, "Server starting", slog.String("port", cfg.Server.Port))
+	serveErr := serve(ctx, cfg, newHTTPServer(cfg, r), healthHandler.Drain)
+
+	// No request is running any more; stop background work before the
+	// deferred CloseDB and trace flush run.
+	stopBackground()
+	<-dispatcherDone
+	<-retentionDone
+	if serveErr != nil {
+		utils.LogError(nil, "Server stopped with an error", serveErr)
+		return 1
 	}
+	utils.LogInfo(nil, "Server stopped")
+	return 0
 }
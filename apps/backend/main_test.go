@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePostgres speaks just enough of the wire protocol for lib/pq to connect
+// and ping; every other statement fails. It records whether a client closed
+// its connection with a Terminate message.
+type fakePostgres struct {
+	listener   net.Listener
+	mu         sync.Mutex
+	terminated bool
+}
+
+func startFakePostgres(t *testing.T) *fakePostgres {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakePostgres{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakePostgres) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	var length int32
+	if binary.Read(reader, binary.BigEndian, &length) != nil {
+		return
+	}
+	if _, err := io.CopyN(io.Discard, reader, int64(length-4)); err != nil {
+		return
+	}
+	writePostgresMessage(conn, 'R', []byte{0, 0, 0, 0})
+	writePostgresMessage(conn, 'Z', []byte("I"))
+
+	for {
+		kind, err := reader.ReadByte()
+		if err != nil || binary.Read(reader, binary.BigEndian, &length) != nil {
+			return
+		}
+		body := make([]byte, length-4)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+		switch kind {
+		case 'Q':
+			if string(body) == ";\x00" {
+				writePostgresMessage(conn, 'I', nil)
+			} else {
+				writePostgresError(conn)
+			}
+			writePostgresMessage(conn, 'Z', []byte("I"))
+		case 'P':
+			writePostgresError(conn)
+		case 'S':
+			writePostgresMessage(conn, 'Z', []byte("I"))
+		case 'X':
+			s.mu.Lock()
+			s.terminated = true
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (s *fakePostgres) sawTerminate() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.terminated
+}
+
+func writePostgresMessage(w io.Writer, kind byte, body []byte) {
+	message := []byte{kind, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(message[1:], uint32(len(body)+4))
+	_, _ = w.Write(append(message, body...))
+}
+
+func writePostgresError(w io.Writer) {
+	writePostgresMessage(w, 'E', []byte("SERROR\x00CXX000\x00Mfake server refuses statements\x00\x00"))
+}
+
+func TestRunClosesDatabaseWhenMigrationsFail(t *testing.T) {
+	server := startFakePostgres(t)
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("OTEL_TRACES_EXPORTER", "")
+	t.Setenv("DATABASE_URL", "postgres://divelog@"+server.listener.Addr().String()+"/divelog?sslmode=disable&connect_timeout=5")
+
+	// log.Fatal here would end the test binary instead of returning
+	assert.Equal(t, 1, run())
+
+	assert.Eventually(t, server.sawTerminate, 5*time.Second, 10*time.Millisecond,
+		"the deferred CloseDB should close the pooled connection")
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import "database/sql"
+
+// HTTP metrics, recorded by middleware.Metrics. Routes are reported by their
+// template, such as /api/v1/dives/:id, so that IDs do not create new series.
+var (
+	HTTPRequests = Default.NewCounterVec("divelog_http_requests_total",
+		"HTTP requests handled, by method, route and status code.", "method", "route", "status")
+	HTTPRequestDuration = Default.NewHistogramVec("divelog_http_request_duration_seconds",
+		"Time taken to handle HTTP requests, by method and route.", DefaultBuckets, "method", "route")
+	HTTPRequestsInFlight = Default.NewGauge("divelog_http_requests_in_flight",
+		"HTTP requests currently being handled.")
+)
+
+// Domain metrics, recorded by the services.
+var (
+	// DivesCreated is labelled by source: "single", "import" or "restore".
+	DivesCreated = Default.NewCounterVec("divelog_dives_created_total",
+		"Dives created, by source.", "source")
+	// ImportDuplicatesSkipped is labelled by what was imported: "dives",
+	// "dive_sites" or "restore".
+	ImportDuplicatesSkipped = Default.NewCounterVec("divelog_import_duplicates_skipped_total",
+		"Imported records skipped because they already existed, by import.", "import")
+	// UndoOperations is labelled by the type of the bulk operation undone.
+	UndoOperations = Default.NewCounterVec("divelog_undo_operations_total",
+		"Bulk operations undone, by operation type.", "operation")
+)
+
+// DBStatsSource reports connection pool statistics; *sql.DB implements it.
+type DBStatsSource interface {
+	Stats() sql.DBStats
+}
+
+// RegisterDBStats exposes the connection pool statistics of db on r.
+func RegisterDBStats(r *Registry, db DBStatsSource) {
+	stats := func(read func(sql.DBStats) float64) func() float64 {
+		return func() float64 { return read(db.Stats()) }
+	}
+	r.NewGaugeFunc("divelog_db_max_open_connections", "Maximum number of open connections to the database.",
+		stats(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	r.NewGaugeFunc("divelog_db_open_connections", "Established connections, both in use and idle.",
+		stats(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	r.NewGaugeFunc("divelog_db_in_use_connections", "Connections currently in use.",
+		stats(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	r.NewGaugeFunc("divelog_db_idle_connections", "Idle connections.",
+		stats(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	r.NewCounterFunc("divelog_db_wait_count_total", "Connections waited for.",
+		stats(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	r.NewCounterFunc("divelog_db_wait_duration_seconds_total", "Time spent waiting for a connection.",
+		stats(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	r.NewCounterFunc("divelog_db_max_idle_closed_total", "Connections closed because of SetMaxIdleConns.",
+		stats(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	r.NewCounterFunc("divelog_db_max_idle_time_closed_total", "Connections closed because of SetConnMaxIdleTime.",
+		stats(func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }))
+	r.NewCounterFunc("divelog_db_max_lifetime_closed_total", "Connections closed because of SetConnMaxLifetime.",
+		stats(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+}
@@ -0,0 +1,343 @@
+// Package metrics collects counters, gauges and histograms and serves them in
+// the Prometheus text exposition format. Metrics register themselves on a
+// Registry when created; the package-level metrics live on Default.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// collector writes one metric family: its HELP and TYPE lines and samples.
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry is a set of metrics served together.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: map[string]collector{}}
+}
+
+// Default holds the application metrics served on /metrics.
+var Default = NewRegistry()
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.collectors[c.name()]; exists {
+		panic("metrics: duplicate metric " + c.name())
+	}
+	r.collectors[c.name()] = c
+}
+
+// WriteTo writes every metric, sorted by name.
+func (r *Registry) WriteTo(w *bufio.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler serves the registry for Prometheus to scrape.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		buffered := bufio.NewWriter(w)
+		r.WriteTo(buffered)
+		buffered.Flush()
+	})
+}
+
+// family holds what every metric has in common.
+type family struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+}
+
+func (f family) name() string { return f.metricName }
+
+func (f family) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.metricName, escapeHelp(f.help), f.metricName, f.kind)
+}
+
+// seriesKey joins label values into a map key.
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func (f family) checkLabels(values []string) {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.metricName, len(f.labels), len(values)))
+	}
+}
+
+// CounterVec counts events, partitioned by label values.
+type CounterVec struct {
+	family
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounterVec registers a counter on r. Without label names it is a single
+// counter.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	counter := &CounterVec{family: family{name, help, "counter", labels}, series: map[string]*counterSeries{}}
+	r.register(counter)
+	return counter
+}
+
+// Inc adds one to the series for labelValues.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative, to the series for labelValues.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counter " + c.metricName + " cannot decrease")
+	}
+	c.checkLabels(labelValues)
+	key := seriesKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	series, ok := c.series[key]
+	if !ok {
+		series = &counterSeries{labelValues: append([]string(nil), labelValues...)}
+		c.series[key] = series
+	}
+	series.value += delta
+}
+
+// Value returns the current count for labelValues.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if series, ok := c.series[seriesKey(labelValues)]; ok {
+		return series.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.series) {
+		series := c.series[key]
+		writeSample(w, c.metricName, c.labels, series.labelValues, nil, series.value)
+	}
+}
+
+// Gauge is a single value that goes up and down.
+type Gauge struct {
+	family
+	mu    sync.Mutex
+	value float64
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	gauge := &Gauge{family: family{metricName: name, help: help, kind: "gauge"}}
+	r.register(gauge)
+	return gauge
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value += delta
+}
+
+func (g *Gauge) Inc() { g.Add(1) }
+func (g *Gauge) Dec() { g.Add(-1) }
+
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	writeSample(w, g.metricName, nil, nil, nil, g.Value())
+}
+
+// funcMetric reads its value when scraped, for values owned elsewhere.
+type funcMetric struct {
+	family
+	value func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is read from value on each scrape.
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) {
+	r.register(&funcMetric{family{metricName: name, help: help, kind: "gauge"}, value})
+}
+
+// NewCounterFunc registers a counter whose value is read from value on each
+// scrape. value must never decrease.
+func (r *Registry) NewCounterFunc(name, help string, value func() float64) {
+	r.register(&funcMetric{family{metricName: name, help: help, kind: "counter"}, value})
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	m.writeHeader(w)
+	writeSample(w, m.metricName, nil, nil, nil, m.value())
+}
+
+// DefaultBuckets suit request latencies in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// HistogramVec counts observations into cumulative buckets, partitioned by
+// label values.
+type HistogramVec struct {
+	family
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// NewHistogramVec registers a histogram on r with the given upper bounds,
+// which must be sorted.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	histogram := &HistogramVec{
+		family:  family{name, help, "histogram", labels},
+		buckets: buckets,
+		series:  map[string]*histogramSeries{},
+	}
+	r.register(histogram)
+	return histogram
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.checkLabels(labelValues)
+	key := seriesKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+// Count returns how many values were observed for labelValues.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if series, ok := h.series[seriesKey(labelValues)]; ok {
+		return series.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+		for i, bound := range h.buckets {
+			writeSample(w, h.metricName+"_bucket", h.labels, series.labelValues, []string{"le", formatValue(bound)}, float64(series.counts[i]))
+		}
+		writeSample(w, h.metricName+"_bucket", h.labels, series.labelValues, []string{"le", "+Inf"}, float64(series.count))
+		writeSample(w, h.metricName+"_sum", h.labels, series.labelValues, nil, series.sum)
+		writeSample(w, h.metricName+"_count", h.labels, series.labelValues, nil, float64(series.count))
+	}
+}
+
+func sortedKeys[V any](series map[string]V) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeSample writes one sample line; extra is an additional name/value label
+// pair such as a histogram's le.
+func writeSample(w *bufio.Writer, name string, labels, values, extra []string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || len(extra) > 0 {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, escapeLabelValue(values[i]))
+		}
+		if len(extra) > 0 {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extra[0], escapeLabelValue(extra[1]))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatValue(value))
+	w.WriteByte('\n')
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string        { return helpEscaper.Replace(help) }
+func escapeLabelValue(value string) string { return labelEscaper.Replace(value) }
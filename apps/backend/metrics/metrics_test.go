@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"bufio"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func render(r *Registry) string {
+	var out strings.Builder
+	w := bufio.NewWriter(&out)
+	r.WriteTo(w)
+	w.Flush()
+	return out.String()
+}
+
+func TestRegistryWritesTextFormat(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("test_requests_total", "Requests handled.", "route", "status")
+	inFlight := r.NewGauge("test_in_flight", "Requests in flight.")
+	latency := r.NewHistogramVec("test_duration_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	r.NewGaugeFunc("test_pool_size", "Pool size.", func() float64 { return 4 })
+
+	requests.Inc("/dives/:id", "200")
+	requests.Add(2, "/dives/:id", "200")
+	requests.Inc("/dives", "201")
+	inFlight.Inc()
+	inFlight.Inc()
+	inFlight.Dec()
+	latency.Observe(0.05, "/dives")
+	latency.Observe(0.5, "/dives")
+
+	assert.Equal(t, `# HELP test_duration_seconds Request latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/dives",le="0.1"} 1
+test_duration_seconds_bucket{route="/dives",le="1"} 2
+test_duration_seconds_bucket{route="/dives",le="+Inf"} 2
+test_duration_seconds_sum{route="/dives"} 0.55
+test_duration_seconds_count{route="/dives"} 2
+# HELP test_in_flight Requests in flight.
+# TYPE test_in_flight gauge
+test_in_flight 1
+# HELP test_pool_size Pool size.
+# TYPE test_pool_size gauge
+test_pool_size 4
+# HELP test_requests_total Requests handled.
+# TYPE test_requests_total counter
+test_requests_total{route="/dives/:id",status="200"} 3
+test_requests_total{route="/dives",status="201"} 1
+`, render(r))
+}
+
+func TestLabelValuesAreEscaped(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounterVec("test_total", "Line one\nline two.", "value")
+	counter.Inc(`say "hi" \ bye` + "\n")
+
+	assert.Contains(t, render(r), `# HELP test_total Line one\nline two.`)
+	assert.Contains(t, render(r), `test_total{value="say \"hi\" \\ bye\n"} 1`)
+}
+
+func TestRegistryRejectsMisuse(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounterVec("test_total", "Test.", "route")
+
+	assert.Panics(t, func() { r.NewGauge("test_total", "Duplicate.") })
+	assert.Panics(t, func() { counter.Inc() })
+	assert.Panics(t, func() { counter.Add(-1, "/dives") })
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Test.").Inc()
+
+	recorder := httptest.NewRecorder()
+	r.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "test_total 1\n")
+}
+
+type fixedDBStats sql.DBStats
+
+func (s fixedDBStats) Stats() sql.DBStats { return sql.DBStats(s) }
+
+func TestRegisterDBStats(t *testing.T) {
+	r := NewRegistry()
+	RegisterDBStats(r, fixedDBStats{
+		MaxOpenConnections: 25, OpenConnections: 3, InUse: 2, Idle: 1,
+		WaitCount: 4, WaitDuration: 1500 * time.Millisecond, MaxLifetimeClosed: 7,
+	})
+
+	output := render(r)
+	assert.Contains(t, output, "# TYPE divelog_db_open_connections gauge\ndivelog_db_open_connections 3\n")
+	assert.Contains(t, output, "divelog_db_max_open_connections 25\n")
+	assert.Contains(t, output, "divelog_db_in_use_connections 2\n")
+	assert.Contains(t, output, "divelog_db_idle_connections 1\n")
+	assert.Contains(t, output, "# TYPE divelog_db_wait_count_total counter\ndivelog_db_wait_count_total 4\n")
+	assert.Contains(t, output, "divelog_db_wait_duration_seconds_total 1.5\n")
+	assert.Contains(t, output, "divelog_db_max_lifetime_closed_total 7\n")
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"divelog-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditActor records who the request acts for in its context, so the audit
+// log can attribute its changes: the API token and its owner, or else the
+// user_id query parameter. It must run after RequestID and Authenticate.
+func AuditActor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := utils.Actor{RequestID: c.GetString("request_id")}
+		if token, ok := APITokenFromContext(c); ok {
+			actor.UserID, actor.TokenID = token.UserID, token.ID
+		} else if userID, err := strconv.Atoi(c.Query("user_id")); err == nil && userID > 0 {
+			actor.UserID = userID
+		}
+		c.Request = c.Request.WithContext(utils.WithActor(c.Request.Context(), actor))
+		c.Next()
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"divelog-backend/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func actorFor(t *testing.T, target, authorization string) utils.Actor {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("request_id", "req-1") })
+	router.Use(Authenticate(testTokens), AuditActor())
+	var actor utils.Actor
+	router.GET("/dives", func(c *gin.Context) {
+		actor = utils.ActorFromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	return actor
+}
+
+func TestAuditActorIdentifiesTokenOrUser(t *testing.T) {
+	assert.Equal(t, utils.Actor{UserID: 4, TokenID: 1, RequestID: "req-1"}, actorFor(t, "/dives", "Bearer reader"))
+	assert.Equal(t, utils.Actor{UserID: 7, RequestID: "req-1"}, actorFor(t, "/dives?user_id=7", ""))
+	assert.Equal(t, utils.Actor{RequestID: "req-1"}, actorFor(t, "/dives?user_id=abc", ""))
+}
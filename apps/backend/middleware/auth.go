@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const apiTokenKey = "apiToken"
+
+// APITokenAuthenticator resolves the personal access token a request presents.
+// APITokenService implements it.
+type APITokenAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (*models.APIToken, error)
+}
+
+// Authenticate accepts a personal access token in "Authorization: Bearer".
+// The token stands in for user_id: it is added to the query when missing, so
+// the handlers and the rate limiter see the token's owner, and a user_id
+// naming anyone else is refused. It must run before anything reads the query.
+// Requests without a token are left to the user_id checks as before.
+func Authenticate(authenticator APITokenAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+		scheme, secret, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(secret) == "" {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_request"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization must be a Bearer token"})
+			return
+		}
+
+		token, err := authenticator.Authenticate(c.Request.Context(), strings.TrimSpace(secret))
+		if errors.Is(err, utils.ErrInvalidAPIToken) {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			utils.LogError(c.Request.Context(), "Failed to authenticate API token", err,
+				slog.String("request_id", c.GetString("request_id")))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate API token"})
+			return
+		}
+
+		query := c.Request.URL.Query()
+		switch requested := query.Get("user_id"); requested {
+		case "":
+			query.Set("user_id", strconv.Itoa(token.UserID))
+			c.Request.URL.RawQuery = query.Encode()
+		case strconv.Itoa(token.UserID):
+		default:
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API token does not belong to user_id"})
+			return
+		}
+
+		c.Set(apiTokenKey, token)
+		c.Set("userID", token.UserID)
+		c.Next()
+	}
+}
+
+// APITokenFromContext returns the token the request was authenticated with.
+func APITokenFromContext(c *gin.Context) (*models.APIToken, bool) {
+	value, exists := c.Get(apiTokenKey)
+	if !exists {
+		return nil, false
+	}
+	token, ok := value.(*models.APIToken)
+	return token, ok
+}
+
+// RequireScope rejects requests whose API token lacks scope. Requests without
+// a token are not affected.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasScope(c, scope) {
+			rejectScope(c, scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScopeByMethod is RequireScope with one scope for reads and another
+// for state-changing requests.
+func RequireScopeByMethod(read, write string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := write
+		if isSafeMethod(c.Request.Method) {
+			scope = read
+		}
+		if !hasScope(c, scope) {
+			rejectScope(c, scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+func hasScope(c *gin.Context, scope string) bool {
+	token, ok := APITokenFromContext(c)
+	return !ok || token.HasScope(scope)
+}
+
+func rejectScope(c *gin.Context, scope string) {
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("API token lacks the %s scope", scope)})
+}
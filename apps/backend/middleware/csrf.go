@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"divelog-backend/utils"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CSRFHeader carries the token from CSRFToken on state-changing requests.
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFCookie holds the same token, for the double-submit comparison.
+	CSRFCookie = "divelog_csrf"
+
+	csrfNonceBytes = 16
+)
+
+// CSRFPolicy configures cross-site request forgery protection.
+type CSRFPolicy struct {
+	// TrustedOrigins may send state-changing requests besides the API's own
+	// host; "*" trusts any origin.
+	TrustedOrigins []string
+	// SessionCookie names the cookie that authenticates browser sessions.
+	// Only requests carrying it need a CSRF token.
+	SessionCookie string
+	// Secret signs tokens and binds them to the session.
+	Secret []byte
+}
+
+// CSRF protects state-changing requests in two layers. Browsers must send
+// them from the API's own origin or a trusted one, judged by the Origin or
+// Referer header. Requests that carry the session cookie must also repeat the
+// token from the CSRF cookie in the X-CSRF-Token header, signed for that
+// session. Requests without the session cookie, such as scripts using API
+// tokens, carry no ambient credentials to forge and skip the token check.
+func CSRF(policy CSRFPolicy) gin.HandlerFunc {
+	anyOrigin := false
+	trusted := make(map[string]bool, len(policy.TrustedOrigins))
+	for _, origin := range policy.TrustedOrigins {
+		anyOrigin = anyOrigin || origin == "*"
+		trusted[origin] = true
+	}
+
+	return func(c *gin.Context) {
+		if isSafeMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		if origin, ok := requestOrigin(c.Request); !ok || !(anyOrigin || trusted[origin] || sameHost(origin, c.Request.Host)) {
+			utils.LogWarn(c.Request.Context(), "Cross-site request rejected",
+				slog.String("origin", origin),
+				slog.String("request_id", c.GetString("request_id")))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Cross-site request rejected"})
+			return
+		}
+
+		session, err := c.Cookie(policy.SessionCookie)
+		if err != nil || session == "" {
+			c.Next()
+			return
+		}
+		token := c.GetHeader(CSRFHeader)
+		cookie, _ := c.Cookie(CSRFCookie)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cookie)) != 1 ||
+			!validCSRFToken(policy.Secret, session, token) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CSRFToken issues a token for the caller's session. It is returned in the
+// body for the client to send back in X-CSRF-Token, and set as the CSRF
+// cookie for the double-submit comparison.
+func CSRFToken(policy CSRFPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, _ := c.Cookie(policy.SessionCookie)
+		token, err := newCSRFToken(policy.Secret, session)
+		if err != nil {
+			utils.LogError(c.Request.Context(), "Failed to generate CSRF token", err,
+				slog.String("request_id", c.GetString("request_id")))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate CSRF token"})
+			return
+		}
+
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     CSRFCookie,
+			Value:    token,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteStrictMode,
+		})
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{"csrf_token": token})
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// requestOrigin returns the origin a browser says the request came from, or
+// "" for clients that send neither Origin nor Referer. ok is false for an
+// opaque "null" origin or a request the browser itself marks cross-site
+// without naming the origin.
+func requestOrigin(r *http.Request) (origin string, ok bool) {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return origin, origin != "null"
+	}
+	if referer, err := url.Parse(r.Header.Get("Referer")); err == nil && referer.Host != "" {
+		return referer.Scheme + "://" + referer.Host, true
+	}
+	return "", r.Header.Get("Sec-Fetch-Site") != "cross-site"
+}
+
+func sameHost(origin, host string) bool {
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(parsed.Host, host)
+}
+
+// newCSRFToken returns a random nonce and its signature for session.
+func newCSRFToken(secret []byte, session string) (string, error) {
+	nonce := make([]byte, csrfNonceBytes)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(nonce) + "." +
+		base64.RawURLEncoding.EncodeToString(signCSRFNonce(secret, session, nonce)), nil
+}
+
+func validCSRFToken(secret []byte, session, token string) bool {
+	encodedNonce, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return false
+	}
+	nonce, err := base64.RawURLEncoding.DecodeString(encodedNonce)
+	if err != nil || len(nonce) != csrfNonceBytes {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, signCSRFNonce(secret, session, nonce))
+}
+
+func signCSRFNonce(secret []byte, session string, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(nonce)
+	mac.Write([]byte(session))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testCSRFPolicy = CSRFPolicy{
+	TrustedOrigins: []string{"https://divelog.example.com"},
+	SessionCookie:  "divelog_session",
+	Secret:         []byte("0123456789abcdef0123456789abcdef"),
+}
+
+func csrfRouter(policy CSRFPolicy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CSRF(policy))
+	router.GET("/csrf-token", CSRFToken(policy))
+	router.GET("/dives", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/dives/bulk-delete", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+// issueCSRFToken fetches a token for session the way a browser client would.
+func issueCSRFToken(t *testing.T, router *gin.Engine, session string) (string, *http.Cookie) {
+	req := httptest.NewRequest(http.MethodGet, "/csrf-token", nil)
+	req.AddCookie(&http.Cookie{Name: "divelog_session", Value: session})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, CSRFCookie, cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+	assert.Equal(t, body["csrf_token"], cookies[0].Value)
+	return body["csrf_token"], cookies[0]
+}
+
+func bulkDelete(router *gin.Engine, headers map[string]string, cookies ...*http.Cookie) int {
+	req := httptest.NewRequest(http.MethodPost, "/dives/bulk-delete", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestCSRFChecksOriginOfStateChangingRequests(t *testing.T) {
+	router := csrfRouter(testCSRFPolicy)
+
+	for name, test := range map[string]struct {
+		headers map[string]string
+		want    int
+	}{
+		"trusted origin":         {map[string]string{"Origin": "https://divelog.example.com"}, http.StatusOK},
+		"same host":              {map[string]string{"Origin": "http://example.com"}, http.StatusOK},
+		"untrusted origin":       {map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+		"opaque origin":          {map[string]string{"Origin": "null"}, http.StatusForbidden},
+		"trusted referer":        {map[string]string{"Referer": "https://divelog.example.com/logbook"}, http.StatusOK},
+		"untrusted referer":      {map[string]string{"Referer": "https://evil.example.com/form"}, http.StatusForbidden},
+		"unnamed cross-site":     {map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		"non-browser client":     {nil, http.StatusOK},
+		"same-origin fetch mark": {map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusOK},
+	} {
+		assert.Equal(t, test.want, bulkDelete(router, test.headers), name)
+	}
+
+	// Safe methods are never checked.
+	req := httptest.NewRequest(http.MethodGet, "/dives", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCSRFTrustsAnyOriginWithWildcard(t *testing.T) {
+	policy := testCSRFPolicy
+	policy.TrustedOrigins = []string{"*"}
+
+	assert.Equal(t, http.StatusOK, bulkDelete(csrfRouter(policy), map[string]string{"Origin": "https://evil.example.com"}))
+}
+
+func TestCSRFRequiresTokenForSessionCookies(t *testing.T) {
+	router := csrfRouter(testCSRFPolicy)
+	session := &http.Cookie{Name: "divelog_session", Value: "session-1"}
+	token, csrfCookie := issueCSRFToken(t, router, "session-1")
+	origin := "https://divelog.example.com"
+
+	assert.Equal(t, http.StatusOK, bulkDelete(router, map[string]string{"Origin": origin, CSRFHeader: token}, session, csrfCookie))
+	assert.Equal(t, http.StatusForbidden, bulkDelete(router, map[string]string{"Origin": origin}, session, csrfCookie), "missing header")
+	assert.Equal(t, http.StatusForbidden, bulkDelete(router, map[string]string{"Origin": origin, CSRFHeader: token}, session), "missing cookie")
+	assert.Equal(t, http.StatusForbidden, bulkDelete(router, map[string]string{"Origin": origin, CSRFHeader: "forged.token"}, session,
+		&http.Cookie{Name: CSRFCookie, Value: "forged.token"}), "forged token")
+
+	// A token issued for one session is useless with another.
+	other := &http.Cookie{Name: "divelog_session", Value: "session-2"}
+	assert.Equal(t, http.StatusForbidden, bulkDelete(router, map[string]string{"Origin": origin, CSRFHeader: token}, other, csrfCookie))
+
+	// Tokens signed with another secret are rejected.
+	rotated := testCSRFPolicy
+	rotated.Secret = []byte("fedcba9876543210fedcba9876543210")
+	assert.Equal(t, http.StatusForbidden, bulkDelete(csrfRouter(rotated), map[string]string{"Origin": origin, CSRFHeader: token}, session, csrfCookie))
+}
+
+func TestCSRFTokenIsFreshEachTime(t *testing.T) {
+	router := csrfRouter(testCSRFPolicy)
+
+	first, _ := issueCSRFToken(t, router, "session-1")
+	second, _ := issueCSRFToken(t, router, "session-1")
+
+	assert.NotEqual(t, first, second)
+	assert.True(t, validCSRFToken(testCSRFPolicy.Secret, "session-1", first))
+	assert.True(t, validCSRFToken(testCSRFPolicy.Secret, "session-1", second))
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader names the client-chosen key that marks retries of one
+// request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyStore keeps the first response per user and key.
+type IdempotencyStore interface {
+	Claim(ctx context.Context, userID int, key, requestHash string, ttl time.Duration) (*models.IdempotencyRecord, error)
+	Complete(ctx context.Context, userID int, key string, response models.IdempotentResponse) error
+	Release(ctx context.Context, userID int, key string) error
+}
+
+type responseCaptureWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w responseCaptureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w responseCaptureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes a write safe to retry. The first request with a given
+// Idempotency-Key runs normally and its response is kept for ttl. Retries
+// with the same key and body get that response again, marked with
+// Idempotent-Replayed. Reusing a key for a different body is rejected with
+// 422, and a retry that arrives while the first request is still running gets
+// 409. Server errors are not kept, so the request can be retried. Requests
+// without the header pass through unchanged. It must run after
+// UserIDMiddleware, because keys are scoped per user.
+func Idempotency(store IdempotencyStore, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey(key) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Idempotency-Key must be 1-255 printable ASCII characters",
+			})
+			return
+		}
+		userID, ok := RequireUserID(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(c.Request.Body); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		requestHash := idempotencyRequestHash(c.Request.Method, c.Request.URL.Path, body)
+
+		ctx := c.Request.Context()
+		record, err := store.Claim(ctx, userID, key, requestHash, ttl)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+			return
+		}
+		if record != nil {
+			replayIdempotentResponse(c, record, requestHash)
+			return
+		}
+
+		writer := responseCaptureWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = writer
+		c.Next()
+
+		// Record the outcome even if the client has gone away: the retry
+		// it is about to send depends on it.
+		ctx = context.WithoutCancel(ctx)
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			if err := store.Release(ctx, userID, key); err != nil {
+				utils.LogWarn(ctx, "Failed to release Idempotency-Key after server error", utils.UserID(userID))
+			}
+			return
+		}
+		response := models.IdempotentResponse{
+			StatusCode:  status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}
+		if err := store.Complete(ctx, userID, key, response); err != nil {
+			utils.LogWarn(ctx, "Failed to store idempotent response", utils.UserID(userID), slog.Int("status", status))
+		}
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, record *models.IdempotencyRecord, requestHash string) {
+	switch {
+	case record.RequestHash != requestHash:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "Idempotency-Key was already used for a different request",
+		})
+	case record.Response == nil:
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "A request with this Idempotency-Key is still being processed",
+		})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.Response.StatusCode, record.Response.ContentType, record.Response.Body)
+		c.Abort()
+	}
+}
+
+// idempotencyRequestHash fingerprints a request so that a reused key can be
+// told apart from a genuine retry.
+func idempotencyRequestHash(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
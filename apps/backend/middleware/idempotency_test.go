@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"context"
+	"divelog-backend/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryIdempotencyStore keeps idempotency records in a map keyed by user and
+// key.
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*models.IdempotencyRecord
+	ttls    []time.Duration
+}
+
+func (s *memoryIdempotencyStore) Claim(ctx context.Context, userID int, key, requestHash string, ttl time.Duration) (*models.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ttls = append(s.ttls, ttl)
+	id := storeKey(userID, key)
+	if record, ok := s.records[id]; ok {
+		return record, nil
+	}
+	if s.records == nil {
+		s.records = map[string]*models.IdempotencyRecord{}
+	}
+	s.records[id] = &models.IdempotencyRecord{RequestHash: requestHash}
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, userID int, key string, response models.IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[storeKey(userID, key)].Response = &response
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, userID int, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, storeKey(userID, key))
+	return nil
+}
+
+func storeKey(userID int, key string) string {
+	return fmt.Sprintf("%d/%s", userID, key)
+}
+
+// newIdempotentRouter serves POST /dives for user 1 through Idempotency,
+// answering with status and counting how often the handler actually runs.
+func newIdempotentRouter(store IdempotencyStore, status *int, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/dives", func(c *gin.Context) {
+		c.Set("userID", 1)
+	}, Idempotency(store, time.Hour), func(c *gin.Context) {
+		*calls++
+		c.JSON(*status, gin.H{"id": *calls})
+	})
+	return router
+}
+
+func postDive(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/dives", strings.NewReader(body))
+	if key != "" {
+		request.Header.Set(IdempotencyKeyHeader, key)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotencyReplaysFirstResponse(t *testing.T) {
+	store := &memoryIdempotencyStore{}
+	status, calls := http.StatusCreated, 0
+	router := newIdempotentRouter(store, &status, &calls)
+
+	first := postDive(router, "retry-1", `{"location":"Blue Hole"}`)
+	retry := postDive(router, "retry-1", `{"location":"Blue Hole"}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "application/json; charset=utf-8", retry.Header().Get("Content-Type"))
+	assert.Equal(t, []time.Duration{time.Hour, time.Hour}, store.ttls)
+}
+
+func TestIdempotencyReplaysClientErrors(t *testing.T) {
+	status, calls := http.StatusConflict, 0
+	router := newIdempotentRouter(&memoryIdempotencyStore{}, &status, &calls)
+
+	postDive(router, "retry-1", `{}`)
+	status = http.StatusCreated
+	retry := postDive(router, "retry-1", `{}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusConflict, retry.Code)
+}
+
+func TestIdempotencyRejectsKeyReuseWithDifferentBody(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	router := newIdempotentRouter(&memoryIdempotencyStore{}, &status, &calls)
+
+	postDive(router, "retry-1", `{"location":"Blue Hole"}`)
+	reused := postDive(router, "retry-1", `{"location":"Great Blue Hole"}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+}
+
+func TestIdempotencyRejectsRetryWhileFirstRequestRuns(t *testing.T) {
+	store := &memoryIdempotencyStore{}
+	_, _ = store.Claim(context.Background(), 1, "retry-1", idempotencyRequestHash(http.MethodPost, "/dives", []byte(`{}`)), time.Hour)
+	status, calls := http.StatusCreated, 0
+	router := newIdempotentRouter(store, &status, &calls)
+
+	retry := postDive(router, "retry-1", `{}`)
+
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, http.StatusConflict, retry.Code)
+	assert.Equal(t, "1", retry.Header().Get("Retry-After"))
+}
+
+func TestIdempotencyReleasesKeyAfterServerError(t *testing.T) {
+	status, calls := http.StatusInternalServerError, 0
+	router := newIdempotentRouter(&memoryIdempotencyStore{}, &status, &calls)
+
+	postDive(router, "retry-1", `{}`)
+	status = http.StatusCreated
+	retry := postDive(router, "retry-1", `{}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Empty(t, retry.Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotencyWithoutKeyPassesThrough(t *testing.T) {
+	store := &memoryIdempotencyStore{}
+	status, calls := http.StatusCreated, 0
+	router := newIdempotentRouter(store, &status, &calls)
+
+	postDive(router, "", `{}`)
+	postDive(router, "", `{}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, store.records)
+}
+
+func TestIdempotencyRejectsInvalidKeys(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	router := newIdempotentRouter(&memoryIdempotencyStore{}, &status, &calls)
+
+	for _, key := range []string{strings.Repeat("k", maxIdempotencyKeyLength+1), "tab\tkey", "clé"} {
+		recorder := postDive(router, key, `{}`)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "key %q", key)
+	}
+	assert.Equal(t, 0, calls)
+}
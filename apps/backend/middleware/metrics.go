@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"divelog-backend/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that matched no route, so that scanners
+// probing random paths do not create a series per path.
+const unmatchedRoute = "unmatched"
+
+// Metrics records the count, status and latency of every request by route
+// template, and how many requests are in flight.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		metrics.HTTPRequests.Inc(method, route, strconv.Itoa(c.Writer.Status()))
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), method, route)
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"divelog-backend/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsRecordsRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Metrics())
+	var inFlight float64
+	router.GET("/metrics-test/:id", func(c *gin.Context) {
+		inFlight = metrics.HTTPRequestsInFlight.Value()
+		c.Status(http.StatusAccepted)
+	})
+	requests := metrics.HTTPRequests.Value(http.MethodGet, "/metrics-test/:id", "202")
+	observed := metrics.HTTPRequestDuration.Count(http.MethodGet, "/metrics-test/:id")
+	unmatched := metrics.HTTPRequests.Value(http.MethodGet, unmatchedRoute, "404")
+
+	for _, path := range []string{"/metrics-test/1", "/metrics-test/2", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, requests+2, metrics.HTTPRequests.Value(http.MethodGet, "/metrics-test/:id", "202"))
+	assert.Equal(t, observed+2, metrics.HTTPRequestDuration.Count(http.MethodGet, "/metrics-test/:id"))
+	assert.Equal(t, unmatched+1, metrics.HTTPRequests.Value(http.MethodGet, unmatchedRoute, "404"))
+	assert.GreaterOrEqual(t, inFlight, float64(1))
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"divelog-backend/ratelimit"
+	"divelog-backend/utils"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitRule adjusts rate limiting for one route.
+type RateLimitRule struct {
+	// Cost is how many tokens a request takes from the client's shared
+	// budget. Zero means one.
+	Cost int
+	// Limit, when set, also caps the route on its own, counted in requests.
+	Limit ratelimit.Limit
+}
+
+// RateLimitPolicy is the budget every client gets, with per-route rules keyed
+// by method and route pattern, such as "POST /api/v1/dives/batch".
+type RateLimitPolicy struct {
+	Default ratelimit.Limit
+	Routes  map[string]RateLimitRule
+}
+
+// RateLimit limits requests per user, or per client IP for requests without a
+// user_id, using token buckets from limiter. Every response carries
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy
+// headers for the most restrictive bucket; rejected requests get 429 with
+// Retry-After. If the limiter is unavailable, requests are let through.
+func RateLimit(limiter ratelimit.Limiter, policy RateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		client := rateLimitClient(c)
+		route := c.Request.Method + " " + c.FullPath()
+		rule := policy.Routes[route]
+		cost := rule.Cost
+		if cost <= 0 {
+			cost = 1
+		}
+
+		// Take from the route bucket first, so a request it rejects does not
+		// also spend the shared budget.
+		var results []ratelimit.Result
+		var limits []ratelimit.Limit
+		if rule.Limit.Requests > 0 {
+			result, err := limiter.Take(ctx, client+"|"+route, rule.Limit, 1)
+			if err != nil {
+				utils.LogWarn(ctx, "Rate limiter unavailable, allowing request", slog.String("error", err.Error()))
+				c.Next()
+				return
+			}
+			results, limits = append(results, result), append(limits, rule.Limit)
+		}
+		if len(results) == 0 || results[0].Allowed {
+			result, err := limiter.Take(ctx, client, policy.Default, cost)
+			if err != nil {
+				utils.LogWarn(ctx, "Rate limiter unavailable, allowing request", slog.String("error", err.Error()))
+				c.Next()
+				return
+			}
+			results, limits = append(results, result), append(limits, policy.Default)
+		}
+
+		binding := mostRestrictive(results)
+		result := results[binding]
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limits[binding].Requests, ceilSeconds(limits[binding].Period)))
+
+		if !result.Allowed {
+			utils.LogWarn(ctx, "Rate limit exceeded",
+				slog.String("client", client),
+				slog.String("route", route),
+				slog.Int("cost", cost))
+			retryAfter := ceilSeconds(result.RetryAfter)
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "Rate limit exceeded. Please slow down.",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitClient identifies whose budget a request spends: the user its API
+// token authenticated, otherwise the client IP. The unauthenticated user_id
+// parameter is ignored so that rotating it cannot buy a fresh budget.
+func rateLimitClient(c *gin.Context) string {
+	if userID, ok := GetUserIDFromContext(c); ok {
+		return "user:" + strconv.Itoa(userID)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// mostRestrictive returns the index of the result to report: a rejection if
+// there is one, otherwise the bucket with the fewest requests left.
+func mostRestrictive(results []ratelimit.Result) int {
+	binding := 0
+	for i, result := range results {
+		if !result.Allowed {
+			return i
+		}
+		if result.Remaining < results[binding].Remaining {
+			binding = i
+		}
+	}
+	return binding
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
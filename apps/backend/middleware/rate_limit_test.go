@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"divelog-backend/ratelimit"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRateLimitedRouter(limiter ratelimit.Limiter, policy RateLimitPolicy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RateLimit(limiter, policy))
+	router.GET("/test", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.POST("/dives/batch", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	return router
+}
+
+func serveFrom(router *gin.Engine, method, target, remoteAddr string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, nil)
+	request.RemoteAddr = remoteAddr
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRateLimit(t *testing.T) {
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter(), RateLimitPolicy{Default: ratelimit.PerMinute(2)})
+
+	for requestNumber := 1; requestNumber <= 3; requestNumber++ {
+		recorder := serveFrom(router, http.MethodGet, "/test", "192.0.2.1:1234")
+		if requestNumber <= 2 {
+			assert.Equal(t, http.StatusNoContent, recorder.Code)
+		} else {
+			assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+			assert.Equal(t, "30", recorder.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter(), RateLimitPolicy{Default: ratelimit.PerMinute(100)})
+
+	recorder := serveFrom(router, http.MethodGet, "/test", "192.0.2.1:1234")
+
+	assert.Equal(t, "100", recorder.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "99", recorder.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", recorder.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "100;w=60", recorder.Header().Get("RateLimit-Policy"))
+	assert.Empty(t, recorder.Header().Get("Retry-After"))
+}
+
+// authenticateFromHeader stands in for Authenticate, trusting X-Test-User.
+func authenticateFromHeader(c *gin.Context) {
+	if userID, err := strconv.Atoi(c.GetHeader("X-Test-User")); err == nil {
+		c.Set("userID", userID)
+	}
+}
+
+func serveAs(router *gin.Engine, userID, remoteAddr string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/test", nil)
+	request.RemoteAddr = remoteAddr
+	request.Header.Set("X-Test-User", userID)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRateLimitKeysByAuthenticatedUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(authenticateFromHeader, RateLimit(ratelimit.NewMemoryLimiter(), RateLimitPolicy{Default: ratelimit.PerMinute(1)}))
+	router.GET("/test", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	assert.Equal(t, http.StatusNoContent, serveAs(router, "1", "192.0.2.1:1234").Code)
+	// The same user from another address shares the budget...
+	assert.Equal(t, http.StatusTooManyRequests, serveAs(router, "1", "192.0.2.2:1234").Code)
+	// ...while another user from the same address has their own.
+	assert.Equal(t, http.StatusNoContent, serveAs(router, "2", "192.0.2.1:1234").Code)
+	assert.Equal(t, http.StatusNoContent, serveFrom(router, http.MethodGet, "/test", "192.0.2.1:1234").Code)
+}
+
+func TestRateLimitIgnoresUnauthenticatedUserID(t *testing.T) {
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter(), RateLimitPolicy{Default: ratelimit.PerMinute(1)})
+
+	assert.Equal(t, http.StatusNoContent, serveFrom(router, http.MethodGet, "/test?user_id=1", "192.0.2.1:1234").Code)
+	// Naming another user does not reset the address's budget.
+	assert.Equal(t, http.StatusTooManyRequests, serveFrom(router, http.MethodGet, "/test?user_id=2", "192.0.2.1:1234").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serveFrom(router, http.MethodGet, "/test?user_id=3", "192.0.2.1:1234").Code)
+	assert.Equal(t, http.StatusNoContent, serveFrom(router, http.MethodGet, "/test?user_id=2", "192.0.2.2:1234").Code)
+}
+
+func TestRateLimitRouteCost(t *testing.T) {
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter(), RateLimitPolicy{
+		Default: ratelimit.PerMinute(10),
+		Routes:  map[string]RateLimitRule{"POST /dives/batch": {Cost: 6}},
+	})
+
+	first := serveFrom(router, http.MethodPost, "/dives/batch", "192.0.2.1:1234")
+	second := serveFrom(router, http.MethodPost, "/dives/batch", "192.0.2.1:1234")
+
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, "4", first.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, http.StatusTooManyRequests, second.Code)
+	assert.Equal(t, "12", second.Header().Get("Retry-After"))
+	// Cheaper requests still fit in what is left.
+	assert.Equal(t, http.StatusNoContent, serveFrom(router, http.MethodGet, "/test", "192.0.2.1:1234").Code)
+}
+
+func TestRateLimitRouteLimit(t *testing.T) {
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter(), RateLimitPolicy{
+		Default: ratelimit.PerMinute(100),
+		Routes:  map[string]RateLimitRule{"POST /dives/batch": {Limit: ratelimit.Limit{Requests: 1, Period: time.Hour}}},
+	})
+
+	first := serveFrom(router, http.MethodPost, "/dives/batch", "192.0.2.1:1234")
+	second := serveFrom(router, http.MethodPost, "/dives/batch", "192.0.2.1:1234")
+	other := serveFrom(router, http.MethodGet, "/test", "192.0.2.1:1234")
+
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, "1;w=3600", first.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, http.StatusTooManyRequests, second.Code)
+	assert.Equal(t, "1", second.Header().Get("RateLimit-Limit"))
+	// The rejected request did not spend the shared budget.
+	assert.Equal(t, http.StatusNoContent, other.Code)
+	assert.Equal(t, "98", other.Header().Get("RateLimit-Remaining"))
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Take(context.Context, string, ratelimit.Limit, int) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	router := newRateLimitedRouter(failingLimiter{}, RateLimitPolicy{Default: ratelimit.PerMinute(1)})
+
+	recorder := serveFrom(router, http.MethodGet, "/test", "192.0.2.1:1234")
+
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("RateLimit-Limit"))
+}
@@ -24,6 +24,35 @@ func BindJSON(c *gin.Context, destination interface{}) bool {
 	return true
 }
 
+// BindQuery decodes query parameters or writes a stable invalid-request response.
+func BindQuery(c *gin.Context, destination interface{}) bool {
+	if err := c.ShouldBindQuery(destination); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid_request",
+			"message": "Query parameters have an invalid format",
+		})
+		return false
+	}
+	return true
+}
+
+// RequireQueryParams writes a validation response naming every missing query
+// parameter. Use it for numeric parameters whose zero value is meaningful.
+func RequireQueryParams(c *gin.Context, names ...string) bool {
+	fields := utils.ValidationErrors{}
+	for _, name := range names {
+		if c.Query(name) == "" {
+			fields.Add(name, "is required")
+		}
+	}
+	return RespondValidationErrors(c, fields)
+}
+
+// BindAndValidateQuery decodes and validates a query-parameter model.
+func BindAndValidateQuery(c *gin.Context, destination Validatable) bool {
+	return BindQuery(c, destination) && ValidateRequest(c, destination)
+}
+
 // ValidateRequest writes field-level validation errors when a request is invalid.
 func ValidateRequest(c *gin.Context, request Validatable) bool {
 	return RespondValidationErrors(c, request.Validate())
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"divelog-backend/tracing"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tracing starts a server span for every request, continuing the caller's
+// trace when it sends a traceparent header. The span and the request ID go
+// into the request context, so spans started by services and repository
+// queries join the same trace and carry the same request_id. It must run
+// after RequestID.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		ctx := tracing.Extract(c.Request.Context(), c.GetHeader(tracing.TraceparentHeader))
+		if requestID := c.GetString("request_id"); requestID != "" {
+			ctx = tracing.WithRequestID(ctx, requestID)
+		}
+		ctx, span := tracing.StartWithKind(ctx, c.Request.Method+" "+route, tracing.KindServer,
+			tracing.String("http.request.method", c.Request.Method),
+			tracing.String("http.route", route),
+			tracing.String("url.path", c.Request.URL.Path),
+		)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(tracing.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, http.StatusText(status))
+		}
+		span.End(nil)
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"divelog-backend/tracing"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSpanExporter struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (e *recordingSpanExporter) Export(_ context.Context, _ string, spans []tracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestTracingStartsServerSpanPerRoute(t *testing.T) {
+	exporter := &recordingSpanExporter{}
+	provider := tracing.NewProvider("divelog-test", exporter, nil)
+	tracing.SetProvider(provider)
+	defer tracing.SetProvider(nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("request_id", "req-1") }, Tracing())
+	router.GET("/dives/:id", func(c *gin.Context) {
+		_, span := tracing.Start(c.Request.Context(), "DiveService.GetDive")
+		span.End(nil)
+		c.Status(http.StatusInternalServerError)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/dives/12", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	require.Len(t, exporter.spans, 2)
+	service, server := exporter.spans[0], exporter.spans[1]
+	assert.Equal(t, "GET /dives/:id", server.Name)
+	assert.Equal(t, tracing.KindServer, server.Kind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", server.ParentSpanID.String())
+	assert.Equal(t, []tracing.Attribute{
+		tracing.String("request_id", "req-1"),
+		tracing.String("http.request.method", "GET"),
+		tracing.String("http.route", "/dives/:id"),
+		tracing.String("url.path", "/dives/12"),
+		tracing.Int("http.response.status_code", 500),
+	}, server.Attributes)
+	assert.Equal(t, tracing.StatusError, server.Status)
+	assert.Equal(t, server.SpanID, service.ParentSpanID)
+	assert.Equal(t, []tracing.Attribute{tracing.String("request_id", "req-1")}, service.Attributes)
+}
@@ -1,8 +1,6 @@
 package middleware
 
 import (
-	"divelog-backend/utils"
-	"log/slog"
 	"net/http"
 	"time"
 
@@ -17,61 +15,6 @@ func RequestSizeLimit(maxSize int64) gin.HandlerFunc {
 	})
 }
 
-// RateLimit middleware implements simple rate limiting (in-memory)
-func RateLimit(requestsPerMinute int) gin.HandlerFunc {
-	type client struct {
-		requests int
-		lastSeen time.Time
-	}
-	
-	clients := make(map[string]*client)
-	
-	return gin.HandlerFunc(func(c *gin.Context) {
-		clientIP := c.ClientIP()
-		now := time.Now()
-		
-		// Clean up old entries periodically
-		if len(clients) > 1000 {
-			for ip, cl := range clients {
-				if now.Sub(cl.lastSeen) > time.Minute*5 {
-					delete(clients, ip)
-				}
-			}
-		}
-		
-		cl, exists := clients[clientIP]
-		if !exists {
-			clients[clientIP] = &client{requests: 1, lastSeen: now}
-			c.Next()
-			return
-		}
-		
-		// Reset counter if more than a minute has passed
-		if now.Sub(cl.lastSeen) > time.Minute {
-			cl.requests = 1
-			cl.lastSeen = now
-			c.Next()
-			return
-		}
-		
-		cl.requests++
-		cl.lastSeen = now
-		
-		if cl.requests > requestsPerMinute {
-			utils.LogWarn(c.Request.Context(), "Rate limit exceeded", 
-				slog.String("client_ip", clientIP),
-				slog.Int("requests", cl.requests))
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded. Please slow down.",
-			})
-			c.Abort()
-			return
-		}
-		
-		c.Next()
-	})
-}
-
 // RequestID middleware adds a unique request ID to each request
 func RequestID() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
@@ -47,25 +47,6 @@ func TestRequestSizeLimit(t *testing.T) {
 	assert.Equal(t, http.StatusRequestEntityTooLarge, overLimit.Code)
 }
 
-func TestRateLimit(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-	router.Use(RateLimit(2))
-	router.GET("/test", func(context *gin.Context) { context.Status(http.StatusNoContent) })
-
-	for requestNumber := 1; requestNumber <= 3; requestNumber++ {
-		request := httptest.NewRequest(http.MethodGet, "/test", nil)
-		request.RemoteAddr = "192.0.2.1:1234"
-		recorder := httptest.NewRecorder()
-		router.ServeHTTP(recorder, request)
-		if requestNumber <= 2 {
-			assert.Equal(t, http.StatusNoContent, recorder.Code)
-		} else {
-			assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
-		}
-	}
-}
-
 func TestRequestID(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
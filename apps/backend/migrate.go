@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"divelog-backend/database"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const migrateUsage = "usage: divelog-backend migrate status|up|down [steps]"
+
+// runMigrateCommand implements `migrate status|up|down [steps]`. down rolls
+// back one migration unless a step count is given.
+func runMigrateCommand(ctx context.Context, db *sql.DB, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(out, "%04d %-40s %s\n", status.Version, status.Name, state)
+		}
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range applied {
+			fmt.Fprintf(out, "applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "database is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("steps must be a positive integer")
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		for _, migration := range reverted {
+			fmt.Fprintf(out, "rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
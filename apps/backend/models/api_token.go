@@ -0,0 +1,75 @@
+package models
+
+import (
+	"divelog-backend/utils"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// API token scopes. Admin grants every other scope as well.
+const (
+	ScopeDivesRead  = "dives:read"
+	ScopeDivesWrite = "dives:write"
+	ScopeExport     = "export"
+	ScopeAdmin      = "admin"
+)
+
+// APITokenScopes lists every scope a token may be granted.
+var APITokenScopes = []string{ScopeDivesRead, ScopeDivesWrite, ScopeExport, ScopeAdmin}
+
+// APIToken is a personal access token. Token holds the secret itself and is
+// only returned when the token is created; afterwards Prefix identifies it.
+type APIToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+// HasScope reports whether the token grants scope, which admin always does.
+func (token *APIToken) HasScope(scope string) bool {
+	for _, granted := range token.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// APITokenRequest creates a token. Tokens expire after ExpiresInDays, 90 by
+// default.
+type APITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays *int     `json:"expires_in_days,omitempty"`
+}
+
+func (request *APITokenRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	request.Name = strings.TrimSpace(request.Name)
+	utils.RequireString(errors, "name", request.Name, 100)
+	if len(request.Scopes) == 0 {
+		errors.Add("scopes", "must contain at least one scope")
+	}
+	seen := map[string]bool{}
+	for i, scope := range request.Scopes {
+		field := fmt.Sprintf("scopes[%d]", i)
+		utils.OneOf(errors, field, scope, APITokenScopes...)
+		if seen[scope] {
+			errors.Add(field, "must not duplicate another scope")
+		}
+		seen[scope] = true
+	}
+	if request.ExpiresInDays == nil {
+		days := 90
+		request.ExpiresInDays = &days
+	}
+	utils.IntRange(errors, "expires_in_days", *request.ExpiresInDays, 1, 365)
+	return errors
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"divelog-backend/utils"
+	"encoding/json"
+	"time"
+)
+
+// Audit log entity types, one per table the audit triggers watch.
+const (
+	AuditEntityDive     = "dive"
+	AuditEntityTrip     = "trip"
+	AuditEntityTag      = "tag"
+	AuditEntityDiveSite = "dive_site"
+	AuditEntitySettings = "settings"
+)
+
+// AuditEntityTypes lists every entity type an audit entry may name.
+var AuditEntityTypes = []string{AuditEntityDive, AuditEntityTrip, AuditEntityTag, AuditEntityDiveSite, AuditEntitySettings}
+
+// AuditEntry records one row changed by a write. Changes maps each changed
+// column to its "before" and "after" values; creates have a null before and
+// deletes a null after. Tagging a dive shows as a change of its tag_id.
+type AuditEntry struct {
+	ID           int64           `json:"id"`
+	UserID       *int            `json:"user_id"` // Owner of the row; nil for shared dive sites
+	ActorUserID  *int            `json:"actor_user_id"`
+	ActorTokenID *int            `json:"actor_token_id,omitempty"`
+	RequestID    *string         `json:"request_id"`
+	EntityType   string          `json:"entity_type"`
+	EntityID     int             `json:"entity_id"`
+	Action       string          `json:"action"`
+	Changes      json.RawMessage `json:"changes"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// AuditLogFilter narrows the audit log, newest first. From is inclusive and
+// To exclusive; BeforeID continues from the last entry of a previous page.
+type AuditLogFilter struct {
+	EntityType string    `form:"entity_type"`
+	EntityID   int       `form:"entity_id"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	BeforeID   int64     `form:"before_id"`
+	Limit      int       `form:"limit"`
+}
+
+func (filter *AuditLogFilter) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if filter.EntityType != "" {
+		utils.OneOf(errors, "entity_type", filter.EntityType, AuditEntityTypes...)
+	}
+	if filter.EntityID < 0 {
+		errors.Add("entity_id", "must be a positive ID")
+	} else if filter.EntityID > 0 && filter.EntityType == "" {
+		errors.Add("entity_id", "requires entity_type")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		errors.Add("to", "must be after from")
+	}
+	if filter.BeforeID < 0 {
+		errors.Add("before_id", "must be an entry ID from an earlier page")
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 100
+	}
+	utils.IntRange(errors, "limit", filter.Limit, 1, 500)
+	return errors
+}
@@ -0,0 +1,274 @@
+package models
+
+import (
+	"divelog-backend/utils"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// BackupFormat identifies archives produced by GET /api/v1/backup.
+	BackupFormat = "divelog-backup"
+	// BackupVersion is the newest archive layout this server reads and writes.
+	BackupVersion = 1
+)
+
+// BackupArchive is a self-contained copy of everything a user owns. IDs inside
+// the archive are only references between its sections; restore assigns new
+// database IDs and remaps them.
+type BackupArchive struct {
+	Format         string                `json:"format"`
+	Version        int                   `json:"version"`
+	CreatedAt      time.Time             `json:"created_at"`
+	Settings       *SettingsRequest      `json:"settings,omitempty"`
+	DiveSites      []BackupDiveSite      `json:"dive_sites"`
+	Trips          []BackupTrip          `json:"trips"`
+	Certifications []BackupCertification `json:"certifications,omitempty"`
+	Tags           []string              `json:"tags"`
+	BulkOperations []BackupBulkOperation `json:"bulk_operations"`
+	Dives          []BackupDive          `json:"dives"`
+}
+
+// BackupDiveSite is a dive site referenced by at least one of the user's dives.
+type BackupDiveSite struct {
+	ID int `json:"id"`
+	DiveSiteRequest
+}
+
+// BackupTrip keeps empty trips as well as trips that group dives.
+type BackupTrip struct {
+	ID int `json:"id"`
+	TripRequest
+}
+
+// BackupCertification keeps a certification's details and prerequisites. Card
+// images live in the media store and are not part of the archive.
+type BackupCertification struct {
+	ID int `json:"id"`
+	CertificationRequest
+}
+
+// BackupDive stores a dive in request form so restore can reuse the regular
+// create path. TripID, CertificationID and DiveSiteID refer to entries in the
+// same archive.
+type BackupDive struct {
+	ID         int  `json:"id"`
+	DiveSiteID *int `json:"dive_site_id,omitempty"`
+	DiveRequest
+	Sightings []BackupSighting `json:"sightings,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// BackupSighting names its species instead of referencing a catalog ID, so a
+// restore can match or recreate the species on another server.
+type BackupSighting struct {
+	SpeciesRequest
+	Count int     `json:"count"`
+	Notes *string `json:"notes,omitempty"`
+}
+
+// BackupBulkOperation preserves undo history. Dive IDs inside BeforeState are
+// archive dive IDs and are rewritten on restore.
+type BackupBulkOperation struct {
+	ID            string          `json:"id"`
+	OperationType string          `json:"operation_type"`
+	BeforeState   json.RawMessage `json:"before_state"`
+	AffectedCount int             `json:"affected_count"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UndoneAt      *time.Time      `json:"undone_at,omitempty"`
+}
+
+// RestoreOptions selects how an archive is applied. Merge keeps existing data
+// and skips duplicates; replace first removes the user's dives, trips, tags and
+// bulk-operation history. Dive sites are shared and are never removed;
+// certifications are kept so their card images survive, and archived ones are
+// merged into them.
+type RestoreOptions struct {
+	Mode   string `json:"mode"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// RestoreCounts reports what a restore did, or would do, to one entity type.
+type RestoreCounts struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+	Deleted int `json:"deleted"`
+}
+
+// RestoreSummary is returned for both real and dry-run restores.
+type RestoreSummary struct {
+	Mode           string        `json:"mode"`
+	DryRun         bool          `json:"dry_run"`
+	Settings       RestoreCounts `json:"settings"`
+	DiveSites      RestoreCounts `json:"dive_sites"`
+	Trips          RestoreCounts `json:"trips"`
+	Certifications RestoreCounts `json:"certifications"`
+	Tags           RestoreCounts `json:"tags"`
+	Dives          RestoreCounts `json:"dives"`
+	Species        RestoreCounts `json:"species"`
+	Sightings      RestoreCounts `json:"sightings"`
+	BulkOperations RestoreCounts `json:"bulk_operations"`
+}
+
+// ToRequest converts a stored dive back into the writable request shape.
+func (d *Dive) ToRequest() DiveRequest {
+	return DiveRequest{
+		DateTime:        d.DateTime.Time.Format("2006-01-02T15:04:05"),
+		Location:        d.Location,
+		Depth:           d.MaxDepth,
+		MeanDepth:       d.MeanDepth,
+		Duration:        d.Duration,
+		Buddy:           d.Buddy,
+		Lat:             d.Latitude,
+		Lng:             d.Longitude,
+		WaterTemp:       d.WaterTemp,
+		Visibility:      d.Visibility,
+		Notes:           d.Notes,
+		Samples:         d.Samples,
+		Equipment:       d.Equipment,
+		Conditions:      d.Conditions,
+		DiveType:        d.DiveType,
+		DiveMode:        d.DiveMode,
+		Computer:        d.Computer,
+		Rating:          d.Rating,
+		SafetyStops:     d.SafetyStops,
+		DiveNumber:      d.DiveNumber,
+		TripID:          d.TripID,
+		CertificationID: d.CertificationID,
+		Tags:            d.Tags,
+		ExtraData:       d.ExtraData,
+	}
+}
+
+// ToRequest converts stored settings into the writable request shape.
+func (us *UserSettings) ToRequest() SettingsRequest {
+	var request SettingsRequest
+	request.UnitPreference = us.UnitPreference
+	request.Units.Depth = us.DepthUnit
+	request.Units.Temperature = us.TemperatureUnit
+	request.Units.Distance = us.DistanceUnit
+	request.Units.Weight = us.WeightUnit
+	request.Units.Pressure = us.PressureUnit
+	request.Units.Volume = us.VolumeUnit
+	request.Preferences.DateFormat = us.DateFormat
+	request.Preferences.TimeFormat = us.TimeFormat
+	request.Preferences.DefaultVisibility = us.DefaultVisibility
+	request.Dive.ShowBuddyReminders = us.ShowBuddyReminders
+	request.Dive.AutoCalculateNitrox = us.AutoCalculateNitrox
+	request.Dive.DefaultGasMix = us.DefaultGasMix
+	request.Dive.MaxDepthWarning = us.MaxDepthWarning
+	return request
+}
+
+// Validate checks restore options supplied as query parameters.
+func (options *RestoreOptions) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	utils.OneOf(errors, "mode", options.Mode, "merge", "replace")
+	return errors
+}
+
+// Validate checks the archive header, every entry, and that all internal
+// references resolve before any row is written.
+func (archive *BackupArchive) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if archive.Format != BackupFormat {
+		errors.Add("format", "must be "+BackupFormat)
+	}
+	utils.IntRange(errors, "version", archive.Version, 1, BackupVersion)
+	if archive.Settings != nil {
+		errors.Merge("settings", archive.Settings.Validate())
+	}
+
+	siteIDs := map[int]bool{}
+	for i := range archive.DiveSites {
+		prefix := fmt.Sprintf("dive_sites[%d]", i)
+		site := &archive.DiveSites[i]
+		if site.ID <= 0 || siteIDs[site.ID] {
+			errors.Add(prefix+".id", "must be a unique positive integer")
+		}
+		siteIDs[site.ID] = true
+		errors.Merge(prefix, site.DiveSiteRequest.Validate())
+	}
+
+	tripIDs := map[int]bool{}
+	tripNames := map[string]bool{}
+	for i := range archive.Trips {
+		prefix := fmt.Sprintf("trips[%d]", i)
+		trip := &archive.Trips[i]
+		if trip.ID <= 0 || tripIDs[trip.ID] {
+			errors.Add(prefix+".id", "must be a unique positive integer")
+		}
+		tripIDs[trip.ID] = true
+		name := strings.ToLower(strings.TrimSpace(trip.Name))
+		if tripNames[name] {
+			errors.Add(prefix+".name", "must not duplicate another trip")
+		}
+		tripNames[name] = true
+		errors.Merge(prefix, trip.TripRequest.Validate())
+	}
+
+	certificationIDs := map[int]bool{}
+	certificationLevels := map[string]bool{}
+	for i := range archive.Certifications {
+		prefix := fmt.Sprintf("certifications[%d]", i)
+		certification := &archive.Certifications[i]
+		if certification.ID <= 0 || certificationIDs[certification.ID] {
+			errors.Add(prefix+".id", "must be a unique positive integer")
+		}
+		certificationIDs[certification.ID] = true
+		errors.Merge(prefix, certification.CertificationRequest.Validate())
+		level := strings.ToLower(certification.Agency) + "\x00" + strings.ToLower(certification.Level)
+		if certificationLevels[level] {
+			errors.Add(prefix+".level", "must not duplicate another certification from the same agency")
+		}
+		certificationLevels[level] = true
+	}
+
+	validateBulkTags(errors, "tags", archive.Tags)
+
+	diveIDs := map[int]bool{}
+	for i := range archive.Dives {
+		prefix := fmt.Sprintf("dives[%d]", i)
+		dive := &archive.Dives[i]
+		if dive.ID <= 0 || diveIDs[dive.ID] {
+			errors.Add(prefix+".id", "must be a unique positive integer")
+		}
+		diveIDs[dive.ID] = true
+		if dive.DiveSiteID != nil && !siteIDs[*dive.DiveSiteID] {
+			errors.Add(prefix+".dive_site_id", "must reference a dive site in the archive")
+		}
+		if dive.TripID != nil && !tripIDs[*dive.TripID] {
+			errors.Add(prefix+".trip_id", "must reference a trip in the archive")
+		}
+		if dive.CertificationID != nil && !certificationIDs[*dive.CertificationID] {
+			errors.Add(prefix+".certification_id", "must reference a certification in the archive")
+		}
+		errors.Merge(prefix, dive.DiveRequest.Validate())
+		for j := range dive.Sightings {
+			sighting := &dive.Sightings[j]
+			sightingPrefix := fmt.Sprintf("%s.sightings[%d]", prefix, j)
+			errors.Merge(sightingPrefix, sighting.SpeciesRequest.Validate())
+			utils.IntRange(errors, sightingPrefix+".count", sighting.Count, 1, maxSightingCount)
+			utils.OptionalString(errors, sightingPrefix+".notes", sighting.Notes, 1000)
+		}
+	}
+
+	for i, operation := range archive.BulkOperations {
+		prefix := fmt.Sprintf("bulk_operations[%d]", i)
+		if len(operation.ID) != 32 {
+			errors.Add(prefix+".id", "must be a 32 character operation ID")
+		}
+		utils.RequireString(errors, prefix+".operation_type", operation.OperationType, 50)
+		if len(operation.BeforeState) == 0 || !json.Valid(operation.BeforeState) {
+			errors.Add(prefix+".before_state", "must be valid JSON")
+		}
+		if operation.AffectedCount < 0 {
+			errors.Add(prefix+".affected_count", "must be greater than or equal to 0")
+		}
+	}
+	return errors
+}
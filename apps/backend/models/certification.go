@@ -0,0 +1,106 @@
+package models
+
+import (
+	"divelog-backend/utils"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	maxCertificationPrerequisites = 20
+	maxRequirementDives           = 10000
+)
+
+// Certification is a diver qualification or, while CertifiedOn is empty, a
+// course the diver is still working towards.
+type Certification struct {
+	ID                  int                        `json:"id"`
+	UserID              int                        `json:"user_id"`
+	Agency              string                     `json:"agency"`
+	Level               string                     `json:"level"`
+	CertificationNumber *string                    `json:"certification_number,omitempty"`
+	CertifiedOn         *string                    `json:"certified_on,omitempty"`
+	Instructor          *string                    `json:"instructor,omitempty"`
+	Notes               *string                    `json:"notes,omitempty"`
+	Prerequisites       []CertificationRequirement `json:"prerequisites"`
+	HasCardImage        bool                       `json:"has_card_image"`
+	TrainingDiveCount   int                        `json:"training_dive_count"`
+	CreatedAt           time.Time                  `json:"created_at"`
+	UpdatedAt           time.Time                  `json:"updated_at"`
+}
+
+// CertificationRequest is the writable portion of a certification. The card
+// image is uploaded separately.
+type CertificationRequest struct {
+	Agency              string                     `json:"agency"`
+	Level               string                     `json:"level"`
+	CertificationNumber *string                    `json:"certification_number,omitempty"`
+	CertifiedOn         *string                    `json:"certified_on,omitempty"`
+	Instructor          *string                    `json:"instructor,omitempty"`
+	Notes               *string                    `json:"notes,omitempty"`
+	Prerequisites       []CertificationRequirement `json:"prerequisites,omitempty"`
+}
+
+func (request *CertificationRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	request.Agency = strings.TrimSpace(request.Agency)
+	request.Level = strings.TrimSpace(request.Level)
+	utils.RequireString(errors, "agency", request.Agency, 50)
+	utils.RequireString(errors, "level", request.Level, 100)
+	utils.OptionalString(errors, "certification_number", request.CertificationNumber, 100)
+	validateDateOnly(errors, "certified_on", request.CertifiedOn)
+	utils.OptionalString(errors, "instructor", request.Instructor, 200)
+	utils.OptionalString(errors, "notes", request.Notes, maxTextLength)
+	if len(request.Prerequisites) > maxCertificationPrerequisites {
+		errors.Add("prerequisites", fmt.Sprintf("must contain at most %d requirements", maxCertificationPrerequisites))
+	}
+	for i := range request.Prerequisites {
+		errors.Merge(fmt.Sprintf("prerequisites[%d]", i), request.Prerequisites[i].Validate())
+	}
+	return errors
+}
+
+// CertificationRequirement is one prerequisite such as "50 logged dives" or
+// "10 dives deeper than 30 m". A dive counts when it matches every criterion
+// that is set; LinkedOnly restricts counting to training dives linked to the
+// certification.
+type CertificationRequirement struct {
+	Description string   `json:"description,omitempty"`
+	MinDives    int      `json:"min_dives"`
+	MinDepth    *float64 `json:"min_depth,omitempty"`
+	MinDuration *int     `json:"min_duration,omitempty"`
+	DiveType    *string  `json:"dive_type,omitempty"`
+	LinkedOnly  bool     `json:"linked_only,omitempty"`
+}
+
+func (requirement *CertificationRequirement) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if len([]rune(requirement.Description)) > 200 {
+		errors.Add("description", "must be at most 200 characters")
+	}
+	utils.IntRange(errors, "min_dives", requirement.MinDives, 1, maxRequirementDives)
+	optionalFloatRange(errors, "min_depth", requirement.MinDepth, 0, maxDiveDepth)
+	optionalIntRange(errors, "min_duration", requirement.MinDuration, 1, maxDiveDuration)
+	utils.OptionalOneOf(errors, "dive_type", requirement.DiveType, DiveTypes...)
+	return errors
+}
+
+// RequirementProgress reports how many logged dives satisfy a requirement.
+type RequirementProgress struct {
+	CertificationRequirement
+	Logged    int  `json:"logged"`
+	Remaining int  `json:"remaining"`
+	Met       bool `json:"met"`
+}
+
+// CertificationProgress compares a user's logbook with a certification's
+// prerequisites. Met is true when every requirement is met.
+type CertificationProgress struct {
+	CertificationID   int                   `json:"certification_id"`
+	Agency            string                `json:"agency"`
+	Level             string                `json:"level"`
+	TrainingDiveCount int                   `json:"training_dive_count"`
+	Requirements      []RequirementProgress `json:"requirements"`
+	Met               bool                  `json:"met"`
+}
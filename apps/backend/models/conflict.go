@@ -0,0 +1,87 @@
+package models
+
+import (
+	"divelog-backend/utils"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// FieldDiff is one writable field whose stored value differs from the value a
+// client tried to write. Field is a dotted path in the request's JSON shape,
+// such as "units.depth"; a nil side means the field is absent there.
+type FieldDiff struct {
+	Field  string      `json:"field"`
+	Server interface{} `json:"server"`
+	Client interface{} `json:"client"`
+}
+
+// VersionConflict rejects a write whose If-Match version is no longer the
+// stored one. It carries what a client needs to resolve the conflict: the
+// current record and how the rejected write differs from it.
+type VersionConflict struct {
+	Version int
+	Current interface{}
+	Diff    []FieldDiff
+}
+
+// NewVersionConflict describes a rejected write. current is the record as the
+// API returns it; stored and requested are the record and the rejected write
+// in the same request shape, so they can be compared field by field.
+func NewVersionConflict(version int, current, stored, requested interface{}) *VersionConflict {
+	return &VersionConflict{Version: version, Current: current, Diff: diffFields(stored, requested)}
+}
+
+func (c *VersionConflict) Error() string {
+	return utils.ErrVersionConflict.Error()
+}
+
+func (c *VersionConflict) Unwrap() error {
+	return utils.ErrVersionConflict
+}
+
+// diffFields compares two values through their JSON form. Nested objects are
+// compared key by key; arrays and scalars are compared as a whole.
+func diffFields(server, client interface{}) []FieldDiff {
+	diff := []FieldDiff{}
+	serverFields, serverErr := jsonObject(server)
+	clientFields, clientErr := jsonObject(client)
+	if serverErr != nil || clientErr != nil {
+		return diff
+	}
+	collectFieldDiffs("", serverFields, clientFields, &diff)
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Field < diff[j].Field })
+	return diff
+}
+
+func jsonObject(value interface{}) (map[string]interface{}, error) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	return fields, json.Unmarshal(payload, &fields)
+}
+
+func collectFieldDiffs(prefix string, server, client map[string]interface{}, diff *[]FieldDiff) {
+	keys := map[string]bool{}
+	for key := range server {
+		keys[key] = true
+	}
+	for key := range client {
+		keys[key] = true
+	}
+	for key := range keys {
+		field := prefix + key
+		serverValue, clientValue := server[key], client[key]
+		serverObject, serverIsObject := serverValue.(map[string]interface{})
+		clientObject, clientIsObject := clientValue.(map[string]interface{})
+		if serverIsObject && clientIsObject {
+			collectFieldDiffs(field+".", serverObject, clientObject, diff)
+			continue
+		}
+		if !reflect.DeepEqual(serverValue, clientValue) {
+			*diff = append(*diff, FieldDiff{Field: field, Server: serverValue, Client: clientValue})
+		}
+	}
+}
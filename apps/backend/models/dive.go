@@ -121,6 +121,9 @@ type DiveComputerIdentity struct {
 	DeviceID *string `json:"device_id,omitempty"`
 	Serial   *string `json:"serial,omitempty"`
 	Firmware *string `json:"firmware,omitempty"`
+	// ExtraData keeps vendor-specific device fields that have no column of
+	// their own, such as a Suunto "Battery" reading or a Shearwater deco model.
+	ExtraData map[string]string `json:"extra_data,omitempty"`
 }
 
 // SafetyStop represents a safety stop during the dive
@@ -139,6 +142,7 @@ type Trip struct {
 	EndDate   *string `json:"end_date,omitempty" db:"end_date"`
 	Notes     *string `json:"notes,omitempty" db:"notes"`
 	DiveCount int     `json:"dive_count,omitempty" db:"dive_count"`
+	Version   int     `json:"version,omitempty" db:"version"`
 }
 
 // TripRequest is the writable portion of a trip. It is also embedded in
@@ -151,6 +155,11 @@ type TripRequest struct {
 	Notes     *string `json:"notes,omitempty"`
 }
 
+// ToRequest converts a stored trip back into the writable request shape.
+func (t *Trip) ToRequest() TripRequest {
+	return TripRequest{Name: t.Name, Location: t.Location, StartDate: t.StartDate, EndDate: t.EndDate, Notes: t.Notes}
+}
+
 // TagSummary describes a reusable tag and how many dives currently use it.
 type TagSummary struct {
 	ID        int    `json:"id"`
@@ -166,6 +175,7 @@ type Dive struct {
 	DiveNumber      *int                  `json:"dive_number,omitempty" db:"dive_number"`
 	TripID          *int                  `json:"trip_id,omitempty" db:"trip_id"`
 	Trip            *Trip                 `json:"trip,omitempty"`
+	CertificationID *int                  `json:"certification_id,omitempty" db:"certification_id"` // Course a training dive counts towards
 	Tags            []string              `json:"tags,omitempty"`
 	DateTime        LocalTime             `json:"datetime" db:"dive_datetime"`
 	MaxDepth        float64               `json:"depth" db:"max_depth"`
@@ -187,63 +197,69 @@ type Dive struct {
 	Computer        *DiveComputerIdentity `json:"computer_metadata,omitempty" db:"computer_metadata"`
 	Rating          *int                  `json:"rating,omitempty" db:"rating"`             // Dive rating 1-5 stars
 	SafetyStops     []SafetyStop          `json:"safety_stops,omitempty" db:"safety_stops"` // Safety stops performed
+	ExtraData       map[string]string     `json:"extra_data,omitempty" db:"extra_data"`     // Imported fields without a dedicated column
 	CreatedAt       time.Time             `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time             `json:"updated_at" db:"updated_at"`
+	Version         int                   `json:"version" db:"version"`
 }
 
 // DiveRequest represents the request body for creating/updating dives
 type DiveRequest struct {
-	DateTime    string                `json:"datetime"` // ISO 8601 format
-	Location    string                `json:"location"`
-	Depth       float64               `json:"depth"`
-	MeanDepth   *float64              `json:"mean_depth,omitempty"`
-	Duration    int                   `json:"duration"`
-	Buddy       *string               `json:"buddy,omitempty"`
-	Lat         float64               `json:"lat"`
-	Lng         float64               `json:"lng"`
-	WaterTemp   *float64              `json:"water_temperature,omitempty"`
-	Visibility  *int                  `json:"visibility,omitempty"`
-	Notes       *string               `json:"notes,omitempty"`
-	Samples     []DiveSample          `json:"samples,omitempty"`
-	Equipment   *Equipment            `json:"equipment,omitempty"`
-	Conditions  *DiveConditions       `json:"conditions,omitempty"`
-	DiveType    *string               `json:"dive_type,omitempty"`
-	DiveMode    *string               `json:"dive_mode,omitempty"`
-	Computer    *DiveComputerIdentity `json:"computer_metadata,omitempty"`
-	Rating      *int                  `json:"rating,omitempty"`
-	SafetyStops []SafetyStop          `json:"safety_stops,omitempty"`
-	DiveNumber  *int                  `json:"dive_number,omitempty"`
-	TripID      *int                  `json:"trip_id,omitempty"`
-	Trip        *TripRequest          `json:"trip,omitempty"`
-	Tags        []string              `json:"tags,omitempty"`
+	DateTime        string                `json:"datetime"` // ISO 8601 format
+	Location        string                `json:"location"`
+	Depth           float64               `json:"depth"`
+	MeanDepth       *float64              `json:"mean_depth,omitempty"`
+	Duration        int                   `json:"duration"`
+	Buddy           *string               `json:"buddy,omitempty"`
+	Lat             float64               `json:"lat"`
+	Lng             float64               `json:"lng"`
+	WaterTemp       *float64              `json:"water_temperature,omitempty"`
+	Visibility      *int                  `json:"visibility,omitempty"`
+	Notes           *string               `json:"notes,omitempty"`
+	Samples         []DiveSample          `json:"samples,omitempty"`
+	Equipment       *Equipment            `json:"equipment,omitempty"`
+	Conditions      *DiveConditions       `json:"conditions,omitempty"`
+	DiveType        *string               `json:"dive_type,omitempty"`
+	DiveMode        *string               `json:"dive_mode,omitempty"`
+	Computer        *DiveComputerIdentity `json:"computer_metadata,omitempty"`
+	Rating          *int                  `json:"rating,omitempty"`
+	SafetyStops     []SafetyStop          `json:"safety_stops,omitempty"`
+	DiveNumber      *int                  `json:"dive_number,omitempty"`
+	TripID          *int                  `json:"trip_id,omitempty"`
+	Trip            *TripRequest          `json:"trip,omitempty"`
+	CertificationID *int                  `json:"certification_id,omitempty"`
+	Tags            []string              `json:"tags,omitempty"`
+	ExtraData       map[string]string     `json:"extra_data,omitempty"`
 }
 
 // ToDive converts a DiveRequest to Dive
 func (dr *DiveRequest) ToDive(userID int) *Dive {
 	dive := &Dive{
-		UserID:      userID,
-		DateTime:    LocalTime{utils.ParseDateTime(dr.DateTime)},
-		Location:    dr.Location,
-		MaxDepth:    dr.Depth,
-		MeanDepth:   dr.MeanDepth,
-		Duration:    dr.Duration,
-		Buddy:       dr.Buddy,
-		Latitude:    dr.Lat,
-		Longitude:   dr.Lng,
-		WaterTemp:   dr.WaterTemp,
-		Visibility:  dr.Visibility,
-		Notes:       dr.Notes,
-		Samples:     dr.Samples,
-		Equipment:   dr.Equipment,
-		Conditions:  dr.Conditions,
-		DiveType:    dr.DiveType,
-		DiveMode:    dr.DiveMode,
-		Computer:    dr.Computer,
-		Rating:      dr.Rating,
-		SafetyStops: dr.SafetyStops,
-		DiveNumber:  dr.DiveNumber,
-		TripID:      dr.TripID,
-		Tags:        dr.Tags,
+		UserID:          userID,
+		DateTime:        LocalTime{utils.ParseDateTime(dr.DateTime)},
+		Location:        dr.Location,
+		MaxDepth:        dr.Depth,
+		MeanDepth:       dr.MeanDepth,
+		Duration:        dr.Duration,
+		Buddy:           dr.Buddy,
+		Latitude:        dr.Lat,
+		Longitude:       dr.Lng,
+		WaterTemp:       dr.WaterTemp,
+		Visibility:      dr.Visibility,
+		Notes:           dr.Notes,
+		Samples:         dr.Samples,
+		Equipment:       dr.Equipment,
+		Conditions:      dr.Conditions,
+		DiveType:        dr.DiveType,
+		DiveMode:        dr.DiveMode,
+		Computer:        dr.Computer,
+		Rating:          dr.Rating,
+		SafetyStops:     dr.SafetyStops,
+		DiveNumber:      dr.DiveNumber,
+		TripID:          dr.TripID,
+		CertificationID: dr.CertificationID,
+		Tags:            dr.Tags,
+		ExtraData:       dr.ExtraData,
 	}
 	if dive.MeanDepth == nil {
 		dive.MeanDepth = CalculateMeanDepth(dive.Samples)
@@ -286,13 +302,31 @@ func CalculateMeanDepth(samples []DiveSample) *float64 {
 
 // DiveSite represents a dive site
 type DiveSite struct {
-	ID          int       `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	Latitude    float64   `json:"latitude" db:"latitude"`
-	Longitude   float64   `json:"longitude" db:"longitude"`
-	Description *string   `json:"description,omitempty" db:"description"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID          int     `json:"id" db:"id"`
+	Name        string  `json:"name" db:"name"`
+	Latitude    float64 `json:"latitude" db:"latitude"`
+	Longitude   float64 `json:"longitude" db:"longitude"`
+	Description *string `json:"description,omitempty" db:"description"`
+	DiveSiteAttributes
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// DiveSiteAttributes describes the site itself rather than any dive there.
+// Depths are in meters. Country, Region and Area form a location hierarchy,
+// for example Egypt / South Sinai / Dahab. CountryCode is ISO 3166-1 alpha-2.
+type DiveSiteAttributes struct {
+	MaxDepth     *float64 `json:"max_depth,omitempty" db:"max_depth"`
+	TypicalDepth *float64 `json:"typical_depth,omitempty" db:"typical_depth"`
+	EntryType    *string  `json:"entry_type,omitempty" db:"entry_type"`
+	Environment  *string  `json:"environment,omitempty" db:"environment"`
+	WaterType    *string  `json:"water_type,omitempty" db:"water_type"`
+	CountryCode  *string  `json:"country_code,omitempty" db:"country_code"`
+	Country      *string  `json:"country,omitempty" db:"country"`
+	Region       *string  `json:"region,omitempty" db:"region"`
+	Area         *string  `json:"area,omitempty" db:"area"`
+	AccessNotes  *string  `json:"access_notes,omitempty" db:"access_notes"`
+	Hazards      *string  `json:"hazards,omitempty" db:"hazards"`
 }
 
 // DiveSiteRequest represents the request body for creating/updating dive sites
@@ -301,14 +335,16 @@ type DiveSiteRequest struct {
 	Latitude    float64 `json:"latitude"`
 	Longitude   float64 `json:"longitude"`
 	Description *string `json:"description,omitempty"`
+	DiveSiteAttributes
 }
 
 // ToDiveSite converts a DiveSiteRequest to DiveSite
 func (dsr *DiveSiteRequest) ToDiveSite() *DiveSite {
 	return &DiveSite{
-		Name:        dsr.Name,
-		Latitude:    dsr.Latitude,
-		Longitude:   dsr.Longitude,
-		Description: dsr.Description,
+		Name:               dsr.Name,
+		Latitude:           dsr.Latitude,
+		Longitude:          dsr.Longitude,
+		Description:        dsr.Description,
+		DiveSiteAttributes: dsr.DiveSiteAttributes,
 	}
 }
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Dive revision sources name the edit that replaced a revision.
+const (
+	DiveRevisionSourceUpdate     = "update"
+	DiveRevisionSourceBulkUpdate = "bulk_update"
+	DiveRevisionSourceTimeShift  = "time_shift"
+	DiveRevisionSourceUndo       = "undo"
+	DiveRevisionSourceRestore    = "restore"
+)
+
+// DiveRevision is an earlier version of a dive. Changes maps each field the
+// following edit changed to its "before" and "after" values, where "after"
+// comes from the next revision or, for the latest one, the current dive. Tag
+// changes show as a change of "tags".
+type DiveRevision struct {
+	Revision    int             `json:"revision"`
+	Source      string          `json:"source"`
+	DiveVersion int             `json:"dive_version"`
+	Changes     json.RawMessage `json:"changes"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
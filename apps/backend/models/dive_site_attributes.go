@@ -0,0 +1,95 @@
+package models
+
+import "divelog-backend/utils"
+
+// Place is the country and first-level region containing a point.
+type Place struct {
+	CountryCode string
+	Country     string
+	Region      string
+}
+
+// Allowed values for the enumerated DiveSiteAttributes, matching the CHECK
+// constraints on dive_sites.
+var (
+	DiveSiteEntryTypes   = []string{"shore", "boat"}
+	DiveSiteEnvironments = []string{"reef", "wreck", "cave", "lake", "quarry"}
+	DiveSiteWaterTypes   = []string{"salt", "fresh", "brackish"}
+)
+
+// DiveSiteFilter is the query for GET /api/v1/dive-sites. Empty fields do not
+// filter; text fields match case-insensitively. MinDepth and MaxDepth bound
+// the site's maximum depth.
+type DiveSiteFilter struct {
+	CountryCode string   `form:"country_code"`
+	Country     string   `form:"country"`
+	Region      string   `form:"region"`
+	Area        string   `form:"area"`
+	EntryType   string   `form:"entry_type"`
+	Environment string   `form:"environment"`
+	WaterType   string   `form:"water_type"`
+	MinDepth    *float64 `form:"min_depth"`
+	MaxDepth    *float64 `form:"max_depth"`
+}
+
+func (filter *DiveSiteFilter) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if filter.EntryType != "" {
+		utils.OneOf(errors, "entry_type", filter.EntryType, DiveSiteEntryTypes...)
+	}
+	if filter.Environment != "" {
+		utils.OneOf(errors, "environment", filter.Environment, DiveSiteEnvironments...)
+	}
+	if filter.WaterType != "" {
+		utils.OneOf(errors, "water_type", filter.WaterType, DiveSiteWaterTypes...)
+	}
+	optionalFloatRange(errors, "min_depth", filter.MinDepth, 0, maxDiveDepth)
+	optionalFloatRange(errors, "max_depth", filter.MaxDepth, 0, maxDiveDepth)
+	if filter.MinDepth != nil && filter.MaxDepth != nil && *filter.MinDepth > *filter.MaxDepth {
+		errors.Add("max_depth", "must be greater than or equal to min_depth")
+	}
+	return errors
+}
+
+// DiveSiteDetail is a dive site with the requesting user's history there.
+// DiveHistory is omitted when the request names no user.
+type DiveSiteDetail struct {
+	DiveSite
+	DiveHistory *DiveSiteHistory `json:"dive_history,omitempty"`
+}
+
+// DiveSiteHistory aggregates one user's dives at a site. Depths are in meters,
+// durations in minutes and temperatures in Celsius.
+type DiveSiteHistory struct {
+	DiveCount               int        `json:"dive_count"`
+	FirstDived              *LocalTime `json:"first_dived,omitempty"`
+	LastDived               *LocalTime `json:"last_dived,omitempty"`
+	DeepestDive             *float64   `json:"deepest_dive,omitempty"`
+	AverageDepth            *float64   `json:"average_depth,omitempty"`
+	TotalDuration           int        `json:"total_duration"`
+	AverageWaterTemperature *float64   `json:"average_water_temperature,omitempty"`
+	MinWaterTemperature     *float64   `json:"min_water_temperature,omitempty"`
+	AverageVisibility       *float64   `json:"average_visibility,omitempty"`
+}
+
+// GeocodeBackfillResult summarizes a reverse-geocoding backfill run. Unmatched
+// sites lie outside every boundary in the dataset, or in a country other than
+// the one already recorded for them.
+type GeocodeBackfillResult struct {
+	Scanned   int `json:"scanned"`
+	Updated   int `json:"updated"`
+	Unmatched int `json:"unmatched"`
+}
+
+// CountryStatistics aggregates one user's dives by the country of their dive
+// site. CountryCode and Country are nil for dives at sites with no country.
+type CountryStatistics struct {
+	CountryCode   *string    `json:"country_code"`
+	Country       *string    `json:"country"`
+	DiveCount     int        `json:"dive_count"`
+	SiteCount     int        `json:"site_count"`
+	DeepestDive   *float64   `json:"deepest_dive,omitempty"`
+	TotalDuration int        `json:"total_duration"`
+	FirstDived    *LocalTime `json:"first_dived,omitempty"`
+	LastDived     *LocalTime `json:"last_dived,omitempty"`
+}
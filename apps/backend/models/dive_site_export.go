@@ -0,0 +1,131 @@
+package models
+
+import (
+	"divelog-backend/utils"
+	"fmt"
+	"strings"
+)
+
+const maxGeoJSONImportFeatures = 5000
+
+// DiveSiteExportFormats lists the supported export formats with their MIME
+// types.
+var DiveSiteExportFormats = map[string]string{
+	"kml":     "application/vnd.google-earth.kml+xml",
+	"geojson": "application/geo+json",
+	"gpx":     "application/gpx+xml",
+}
+
+// DiveSiteExportRequest is the query for GET /api/v1/dive-sites/export.
+type DiveSiteExportRequest struct {
+	Format    string `form:"format"`
+	DivedOnly bool   `form:"dived_only"`
+}
+
+func (request *DiveSiteExportRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if request.Format == "" {
+		request.Format = "geojson"
+	}
+	utils.OneOf(errors, "format", request.Format, "kml", "geojson", "gpx")
+	return errors
+}
+
+// DiveSiteExportEntry is a dive site with statistics from the requesting
+// user's dives there. DeepestDive is exported as max_depth.
+type DiveSiteExportEntry struct {
+	DiveSite
+	DiveCount   int        `json:"dive_count"`
+	DeepestDive *float64   `json:"deepest_dive,omitempty"`
+	LastDived   *LocalTime `json:"last_dived,omitempty"`
+}
+
+// GeoJSONFeatureCollection is the subset of RFC 7946 used for dive-site
+// import and export.
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *GeoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// Validate accepts Point features carrying a name property; other geometry
+// types cannot be represented as dive sites.
+func (collection *GeoJSONFeatureCollection) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if collection.Type != "FeatureCollection" {
+		errors.Add("type", "must be FeatureCollection")
+	}
+	if len(collection.Features) == 0 {
+		errors.Add("features", "must contain at least one feature")
+	}
+	if len(collection.Features) > maxGeoJSONImportFeatures {
+		errors.Add("features", fmt.Sprintf("must contain at most %d features", maxGeoJSONImportFeatures))
+	}
+	for i, feature := range collection.Features {
+		prefix := fmt.Sprintf("features[%d]", i)
+		if feature.Type != "Feature" {
+			errors.Add(prefix+".type", "must be Feature")
+		}
+		if feature.Geometry == nil || feature.Geometry.Type != "Point" {
+			errors.Add(prefix+".geometry", "must be a Point")
+			continue
+		}
+		if count := len(feature.Geometry.Coordinates); count < 2 || count > 3 {
+			errors.Add(prefix+".geometry.coordinates", "must be [longitude, latitude] or [longitude, latitude, altitude]")
+			continue
+		}
+		request := feature.ToDiveSiteRequest()
+		site := request.Validate()
+		for _, field := range []string{"name", "description"} {
+			if message, ok := site[field]; ok {
+				errors.Add(prefix+".properties."+field, message)
+			}
+		}
+		for _, field := range []string{"longitude", "latitude"} {
+			if message, ok := site[field]; ok {
+				errors.Add(prefix+".geometry.coordinates", field+" "+message)
+			}
+		}
+	}
+	return errors
+}
+
+// ToDiveSiteRequest maps a Point feature to a dive-site request. GeoJSON orders
+// coordinates longitude first.
+func (feature *GeoJSONFeature) ToDiveSiteRequest() DiveSiteRequest {
+	request := DiveSiteRequest{}
+	if feature.Geometry != nil && len(feature.Geometry.Coordinates) >= 2 {
+		request.Longitude = feature.Geometry.Coordinates[0]
+		request.Latitude = feature.Geometry.Coordinates[1]
+	}
+	if name, ok := feature.Properties["name"].(string); ok {
+		request.Name = strings.TrimSpace(name)
+	}
+	if description, ok := feature.Properties["description"].(string); ok && strings.TrimSpace(description) != "" {
+		request.Description = &description
+	}
+	return request
+}
+
+// DiveSiteImportResult reports which features created sites and which matched
+// an existing site within the duplicate distance.
+type DiveSiteImportResult struct {
+	Created []DiveSite              `json:"created"`
+	Skipped []SkippedDiveSiteImport `json:"skipped"`
+}
+
+type SkippedDiveSiteImport struct {
+	Index      int    `json:"index"`
+	Name       string `json:"name"`
+	ExistingID int    `json:"existing_id"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import "divelog-backend/utils"
+
+const (
+	defaultDuplicateMaxDistanceKM = 2
+	maxDuplicateMaxDistanceKM     = 50
+	defaultDuplicateMinScore      = 0.6
+	defaultDuplicateLimit         = 50
+	maxDuplicateLimit             = 500
+)
+
+// MergeDiveSitesRequest names the sites folded into the target of
+// POST /api/v1/dive-sites/:id/merge.
+type MergeDiveSitesRequest struct {
+	SourceSiteIDs []int `json:"source_site_ids"`
+}
+
+func (request *MergeDiveSitesRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if len(request.SourceSiteIDs) == 0 {
+		errors.Add("source_site_ids", "must contain at least one dive site")
+	}
+	for _, id := range request.SourceSiteIDs {
+		if id <= 0 {
+			errors.Add("source_site_ids", "must contain only positive dive site IDs")
+			break
+		}
+	}
+	return errors
+}
+
+// DiveSiteMergeResult reports the surviving site and what was folded into it.
+type DiveSiteMergeResult struct {
+	Target          DiveSite `json:"target"`
+	MergedSiteIDs   []int    `json:"merged_site_ids"`
+	ReassignedDives int64    `json:"reassigned_dives"`
+}
+
+// DiveSiteDuplicatesRequest is the query for GET /api/v1/dive-sites/duplicates.
+type DiveSiteDuplicatesRequest struct {
+	MaxDistanceKM float64 `form:"max_distance"`
+	MinScore      float64 `form:"min_score"`
+	Limit         int     `form:"limit"`
+}
+
+func (request *DiveSiteDuplicatesRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if request.MaxDistanceKM == 0 {
+		request.MaxDistanceKM = defaultDuplicateMaxDistanceKM
+	}
+	if request.MaxDistanceKM < 0 || request.MaxDistanceKM > maxDuplicateMaxDistanceKM {
+		errors.Add("max_distance", "must be greater than 0 and at most 50 km")
+	}
+	if request.MinScore == 0 {
+		request.MinScore = defaultDuplicateMinScore
+	}
+	utils.FloatRange(errors, "min_score", request.MinScore, 0, 1)
+	if request.Limit == 0 {
+		request.Limit = defaultDuplicateLimit
+	}
+	utils.IntRange(errors, "limit", request.Limit, 1, maxDuplicateLimit)
+	return errors
+}
+
+// DiveSitePair is two sites within the duplicate search distance of each other.
+type DiveSitePair struct {
+	Site       DiveSite `json:"site"`
+	Other      DiveSite `json:"other"`
+	DistanceKM float64  `json:"distance_km"`
+}
+
+// DiveSiteDuplicateCandidate is a scored DiveSitePair. NameSimilarity and
+// Score are between 0 and 1; higher means more likely the same site.
+type DiveSiteDuplicateCandidate struct {
+	DiveSitePair
+	NameSimilarity float64 `json:"name_similarity"`
+	Score          float64 `json:"score"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import "divelog-backend/utils"
+
+const (
+	defaultGeoLimit = 200
+	maxGeoLimit     = 1000
+	// MaxNearbyRadiusKM bounds nearby searches so they stay index-assisted.
+	MaxNearbyRadiusKM = 500
+	// MaxClusterZoom is the deepest web-map zoom level clustering supports.
+	MaxClusterZoom = 22
+)
+
+// BoundingBox is a map viewport in degrees. West may be greater than East when
+// the box crosses the antimeridian.
+type BoundingBox struct {
+	South float64 `json:"south" form:"south"`
+	West  float64 `json:"west" form:"west"`
+	North float64 `json:"north" form:"north"`
+	East  float64 `json:"east" form:"east"`
+}
+
+// CrossesAntimeridian reports whether the box wraps from 180° to -180°.
+func (box BoundingBox) CrossesAntimeridian() bool {
+	return box.West > box.East
+}
+
+func (box *BoundingBox) validate(errors utils.ValidationErrors) {
+	utils.FloatRange(errors, "south", box.South, -90, 90)
+	utils.FloatRange(errors, "north", box.North, -90, 90)
+	utils.FloatRange(errors, "west", box.West, -180, 180)
+	utils.FloatRange(errors, "east", box.East, -180, 180)
+	if box.South > box.North {
+		errors.Add("north", "must be greater than or equal to south")
+	}
+}
+
+// NearbyDiveSitesRequest is the query for GET /api/v1/dive-sites/nearby.
+type NearbyDiveSitesRequest struct {
+	Latitude  float64 `form:"lat"`
+	Longitude float64 `form:"lng"`
+	RadiusKM  float64 `form:"radius"`
+	Limit     int     `form:"limit"`
+}
+
+func (request *NearbyDiveSitesRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	utils.FloatRange(errors, "lat", request.Latitude, -90, 90)
+	utils.FloatRange(errors, "lng", request.Longitude, -180, 180)
+	if request.RadiusKM <= 0 || request.RadiusKM > MaxNearbyRadiusKM {
+		errors.Add("radius", "must be greater than 0 and at most 500 km")
+	}
+	validateGeoLimit(errors, &request.Limit)
+	return errors
+}
+
+// BoundingBoxDiveSitesRequest is the query for GET /api/v1/dive-sites/bbox.
+type BoundingBoxDiveSitesRequest struct {
+	BoundingBox
+	Limit int `form:"limit"`
+}
+
+func (request *BoundingBoxDiveSitesRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	request.BoundingBox.validate(errors)
+	validateGeoLimit(errors, &request.Limit)
+	return errors
+}
+
+// DiveSiteClustersRequest is the query for GET /api/v1/dive-sites/clusters.
+type DiveSiteClustersRequest struct {
+	BoundingBox
+	Zoom int `form:"zoom"`
+}
+
+func (request *DiveSiteClustersRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	request.BoundingBox.validate(errors)
+	utils.IntRange(errors, "zoom", request.Zoom, 0, MaxClusterZoom)
+	return errors
+}
+
+func validateGeoLimit(errors utils.ValidationErrors, limit *int) {
+	if *limit == 0 {
+		*limit = defaultGeoLimit
+	}
+	utils.IntRange(errors, "limit", *limit, 1, maxGeoLimit)
+}
+
+// NearbyDiveSite is a dive site with its great-circle distance from the query point.
+type NearbyDiveSite struct {
+	DiveSite
+	DistanceKM float64 `json:"distance_km"`
+}
+
+// DiveSiteCluster summarizes the sites sharing a geohash cell. Latitude and
+// Longitude are the centroid of its sites; Site is set when the cell holds a
+// single site so clients can render it as an ordinary marker.
+type DiveSiteCluster struct {
+	Geohash   string      `json:"geohash"`
+	Count     int         `json:"count"`
+	Latitude  float64     `json:"latitude"`
+	Longitude float64     `json:"longitude"`
+	Bounds    BoundingBox `json:"bounds"`
+	Site      *DiveSite   `json:"site,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+// IdempotencyRecord is what is stored for the first request made with an
+// Idempotency-Key. Response is nil while that request is still running.
+type IdempotencyRecord struct {
+	RequestHash string
+	Response    *IdempotentResponse
+}
+
+// IdempotentResponse is a stored response, replayed for retries of the same
+// request.
+type IdempotentResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
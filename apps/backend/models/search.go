@@ -0,0 +1,47 @@
+package models
+
+import (
+	"divelog-backend/utils"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// SearchRequest is the query accepted by GET /api/v1/search. Query uses web
+// search syntax: quoted phrases, `or`, and `-` to exclude a word.
+type SearchRequest struct {
+	Query string
+	Limit int
+}
+
+func (request *SearchRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	request.Query = strings.TrimSpace(request.Query)
+	utils.RequireString(errors, "q", request.Query, 200)
+	if request.Limit == 0 {
+		request.Limit = defaultSearchLimit
+	}
+	utils.IntRange(errors, "limit", request.Limit, 1, maxSearchLimit)
+	return errors
+}
+
+// SearchHit is one ranked match. Headline is an HTML-escaped excerpt with the
+// matched words wrapped in <mark> elements.
+type SearchHit struct {
+	ID       int        `json:"id"`
+	Title    string     `json:"title"`
+	Date     *LocalTime `json:"date,omitempty"`
+	Headline string     `json:"headline"`
+	Rank     float64    `json:"rank"`
+}
+
+// SearchResults groups hits by entity type, each ordered by rank.
+type SearchResults struct {
+	Query     string      `json:"query"`
+	Dives     []SearchHit `json:"dives"`
+	DiveSites []SearchHit `json:"dive_sites"`
+	Trips     []SearchHit `json:"trips"`
+}
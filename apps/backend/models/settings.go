@@ -31,6 +31,7 @@ type UserSettings struct {
 
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Version   int       `json:"version" db:"version"`
 }
 
 // SettingsRequest represents the request body for updating settings
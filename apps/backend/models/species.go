@@ -0,0 +1,141 @@
+package models
+
+import (
+	"divelog-backend/utils"
+	"fmt"
+	"strings"
+)
+
+const (
+	maxSightingsPerDive = 200
+	maxSightingCount    = 100000
+)
+
+// SpeciesCategories lists the allowed Species.Category values, matching the
+// CHECK constraint on species.
+var SpeciesCategories = []string{
+	"fish", "shark", "ray", "reptile", "mammal", "cephalopod",
+	"crustacean", "mollusc", "echinoderm", "cnidarian", "other",
+}
+
+// Species is an entry in the shared marine-life catalog.
+type Species struct {
+	ID             int     `json:"id"`
+	CommonName     string  `json:"common_name"`
+	ScientificName *string `json:"scientific_name,omitempty"`
+	Category       string  `json:"category"`
+}
+
+// SpeciesRequest adds a species to the catalog.
+type SpeciesRequest struct {
+	CommonName     string  `json:"common_name"`
+	ScientificName *string `json:"scientific_name,omitempty"`
+	Category       string  `json:"category"`
+}
+
+func (request *SpeciesRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	request.CommonName = strings.TrimSpace(request.CommonName)
+	utils.RequireString(errors, "common_name", request.CommonName, 200)
+	utils.OptionalString(errors, "scientific_name", request.ScientificName, 200)
+	utils.OneOf(errors, "category", request.Category, SpeciesCategories...)
+	return errors
+}
+
+// SpeciesFilter narrows the catalog and the life list. Query matches common
+// or scientific names case-insensitively.
+type SpeciesFilter struct {
+	Query    string `form:"q"`
+	Category string `form:"category"`
+}
+
+func (filter *SpeciesFilter) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	filter.Query = strings.TrimSpace(filter.Query)
+	if len(filter.Query) > 200 {
+		errors.Add("q", "must be at most 200 characters")
+	}
+	if filter.Category != "" {
+		utils.OneOf(errors, "category", filter.Category, SpeciesCategories...)
+	}
+	return errors
+}
+
+// SightingRequest records one species seen on a dive. Count defaults to 1.
+type SightingRequest struct {
+	SpeciesID int     `json:"species_id"`
+	Count     int     `json:"count"`
+	Notes     *string `json:"notes,omitempty"`
+}
+
+// DiveSightingsRequest replaces every sighting recorded for a dive.
+type DiveSightingsRequest struct {
+	Sightings []SightingRequest `json:"sightings"`
+}
+
+func (request *DiveSightingsRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if len(request.Sightings) > maxSightingsPerDive {
+		errors.Add("sightings", fmt.Sprintf("must contain at most %d species", maxSightingsPerDive))
+	}
+	seen := map[int]bool{}
+	for i := range request.Sightings {
+		prefix := fmt.Sprintf("sightings[%d]", i)
+		sighting := &request.Sightings[i]
+		if sighting.SpeciesID <= 0 {
+			errors.Add(prefix+".species_id", "must be a positive integer")
+		} else if seen[sighting.SpeciesID] {
+			errors.Add(prefix+".species_id", "must not duplicate another sighting")
+		}
+		seen[sighting.SpeciesID] = true
+		if sighting.Count == 0 {
+			sighting.Count = 1
+		}
+		utils.IntRange(errors, prefix+".count", sighting.Count, 1, maxSightingCount)
+		utils.OptionalString(errors, prefix+".notes", sighting.Notes, 1000)
+	}
+	return errors
+}
+
+// Sighting is a species seen on a dive.
+type Sighting struct {
+	Species Species `json:"species"`
+	Count   int     `json:"count"`
+	Notes   *string `json:"notes,omitempty"`
+}
+
+// SpeciesSightingSite aggregates one user's sightings of a species at one
+// dive site. DiveSiteID is nil for dives without a site.
+type SpeciesSightingSite struct {
+	DiveSiteID *int      `json:"dive_site_id"`
+	Name       string    `json:"name"`
+	Latitude   *float64  `json:"latitude,omitempty"`
+	Longitude  *float64  `json:"longitude,omitempty"`
+	Country    *string   `json:"country,omitempty"`
+	DiveCount  int       `json:"dive_count"`
+	TotalCount int       `json:"total_count"`
+	FirstSeen  LocalTime `json:"first_seen"`
+	LastSeen   LocalTime `json:"last_seen"`
+}
+
+// SpeciesSightings answers "where have I seen this species": the user's
+// sightings grouped by site, most-visited site first.
+type SpeciesSightings struct {
+	Species    Species               `json:"species"`
+	DiveCount  int                   `json:"dive_count"`
+	TotalCount int                   `json:"total_count"`
+	Sites      []SpeciesSightingSite `json:"sites"`
+}
+
+// LifeListEntry is one species on a user's life list with where and when it
+// was first seen.
+type LifeListEntry struct {
+	Species       Species   `json:"species"`
+	DiveCount     int       `json:"dive_count"`
+	TotalCount    int       `json:"total_count"`
+	SiteCount     int       `json:"site_count"`
+	FirstSeen     LocalTime `json:"first_seen"`
+	FirstDiveID   int       `json:"first_dive_id"`
+	FirstSiteName string    `json:"first_site_name"`
+	LastSeen      LocalTime `json:"last_seen"`
+}
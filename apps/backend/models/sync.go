@@ -0,0 +1,57 @@
+package models
+
+import (
+	"divelog-backend/utils"
+	"time"
+)
+
+// Change feed entity types and operations, matching the CHECK constraints on
+// change_log.
+const (
+	SyncEntityDive     = "dive"
+	SyncEntityTrip     = "trip"
+	SyncEntityTag      = "tag"
+	SyncEntityDiveSite = "dive_site"
+	SyncEntitySettings = "settings"
+
+	SyncOperationUpsert = "upsert"
+	SyncOperationDelete = "delete"
+)
+
+// SyncRequest asks for the changes after cursor Since; 0 returns the whole
+// logbook.
+type SyncRequest struct {
+	Since int64 `form:"since"`
+	Limit int   `form:"limit"`
+}
+
+func (request *SyncRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if request.Since < 0 {
+		errors.Add("since", "must be a cursor returned by an earlier sync or 0")
+	}
+	if request.Limit == 0 {
+		request.Limit = 500
+	}
+	utils.IntRange(errors, "limit", request.Limit, 1, 1000)
+	return errors
+}
+
+// SyncChange is the latest change to one entity. Data holds the entity's
+// current state for upserts and is omitted for deletes.
+type SyncChange struct {
+	Seq        int64       `json:"seq"`
+	EntityType string      `json:"entity_type"`
+	EntityID   int         `json:"entity_id"`
+	Operation  string      `json:"operation"`
+	ChangedAt  time.Time   `json:"changed_at"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+// SyncResponse is one page of the change feed. Clients store Cursor and pass
+// it as since next time; HasMore means the next page is already available.
+type SyncResponse struct {
+	Changes []SyncChange `json:"changes"`
+	Cursor  int64        `json:"cursor"`
+	HasMore bool         `json:"has_more"`
+}
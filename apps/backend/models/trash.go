@@ -0,0 +1,39 @@
+package models
+
+import (
+	"divelog-backend/utils"
+	"time"
+)
+
+// TrashItemTypes lists what can be in the trash. Items are named by their
+// audit log entity type.
+var TrashItemTypes = []string{AuditEntityDive, AuditEntityTrip, AuditEntityTag, AuditEntityDiveSite}
+
+// TrashItem is a deleted dive, trip, tag or dive site that can still be
+// restored. Dives are named by their location or site, falling back to their
+// date and time.
+type TrashItem struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"` // When the retention job deletes it for good
+}
+
+// TrashFilter narrows the trash to one item type.
+type TrashFilter struct {
+	Type string `form:"type"`
+}
+
+func (filter *TrashFilter) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if filter.Type != "" {
+		utils.OneOf(errors, "type", filter.Type, TrashItemTypes...)
+	}
+	return errors
+}
+
+// TrashPurgeResult reports how many items emptying the trash deleted.
+type TrashPurgeResult struct {
+	Purged int64 `json:"purged"`
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"divelog-backend/utils"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
@@ -12,8 +14,22 @@ const (
 	maxDiveDuration    = 1440
 	maxTextLength      = 10000
 	maxEquipmentString = 255
+
+	maxExtraDataEntries = 100
+	maxExtraDataKey     = 64
+	maxExtraDataValue   = 1000
+	maxExtraDataBytes   = 16 * 1024
 )
 
+// extraDataKeyPattern keeps vendor keys usable as JSON object keys and query
+// parameters without escaping.
+var extraDataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]*$`)
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// DiveTypes lists the allowed Dive.DiveType values.
+var DiveTypes = []string{"recreational", "training", "technical", "work", "research"}
+
 // Validate applies API and database constraints to a dive request.
 func (dr *DiveRequest) Validate() utils.ValidationErrors {
 	errors := utils.ValidationErrors{}
@@ -36,8 +52,7 @@ func (dr *DiveRequest) Validate() utils.ValidationErrors {
 	utils.OptionalString(errors, "notes", dr.Notes, maxTextLength)
 	optionalFloatRange(errors, "water_temperature", dr.WaterTemp, -273.15, 100)
 	optionalIntRange(errors, "visibility", dr.Visibility, 0, 1000)
-	utils.OptionalOneOf(errors, "dive_type", dr.DiveType,
-		"recreational", "training", "technical", "work", "research")
+	utils.OptionalOneOf(errors, "dive_type", dr.DiveType, DiveTypes...)
 	utils.OptionalOneOf(errors, "dive_mode", dr.DiveMode, "OC", "freedive", "CCR", "pSCR")
 	if dr.Computer != nil {
 		utils.OptionalString(errors, "computer_metadata.vendor", dr.Computer.Vendor, maxEquipmentString)
@@ -45,7 +60,9 @@ func (dr *DiveRequest) Validate() utils.ValidationErrors {
 		utils.OptionalString(errors, "computer_metadata.device_id", dr.Computer.DeviceID, maxEquipmentString)
 		utils.OptionalString(errors, "computer_metadata.serial", dr.Computer.Serial, maxEquipmentString)
 		utils.OptionalString(errors, "computer_metadata.firmware", dr.Computer.Firmware, maxEquipmentString)
+		validateExtraData(errors, "computer_metadata.extra_data", dr.Computer.ExtraData)
 	}
+	validateExtraData(errors, "extra_data", dr.ExtraData)
 	optionalIntRange(errors, "rating", dr.Rating, 1, 5)
 	optionalIntRange(errors, "dive_number", dr.DiveNumber, 1, 10000000)
 	if dr.TripID != nil && *dr.TripID <= 0 {
@@ -57,6 +74,14 @@ func (dr *DiveRequest) Validate() utils.ValidationErrors {
 	if dr.Trip != nil {
 		errors.Merge("trip", dr.Trip.Validate())
 	}
+	if dr.CertificationID != nil {
+		if *dr.CertificationID <= 0 {
+			errors.Add("certification_id", "must be a positive integer")
+		}
+		if dr.DiveType == nil || *dr.DiveType != "training" {
+			errors.Add("certification_id", "requires dive_type training")
+		}
+	}
 	seenTags := map[string]bool{}
 	for i, tag := range dr.Tags {
 		trimmed := strings.TrimSpace(tag)
@@ -111,6 +136,39 @@ func (tr *TripRequest) Validate() utils.ValidationErrors {
 	return errors
 }
 
+// ValidExtraDataKey reports whether key may be stored in an extra_data map.
+func ValidExtraDataKey(key string) bool {
+	return len(key) <= maxExtraDataKey && extraDataKeyPattern.MatchString(key)
+}
+
+// validateExtraData bounds the free-form vendor fields so a single import
+// cannot turn a dive row into an arbitrary document store.
+func validateExtraData(errors utils.ValidationErrors, field string, data map[string]string) {
+	if len(data) > maxExtraDataEntries {
+		errors.Add(field, fmt.Sprintf("must contain at most %d entries", maxExtraDataEntries))
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	size := 0
+	for _, key := range keys {
+		value := data[key]
+		path := fmt.Sprintf("%s[%q]", field, key)
+		if !ValidExtraDataKey(key) {
+			errors.Add(path, fmt.Sprintf("key must be at most %d letters, digits, '_', '.', ':' or '-' and start with a letter or digit", maxExtraDataKey))
+		}
+		if len([]rune(value)) > maxExtraDataValue {
+			errors.Add(path, fmt.Sprintf("must be at most %d characters", maxExtraDataValue))
+		}
+		size += len(key) + len(value)
+	}
+	if size > maxExtraDataBytes {
+		errors.Add(field, fmt.Sprintf("must total at most %d bytes of keys and values", maxExtraDataBytes))
+	}
+}
+
 func validateDateOnly(errors utils.ValidationErrors, field string, value *string) time.Time {
 	if value == nil || strings.TrimSpace(*value) == "" {
 		return time.Time{}
@@ -130,9 +188,36 @@ func (dsr *DiveSiteRequest) Validate() utils.ValidationErrors {
 	utils.FloatRange(errors, "latitude", dsr.Latitude, -90, 90)
 	utils.FloatRange(errors, "longitude", dsr.Longitude, -180, 180)
 	utils.OptionalString(errors, "description", dsr.Description, maxTextLength)
+	dsr.DiveSiteAttributes.validate(errors)
 	return errors
 }
 
+func (attributes *DiveSiteAttributes) validate(errors utils.ValidationErrors) {
+	optionalFloatRange(errors, "max_depth", attributes.MaxDepth, 0.1, maxDiveDepth)
+	maxTypicalDepth := maxDiveDepth
+	if attributes.MaxDepth != nil {
+		maxTypicalDepth = *attributes.MaxDepth
+	}
+	optionalFloatRange(errors, "typical_depth", attributes.TypicalDepth, 0.1, maxTypicalDepth)
+	utils.OptionalOneOf(errors, "entry_type", attributes.EntryType, DiveSiteEntryTypes...)
+	utils.OptionalOneOf(errors, "environment", attributes.Environment, DiveSiteEnvironments...)
+	utils.OptionalOneOf(errors, "water_type", attributes.WaterType, DiveSiteWaterTypes...)
+	if attributes.CountryCode != nil && !countryCodePattern.MatchString(*attributes.CountryCode) {
+		errors.Add("country_code", "must be an ISO 3166-1 alpha-2 code such as EG")
+	}
+	utils.OptionalString(errors, "country", attributes.Country, 100)
+	utils.OptionalString(errors, "region", attributes.Region, 255)
+	utils.OptionalString(errors, "area", attributes.Area, 255)
+	if attributes.Area != nil && attributes.Region == nil {
+		errors.Add("area", "requires region")
+	}
+	if attributes.Region != nil && attributes.Country == nil {
+		errors.Add("region", "requires country")
+	}
+	utils.OptionalString(errors, "access_notes", attributes.AccessNotes, maxTextLength)
+	utils.OptionalString(errors, "hazards", attributes.Hazards, maxTextLength)
+}
+
 // Validate applies the allowed settings values enforced by PostgreSQL.
 func (sr *SettingsRequest) Validate() utils.ValidationErrors {
 	errors := utils.ValidationErrors{}
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -96,6 +100,31 @@ func TestDiveRequestValidateProfileIdentityFields(t *testing.T) {
 	assert.Contains(t, errors, "dive_mode")
 }
 
+func TestDiveRequestValidateExtraData(t *testing.T) {
+	request := validDiveRequestForValidation()
+	request.ExtraData = map[string]string{"shearwater.deco_model": "GF 40/85"}
+	request.Computer = &DiveComputerIdentity{ExtraData: map[string]string{"Battery": "3.9V"}}
+	assert.Empty(t, request.Validate())
+
+	request.ExtraData = map[string]string{"bad key": "x", "notes": strings.Repeat("a", 1001)}
+	request.Computer.ExtraData = map[string]string{"-leading": "x"}
+	errors := request.Validate()
+	assert.Contains(t, errors, `extra_data["bad key"]`)
+	assert.Contains(t, errors, `extra_data["notes"]`)
+	assert.Contains(t, errors, `computer_metadata.extra_data["-leading"]`)
+}
+
+func TestDiveRequestValidateExtraDataSize(t *testing.T) {
+	request := validDiveRequestForValidation()
+	request.ExtraData = map[string]string{}
+	for i := 0; i < 101; i++ {
+		request.ExtraData[fmt.Sprintf("key%d", i)] = strings.Repeat("v", 200)
+	}
+
+	errors := request.Validate()
+	assert.Equal(t, "must contain at most 100 entries", errors["extra_data"])
+}
+
 func TestCalculateMeanDepthUsesElapsedTime(t *testing.T) {
 	mean := CalculateMeanDepth([]DiveSample{{Time: 0, Depth: 0}, {Time: 60, Depth: 20}, {Time: 180, Depth: 20}})
 	assert.NotNil(t, mean)
@@ -181,3 +210,190 @@ func validSettingsRequestForValidation() SettingsRequest {
 	request.Dive.MaxDepthWarning = 40
 	return request
 }
+
+func TestBackupArchiveValidateAcceptsValidArchive(t *testing.T) {
+	siteID, tripID := 1, 2
+	dive := BackupDive{ID: 3, DiveSiteID: &siteID, DiveRequest: validDiveRequestForValidation()}
+	dive.TripID = &tripID
+	archive := BackupArchive{
+		Format: BackupFormat, Version: BackupVersion,
+		DiveSites: []BackupDiveSite{{ID: siteID, DiveSiteRequest: DiveSiteRequest{Name: "Monterey Bay", Latitude: 36.6, Longitude: -121.9}}},
+		Trips:     []BackupTrip{{ID: tripID, TripRequest: TripRequest{Name: "California"}}},
+		Tags:      []string{"kelp"},
+		Dives:     []BackupDive{dive},
+	}
+	assert.Empty(t, archive.Validate())
+}
+
+func TestBackupArchiveValidateReportsBrokenReferences(t *testing.T) {
+	missing := 99
+	dive := BackupDive{ID: 3, DiveSiteID: &missing, DiveRequest: validDiveRequestForValidation()}
+	dive.TripID = &missing
+	archive := BackupArchive{
+		Format: "other", Version: BackupVersion + 1,
+		Trips:          []BackupTrip{{ID: 1, TripRequest: TripRequest{Name: "Trip"}}, {ID: 1, TripRequest: TripRequest{Name: "trip"}}},
+		Dives:          []BackupDive{dive},
+		BulkOperations: []BackupBulkOperation{{ID: "short", OperationType: "timestamp_shift", BeforeState: []byte("{")}},
+	}
+	errors := archive.Validate()
+	for _, field := range []string{
+		"format", "version", "trips[1].id", "trips[1].name", "dives[0].dive_site_id", "dives[0].trip_id",
+		"bulk_operations[0].id", "bulk_operations[0].before_state",
+	} {
+		assert.Contains(t, errors, field)
+	}
+}
+
+func TestRestoreOptionsValidateMode(t *testing.T) {
+	assert.Empty(t, (&RestoreOptions{Mode: "replace"}).Validate())
+	assert.Contains(t, (&RestoreOptions{Mode: "overwrite"}).Validate(), "mode")
+}
+
+func TestGeoJSONFeatureCollectionValidateReportsFeaturePaths(t *testing.T) {
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{
+		{Type: "Feature", Geometry: &GeoJSONGeometry{Type: "Point", Coordinates: []float64{-121.9, 36.6, -5}}, Properties: map[string]interface{}{"name": " Lovers Point "}},
+		{Type: "Feature", Geometry: &GeoJSONGeometry{Type: "Point", Coordinates: []float64{10, 95}}, Properties: map[string]interface{}{}},
+	}}
+	errors := collection.Validate()
+	assert.Equal(t, []string{"features[1].geometry.coordinates", "features[1].properties.name"}, sortedKeys(errors))
+
+	request := collection.Features[0].ToDiveSiteRequest()
+	assert.Equal(t, DiveSiteRequest{Name: "Lovers Point", Latitude: 36.6, Longitude: -121.9}, request)
+}
+
+func TestDiveSiteExportRequestDefaultsToGeoJSON(t *testing.T) {
+	request := DiveSiteExportRequest{}
+	assert.Empty(t, request.Validate())
+	assert.Equal(t, "geojson", request.Format)
+	assert.Contains(t, (&DiveSiteExportRequest{Format: "csv"}).Validate(), "format")
+}
+
+func sortedKeys(errors map[string]string) []string {
+	keys := make([]string, 0, len(errors))
+	for key := range errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestDiveSiteRequestValidateAttributes(t *testing.T) {
+	maxDepth, typicalDepth := 18.0, 25.0
+	entry, environment, area := "pier", "reef", "Dahab"
+	request := DiveSiteRequest{Name: "Lighthouse", DiveSiteAttributes: DiveSiteAttributes{
+		MaxDepth: &maxDepth, TypicalDepth: &typicalDepth, EntryType: &entry, Environment: &environment, Area: &area,
+	}}
+
+	assert.Equal(t, []string{"area", "entry_type", "typical_depth"}, sortedKeys(request.Validate()))
+}
+
+func TestDiveSiteFilterValidate(t *testing.T) {
+	minDepth, maxDepth := 30.0, 10.0
+	assert.Empty(t, (&DiveSiteFilter{Environment: "cave"}).Validate())
+	assert.Equal(t, []string{"max_depth", "water_type"}, sortedKeys((&DiveSiteFilter{
+		WaterType: "sea", MinDepth: &minDepth, MaxDepth: &maxDepth,
+	}).Validate()))
+}
+
+func TestBackupArchiveValidateReportsSightingFields(t *testing.T) {
+	dive := BackupDive{ID: 3, DiveRequest: validDiveRequestForValidation(), Sightings: []BackupSighting{
+		{SpeciesRequest: SpeciesRequest{CommonName: "Garibaldi", Category: "fish"}, Count: 2},
+		{SpeciesRequest: SpeciesRequest{CommonName: "", Category: "plant"}, Count: 0},
+	}}
+	archive := BackupArchive{Format: BackupFormat, Version: BackupVersion, Dives: []BackupDive{dive}}
+
+	assert.Equal(t, []string{
+		"dives[0].sightings[1].category", "dives[0].sightings[1].common_name", "dives[0].sightings[1].count",
+	}, sortedKeys(archive.Validate()))
+}
+
+func TestSpeciesFilterValidate(t *testing.T) {
+	filter := SpeciesFilter{Query: "  manta ", Category: "ray"}
+	assert.Empty(t, filter.Validate())
+	assert.Equal(t, "manta", filter.Query)
+	assert.Contains(t, (&SpeciesFilter{Category: "bird"}).Validate(), "category")
+}
+
+func TestDiveRequestCertificationRequiresTrainingDive(t *testing.T) {
+	request := validDiveRequestForValidation()
+	certificationID := 4
+	request.CertificationID = &certificationID
+	assert.Contains(t, request.Validate(), "certification_id")
+
+	training := "training"
+	request.DiveType = &training
+	assert.Empty(t, request.Validate())
+}
+
+func TestBackupArchiveValidateReportsCertificationReferences(t *testing.T) {
+	certificationID, training := 8, "training"
+	dive := BackupDive{ID: 3, DiveRequest: validDiveRequestForValidation()}
+	dive.DiveType = &training
+	dive.CertificationID = &certificationID
+	archive := BackupArchive{Format: BackupFormat, Version: BackupVersion, Dives: []BackupDive{dive},
+		Certifications: []BackupCertification{
+			{ID: 2, CertificationRequest: CertificationRequest{Agency: "PADI", Level: "Rescue Diver"}},
+			{ID: 5, CertificationRequest: CertificationRequest{Agency: "padi", Level: "rescue diver "}},
+		}}
+
+	assert.Equal(t, []string{"certifications[1].level", "dives[0].certification_id"}, sortedKeys(archive.Validate()))
+}
+
+func TestWebhookSubscriptionRequestValidate(t *testing.T) {
+	request := WebhookSubscriptionRequest{URL: "/relative", Events: []string{"dive.created", "dive.created"}}
+	assert.Equal(t, []string{"events[1]", "url"}, sortedKeys(request.Validate()))
+
+	request = WebhookSubscriptionRequest{URL: " https://hooks.example.com/divelog ", Events: []string{"dives.imported"}}
+	assert.Empty(t, request.Validate())
+	assert.Equal(t, "https://hooks.example.com/divelog", request.URL)
+	if assert.NotNil(t, request.Active) {
+		assert.True(t, *request.Active)
+	}
+
+	assert.Contains(t, (&WebhookSubscriptionRequest{URL: "https://example.com"}).Validate(), "events")
+
+	for _, local := range []string{"http://localhost:8080/hook", "http://127.0.0.1/hook", "http://[::1]/hook", "http://169.254.169.254/latest", "https://10.0.0.5/hook"} {
+		request = WebhookSubscriptionRequest{URL: local, Events: []string{"dive.created"}}
+		assert.Contains(t, request.Validate(), "url", local)
+	}
+}
+
+func TestAPITokenRequestValidate(t *testing.T) {
+	days := 400
+	request := APITokenRequest{Name: " ", Scopes: []string{"dives:read", "dives:read", "everything"}, ExpiresInDays: &days}
+	assert.Equal(t, []string{"expires_in_days", "name", "scopes[1]", "scopes[2]"}, sortedKeys(request.Validate()))
+
+	request = APITokenRequest{Name: " Nightly export ", Scopes: []string{ScopeExport}}
+	assert.Empty(t, request.Validate())
+	assert.Equal(t, "Nightly export", request.Name)
+	if assert.NotNil(t, request.ExpiresInDays) {
+		assert.Equal(t, 90, *request.ExpiresInDays)
+	}
+
+	assert.Contains(t, (&APITokenRequest{Name: "script"}).Validate(), "scopes")
+}
+
+func TestAPITokenHasScope(t *testing.T) {
+	token := APIToken{Scopes: []string{ScopeDivesRead}}
+	assert.True(t, token.HasScope(ScopeDivesRead))
+	assert.False(t, token.HasScope(ScopeDivesWrite))
+
+	admin := APIToken{Scopes: []string{ScopeAdmin}}
+	assert.True(t, admin.HasScope(ScopeExport))
+}
+
+func TestAuditLogFilterValidate(t *testing.T) {
+	filter := AuditLogFilter{EntityType: "certification", EntityID: -1, BeforeID: -1, Limit: 501}
+	assert.Equal(t, []string{"before_id", "entity_id", "entity_type", "limit"}, sortedKeys(filter.Validate()))
+
+	filter = AuditLogFilter{EntityID: 12}
+	assert.Contains(t, filter.Validate(), "entity_id")
+
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	filter = AuditLogFilter{From: from, To: from}
+	assert.Contains(t, filter.Validate(), "to")
+
+	filter = AuditLogFilter{EntityType: AuditEntityDive, EntityID: 12, From: from, To: from.Add(time.Hour)}
+	assert.Empty(t, filter.Validate())
+	assert.Equal(t, 100, filter.Limit)
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"divelog-backend/utils"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Webhook event types. Single-dive events carry the dive; the plural events
+// describe bulk changes and imports.
+const (
+	WebhookEventDiveCreated   = "dive.created"
+	WebhookEventDiveUpdated   = "dive.updated"
+	WebhookEventDiveDeleted   = "dive.deleted"
+	WebhookEventDivesImported = "dives.imported"
+	WebhookEventDivesUpdated  = "dives.updated"
+	WebhookEventDivesDeleted  = "dives.deleted"
+)
+
+// WebhookEventTypes lists every event a subscription may select.
+var WebhookEventTypes = []string{
+	WebhookEventDiveCreated, WebhookEventDiveUpdated, WebhookEventDiveDeleted,
+	WebhookEventDivesImported, WebhookEventDivesUpdated, WebhookEventDivesDeleted,
+}
+
+// WebhookDeliveryStatuses lists the allowed WebhookDelivery.Status values,
+// matching the CHECK constraint on webhook_deliveries.
+var WebhookDeliveryStatuses = []string{"pending", "delivering", "succeeded", "failed"}
+
+// WebhookSubscription sends the selected events for one user to URL. Secret
+// signs every payload and is only returned when it is generated.
+type WebhookSubscription struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	URL         string    `json:"url"`
+	Description *string   `json:"description,omitempty"`
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookSubscriptionRequest creates or replaces a subscription. Active
+// defaults to true; an inactive subscription keeps its pending deliveries
+// until it is re-enabled.
+type WebhookSubscriptionRequest struct {
+	URL         string   `json:"url"`
+	Description *string  `json:"description,omitempty"`
+	Events      []string `json:"events"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+func (request *WebhookSubscriptionRequest) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	request.URL = strings.TrimSpace(request.URL)
+	utils.RequireString(errors, "url", request.URL, 2000)
+	if request.URL != "" {
+		parsed, err := url.Parse(request.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+			errors.Add("url", "must be an absolute http or https URL")
+		} else if !isPublicHost(parsed.Hostname()) {
+			errors.Add("url", "must not point at a local or private address")
+		}
+	}
+	utils.OptionalString(errors, "description", request.Description, 255)
+	if len(request.Events) == 0 {
+		errors.Add("events", "must contain at least one event type")
+	}
+	seen := map[string]bool{}
+	for i, event := range request.Events {
+		field := fmt.Sprintf("events[%d]", i)
+		utils.OneOf(errors, field, event, WebhookEventTypes...)
+		if seen[event] {
+			errors.Add(field, "must not duplicate another event type")
+		}
+		seen[event] = true
+	}
+	if request.Active == nil {
+		active := true
+		request.Active = &active
+	}
+	return errors
+}
+
+// WebhookEvent is the signed JSON body posted to subscribers. ID stays the
+// same across retries so receivers can discard repeats.
+type WebhookEvent struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	UserID     int         `json:"user_id"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// DeletedDive is the data of a dive.deleted event.
+type DeletedDive struct {
+	ID int `json:"id"`
+}
+
+// DiveChangeSummary is the data of dives.imported, dives.updated and
+// dives.deleted events. DiveIDs is omitted when the change is not limited to
+// known dives, such as renumbering.
+type DiveChangeSummary struct {
+	Operation     string `json:"operation"`
+	DiveIDs       []int  `json:"dive_ids,omitempty"`
+	AffectedCount int64  `json:"affected_count"`
+	SkippedCount  int    `json:"skipped_count,omitempty"`
+}
+
+// WebhookDelivery is one event queued for one subscription, together with
+// the outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int             `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// WebhookDeliveryFilter narrows the delivery log, newest first.
+type WebhookDeliveryFilter struct {
+	Status string `form:"status"`
+	Limit  int    `form:"limit"`
+}
+
+func (filter *WebhookDeliveryFilter) Validate() utils.ValidationErrors {
+	errors := utils.ValidationErrors{}
+	if filter.Status != "" {
+		utils.OneOf(errors, "status", filter.Status, WebhookDeliveryStatuses...)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 50
+	}
+	utils.IntRange(errors, "limit", filter.Limit, 1, 500)
+	return errors
+}
+
+// PendingWebhookDelivery is a delivery claimed by the dispatcher, with what it
+// needs to send and sign the request.
+type PendingWebhookDelivery struct {
+	ID        int64
+	EventID   string
+	EventType string
+	Payload   []byte
+	Attempts  int
+	URL       string
+	Secret    string
+}
+
+// WebhookAttempt is the outcome of sending a delivery once. A nil
+// NextAttemptAt on a failed attempt marks the delivery as failed for good.
+type WebhookAttempt struct {
+	Succeeded     bool
+	StatusCode    *int
+	Error         *string
+	NextAttemptAt *time.Time
+}
+
+// isPublicHost rejects hosts that name a local or private address without a
+// DNS lookup; hostnames are resolved and checked when the subscription is
+// saved and again on every delivery.
+func isPublicHost(host string) bool {
+	if utils.IsLocalHostname(host) {
+		return false
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return utils.IsPublicAddress(ip)
+	}
+	return true
+}
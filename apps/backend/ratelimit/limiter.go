@@ -0,0 +1,129 @@
+// Package ratelimit implements token-bucket rate limiting. Each key owns a
+// bucket holding up to Limit.Requests tokens that refills evenly over
+// Limit.Period; a request takes as many tokens as it costs. MemoryLimiter keeps
+// buckets in process and RedisLimiter shares them between replicas.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests requests per Period, all of which may arrive at once.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// PerMinute returns a limit of requests per minute.
+func PerMinute(requests int) Limit {
+	return Limit{Requests: requests, Period: time.Minute}
+}
+
+// refillInterval is how long one token takes to come back.
+func (l Limit) refillInterval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// Result describes a bucket after a request has been counted against it.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket capacity and Remaining the whole tokens left in it.
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again, and RetryAfter how
+	// long until a rejected request would be allowed.
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter takes cost tokens from the bucket for key.
+type Limiter interface {
+	Take(ctx context.Context, key string, limit Limit, cost int) (Result, error)
+}
+
+// newResult derives a Result from the tokens left after a request.
+func newResult(limit Limit, cost int, tokens float64, allowed bool) Result {
+	interval := float64(limit.refillInterval())
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration(math.Ceil((float64(limit.Requests) - tokens) * interval)),
+	}
+	if !allowed {
+		if cost > limit.Requests {
+			// The request can never fit; ask the client to wait a full period.
+			result.RetryAfter = limit.Period
+		} else {
+			result.RetryAfter = time.Duration(math.Ceil((float64(cost) - tokens) * interval))
+		}
+	}
+	return result
+}
+
+// refill returns tokens topped up for the time elapsed since last.
+func refill(limit Limit, tokens float64, last, now time.Time) float64 {
+	if elapsed := now.Sub(last); elapsed > 0 {
+		tokens += float64(elapsed) / float64(limit.refillInterval())
+	}
+	return math.Min(tokens, float64(limit.Requests))
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// MemoryLimiter keeps buckets in process memory. It is safe for concurrent use
+// but each replica counts separately.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: map[string]*memoryBucket{}, now: time.Now}
+}
+
+// memorySweepInterval is how often full buckets, which behave exactly like
+// missing ones, are dropped so idle clients do not accumulate.
+const memorySweepInterval = time.Minute
+
+func (l *MemoryLimiter) Take(_ context.Context, key string, limit Limit, cost int) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= memorySweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok || bucket.limit != limit {
+		bucket = &memoryBucket{tokens: float64(limit.Requests), updated: now, limit: limit}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = refill(limit, bucket.tokens, bucket.updated, now)
+	bucket.updated = now
+
+	allowed := bucket.tokens >= float64(cost)
+	if allowed {
+		bucket.tokens -= float64(cost)
+	}
+	return newResult(limit, cost, bucket.tokens, allowed), nil
+}
+
+func (l *MemoryLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if refill(bucket.limit, bucket.tokens, bucket.updated, now) >= float64(bucket.limit.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
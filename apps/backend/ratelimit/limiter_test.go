@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestMemoryLimiter() (*MemoryLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := NewMemoryLimiter()
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+func TestMemoryLimiterAllowsBurstThenRefills(t *testing.T) {
+	limiter, clock := newTestMemoryLimiter()
+	ctx := context.Background()
+	limit := PerMinute(3)
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Take(ctx, "ip:192.0.2.1", limit, 1)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2-i, result.Remaining)
+	}
+
+	rejected, err := limiter.Take(ctx, "ip:192.0.2.1", limit, 1)
+	require.NoError(t, err)
+	assert.False(t, rejected.Allowed)
+	assert.Equal(t, 3, rejected.Limit)
+	assert.Equal(t, 20*time.Second, rejected.RetryAfter)
+	assert.Equal(t, time.Minute, rejected.Reset)
+
+	// One token comes back every 20 seconds, not all at once.
+	clock.now = clock.now.Add(20 * time.Second)
+	result, err := limiter.Take(ctx, "ip:192.0.2.1", limit, 1)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	other, err := limiter.Take(ctx, "ip:192.0.2.2", limit, 1)
+	require.NoError(t, err)
+	assert.True(t, other.Allowed)
+}
+
+func TestMemoryLimiterCost(t *testing.T) {
+	limiter, _ := newTestMemoryLimiter()
+	ctx := context.Background()
+	limit := PerMinute(10)
+
+	result, err := limiter.Take(ctx, "user:1", limit, 8)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+
+	result, err = limiter.Take(ctx, "user:1", limit, 8)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining, "a rejected request takes nothing")
+	assert.Equal(t, 36*time.Second, result.RetryAfter)
+
+	tooBig, err := limiter.Take(ctx, "user:2", limit, 11)
+	require.NoError(t, err)
+	assert.False(t, tooBig.Allowed)
+	assert.Equal(t, time.Minute, tooBig.RetryAfter)
+}
+
+func TestMemoryLimiterSweepsFullBuckets(t *testing.T) {
+	limiter, clock := newTestMemoryLimiter()
+	ctx := context.Background()
+
+	_, _ = limiter.Take(ctx, "ip:192.0.2.1", PerMinute(60), 1)
+	_, _ = limiter.Take(ctx, "ip:192.0.2.2", Limit{Requests: 1, Period: time.Hour}, 1)
+	clock.now = clock.now.Add(2 * memorySweepInterval)
+	_, _ = limiter.Take(ctx, "ip:192.0.2.3", PerMinute(60), 1)
+
+	assert.NotContains(t, limiter.buckets, "ip:192.0.2.1")
+	assert.Contains(t, limiter.buckets, "ip:192.0.2.2")
+	assert.Contains(t, limiter.buckets, "ip:192.0.2.3")
+}
+
+func TestMemoryLimiterConcurrentTakes(t *testing.T) {
+	limiter, _ := newTestMemoryLimiter()
+	var allowed int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := limiter.Take(context.Background(), "user:1", PerMinute(20), 1)
+			if err == nil && result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 20, allowed)
+}
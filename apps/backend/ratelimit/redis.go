@@ -0,0 +1,285 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tokenBucketScript updates one bucket atomically. It reads the clock from the
+// Redis server, so replicas with skewed clocks still agree. The bucket expires
+// once it would be full again, since a missing bucket counts as full.
+const tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) * 1000 + math.floor(tonumber(clock[2]) / 1000)
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1]) or capacity
+local updated = tonumber(bucket[2]) or now
+if now > updated then
+  tokens = math.min(capacity, tokens + (now - updated) / interval)
+end
+local allowed = 0
+if tokens >= cost then
+  tokens = tokens - cost
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) * interval) + 1000)
+return {allowed, tostring(tokens)}
+`
+
+var tokenBucketSHA = func() string {
+	sum := sha1.Sum([]byte(tokenBucketScript))
+	return hex.EncodeToString(sum[:])
+}()
+
+const (
+	redisKeyPrefix   = "divelog:ratelimit:"
+	redisTimeout     = 2 * time.Second
+	redisIdleConns   = 8
+	redisDefaultPort = "6379"
+)
+
+// RedisLimiter keeps buckets in Redis, or any server speaking its protocol and
+// running Lua scripts, so that all replicas share one budget per key.
+type RedisLimiter struct {
+	address  string
+	username string
+	password string
+	database int
+	useTLS   bool
+	idle     chan *redisConn
+}
+
+// NewRedisLimiter connects lazily to the server named by a
+// redis://[user:password@]host[:port][/database] URL; rediss:// uses TLS.
+func NewRedisLimiter(rawURL string) (*RedisLimiter, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis URL: %w", err)
+	}
+	if parsed.Scheme != "redis" && parsed.Scheme != "rediss" {
+		return nil, fmt.Errorf("redis URL must use redis:// or rediss://, got %q", parsed.Scheme)
+	}
+	if parsed.Hostname() == "" {
+		return nil, errors.New("redis URL has no host")
+	}
+	limiter := &RedisLimiter{
+		address: parsed.Host,
+		useTLS:  parsed.Scheme == "rediss",
+		idle:    make(chan *redisConn, redisIdleConns),
+	}
+	if parsed.Port() == "" {
+		limiter.address = net.JoinHostPort(parsed.Hostname(), redisDefaultPort)
+	}
+	if parsed.User != nil {
+		limiter.username = parsed.User.Username()
+		limiter.password, _ = parsed.User.Password()
+	}
+	if database := strings.Trim(parsed.Path, "/"); database != "" {
+		if limiter.database, err = strconv.Atoi(database); err != nil || limiter.database < 0 {
+			return nil, fmt.Errorf("invalid redis database %q", database)
+		}
+	}
+	return limiter, nil
+}
+
+func (l *RedisLimiter) Take(ctx context.Context, key string, limit Limit, cost int) (Result, error) {
+	conn, err := l.conn(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	interval := float64(limit.refillInterval()) / float64(time.Millisecond)
+	args := []string{"1", redisKeyPrefix + key, strconv.Itoa(limit.Requests),
+		strconv.FormatFloat(interval, 'f', -1, 64), strconv.Itoa(cost)}
+
+	reply, err := conn.do(ctx, append([]string{"EVALSHA", tokenBucketSHA}, args...)...)
+	var serverErr redisError
+	if errors.As(err, &serverErr) && strings.HasPrefix(string(serverErr), "NOSCRIPT") {
+		reply, err = conn.do(ctx, append([]string{"EVAL", tokenBucketScript}, args...)...)
+	}
+	if err != nil {
+		l.discard(conn, err)
+		return Result{}, err
+	}
+	l.release(conn)
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("unexpected token count %q", remaining)
+	}
+	return newResult(limit, cost, tokens, allowed == 1), nil
+}
+
+// Close closes idle connections.
+func (l *RedisLimiter) Close() error {
+	for {
+		select {
+		case conn := <-l.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (l *RedisLimiter) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-l.idle:
+		return conn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var netConn net.Conn
+	var err error
+	if l.useTLS {
+		host, _, _ := net.SplitHostPort(l.address)
+		netConn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", l.address)
+	} else {
+		netConn, err = dialer.DialContext(ctx, "tcp", l.address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to redis: %w", err)
+	}
+	conn := &redisConn{Conn: netConn, reader: bufio.NewReader(netConn)}
+
+	if l.password != "" {
+		auth := []string{"AUTH", l.password}
+		if l.username != "" {
+			auth = []string{"AUTH", l.username, l.password}
+		}
+		if _, err := conn.do(ctx, auth...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("authenticate to redis: %w", err)
+		}
+	}
+	if l.database != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(l.database)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("select redis database: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (l *RedisLimiter) release(conn *redisConn) {
+	select {
+	case l.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// discard drops a connection after a transport error, which may have left a
+// reply unread. A server error reply leaves the connection usable.
+func (l *RedisLimiter) discard(conn *redisConn, err error) {
+	var serverErr redisError
+	if errors.As(err, &serverErr) {
+		l.release(conn)
+		return
+	}
+	conn.Close()
+}
+
+// redisError is an error reply sent by the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// redisConn speaks RESP, the Redis serialization protocol, over one
+// connection. Replies decode to string, int64, []interface{}, nil or
+// redisError.
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *redisConn) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(redisTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c, command.String()); err != nil {
+		return nil, err
+	}
+
+	reply, err := c.readReply()
+	if err != nil {
+		return nil, err
+	}
+	if serverErr, ok := reply.(redisError); ok {
+		return nil, serverErr
+	}
+	return reply, nil
+}
+
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("malformed redis reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return redisError(payload), nil
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, count)
+		for i := range values {
+			if values[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unknown redis reply type %q", kind)
+}
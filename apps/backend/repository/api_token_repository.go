@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// apiTokenTouchInterval limits how often authenticating with a token writes
+// its last_used_at, so busy scripts do not update the row on every request.
+const apiTokenTouchInterval = time.Minute
+
+// APITokenRepository stores personal access tokens by their hash.
+type APITokenRepository struct {
+	db *sql.DB
+}
+
+func NewAPITokenRepository(db *sql.DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+const apiTokenColumns = `id, user_id, name, token_prefix, scopes, expires_at, last_used_at, created_at`
+
+func scanAPIToken(row interface{ Scan(...interface{}) error }) (*models.APIToken, error) {
+	var token models.APIToken
+	if err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, pq.Array(&token.Scopes),
+		&token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListTokens returns userID's tokens, newest first, expired ones included.
+func (r *APITokenRepository) ListTokens(ctx context.Context, userID int) ([]models.APIToken, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+apiTokenColumns+` FROM api_tokens WHERE user_id = $1 ORDER BY id DESC`, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error listing API tokens", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			utils.LogError(ctx, "Error scanning API token", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		tokens = append(tokens, *token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return tokens, nil
+}
+
+// CreateToken stores a validated token request with the hash and prefix of
+// the generated secret.
+func (r *APITokenRepository) CreateToken(ctx context.Context, userID int, request models.APITokenRequest, hash, prefix string, expiresAt time.Time) (*models.APIToken, error) {
+	token, err := scanAPIToken(r.db.QueryRowContext(ctx, `
+		INSERT INTO api_tokens (user_id, name, token_prefix, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+apiTokenColumns,
+		userID, request.Name, prefix, hash, pq.Array(request.Scopes), expiresAt))
+	if err != nil {
+		utils.LogError(ctx, "Error creating API token", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	return token, nil
+}
+
+// DeleteToken revokes one of userID's tokens.
+func (r *APITokenRepository) DeleteToken(ctx context.Context, userID, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error deleting API token", err, utils.UserID(userID))
+		return utils.ErrDatabaseError
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return utils.ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the unexpired token with hash and records that it was
+// used.
+func (r *APITokenRepository) Authenticate(ctx context.Context, hash string) (*models.APIToken, error) {
+	token, err := scanAPIToken(r.db.QueryRowContext(ctx, `
+		WITH token AS (
+			SELECT `+apiTokenColumns+` FROM api_tokens
+			WHERE token_hash = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		), touched AS (
+			UPDATE api_tokens SET last_used_at = NOW()
+			WHERE id IN (SELECT id FROM token
+			             WHERE last_used_at IS NULL OR last_used_at <= NOW() - $2 * INTERVAL '1 second')
+		)
+		SELECT `+apiTokenColumns+` FROM token`, hash, apiTokenTouchInterval.Seconds()))
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrInvalidAPIToken
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error authenticating API token", err)
+		return nil, utils.ErrDatabaseError
+	}
+	return token, nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"fmt"
+	"strings"
+)
+
+// AuditRepository reads the audit log. Entries are only ever written by the
+// database triggers from migration 0015.
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// ListEntries returns the entries for changes to userID's logbook, and for
+// changes userID made to shared dive sites, newest first.
+func (r *AuditRepository) ListEntries(ctx context.Context, userID int, filter models.AuditLogFilter) ([]models.AuditEntry, error) {
+	conditions := []string{"(user_id = $1 OR (user_id IS NULL AND actor_user_id = $1))"}
+	args := []interface{}{userID}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+	if filter.EntityType != "" {
+		addCondition("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID > 0 {
+		addCondition("entity_id = $%d", filter.EntityID)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, user_id, actor_user_id, actor_token_id, request_id, entity_type, entity_id, action, changes, created_at
+		FROM audit_log WHERE %s
+		ORDER BY id DESC LIMIT $%d`, strings.Join(conditions, " AND "), len(args)), args...)
+	if err != nil {
+		utils.LogError(ctx, "Error querying audit log", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.ActorUserID, &entry.ActorTokenID, &entry.RequestID,
+			&entry.EntityType, &entry.EntityID, &entry.Action, &entry.Changes, &entry.CreatedAt); err != nil {
+			utils.LogError(ctx, "Error scanning audit entry", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return entries, nil
+}
@@ -0,0 +1,446 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupRepository reads and writes the parts of a user's logbook that are not
+// covered by the dive and dive-site repositories. It is normally used through
+// SQLTransactor.WithinBackupTransaction so an archive is one consistent
+// snapshot and a restore is all-or-nothing.
+type BackupRepository struct {
+	db dbExecutor
+}
+
+func newBackupRepository(db dbExecutor) *BackupRepository {
+	return &BackupRepository{db: db}
+}
+
+// ExportSettings returns the user's settings, or nil when none were saved.
+func (r *BackupRepository) ExportSettings(ctx context.Context, userID int) (*models.SettingsRequest, error) {
+	settings := &models.UserSettings{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT unit_preference, depth_unit, temperature_unit, distance_unit, weight_unit, pressure_unit, volume_unit,
+		       date_format, time_format, default_visibility, show_buddy_reminders, auto_calculate_nitrox,
+		       default_gas_mix, max_depth_warning
+		FROM user_settings WHERE user_id = $1`, userID).Scan(
+		&settings.UnitPreference, &settings.DepthUnit, &settings.TemperatureUnit, &settings.DistanceUnit,
+		&settings.WeightUnit, &settings.PressureUnit, &settings.VolumeUnit,
+		&settings.DateFormat, &settings.TimeFormat, &settings.DefaultVisibility,
+		&settings.ShowBuddyReminders, &settings.AutoCalculateNitrox,
+		&settings.DefaultGasMix, &settings.MaxDepthWarning,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error exporting settings", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	request := settings.ToRequest()
+	return &request, nil
+}
+
+// ExportDiveSites returns the shared dive sites referenced by the user's dives.
+func (r *BackupRepository) ExportDiveSites(ctx context.Context, userID int) ([]models.BackupDiveSite, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT ds.id, ds.name, ds.latitude, ds.longitude, ds.description,
+		       ds.max_depth, ds.typical_depth, ds.entry_type, ds.environment, ds.water_type,
+		       ds.country_code, ds.country, ds.region, ds.area, ds.access_notes, ds.hazards
+		FROM dive_sites ds
+		WHERE ds.deleted_at IS NULL
+		  AND EXISTS (SELECT 1 FROM dives d WHERE d.dive_site_id = ds.id AND d.user_id = $1 AND d.deleted_at IS NULL)
+		ORDER BY ds.id`, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error exporting dive sites", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+	sites := []models.BackupDiveSite{}
+	for rows.Next() {
+		var site models.BackupDiveSite
+		if err := rows.Scan(
+			&site.ID, &site.Name, &site.Latitude, &site.Longitude, &site.Description,
+			&site.MaxDepth, &site.TypicalDepth, &site.EntryType, &site.Environment, &site.WaterType,
+			&site.CountryCode, &site.Country, &site.Region, &site.Area, &site.AccessNotes, &site.Hazards,
+		); err != nil {
+			return nil, utils.ErrDatabaseError
+		}
+		sites = append(sites, site)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return sites, nil
+}
+
+// ExportTrips returns every trip, including trips without dives.
+func (r *BackupRepository) ExportTrips(ctx context.Context, userID int) ([]models.BackupTrip, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, name, location, start_date::text, end_date::text, notes
+		FROM trips WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id`, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error exporting trips", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+	trips := []models.BackupTrip{}
+	for rows.Next() {
+		var trip models.BackupTrip
+		var location, start, end, notes sql.NullString
+		if err := rows.Scan(&trip.ID, &trip.Name, &location, &start, &end, &notes); err != nil {
+			return nil, utils.ErrDatabaseError
+		}
+		trip.Location, trip.StartDate, trip.EndDate, trip.Notes = nullStringPointer(location), nullStringPointer(start), nullStringPointer(end), nullStringPointer(notes)
+		trips = append(trips, trip)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return trips, nil
+}
+
+// ExportCertifications returns every certification without card images.
+func (r *BackupRepository) ExportCertifications(ctx context.Context, userID int) ([]models.BackupCertification, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, agency, level, certification_number, certified_on::text, instructor, notes, prerequisites
+		FROM certifications WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error exporting certifications", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+	certifications := []models.BackupCertification{}
+	for rows.Next() {
+		var certification models.BackupCertification
+		var number, certifiedOn, instructor, notes sql.NullString
+		var prerequisites []byte
+		if err := rows.Scan(&certification.ID, &certification.Agency, &certification.Level,
+			&number, &certifiedOn, &instructor, &notes, &prerequisites); err != nil {
+			return nil, utils.ErrDatabaseError
+		}
+		certification.CertificationNumber, certification.CertifiedOn = nullStringPointer(number), nullStringPointer(certifiedOn)
+		certification.Instructor, certification.Notes = nullStringPointer(instructor), nullStringPointer(notes)
+		utils.UnmarshalJSON(prerequisites, &certification.Prerequisites)
+		certifications = append(certifications, certification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return certifications, nil
+}
+
+// ExportTags returns every tag name, including tags no dive uses.
+func (r *BackupRepository) ExportTags(ctx context.Context, userID int) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT name FROM tags WHERE user_id = $1 AND deleted_at IS NULL ORDER BY lower(name)`, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error exporting tags", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+	tags := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, utils.ErrDatabaseError
+		}
+		tags = append(tags, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return tags, nil
+}
+
+// ExportBulkOperations returns the user's bulk-operation history, oldest first.
+func (r *BackupRepository) ExportBulkOperations(ctx context.Context, userID int) ([]models.BackupBulkOperation, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, operation_type, before_state, affected_count, created_at, undone_at
+		FROM bulk_operations WHERE user_id = $1 ORDER BY created_at, id`, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error exporting bulk operations", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+	operations := []models.BackupBulkOperation{}
+	for rows.Next() {
+		var operation models.BackupBulkOperation
+		var beforeState []byte
+		if err := rows.Scan(&operation.ID, &operation.OperationType, &beforeState, &operation.AffectedCount, &operation.CreatedAt, &operation.UndoneAt); err != nil {
+			return nil, utils.ErrDatabaseError
+		}
+		operation.BeforeState = beforeState
+		operations = append(operations, operation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return operations, nil
+}
+
+// ExportDives passes each of the user's dives to emit in chronological order
+// without loading the whole logbook into memory.
+func (r *BackupRepository) ExportDives(ctx context.Context, userID int, emit func(models.BackupDive) error) error {
+	rows, err := r.db.QueryContext(ctx, diveSelectQuery+`
+		ORDER BY d.dive_datetime, d.id`, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error exporting dives", err, utils.UserID(userID))
+		return utils.ErrDatabaseError
+	}
+	defer rows.Close()
+	dives := newDiveRepository(r.db)
+	for rows.Next() {
+		dive, err := dives.scanDive(rows)
+		if err != nil {
+			utils.LogError(ctx, "Error scanning exported dive", err, utils.UserID(userID))
+			return utils.ErrDatabaseError
+		}
+		entry := models.BackupDive{
+			ID: dive.ID, DiveSiteID: dive.DiveSiteID, DiveRequest: dive.ToRequest(),
+			CreatedAt: dive.CreatedAt, UpdatedAt: dive.UpdatedAt,
+		}
+		if err := emit(entry); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		utils.LogError(ctx, "Error iterating exported dives", err, utils.UserID(userID))
+		return utils.ErrDatabaseError
+	}
+	return nil
+}
+
+// ExportSightings returns the sightings on the user's dives keyed by dive ID.
+func (r *BackupRepository) ExportSightings(ctx context.Context, userID int) (map[int][]models.BackupSighting, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT sg.dive_id, s.common_name, s.scientific_name, s.category, sg.count, sg.notes
+		FROM dive_sightings sg
+		JOIN dives d ON d.id = sg.dive_id
+		JOIN species s ON s.id = sg.species_id
+		WHERE d.user_id = $1 AND d.deleted_at IS NULL
+		ORDER BY sg.dive_id, lower(s.common_name)`, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error exporting sightings", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+	sightings := map[int][]models.BackupSighting{}
+	for rows.Next() {
+		var diveID int
+		var sighting models.BackupSighting
+		if err := rows.Scan(&diveID, &sighting.CommonName, &sighting.ScientificName, &sighting.Category,
+			&sighting.Count, &sighting.Notes); err != nil {
+			return nil, utils.ErrDatabaseError
+		}
+		sightings[diveID] = append(sightings[diveID], sighting)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return sightings, nil
+}
+
+// DeleteUserData removes the user-owned rows a replace restore overwrites,
+// including those in the trash, and reports how many dives, trips, tags and
+// bulk operations were removed.
+func (r *BackupRepository) DeleteUserData(ctx context.Context, userID int) (dives, trips, tags, operations int, err error) {
+	counts := make([]int, 4)
+	for i, table := range []string{"dives", "trips", "tags", "bulk_operations"} {
+		result, execErr := r.db.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, userID)
+		if execErr != nil {
+			utils.LogError(ctx, "Error clearing user data for restore", execErr, utils.UserID(userID))
+			return 0, 0, 0, 0, utils.ErrDatabaseError
+		}
+		affected, _ := result.RowsAffected()
+		counts[i] = int(affected)
+	}
+	return counts[0], counts[1], counts[2], counts[3], nil
+}
+
+// UpsertSettings writes restored settings and reports whether a row was created.
+func (r *BackupRepository) UpsertSettings(ctx context.Context, userID int, request models.SettingsRequest) (bool, error) {
+	settings := request.ToUserSettings(userID)
+	var created bool
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO user_settings (user_id, unit_preference, depth_unit, temperature_unit, distance_unit, weight_unit, pressure_unit, volume_unit,
+		                          date_format, time_format, default_visibility, show_buddy_reminders, auto_calculate_nitrox,
+		                          default_gas_mix, max_depth_warning)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (user_id) DO UPDATE SET
+			unit_preference = EXCLUDED.unit_preference, depth_unit = EXCLUDED.depth_unit,
+			temperature_unit = EXCLUDED.temperature_unit, distance_unit = EXCLUDED.distance_unit,
+			weight_unit = EXCLUDED.weight_unit, pressure_unit = EXCLUDED.pressure_unit, volume_unit = EXCLUDED.volume_unit,
+			date_format = EXCLUDED.date_format, time_format = EXCLUDED.time_format,
+			default_visibility = EXCLUDED.default_visibility, show_buddy_reminders = EXCLUDED.show_buddy_reminders,
+			auto_calculate_nitrox = EXCLUDED.auto_calculate_nitrox, default_gas_mix = EXCLUDED.default_gas_mix,
+			max_depth_warning = EXCLUDED.max_depth_warning, updated_at = NOW()
+		RETURNING (xmax = 0)`,
+		userID, settings.UnitPreference, settings.DepthUnit, settings.TemperatureUnit, settings.DistanceUnit,
+		settings.WeightUnit, settings.PressureUnit, settings.VolumeUnit, settings.DateFormat, settings.TimeFormat,
+		settings.DefaultVisibility, settings.ShowBuddyReminders, settings.AutoCalculateNitrox,
+		settings.DefaultGasMix, settings.MaxDepthWarning,
+	).Scan(&created)
+	if err != nil {
+		utils.LogError(ctx, "Error restoring settings", err, utils.UserID(userID))
+		return false, utils.ErrDatabaseError
+	}
+	return created, nil
+}
+
+// UpsertTrip restores a trip by name, filling only fields the existing trip lacks.
+func (r *BackupRepository) UpsertTrip(ctx context.Context, userID int, request models.TripRequest) (int, bool, error) {
+	var id int
+	var created bool
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO trips (user_id, name, location, start_date, end_date, notes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, lower(name)) WHERE deleted_at IS NULL DO UPDATE SET
+			location = COALESCE(trips.location, EXCLUDED.location),
+			start_date = COALESCE(trips.start_date, EXCLUDED.start_date),
+			end_date = COALESCE(trips.end_date, EXCLUDED.end_date),
+			notes = COALESCE(trips.notes, EXCLUDED.notes)
+		RETURNING id, (xmax = 0)`,
+		userID, strings.TrimSpace(request.Name), optionalText(request.Location),
+		optionalText(request.StartDate), optionalText(request.EndDate), optionalText(request.Notes),
+	).Scan(&id, &created)
+	if err != nil {
+		utils.LogError(ctx, "Error restoring trip", err, utils.UserID(userID))
+		return 0, false, utils.ErrDatabaseError
+	}
+	return id, created, nil
+}
+
+// UpsertCertification restores a certification by agency and level, filling
+// only fields the existing certification lacks.
+func (r *BackupRepository) UpsertCertification(ctx context.Context, userID int, request models.CertificationRequest) (int, bool, error) {
+	prerequisites, err := prerequisitesParam(request.Prerequisites)
+	if err != nil {
+		return 0, false, utils.ErrProcessingFailed
+	}
+	var id int
+	var created bool
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO certifications (user_id, agency, level, certification_number, certified_on, instructor, notes, prerequisites)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, lower(agency), lower(level)) DO UPDATE SET
+			certification_number = COALESCE(certifications.certification_number, EXCLUDED.certification_number),
+			certified_on = COALESCE(certifications.certified_on, EXCLUDED.certified_on),
+			instructor = COALESCE(certifications.instructor, EXCLUDED.instructor),
+			notes = COALESCE(certifications.notes, EXCLUDED.notes),
+			prerequisites = CASE WHEN certifications.prerequisites = '[]'::jsonb
+				THEN EXCLUDED.prerequisites ELSE certifications.prerequisites END
+		RETURNING id, (xmax = 0)`,
+		userID, strings.TrimSpace(request.Agency), strings.TrimSpace(request.Level),
+		optionalText(request.CertificationNumber), optionalText(request.CertifiedOn),
+		optionalText(request.Instructor), optionalText(request.Notes), prerequisites,
+	).Scan(&id, &created)
+	if err != nil {
+		utils.LogError(ctx, "Error restoring certification", err, utils.UserID(userID))
+		return 0, false, utils.ErrDatabaseError
+	}
+	return id, created, nil
+}
+
+// UpsertTag restores a tag by case-insensitive name.
+func (r *BackupRepository) UpsertTag(ctx context.Context, userID int, name string) (bool, error) {
+	var created bool
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO tags (user_id, name) VALUES ($1, $2)
+		ON CONFLICT (user_id, lower(name)) WHERE deleted_at IS NULL DO UPDATE SET name = tags.name
+		RETURNING (xmax = 0)`, userID, strings.TrimSpace(name)).Scan(&created)
+	if err != nil {
+		utils.LogError(ctx, "Error restoring tag", err, utils.UserID(userID))
+		return false, utils.ErrDatabaseError
+	}
+	return created, nil
+}
+
+// InsertBulkOperation restores undo history, leaving an operation the user
+// already has untouched. An ID that belongs to another account, as when an
+// archive is restored into a second account, is replaced by one derived from
+// it and the user, so restoring the same archive again still skips it.
+func (r *BackupRepository) InsertBulkOperation(ctx context.Context, userID int, operation models.BackupBulkOperation) (bool, error) {
+	createdAt := operation.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	id := operation.ID
+	var owner int
+	err := r.db.QueryRowContext(ctx, `SELECT user_id FROM bulk_operations WHERE id = $1`, id).Scan(&owner)
+	if err != nil && err != sql.ErrNoRows {
+		utils.LogError(ctx, "Error checking restored bulk operation", err, utils.UserID(userID))
+		return false, utils.ErrDatabaseError
+	}
+	if err == nil && owner != userID {
+		id = restoredOperationID(id, userID)
+	}
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO bulk_operations (id, user_id, operation_type, before_state, affected_count, created_at, undone_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO NOTHING`,
+		id, userID, operation.OperationType, []byte(operation.BeforeState),
+		operation.AffectedCount, createdAt, operation.UndoneAt)
+	if err != nil {
+		utils.LogError(ctx, "Error restoring bulk operation", err, utils.UserID(userID))
+		return false, utils.ErrDatabaseError
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// restoredOperationID derives the ID userID's copy of another account's bulk
+// operation gets. It has the length and format of newOperationID.
+func restoredOperationID(id string, userID int) string {
+	sum := sha256.Sum256([]byte(id + ":" + strconv.Itoa(userID)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// UpsertSpecies finds a catalog species by scientific name, falling back to
+// common name, and creates it when neither matches. It reports whether the
+// species was created.
+func (r *BackupRepository) UpsertSpecies(ctx context.Context, request models.SpeciesRequest) (int, bool, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id FROM species
+		WHERE lower(scientific_name) = lower($2) OR lower(common_name) = lower($1)
+		ORDER BY (lower(scientific_name) = lower($2)) IS TRUE DESC
+		LIMIT 1`, strings.TrimSpace(request.CommonName), optionalText(request.ScientificName)).Scan(&id)
+	if err == nil {
+		return id, false, nil
+	}
+	if err != sql.ErrNoRows {
+		utils.LogError(ctx, "Error matching restored species", err)
+		return 0, false, utils.ErrDatabaseError
+	}
+	if err := r.db.QueryRowContext(ctx, `
+		INSERT INTO species (common_name, scientific_name, category) VALUES ($1, $2, $3)
+		RETURNING id`, strings.TrimSpace(request.CommonName), optionalText(request.ScientificName), request.Category,
+	).Scan(&id); err != nil {
+		utils.LogError(ctx, "Error restoring species", err)
+		return 0, false, utils.ErrDatabaseError
+	}
+	return id, true, nil
+}
+
+// InsertSighting restores a sighting, leaving an existing sighting of the same
+// species on the dive untouched.
+func (r *BackupRepository) InsertSighting(ctx context.Context, diveID, speciesID int, sighting models.BackupSighting) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO dive_sightings (dive_id, species_id, count, notes) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (dive_id, species_id) DO NOTHING`,
+		diveID, speciesID, sighting.Count, optionalText(sighting.Notes))
+	if err != nil {
+		utils.LogError(ctx, "Error restoring sighting", err)
+		return false, utils.ErrDatabaseError
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
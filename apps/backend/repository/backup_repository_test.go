@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestoredOperationIDIsStablePerUser(t *testing.T) {
+	original := "0123456789abcdef0123456789abcdef"
+
+	id := restoredOperationID(original, 7)
+
+	assert.Len(t, id, 32)
+	assert.NotEqual(t, original, id)
+	assert.Equal(t, id, restoredOperationID(original, 7))
+	assert.NotEqual(t, id, restoredOperationID(original, 8))
+}
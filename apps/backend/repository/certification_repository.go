@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"fmt"
+	"strings"
+)
+
+// CertificationRepository stores each user's certifications and the metadata
+// of their card images; the images themselves live in the media store.
+type CertificationRepository struct {
+	db *sql.DB
+}
+
+func NewCertificationRepository(db *sql.DB) *CertificationRepository {
+	return &CertificationRepository{db: db}
+}
+
+const certificationColumns = `
+	c.id, c.user_id, c.agency, c.level, c.certification_number, c.certified_on::text, c.instructor, c.notes,
+	c.prerequisites, c.card_image_key IS NOT NULL, c.created_at, c.updated_at,
+	(SELECT COUNT(*)::int FROM dives d WHERE d.certification_id = c.id AND d.deleted_at IS NULL)`
+
+func scanCertification(row interface{ Scan(...interface{}) error }) (*models.Certification, error) {
+	var certification models.Certification
+	var number, certifiedOn, instructor, notes sql.NullString
+	var prerequisites []byte
+	if err := row.Scan(
+		&certification.ID, &certification.UserID, &certification.Agency, &certification.Level,
+		&number, &certifiedOn, &instructor, &notes, &prerequisites, &certification.HasCardImage,
+		&certification.CreatedAt, &certification.UpdatedAt, &certification.TrainingDiveCount,
+	); err != nil {
+		return nil, err
+	}
+	certification.CertificationNumber = nullStringPointer(number)
+	certification.CertifiedOn = nullStringPointer(certifiedOn)
+	certification.Instructor = nullStringPointer(instructor)
+	certification.Notes = nullStringPointer(notes)
+	certification.Prerequisites = []models.CertificationRequirement{}
+	utils.UnmarshalJSON(prerequisites, &certification.Prerequisites)
+	return &certification, nil
+}
+
+func prerequisitesParam(requirements []models.CertificationRequirement) ([]byte, error) {
+	if requirements == nil {
+		requirements = []models.CertificationRequirement{}
+	}
+	return utils.MarshalJSON(requirements)
+}
+
+// List returns userID's certifications, completed ones in certification order
+// followed by courses in progress.
+func (r *CertificationRepository) List(ctx context.Context, userID int) ([]models.Certification, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+certificationColumns+`
+		FROM certifications c
+		WHERE c.user_id = $1
+		ORDER BY c.certified_on NULLS LAST, c.id`, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error listing certifications", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	certifications := []models.Certification{}
+	for rows.Next() {
+		certification, err := scanCertification(rows)
+		if err != nil {
+			utils.LogError(ctx, "Error scanning certification", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		certifications = append(certifications, *certification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return certifications, nil
+}
+
+// Get returns one of userID's certifications.
+func (r *CertificationRepository) Get(ctx context.Context, userID, id int) (*models.Certification, error) {
+	certification, err := scanCertification(r.db.QueryRowContext(ctx, `
+		SELECT `+certificationColumns+`
+		FROM certifications c
+		WHERE c.id = $1 AND c.user_id = $2`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrCertificationNotFound
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error getting certification", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	return certification, nil
+}
+
+// Create adds a certification. Agency and level are unique per user
+// regardless of case.
+func (r *CertificationRepository) Create(ctx context.Context, userID int, request models.CertificationRequest) (*models.Certification, error) {
+	prerequisites, err := prerequisitesParam(request.Prerequisites)
+	if err != nil {
+		return nil, utils.ErrProcessingFailed
+	}
+	var id int
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO certifications (user_id, agency, level, certification_number, certified_on, instructor, notes, prerequisites)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		userID, request.Agency, request.Level, optionalText(request.CertificationNumber),
+		optionalText(request.CertifiedOn), optionalText(request.Instructor), optionalText(request.Notes), prerequisites,
+	).Scan(&id)
+	if isUniqueViolation(err) {
+		return nil, utils.ErrCertificationExists
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error creating certification", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	return r.Get(ctx, userID, id)
+}
+
+// Update replaces the writable fields of one of userID's certifications.
+func (r *CertificationRepository) Update(ctx context.Context, userID, id int, request models.CertificationRequest) (*models.Certification, error) {
+	prerequisites, err := prerequisitesParam(request.Prerequisites)
+	if err != nil {
+		return nil, utils.ErrProcessingFailed
+	}
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE certifications
+		SET agency = $1, level = $2, certification_number = $3, certified_on = $4, instructor = $5,
+		    notes = $6, prerequisites = $7, updated_at = NOW()
+		WHERE id = $8 AND user_id = $9`,
+		request.Agency, request.Level, optionalText(request.CertificationNumber), optionalText(request.CertifiedOn),
+		optionalText(request.Instructor), optionalText(request.Notes), prerequisites, id, userID)
+	if isUniqueViolation(err) {
+		return nil, utils.ErrCertificationExists
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error updating certification", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return nil, utils.ErrCertificationNotFound
+	}
+	return r.Get(ctx, userID, id)
+}
+
+// Delete removes one of userID's certifications and returns the key of its
+// card image, if any, so the caller can remove the file. Linked dives keep
+// their data and lose only the link.
+func (r *CertificationRepository) Delete(ctx context.Context, userID, id int) (*string, error) {
+	var cardKey sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+		DELETE FROM certifications WHERE id = $1 AND user_id = $2
+		RETURNING card_image_key`, id, userID).Scan(&cardKey)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrCertificationNotFound
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error deleting certification", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	return nullStringPointer(cardKey), nil
+}
+
+// CardImage returns the media key and content type of a certification's card
+// image.
+func (r *CertificationRepository) CardImage(ctx context.Context, userID, id int) (string, string, error) {
+	var key, contentType sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+		SELECT card_image_key, card_image_type FROM certifications WHERE id = $1 AND user_id = $2`,
+		id, userID).Scan(&key, &contentType)
+	if err == sql.ErrNoRows {
+		return "", "", utils.ErrCertificationNotFound
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error getting certification card image", err, utils.UserID(userID))
+		return "", "", utils.ErrDatabaseError
+	}
+	if !key.Valid {
+		return "", "", utils.ErrCardImageNotFound
+	}
+	return key.String, contentType.String, nil
+}
+
+// SetCardImage points a certification at a stored image, or clears the image
+// when key is nil, and returns the key it replaced.
+func (r *CertificationRepository) SetCardImage(ctx context.Context, userID, id int, key, contentType *string) (*string, error) {
+	var previous sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE certifications c
+		SET card_image_key = $1, card_image_type = $2, updated_at = NOW()
+		FROM certifications old
+		WHERE c.id = $3 AND c.user_id = $4 AND old.id = c.id
+		RETURNING old.card_image_key`, key, contentType, id, userID).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrCertificationNotFound
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error setting certification card image", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	return nullStringPointer(previous), nil
+}
+
+// requirementCondition turns requirement into a WHERE condition on dives
+// aliased d, with parameters numbered from first.
+func requirementCondition(requirement models.CertificationRequirement, certificationID, first int) (string, []interface{}) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	add := func(format string, value interface{}) {
+		conditions = append(conditions, fmt.Sprintf(format, first+len(args)))
+		args = append(args, value)
+	}
+	if requirement.MinDepth != nil {
+		add("d.max_depth >= $%d", *requirement.MinDepth)
+	}
+	if requirement.MinDuration != nil {
+		add("d.duration >= $%d", *requirement.MinDuration)
+	}
+	if requirement.DiveType != nil {
+		add("d.dive_type = $%d", *requirement.DiveType)
+	}
+	if requirement.LinkedOnly {
+		add("d.certification_id = $%d", certificationID)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// CountMatchingDives returns how many of userID's dives satisfy requirement.
+func (r *CertificationRepository) CountMatchingDives(ctx context.Context, userID, certificationID int, requirement models.CertificationRequirement) (int, error) {
+	condition, args := requirementCondition(requirement, certificationID, 2)
+	var count int
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM dives d WHERE d.user_id = $1 AND d.deleted_at IS NULL AND `+condition,
+		append([]interface{}{userID}, args...)...,
+	).Scan(&count); err != nil {
+		utils.LogError(ctx, "Error counting dives for certification requirement", err, utils.UserID(userID))
+		return 0, utils.ErrDatabaseError
+	}
+	return count, nil
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"divelog-backend/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirementConditionNumbersParameters(t *testing.T) {
+	depth, duration, diveType := 30.0, 20, "training"
+	condition, args := requirementCondition(models.CertificationRequirement{
+		MinDives: 10, MinDepth: &depth, MinDuration: &duration, DiveType: &diveType, LinkedOnly: true,
+	}, 7, 2)
+
+	assert.Equal(t, "TRUE AND d.max_depth >= $2 AND d.duration >= $3 AND d.dive_type = $4 AND d.certification_id = $5", condition)
+	assert.Equal(t, []interface{}{30.0, 20, "training", 7}, args)
+
+	condition, args = requirementCondition(models.CertificationRequirement{MinDives: 50}, 7, 2)
+	assert.Equal(t, "TRUE", condition)
+	assert.Empty(t, args)
+}
@@ -32,52 +32,90 @@ func jsonbParam(payload []byte) interface{} {
 }
 
 // marshalDiveJSON serializes the JSONB-backed fields of a dive.
-func marshalDiveJSON(dive *models.Dive) (samples, equipment, conditions, safetyStops, computer interface{}, err error) {
+func marshalDiveJSON(dive *models.Dive) (samples, equipment, conditions, safetyStops, computer, extraData interface{}, err error) {
 	samplesJSON, err := utils.MarshalJSON(dive.Samples)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, err
 	}
 	equipmentJSON, err := utils.MarshalJSON(dive.Equipment)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, err
 	}
 	conditionsJSON, err := utils.MarshalJSON(dive.Conditions)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, err
 	}
 	safetyStopsJSON, err := utils.MarshalJSON(dive.SafetyStops)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, err
 	}
 	computerJSON, err := utils.MarshalJSON(dive.Computer)
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, nil, nil, err
+	}
+	var extraDataJSON []byte
+	if len(dive.ExtraData) > 0 {
+		if extraDataJSON, err = utils.MarshalJSON(dive.ExtraData); err != nil {
+			return nil, nil, nil, nil, nil, nil, err
+		}
 	}
 	return jsonbParam(samplesJSON), jsonbParam(equipmentJSON),
-		jsonbParam(conditionsJSON), jsonbParam(safetyStopsJSON), jsonbParam(computerJSON), nil
+		jsonbParam(conditionsJSON), jsonbParam(safetyStopsJSON), jsonbParam(computerJSON), jsonbParam(extraDataJSON), nil
 }
 
-// GetDivesByUserID retrieves all dives for a user
-func (r *DiveRepository) GetDivesByUserID(ctx context.Context, userID int) ([]models.Dive, error) {
-	query := `
+// diveSelectQuery is shared by every read that returns full dives; callers
+// append further conditions after the user filter. Trashed dives are left out,
+// and a dive in a trashed trip reads as having no trip.
+const diveSelectQuery = `
 		SELECT 
-			d.id, d.user_id, d.dive_site_id, d.dive_number, d.trip_id, d.dive_datetime, d.max_depth, d.duration,
+			d.id, d.user_id, d.dive_site_id, d.dive_number, tr.id, d.certification_id, d.dive_datetime, d.max_depth, d.duration,
 			d.buddy, d.water_temperature, d.visibility, d.notes, d.samples, d.equipment,
-			d.conditions, d.dive_type, d.dive_mode, d.mean_depth, d.computer_metadata, d.rating, d.safety_stops, d.created_at, d.updated_at,
+			d.conditions, d.dive_type, d.dive_mode, d.mean_depth, d.computer_metadata, d.rating, d.safety_stops, d.extra_data, d.created_at, d.updated_at, d.version,
 			COALESCE(ds.latitude, d.latitude, 0.0) as latitude,
 			COALESCE(ds.longitude, d.longitude, 0.0) as longitude,
 			COALESCE(ds.name, d.location, 'Unknown Location') as location,
 			tr.name, tr.location, tr.start_date::text, tr.end_date::text, tr.notes,
 			ARRAY(SELECT t.name FROM dive_tags dt JOIN tags t ON t.id = dt.tag_id
-			      WHERE dt.dive_id = d.id ORDER BY lower(t.name)) AS tags
+			      WHERE dt.dive_id = d.id AND t.deleted_at IS NULL ORDER BY lower(t.name)) AS tags
 		FROM dives d
-		LEFT JOIN dive_sites ds ON d.dive_site_id = ds.id
-		LEFT JOIN trips tr ON d.trip_id = tr.id
-		WHERE d.user_id = $1
-		ORDER BY d.dive_datetime DESC, d.created_at DESC
-	`
+		LEFT JOIN dive_sites ds ON d.dive_site_id = ds.id AND ds.deleted_at IS NULL
+		LEFT JOIN trips tr ON d.trip_id = tr.id AND tr.deleted_at IS NULL
+		WHERE d.user_id = $1 AND d.deleted_at IS NULL`
 
-	rows, err := r.db.Query(query, userID)
+// GetDivesByUserID retrieves all dives for a user
+func (r *DiveRepository) GetDivesByUserID(ctx context.Context, userID int) ([]models.Dive, error) {
+	return r.queryDives(ctx, userID, "")
+}
+
+// GetDivesByExtraData retrieves a user's dives that carry an extra-data key at
+// either the dive or the dive-computer level, optionally with a given value.
+func (r *DiveRepository) GetDivesByExtraData(ctx context.Context, userID int, key string, value *string) ([]models.Dive, error) {
+	if value == nil {
+		return r.queryDives(ctx, userID,
+			` AND (d.extra_data ? $2 OR d.computer_metadata->'extra_data' ? $2)`, key)
+	}
+	return r.queryDives(ctx, userID,
+		` AND (d.extra_data->>$2 = $3 OR d.computer_metadata->'extra_data'->>$2 = $3)`, key, *value)
+}
+
+// GetDive retrieves one of a user's dives. Its surface interval depends on the
+// previous dive and is left unset.
+func (r *DiveRepository) GetDive(ctx context.Context, userID, diveID int) (*models.Dive, error) {
+	dives, err := r.queryDives(ctx, userID, ` AND d.id = $2`, diveID)
+	if err != nil {
+		return nil, err
+	}
+	if len(dives) == 0 {
+		return nil, utils.ErrDiveNotFound
+	}
+	return &dives[0], nil
+}
+
+func (r *DiveRepository) queryDives(ctx context.Context, userID int, condition string, args ...interface{}) ([]models.Dive, error) {
+	query := diveSelectQuery + condition + `
+		ORDER BY d.dive_datetime DESC, d.created_at DESC`
+
+	rows, err := r.db.Query(query, append([]interface{}{userID}, args...)...)
 	if err != nil {
 		utils.LogError(ctx, "Error querying dives", err, utils.UserID(userID))
 		return nil, utils.ErrDatabaseError
@@ -119,26 +157,33 @@ func (r *DiveRepository) CreateDive(ctx context.Context, dive *models.Dive) erro
 	if err := r.prepareDiveOrganization(dive); err != nil {
 		return err
 	}
-	samplesParam, equipmentParam, conditionsParam, safetyStopsParam, computerParam, err := marshalDiveJSON(dive)
+	samplesParam, equipmentParam, conditionsParam, safetyStopsParam, computerParam, extraDataParam, err := marshalDiveJSON(dive)
 	if err != nil {
 		utils.LogError(ctx, "Error marshaling dive JSON fields", err, utils.UserID(dive.UserID))
 		return utils.ErrProcessingFailed
 	}
 
 	query := `
-		INSERT INTO dives (user_id, dive_site_id, dive_number, trip_id, dive_datetime, max_depth, mean_depth, duration, buddy, latitude, longitude, location, water_temperature, visibility, notes, samples, equipment, conditions, dive_type, dive_mode, computer_metadata, rating, safety_stops, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25)
+		INSERT INTO dives (user_id, dive_site_id, dive_number, trip_id, certification_id, dive_datetime, max_depth, mean_depth, duration, buddy, latitude, longitude, location, water_temperature, visibility, notes, samples, equipment, conditions, dive_type, dive_mode, computer_metadata, rating, safety_stops, extra_data, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)
 		RETURNING id, created_at, updated_at
 	`
 
-	now := time.Now()
+	// Restored dives keep their original timestamps; new dives use the current time.
+	createdAt, updatedAt := time.Now(), time.Now()
+	if !dive.CreatedAt.IsZero() {
+		createdAt = dive.CreatedAt
+	}
+	if !dive.UpdatedAt.IsZero() {
+		updatedAt = dive.UpdatedAt
+	}
 	err = r.db.QueryRow(
 		query,
-		dive.UserID, dive.DiveSiteID, dive.DiveNumber, dive.TripID, dive.DateTime, dive.MaxDepth, dive.MeanDepth, dive.Duration,
+		dive.UserID, dive.DiveSiteID, dive.DiveNumber, dive.TripID, dive.CertificationID, dive.DateTime, dive.MaxDepth, dive.MeanDepth, dive.Duration,
 		dive.Buddy, dive.Latitude, dive.Longitude, dive.Location,
 		dive.WaterTemp, dive.Visibility, dive.Notes, samplesParam, equipmentParam,
-		conditionsParam, dive.DiveType, dive.DiveMode, computerParam, dive.Rating, safetyStopsParam,
-		now, now,
+		conditionsParam, dive.DiveType, dive.DiveMode, computerParam, dive.Rating, safetyStopsParam, extraDataParam,
+		createdAt, updatedAt,
 	).Scan(&dive.ID, &dive.CreatedAt, &dive.UpdatedAt)
 
 	if err != nil {
@@ -149,26 +194,31 @@ func (r *DiveRepository) CreateDive(ctx context.Context, dive *models.Dive) erro
 		return err
 	}
 
-	return nil
+	return r.refreshVersion(ctx, dive)
 }
 
-// UpdateDive updates an existing dive
+// UpdateDive updates an existing dive, keeping its previous version as a
+// revision. It runs in the transaction of the calling workflow.
 func (r *DiveRepository) UpdateDive(ctx context.Context, diveID, userID int, dive *models.Dive) error {
 	dive.ID = diveID
 	if err := r.prepareDiveOrganization(dive); err != nil {
 		return err
 	}
-	samplesParam, equipmentParam, conditionsParam, safetyStopsParam, computerParam, err := marshalDiveJSON(dive)
+	samplesParam, equipmentParam, conditionsParam, safetyStopsParam, computerParam, extraDataParam, err := marshalDiveJSON(dive)
 	if err != nil {
 		return utils.ErrProcessingFailed
 	}
+	if err := recordDiveRevisions(ctx, r.db, userID, []int{diveID}, models.DiveRevisionSourceUpdate); err != nil {
+		return err
+	}
 
 	query := `
 		UPDATE dives
 		SET dive_site_id = $1, dive_number = $2, trip_id = $3, dive_datetime = $4, max_depth = $5, mean_depth = $6, duration = $7, buddy = $8,
 		    latitude = $9, longitude = $10, location = $11, water_temperature = $12, visibility = $13, notes = $14, samples = $15, equipment = $16,
-		    conditions = $17, dive_type = $18, dive_mode = $19, computer_metadata = $20, rating = $21, safety_stops = $22, updated_at = $23
-		WHERE id = $24 AND user_id = $25
+		    conditions = $17, dive_type = $18, dive_mode = $19, computer_metadata = $20, rating = $21, safety_stops = $22, extra_data = $23, updated_at = $24,
+		    certification_id = $25
+		WHERE id = $26 AND user_id = $27 AND deleted_at IS NULL
 		RETURNING id, user_id, created_at, updated_at
 	`
 	now := time.Now()
@@ -177,8 +227,8 @@ func (r *DiveRepository) UpdateDive(ctx context.Context, diveID, userID int, div
 		query,
 		dive.DiveSiteID, dive.DiveNumber, dive.TripID, dive.DateTime, dive.MaxDepth, dive.MeanDepth, dive.Duration, dive.Buddy,
 		dive.Latitude, dive.Longitude, dive.Location, dive.WaterTemp, dive.Visibility, dive.Notes, samplesParam, equipmentParam,
-		conditionsParam, dive.DiveType, dive.DiveMode, computerParam, dive.Rating, safetyStopsParam, now,
-		diveID, userID,
+		conditionsParam, dive.DiveType, dive.DiveMode, computerParam, dive.Rating, safetyStopsParam, extraDataParam, now,
+		dive.CertificationID, diveID, userID,
 	).Scan(
 		&dive.ID, &dive.UserID, &dive.CreatedAt, &dive.UpdatedAt,
 	)
@@ -195,56 +245,78 @@ func (r *DiveRepository) UpdateDive(ctx context.Context, diveID, userID int, div
 		return err
 	}
 
+	return r.refreshVersion(ctx, dive)
+}
+
+// refreshVersion reads the dive's version after its tags were rewritten, since
+// each tag change touches the dive row and bumps the version again.
+func (r *DiveRepository) refreshVersion(ctx context.Context, dive *models.Dive) error {
+	if err := r.db.QueryRowContext(ctx, `SELECT version FROM dives WHERE id = $1`, dive.ID).Scan(&dive.Version); err != nil {
+		utils.LogError(ctx, "Error reading dive version", err, utils.UserID(dive.UserID), utils.DiveID(dive.ID))
+		return utils.ErrDatabaseError
+	}
 	return nil
 }
 
 // DeleteAllDives removes every dive belonging to a user and reports how many
 // rows were removed. Intended for resetting local test data.
 func (r *DiveRepository) DeleteAllDives(ctx context.Context, userID int) (int64, error) {
-	result, err := r.db.ExecContext(ctx, `DELETE FROM dives WHERE user_id = $1`, userID)
-	if err != nil {
-		utils.LogError(ctx, "Error deleting all dives", err, utils.UserID(userID))
-		return 0, utils.ErrDatabaseError
-	}
-
-	deleted, err := result.RowsAffected()
-	if err != nil {
-		utils.LogError(ctx, "Error getting rows affected", err, utils.UserID(userID))
-		return 0, utils.ErrDatabaseError
-	}
+	var deleted int64
+	err := auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		result, err := db.ExecContext(ctx, `DELETE FROM dives WHERE user_id = $1`, userID)
+		if err != nil {
+			utils.LogError(ctx, "Error deleting all dives", err, utils.UserID(userID))
+			return utils.ErrDatabaseError
+		}
 
-	return deleted, nil
+		deleted, err = result.RowsAffected()
+		if err != nil {
+			utils.LogError(ctx, "Error getting rows affected", err, utils.UserID(userID))
+			return utils.ErrDatabaseError
+		}
+		return nil
+	})
+	return deleted, err
 }
 
-// DeleteDive deletes a dive
+// DeleteDive moves a dive to the trash. Its tags, sightings and revisions stay
+// with it until it is purged.
 func (r *DiveRepository) DeleteDive(ctx context.Context, diveID, userID int) error {
-	query := `DELETE FROM dives WHERE id = $1 AND user_id = $2`
-	result, err := r.db.Exec(query, diveID, userID)
-	if err != nil {
-		utils.LogError(ctx, "Error deleting dive", err, utils.UserID(userID), utils.DiveID(diveID))
-		return utils.ErrDatabaseError
-	}
+	return auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		query := `UPDATE dives SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
+		result, err := db.ExecContext(ctx, query, diveID, userID)
+		if err != nil {
+			utils.LogError(ctx, "Error deleting dive", err, utils.UserID(userID), utils.DiveID(diveID))
+			return utils.ErrDatabaseError
+		}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		utils.LogError(ctx, "Error getting rows affected", err, utils.UserID(userID), utils.DiveID(diveID))
-		return utils.ErrDatabaseError
-	}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			utils.LogError(ctx, "Error getting rows affected", err, utils.UserID(userID), utils.DiveID(diveID))
+			return utils.ErrDatabaseError
+		}
 
-	if rowsAffected == 0 {
-		return utils.ErrDiveNotFound
-	}
+		if rowsAffected == 0 {
+			return utils.ErrDiveNotFound
+		}
 
-	return nil
+		return nil
+	})
+}
+
+// EnqueueWebhookEvent queues a webhook event for userID in the repository's
+// transaction, so it is only sent if the transaction's writes commit.
+func (r *DiveRepository) EnqueueWebhookEvent(ctx context.Context, userID int, eventType string, data interface{}) error {
+	return enqueueWebhookEvent(ctx, r.db, userID, eventType, data)
 }
 
 // GetCurrentDive gets current dive info for comparison
 func (r *DiveRepository) GetCurrentDive(ctx context.Context, diveID, userID int) (*models.Dive, error) {
-	query := `SELECT dive_datetime, latitude, longitude, location FROM dives WHERE id = $1 AND user_id = $2`
+	query := `SELECT dive_datetime, latitude, longitude, location, version FROM dives WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
 
 	var dive models.Dive
 	err := r.db.QueryRow(query, diveID, userID).Scan(
-		&dive.DateTime, &dive.Latitude, &dive.Longitude, &dive.Location,
+		&dive.DateTime, &dive.Latitude, &dive.Longitude, &dive.Location, &dive.Version,
 	)
 
 	if err != nil {
@@ -264,7 +336,7 @@ func (r *DiveRepository) CheckDuplicateDive(ctx context.Context, userID int, div
 	dt := utils.ParseDateTime(diveDateTime)
 
 	query := `SELECT COUNT(*) FROM dives
-			  WHERE user_id = $1 AND dive_site_id = $2 AND dive_datetime = $3`
+			  WHERE user_id = $1 AND dive_site_id = $2 AND dive_datetime = $3 AND deleted_at IS NULL`
 
 	var count int
 	err := r.db.QueryRow(query, userID, diveSiteID, dt).Scan(&count)
@@ -280,7 +352,7 @@ func (r *DiveRepository) CheckDuplicateDive(ctx context.Context, userID int, div
 func (r *DiveRepository) CheckDuplicateDiveForUpdate(ctx context.Context, userID, diveSiteID int, diveDateTime string, excludeDiveID int) (bool, error) {
 	dt := utils.ParseDateTime(diveDateTime)
 	query := `SELECT COUNT(*) FROM dives
-		WHERE user_id = $1 AND dive_site_id = $2 AND dive_datetime = $3 AND id != $4`
+		WHERE user_id = $1 AND dive_site_id = $2 AND dive_datetime = $3 AND id != $4 AND deleted_at IS NULL`
 
 	var count int
 	err := r.db.QueryRow(query, userID, diveSiteID, dt, excludeDiveID).Scan(&count)
@@ -299,15 +371,16 @@ func (r *DiveRepository) scanDive(rows *sql.Rows) (*models.Dive, error) {
 	var conditionsJSON []byte
 	var safetyStopsJSON []byte
 	var computerJSON []byte
+	var extraDataJSON []byte
 	var tripName, tripLocation, tripStart, tripEnd, tripNotes sql.NullString
 	var tags []string
 
 	err := rows.Scan(
-		&dive.ID, &dive.UserID, &dive.DiveSiteID, &dive.DiveNumber, &dive.TripID, &dive.DateTime, &dive.MaxDepth,
+		&dive.ID, &dive.UserID, &dive.DiveSiteID, &dive.DiveNumber, &dive.TripID, &dive.CertificationID, &dive.DateTime, &dive.MaxDepth,
 		&dive.Duration, &dive.Buddy, &dive.WaterTemp, &dive.Visibility,
 		&dive.Notes, &samplesJSON, &equipmentJSON,
-		&conditionsJSON, &dive.DiveType, &dive.DiveMode, &dive.MeanDepth, &computerJSON, &dive.Rating, &safetyStopsJSON,
-		&dive.CreatedAt, &dive.UpdatedAt,
+		&conditionsJSON, &dive.DiveType, &dive.DiveMode, &dive.MeanDepth, &computerJSON, &dive.Rating, &safetyStopsJSON, &extraDataJSON,
+		&dive.CreatedAt, &dive.UpdatedAt, &dive.Version,
 		&dive.Latitude, &dive.Longitude, &dive.Location,
 		&tripName, &tripLocation, &tripStart, &tripEnd, &tripNotes, pq.Array(&tags),
 	)
@@ -321,6 +394,7 @@ func (r *DiveRepository) scanDive(rows *sql.Rows) (*models.Dive, error) {
 	utils.UnmarshalJSON(conditionsJSON, &dive.Conditions)
 	utils.UnmarshalJSON(safetyStopsJSON, &dive.SafetyStops)
 	utils.UnmarshalJSON(computerJSON, &dive.Computer)
+	utils.UnmarshalJSON(extraDataJSON, &dive.ExtraData)
 	dive.Tags = tags
 	if dive.TripID != nil && tripName.Valid {
 		dive.Trip = &models.Trip{ID: *dive.TripID, UserID: dive.UserID, Name: tripName.String}
@@ -348,13 +422,14 @@ func optionalText(value *string) interface{} {
 	return strings.TrimSpace(*value)
 }
 
-// prepareDiveOrganization assigns the next human-visible number and resolves
-// either a selected trip ID or imported trip metadata within the current
-// transaction-bound repository.
+// prepareDiveOrganization assigns the next human-visible number, resolves
+// either a selected trip ID or imported trip metadata, and checks that a linked
+// certification belongs to the diver, all within the current transaction-bound
+// repository.
 func (r *DiveRepository) prepareDiveOrganization(dive *models.Dive) error {
 	if dive.DiveNumber == nil {
 		var next int
-		if err := r.db.QueryRow(`SELECT COALESCE(MAX(dive_number), 0) + 1 FROM dives WHERE user_id = $1`, dive.UserID).Scan(&next); err != nil {
+		if err := r.db.QueryRow(`SELECT COALESCE(MAX(dive_number), 0) + 1 FROM dives WHERE user_id = $1 AND deleted_at IS NULL`, dive.UserID).Scan(&next); err != nil {
 			return utils.ErrDatabaseError
 		}
 		dive.DiveNumber = &next
@@ -365,7 +440,7 @@ func (r *DiveRepository) prepareDiveOrganization(dive *models.Dive) error {
 		err := r.db.QueryRow(`
 			INSERT INTO trips (user_id, name, location, start_date, end_date, notes)
 			VALUES ($1, $2, $3, $4, $5, $6)
-			ON CONFLICT (user_id, lower(name)) DO UPDATE SET
+			ON CONFLICT (user_id, lower(name)) WHERE deleted_at IS NULL DO UPDATE SET
 				location = COALESCE(EXCLUDED.location, trips.location),
 				start_date = COALESCE(EXCLUDED.start_date, trips.start_date),
 				end_date = COALESCE(EXCLUDED.end_date, trips.end_date),
@@ -382,7 +457,7 @@ func (r *DiveRepository) prepareDiveOrganization(dive *models.Dive) error {
 		dive.Trip.UserID = dive.UserID
 	} else if dive.TripID != nil {
 		var name string
-		if err := r.db.QueryRow(`SELECT name FROM trips WHERE id = $1 AND user_id = $2`, *dive.TripID, dive.UserID).Scan(&name); err != nil {
+		if err := r.db.QueryRow(`SELECT name FROM trips WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`, *dive.TripID, dive.UserID).Scan(&name); err != nil {
 			if err == sql.ErrNoRows {
 				return utils.ErrTripNotFound
 			}
@@ -390,11 +465,26 @@ func (r *DiveRepository) prepareDiveOrganization(dive *models.Dive) error {
 		}
 		dive.Trip = &models.Trip{ID: *dive.TripID, UserID: dive.UserID, Name: name}
 	}
+
+	if dive.CertificationID != nil {
+		var owned bool
+		if err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM certifications WHERE id = $1 AND user_id = $2)`,
+			*dive.CertificationID, dive.UserID).Scan(&owned); err != nil {
+			return utils.ErrDatabaseError
+		}
+		if !owned {
+			return utils.ErrCertificationNotFound
+		}
+	}
 	return nil
 }
 
+// replaceDiveTags sets the dive's live tags. Links to trashed tags are kept so
+// restoring the tag brings them back.
 func (r *DiveRepository) replaceDiveTags(diveID, userID int, tagNames []string) error {
-	if _, err := r.db.Exec(`DELETE FROM dive_tags WHERE dive_id = $1`, diveID); err != nil {
+	if _, err := r.db.Exec(`
+		DELETE FROM dive_tags dt USING tags t
+		WHERE dt.tag_id = t.id AND dt.dive_id = $1 AND t.deleted_at IS NULL`, diveID); err != nil {
 		return utils.ErrDatabaseError
 	}
 	seen := map[string]bool{}
@@ -408,7 +498,7 @@ func (r *DiveRepository) replaceDiveTags(diveID, userID int, tagNames []string)
 		var tagID int
 		err := r.db.QueryRow(`
 			INSERT INTO tags (user_id, name) VALUES ($1, $2)
-			ON CONFLICT (user_id, lower(name)) DO UPDATE SET name = tags.name
+			ON CONFLICT (user_id, lower(name)) WHERE deleted_at IS NULL DO UPDATE SET name = tags.name
 			RETURNING id`, userID, name).Scan(&tagID)
 		if err != nil {
 			return utils.ErrDatabaseError
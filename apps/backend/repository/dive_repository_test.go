@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"divelog-backend/models"
+	"divelog-backend/utils"
 	"errors"
 	"fmt"
 	"testing"
@@ -93,10 +94,12 @@ func TestDiveValidation(t *testing.T) {
 }
 
 type deleteAllTestDriver struct {
-	result driver.Result
-	err    error
-	query  string
-	args   []driver.NamedValue
+	result    driver.Result
+	err       error
+	query     string
+	args      []driver.NamedValue
+	queries   []string
+	committed bool
 }
 
 func (d *deleteAllTestDriver) Open(string) (driver.Conn, error) {
@@ -114,15 +117,27 @@ func (c *deleteAllTestConn) Prepare(string) (driver.Stmt, error) {
 func (c *deleteAllTestConn) Close() error { return nil }
 
 func (c *deleteAllTestConn) Begin() (driver.Tx, error) {
-	return nil, errors.New("not implemented")
+	return &deleteAllTestTx{driver: c.driver}, nil
 }
 
 func (c *deleteAllTestConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
 	c.driver.query = query
 	c.driver.args = args
+	c.driver.queries = append(c.driver.queries, query)
 	return c.driver.result, c.driver.err
 }
 
+type deleteAllTestTx struct {
+	driver *deleteAllTestDriver
+}
+
+func (tx *deleteAllTestTx) Commit() error {
+	tx.driver.committed = true
+	return nil
+}
+
+func (tx *deleteAllTestTx) Rollback() error { return nil }
+
 func TestDiveRepositoryDeleteAllDivesScopesDeleteToUser(t *testing.T) {
 	testDriver := &deleteAllTestDriver{result: driver.RowsAffected(7)}
 	driverName := fmt.Sprintf("delete-all-dives-success-%d", time.Now().UnixNano())
@@ -138,6 +153,25 @@ func TestDiveRepositoryDeleteAllDivesScopesDeleteToUser(t *testing.T) {
 	assert.Equal(t, "DELETE FROM dives WHERE user_id = $1", testDriver.query)
 	require.Len(t, testDriver.args, 1)
 	assert.Equal(t, int64(42), testDriver.args[0].Value)
+	assert.True(t, testDriver.committed)
+}
+
+func TestDiveRepositoryDeleteAllDivesTagsTransactionForAuditLog(t *testing.T) {
+	testDriver := &deleteAllTestDriver{result: driver.RowsAffected(2)}
+	driverName := fmt.Sprintf("delete-all-dives-audit-%d", time.Now().UnixNano())
+	sql.Register(driverName, testDriver)
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	ctx := utils.WithActor(context.Background(), utils.Actor{UserID: 42, TokenID: 3, RequestID: "req-1"})
+
+	_, err = NewDiveRepository(db).DeleteAllDives(ctx, 42)
+
+	require.NoError(t, err)
+	require.Len(t, testDriver.queries, 2)
+	assert.Contains(t, testDriver.queries[0], "set_config('divelog.actor_user_id'")
+	assert.Equal(t, "DELETE FROM dives WHERE user_id = $1", testDriver.queries[1])
+	assert.True(t, testDriver.committed)
 }
 
 func TestCalculateSurfaceIntervalsUsesPreviousDiveEnd(t *testing.T) {
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+// DiveRevisionRepository reads and restores the revision history of dives.
+// Revisions are written by the dive write paths through recordDiveRevisions.
+type DiveRevisionRepository struct {
+	db *sql.DB
+}
+
+func NewDiveRevisionRepository(db *sql.DB) *DiveRevisionRepository {
+	return &DiveRevisionRepository{db: db}
+}
+
+// recordDiveRevisions stores the current version of each of the user's
+// selected dives as their next revision. Callers run it in the transaction of
+// the edit, before changing the dives.
+func recordDiveRevisions(ctx context.Context, db dbExecutor, userID int, diveIDs []int, source string) error {
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO dive_revisions (dive_id, user_id, revision, source, dive_version, snapshot)
+		SELECT d.id, d.user_id, COALESCE((SELECT MAX(r.revision) FROM dive_revisions r WHERE r.dive_id = d.id), 0) + 1,
+		       $3, d.version, dive_revision_snapshot(d)
+		FROM dives d WHERE d.user_id = $1 AND d.id = ANY($2) AND d.deleted_at IS NULL`,
+		userID, pq.Array(diveIDs), source); err != nil {
+		utils.LogError(ctx, "Error recording dive revisions", err, utils.UserID(userID))
+		return utils.ErrDatabaseError
+	}
+	return nil
+}
+
+// ListRevisions returns the revisions of a dive, newest first, each with the
+// changes the edit that replaced it made.
+func (r *DiveRevisionRepository) ListRevisions(ctx context.Context, userID, diveID int) ([]models.DiveRevision, error) {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM dives WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`, diveID, userID,
+	).Scan(&exists); err != nil {
+		utils.LogError(ctx, "Error checking dive", err, utils.UserID(userID), utils.DiveID(diveID))
+		return nil, utils.ErrDatabaseError
+	}
+	if !exists {
+		return nil, utils.ErrDiveNotFound
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT r.revision, r.source, r.dive_version, r.created_at,
+		       audit_diff(r.snapshot, COALESCE(LEAD(r.snapshot) OVER (ORDER BY r.revision), dive_revision_snapshot(d)))
+		FROM dive_revisions r JOIN dives d ON d.id = r.dive_id
+		WHERE r.dive_id = $1 AND r.user_id = $2
+		ORDER BY r.revision DESC`, diveID, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error querying dive revisions", err, utils.UserID(userID), utils.DiveID(diveID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	revisions := []models.DiveRevision{}
+	for rows.Next() {
+		var revision models.DiveRevision
+		if err := rows.Scan(&revision.Revision, &revision.Source, &revision.DiveVersion, &revision.CreatedAt, &revision.Changes); err != nil {
+			utils.LogError(ctx, "Error scanning dive revision", err, utils.UserID(userID), utils.DiveID(diveID))
+			return nil, utils.ErrDatabaseError
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return revisions, nil
+}
+
+// RestoreRevision puts a dive back the way it was in an earlier revision. The
+// version it replaces becomes a revision of its own, so a restore can be
+// reverted like any other edit. A trip or certification deleted since is
+// left unset, and a deleted dive site keeps the dive at its current site.
+// Webhook subscribers are sent the restored dive as dive.updated.
+func (r *DiveRevisionRepository) RestoreRevision(ctx context.Context, userID, diveID, revision int) (*models.Dive, error) {
+	tx, err := beginAudited(ctx, r.db, serializable)
+	if err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	defer tx.Rollback()
+
+	if err := ensureOwnedDives(ctx, tx, userID, []int{diveID}); err != nil {
+		return nil, err
+	}
+	var snapshot []byte
+	err = tx.QueryRowContext(ctx,
+		`SELECT snapshot FROM dive_revisions WHERE dive_id = $1 AND user_id = $2 AND revision = $3`,
+		diveID, userID, revision).Scan(&snapshot)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrDiveRevisionNotFound
+	}
+	if err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	var restored struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.Unmarshal(snapshot, &restored); err != nil {
+		return nil, utils.ErrProcessingFailed
+	}
+
+	if err := recordDiveRevisions(ctx, tx, userID, []int{diveID}, models.DiveRevisionSourceRestore); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE dives d
+		SET dive_site_id = COALESCE((SELECT id FROM dive_sites WHERE id = restored.dive_site_id AND deleted_at IS NULL), d.dive_site_id),
+		    dive_number = restored.dive_number,
+		    trip_id = (SELECT id FROM trips WHERE id = restored.trip_id AND user_id = d.user_id AND deleted_at IS NULL),
+		    dive_datetime = restored.dive_datetime, max_depth = restored.max_depth, mean_depth = restored.mean_depth,
+		    duration = restored.duration, buddy = restored.buddy, latitude = restored.latitude, longitude = restored.longitude,
+		    location = restored.location, water_temperature = restored.water_temperature, visibility = restored.visibility,
+		    notes = restored.notes, samples = restored.samples, equipment = restored.equipment, conditions = restored.conditions,
+		    dive_type = restored.dive_type, dive_mode = restored.dive_mode, computer_metadata = restored.computer_metadata,
+		    rating = restored.rating, safety_stops = restored.safety_stops, extra_data = restored.extra_data,
+		    certification_id = (SELECT id FROM certifications WHERE id = restored.certification_id AND user_id = d.user_id),
+		    updated_at = NOW()
+		FROM jsonb_populate_record(NULL::dives, $1) restored
+		WHERE d.id = $2 AND d.user_id = $3`, snapshot, diveID, userID); err != nil {
+		utils.LogError(ctx, "Error restoring dive revision", err, utils.UserID(userID), utils.DiveID(diveID))
+		return nil, utils.ErrDatabaseError
+	}
+
+	if err := ensureNoDuplicateDive(ctx, tx, diveID); err != nil {
+		return nil, err
+	}
+
+	dives := newDiveRepository(tx)
+	if err := dives.replaceDiveTags(diveID, userID, restored.Tags); err != nil {
+		return nil, err
+	}
+	dive, err := dives.GetDive(ctx, userID, diveID)
+	if err != nil {
+		return nil, err
+	}
+	if err := enqueueWebhookEvent(ctx, tx, userID, models.WebhookEventDiveUpdated, dive); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return dive, nil
+}
+
+// ensureNoDuplicateDive returns ErrDuplicateDive when another live dive of the
+// same user has the dive's site and start time.
+func ensureNoDuplicateDive(ctx context.Context, db dbExecutor, diveID int) error {
+	var duplicate bool
+	if err := db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM dives target JOIN dives existing
+		  ON existing.user_id = target.user_id
+		 AND existing.dive_site_id IS NOT DISTINCT FROM target.dive_site_id
+		 AND existing.dive_datetime = target.dive_datetime
+		 WHERE target.id = $1 AND existing.id <> target.id AND existing.deleted_at IS NULL)`, diveID).Scan(&duplicate); err != nil {
+		return utils.ErrDatabaseError
+	}
+	if duplicate {
+		return utils.ErrDuplicateDive
+	}
+	return nil
+}
+
+// ensureNoDuplicateDivesAcrossSites returns ErrDuplicateDive when live dives
+// of the same user at two different sites among siteIDs share a start time,
+// which ensureNoDuplicateDive would reject once the sites are merged.
+func ensureNoDuplicateDivesAcrossSites(ctx context.Context, db dbExecutor, siteIDs []int) error {
+	var duplicate bool
+	if err := db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM dives a JOIN dives b
+		  ON b.user_id = a.user_id
+		 AND b.dive_datetime = a.dive_datetime
+		 AND b.dive_site_id <> a.dive_site_id
+		 WHERE a.dive_site_id = ANY($1) AND b.dive_site_id = ANY($1)
+		   AND a.deleted_at IS NULL AND b.deleted_at IS NULL)`, pq.Array(siteIDs)).Scan(&duplicate); err != nil {
+		utils.LogError(ctx, "Error checking for duplicate dives", err)
+		return utils.ErrDatabaseError
+	}
+	if duplicate {
+		return utils.ErrDuplicateDive
+	}
+	return nil
+}
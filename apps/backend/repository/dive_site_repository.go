@@ -5,6 +5,11 @@ import (
 	"database/sql"
 	"divelog-backend/models"
 	"divelog-backend/utils"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/lib/pq"
 )
 
 type DiveSiteRepository struct {
@@ -19,19 +24,19 @@ func newDiveSiteRepository(db dbExecutor) *DiveSiteRepository {
 	return &DiveSiteRepository{db: db}
 }
 
-// GetAll returns all dive sites
-func (r *DiveSiteRepository) GetAll(ctx context.Context) ([]models.DiveSite, error) {
-	query := `SELECT id, name, latitude, longitude, description, created_at, updated_at 
-			  FROM dive_sites ORDER BY name`
+// GetAll returns the dive sites matching filter
+func (r *DiveSiteRepository) GetAll(ctx context.Context, filter models.DiveSiteFilter) ([]models.DiveSite, error) {
+	condition, args := diveSiteFilterCondition(filter)
+	query := `SELECT ` + diveSiteColumns + ` FROM dive_sites WHERE deleted_at IS NULL AND ` + condition + ` ORDER BY name`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		utils.LogError(ctx, "Error querying dive sites", err)
 		return nil, utils.ErrDatabaseError
 	}
 	defer rows.Close()
 
-	var sites []models.DiveSite
+	sites := []models.DiveSite{}
 	for rows.Next() {
 		site, err := r.scanDiveSite(rows)
 		if err != nil {
@@ -46,9 +51,9 @@ func (r *DiveSiteRepository) GetAll(ctx context.Context) ([]models.DiveSite, err
 
 // Search searches for dive sites by name
 func (r *DiveSiteRepository) Search(ctx context.Context, query string) ([]models.DiveSite, error) {
-	searchQuery := `SELECT id, name, latitude, longitude, description, created_at, updated_at 
+	searchQuery := `SELECT ` + diveSiteColumns + `
 					FROM dive_sites 
-					WHERE LOWER(name) LIKE LOWER($1) 
+					WHERE LOWER(name) LIKE LOWER($1) AND deleted_at IS NULL
 					ORDER BY name
 					LIMIT 10`
 
@@ -74,14 +79,10 @@ func (r *DiveSiteRepository) Search(ctx context.Context, query string) ([]models
 
 // GetByID returns a specific dive site
 func (r *DiveSiteRepository) GetByID(ctx context.Context, id int) (*models.DiveSite, error) {
-	query := `SELECT id, name, latitude, longitude, description, created_at, updated_at 
-			  FROM dive_sites WHERE id = $1`
+	query := `SELECT ` + diveSiteColumns + ` FROM dive_sites WHERE id = $1 AND deleted_at IS NULL`
 
 	var site models.DiveSite
-	err := r.db.QueryRow(query, id).Scan(
-		&site.ID, &site.Name, &site.Latitude, &site.Longitude,
-		&site.Description, &site.CreatedAt, &site.UpdatedAt,
-	)
+	err := r.db.QueryRow(query, id).Scan(diveSiteScanTargets(&site)...)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -97,15 +98,16 @@ func (r *DiveSiteRepository) GetByID(ctx context.Context, id int) (*models.DiveS
 // UpdateDiveSite persists an already validated dive site update.
 func (r *DiveSiteRepository) UpdateDiveSite(ctx context.Context, id int, siteReq *models.DiveSiteRequest) (*models.DiveSite, error) {
 	updateQuery := `UPDATE dive_sites 
-					SET name = $1, latitude = $2, longitude = $3, description = $4, updated_at = NOW()
-					WHERE id = $5
-					RETURNING id, name, latitude, longitude, description, created_at, updated_at`
+					SET name = $1, latitude = $2, longitude = $3, description = $4,
+					    max_depth = $5, typical_depth = $6, entry_type = $7, environment = $8, water_type = $9,
+					    country_code = $10, country = $11, region = $12, area = $13, access_notes = $14, hazards = $15,
+					    updated_at = NOW()
+					WHERE id = $16 AND deleted_at IS NULL
+					RETURNING ` + diveSiteColumns
 
 	var site models.DiveSite
-	err := r.db.QueryRow(updateQuery, siteReq.Name, siteReq.Latitude, siteReq.Longitude, siteReq.Description, id).Scan(
-		&site.ID, &site.Name, &site.Latitude, &site.Longitude,
-		&site.Description, &site.CreatedAt, &site.UpdatedAt,
-	)
+	args := append(diveSiteWriteArgs(siteReq), id)
+	err := r.db.QueryRow(updateQuery, args...).Scan(diveSiteScanTargets(&site)...)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -120,7 +122,7 @@ func (r *DiveSiteRepository) UpdateDiveSite(ctx context.Context, id int, siteReq
 
 func (r *DiveSiteRepository) CountDivesBySiteID(ctx context.Context, id int) (int, error) {
 	var diveCount int
-	err := r.db.QueryRow(`SELECT COUNT(*) FROM dives WHERE dive_site_id = $1`, id).Scan(&diveCount)
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM dives WHERE dive_site_id = $1 AND deleted_at IS NULL`, id).Scan(&diveCount)
 	if err != nil {
 		utils.LogError(ctx, "Error checking dive site usage", err)
 		return 0, utils.ErrDatabaseError
@@ -128,10 +130,17 @@ func (r *DiveSiteRepository) CountDivesBySiteID(ctx context.Context, id int) (in
 	return diveCount, nil
 }
 
-// DeleteDiveSite deletes an already validated unused dive site.
+// DeleteDiveSite moves an already validated unused dive site to the trash of
+// the acting user, who can restore it until it is purged. Without an actor
+// the site would land in nobody's trash, so it fails with ErrMissingUserID.
 func (r *DiveSiteRepository) DeleteDiveSite(ctx context.Context, id int) error {
-	deleteQuery := `DELETE FROM dive_sites WHERE id = $1`
-	result, err := r.db.Exec(deleteQuery, id)
+	actor := utils.ActorFromContext(ctx)
+	if actor.UserID == 0 {
+		return utils.ErrMissingUserID
+	}
+	deleteQuery := `UPDATE dive_sites SET deleted_at = NOW(), deleted_by = $2, updated_at = NOW()
+					WHERE id = $1 AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, deleteQuery, id, actor.UserID)
 	if err != nil {
 		utils.LogError(ctx, "Error deleting dive site", err)
 		return utils.ErrDatabaseError
@@ -150,10 +159,160 @@ func (r *DiveSiteRepository) DeleteDiveSite(ctx context.Context, id int) error {
 	return nil
 }
 
+// GetHistory aggregates userID's dives at a site.
+func (r *DiveSiteRepository) GetHistory(ctx context.Context, siteID, userID int) (*models.DiveSiteHistory, error) {
+	var history models.DiveSiteHistory
+	var firstDived, lastDived models.LocalTime
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), MIN(dive_datetime), MAX(dive_datetime),
+		       MAX(max_depth)::float8, ROUND(AVG(max_depth), 2)::float8, COALESCE(SUM(duration), 0),
+		       ROUND(AVG(water_temperature), 1)::float8, MIN(water_temperature)::float8,
+		       ROUND(AVG(visibility), 1)::float8
+		FROM dives
+		WHERE dive_site_id = $1 AND user_id = $2 AND deleted_at IS NULL`, siteID, userID).Scan(
+		&history.DiveCount, &firstDived, &lastDived,
+		&history.DeepestDive, &history.AverageDepth, &history.TotalDuration,
+		&history.AverageWaterTemperature, &history.MinWaterTemperature, &history.AverageVisibility,
+	)
+	if err != nil {
+		utils.LogError(ctx, "Error aggregating dive site history", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	if !firstDived.IsZero() {
+		history.FirstDived = &firstDived
+		history.LastDived = &lastDived
+	}
+	return &history, nil
+}
+
+// FindSitesForGeocoding returns up to limit sites with an ID above afterID,
+// in ID order. Unless overwrite is set, only sites still missing a country
+// code, country or region are returned.
+func (r *DiveSiteRepository) FindSitesForGeocoding(ctx context.Context, afterID, limit int, overwrite bool) ([]models.DiveSite, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+diveSiteColumns+`
+		FROM dive_sites
+		WHERE id > $1 AND deleted_at IS NULL AND ($3::boolean OR country_code IS NULL OR country IS NULL OR region IS NULL)
+		ORDER BY id
+		LIMIT $2`, afterID, limit, overwrite)
+	if err != nil {
+		utils.LogError(ctx, "Error querying dive sites for geocoding", err)
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	sites := []models.DiveSite{}
+	for rows.Next() {
+		site, err := r.scanDiveSite(rows)
+		if err != nil {
+			return nil, err
+		}
+		sites = append(sites, *site)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return sites, nil
+}
+
+// SetDiveSitePlace records the country and region a site lies in and reports
+// whether the site was changed. Without overwrite only missing values are
+// filled, and a site already recorded in a different country is left alone.
+// With overwrite all three are replaced, and the area is cleared when the
+// region changes.
+func (r *DiveSiteRepository) SetDiveSitePlace(ctx context.Context, id int, place models.Place, overwrite bool) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE dive_sites SET
+			country_code = CASE WHEN $5::boolean THEN $2 ELSE COALESCE(country_code, $2) END,
+			country = CASE WHEN $5::boolean THEN $3 ELSE COALESCE(country, $3) END,
+			region = CASE WHEN $5::boolean THEN NULLIF($4, '') ELSE COALESCE(region, NULLIF($4, '')) END,
+			area = CASE WHEN $5::boolean AND region IS DISTINCT FROM NULLIF($4, '') THEN NULL ELSE area END,
+			updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL AND ($5::boolean OR country IS NULL OR LOWER(country) = LOWER($3))`,
+		id, place.CountryCode, place.Country, place.Region, overwrite)
+	if err != nil {
+		utils.LogError(ctx, "Error setting dive site place", err, slog.Int("dive_site_id", id))
+		return false, utils.ErrDatabaseError
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(ctx, "Error getting rows affected", err)
+		return false, utils.ErrDatabaseError
+	}
+	return updated > 0, nil
+}
+
+// CountryStatistics groups userID's dives by the country code of their site,
+// most-dived country first. Dives at sites without a country form one group
+// with a nil code.
+func (r *DiveSiteRepository) CountryStatistics(ctx context.Context, userID int) ([]models.CountryStatistics, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT ds.country_code, MIN(ds.country), COUNT(*), COUNT(DISTINCT d.dive_site_id),
+		       MAX(d.max_depth)::float8, COALESCE(SUM(d.duration), 0),
+		       MIN(d.dive_datetime), MAX(d.dive_datetime)
+		FROM dives d
+		LEFT JOIN dive_sites ds ON ds.id = d.dive_site_id AND ds.deleted_at IS NULL
+		WHERE d.user_id = $1 AND d.deleted_at IS NULL
+		GROUP BY ds.country_code
+		ORDER BY COUNT(*) DESC, ds.country_code NULLS LAST`, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error aggregating country statistics", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	statistics := []models.CountryStatistics{}
+	for rows.Next() {
+		var entry models.CountryStatistics
+		var deepest sql.NullFloat64
+		var firstDived, lastDived models.LocalTime
+		if err := rows.Scan(&entry.CountryCode, &entry.Country, &entry.DiveCount, &entry.SiteCount,
+			&deepest, &entry.TotalDuration, &firstDived, &lastDived); err != nil {
+			utils.LogError(ctx, "Error scanning country statistics", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		if deepest.Valid {
+			entry.DeepestDive = &deepest.Float64
+		}
+		if !firstDived.IsZero() {
+			entry.FirstDived = &firstDived
+			entry.LastDived = &lastDived
+		}
+		statistics = append(statistics, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return statistics, nil
+}
+
+// ReassignDives points every dive at one of sourceIDs to targetID and returns
+// how many dives moved. It returns ErrDuplicateDive, moving nothing, when two
+// live dives of the same user at different sites among the target and sources
+// share a start time, since the merge would make them duplicates.
+func (r *DiveSiteRepository) ReassignDives(ctx context.Context, sourceIDs []int, targetID int) (int64, error) {
+	if err := ensureNoDuplicateDivesAcrossSites(ctx, r.db, append([]int{targetID}, sourceIDs...)); err != nil {
+		return 0, err
+	}
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE dives SET dive_site_id = $1, updated_at = NOW() WHERE dive_site_id = ANY($2)`,
+		targetID, pq.Array(sourceIDs))
+	if err != nil {
+		utils.LogError(ctx, "Error reassigning dives", err)
+		return 0, utils.ErrDatabaseError
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		utils.LogError(ctx, "Error getting rows affected", err)
+		return 0, utils.ErrDatabaseError
+	}
+	return moved, nil
+}
+
 // GetDiveSiteByDiveID gets the dive site ID for a specific dive
 func (r *DiveSiteRepository) GetDiveSiteByDiveID(ctx context.Context, diveID int) (*int, error) {
 	var diveSiteID *int
-	err := r.db.QueryRow(`SELECT dive_site_id FROM dives WHERE id = $1`, diveID).Scan(&diveSiteID)
+	err := r.db.QueryRow(`SELECT dive_site_id FROM dives WHERE id = $1 AND deleted_at IS NULL`, diveID).Scan(&diveSiteID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, utils.ErrDiveNotFound
@@ -165,8 +324,7 @@ func (r *DiveSiteRepository) GetDiveSiteByDiveID(ctx context.Context, diveID int
 
 func (r *DiveSiteRepository) FindDiveSitesByName(ctx context.Context, name string) ([]models.DiveSite, error) {
 	rows, err := r.db.Query(
-		`SELECT id, name, latitude, longitude, description, created_at, updated_at
-		 FROM dive_sites WHERE LOWER(name) = LOWER($1)`,
+		`SELECT `+diveSiteColumns+` FROM dive_sites WHERE LOWER(name) = LOWER($1) AND deleted_at IS NULL`,
 		name,
 	)
 	if err != nil {
@@ -191,17 +349,16 @@ func (r *DiveSiteRepository) FindDiveSitesByName(ctx context.Context, name strin
 	return sites, nil
 }
 
-func (r *DiveSiteRepository) CreateDiveSite(ctx context.Context, name string, latitude, longitude float64, description *string) (*models.DiveSite, error) {
-	insertQuery := `INSERT INTO dive_sites (name, latitude, longitude, description, created_at, updated_at)
-				   VALUES ($1, $2, $3, $4, NOW(), NOW())
-				   RETURNING id, name, latitude, longitude, description, created_at, updated_at`
+// CreateDiveSite inserts an already validated dive site.
+func (r *DiveSiteRepository) CreateDiveSite(ctx context.Context, request *models.DiveSiteRequest) (*models.DiveSite, error) {
+	insertQuery := `INSERT INTO dive_sites (name, latitude, longitude, description,
+				       max_depth, typical_depth, entry_type, environment, water_type,
+				       country_code, country, region, area, access_notes, hazards, created_at, updated_at)
+				   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW(), NOW())
+				   RETURNING ` + diveSiteColumns
 
 	var newSite models.DiveSite
-	err := r.db.QueryRow(insertQuery, name, latitude, longitude, description).Scan(
-		&newSite.ID, &newSite.Name, &newSite.Latitude,
-		&newSite.Longitude, &newSite.Description,
-		&newSite.CreatedAt, &newSite.UpdatedAt,
-	)
+	err := r.db.QueryRow(insertQuery, diveSiteWriteArgs(request)...).Scan(diveSiteScanTargets(&newSite)...)
 
 	if err != nil {
 		return nil, utils.ErrDatabaseError
@@ -213,12 +370,286 @@ func (r *DiveSiteRepository) CreateDiveSite(ctx context.Context, name string, la
 // scanDiveSite scans a dive site from database rows
 func (r *DiveSiteRepository) scanDiveSite(rows *sql.Rows) (*models.DiveSite, error) {
 	var site models.DiveSite
-	err := rows.Scan(
-		&site.ID, &site.Name, &site.Latitude, &site.Longitude,
-		&site.Description, &site.CreatedAt, &site.UpdatedAt,
-	)
-	if err != nil {
+	if err := rows.Scan(diveSiteScanTargets(&site)...); err != nil {
 		return nil, err
 	}
 	return &site, nil
 }
+
+const diveSiteColumns = `id, name, latitude, longitude, description,
+	max_depth, typical_depth, entry_type, environment, water_type,
+	country_code, country, region, area, access_notes, hazards, created_at, updated_at`
+
+// qualifiedDiveSiteColumns prefixes diveSiteColumns with a table alias.
+func qualifiedDiveSiteColumns(alias string) string {
+	columns := strings.Split(diveSiteColumns, ",")
+	for i, column := range columns {
+		columns[i] = alias + "." + strings.TrimSpace(column)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// diveSiteScanTargets returns destinations for a row selected with
+// diveSiteColumns.
+func diveSiteScanTargets(site *models.DiveSite) []interface{} {
+	return []interface{}{
+		&site.ID, &site.Name, &site.Latitude, &site.Longitude, &site.Description,
+		&site.MaxDepth, &site.TypicalDepth, &site.EntryType, &site.Environment, &site.WaterType,
+		&site.CountryCode, &site.Country, &site.Region, &site.Area, &site.AccessNotes, &site.Hazards,
+		&site.CreatedAt, &site.UpdatedAt,
+	}
+}
+
+// diveSiteWriteArgs returns the insert and update parameters $1-$15.
+func diveSiteWriteArgs(request *models.DiveSiteRequest) []interface{} {
+	return []interface{}{
+		request.Name, request.Latitude, request.Longitude, request.Description,
+		request.MaxDepth, request.TypicalDepth, request.EntryType, request.Environment, request.WaterType,
+		request.CountryCode, request.Country, request.Region, request.Area, request.AccessNotes, request.Hazards,
+	}
+}
+
+// diveSiteFilterCondition turns filter into a WHERE condition and its
+// parameters, numbered from $1.
+func diveSiteFilterCondition(filter models.DiveSiteFilter) (string, []interface{}) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	add := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+	for _, text := range []struct{ column, value string }{
+		{"country", filter.Country}, {"region", filter.Region}, {"area", filter.Area},
+	} {
+		if text.value != "" {
+			add("LOWER("+text.column+") = LOWER($%d)", text.value)
+		}
+	}
+	for _, enum := range []struct{ column, value string }{
+		{"entry_type", filter.EntryType}, {"environment", filter.Environment}, {"water_type", filter.WaterType},
+	} {
+		if enum.value != "" {
+			add(enum.column+" = $%d", enum.value)
+		}
+	}
+	if filter.CountryCode != "" {
+		add("country_code = UPPER($%d)", filter.CountryCode)
+	}
+	if filter.MinDepth != nil {
+		add("max_depth >= $%d", *filter.MinDepth)
+	}
+	if filter.MaxDepth != nil {
+		add("max_depth <= $%d", *filter.MaxDepth)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// boundingBoxCondition restricts dive_sites to box, splitting the longitude
+// range when the box crosses the antimeridian. Placeholders start at $first.
+func boundingBoxCondition(box models.BoundingBox, first int) (string, []interface{}) {
+	longitude := fmt.Sprintf("longitude BETWEEN $%d AND $%d", first+2, first+3)
+	if box.CrossesAntimeridian() {
+		longitude = fmt.Sprintf("(longitude >= $%d OR longitude <= $%d)", first+2, first+3)
+	}
+	condition := fmt.Sprintf("latitude BETWEEN $%d AND $%d AND %s", first, first+1, longitude)
+	return condition, []interface{}{box.South, box.North, box.West, box.East}
+}
+
+// FindNearby returns sites within radiusKM of a point, nearest first. box must
+// enclose the search circle; it lets the (latitude, longitude) index discard
+// distant rows before distances are computed.
+func (r *DiveSiteRepository) FindNearby(ctx context.Context, latitude, longitude, radiusKM float64, box models.BoundingBox, limit int) ([]models.NearbyDiveSite, error) {
+	condition, args := boundingBoxCondition(box, 5)
+	query := `
+		SELECT ` + diveSiteColumns + `, distance_km FROM (
+			SELECT ` + diveSiteColumns + `,
+			       6371 * 2 * asin(least(1, sqrt(
+			           power(sin(radians(latitude::float8 - $1::float8) / 2), 2) +
+			           cos(radians($1::float8)) * cos(radians(latitude::float8)) *
+			           power(sin(radians(longitude::float8 - $2::float8) / 2), 2)
+			       ))) AS distance_km
+			FROM dive_sites
+			WHERE deleted_at IS NULL AND ` + condition + `
+		) candidates
+		WHERE distance_km <= $3::float8
+		ORDER BY distance_km, id
+		LIMIT $4`
+	rows, err := r.db.QueryContext(ctx, query, append([]interface{}{latitude, longitude, radiusKM, limit}, args...)...)
+	if err != nil {
+		utils.LogError(ctx, "Error querying nearby dive sites", err)
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	sites := []models.NearbyDiveSite{}
+	for rows.Next() {
+		var site models.NearbyDiveSite
+		if err := rows.Scan(append(diveSiteScanTargets(&site.DiveSite), &site.DistanceKM)...); err != nil {
+			utils.LogError(ctx, "Error scanning nearby dive site", err)
+			return nil, utils.ErrDatabaseError
+		}
+		sites = append(sites, site)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return sites, nil
+}
+
+// FindInBoundingBox returns up to limit sites inside a map viewport.
+func (r *DiveSiteRepository) FindInBoundingBox(ctx context.Context, box models.BoundingBox, limit int) ([]models.DiveSite, error) {
+	condition, args := boundingBoxCondition(box, 2)
+	query := `SELECT ` + diveSiteColumns + ` FROM dive_sites WHERE deleted_at IS NULL AND ` + condition + ` ORDER BY id LIMIT $1`
+	rows, err := r.db.QueryContext(ctx, query, append([]interface{}{limit}, args...)...)
+	if err != nil {
+		utils.LogError(ctx, "Error querying dive sites in bounding box", err)
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	sites := []models.DiveSite{}
+	for rows.Next() {
+		site, err := r.scanDiveSite(rows)
+		if err != nil {
+			utils.LogError(ctx, "Error scanning dive site", err)
+			return nil, utils.ErrDatabaseError
+		}
+		sites = append(sites, *site)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return sites, nil
+}
+
+// ClusterInBoundingBox groups the sites inside box by geohash prefix of the
+// given length and returns one summary per occupied cell.
+func (r *DiveSiteRepository) ClusterInBoundingBox(ctx context.Context, box models.BoundingBox, precision int) ([]models.DiveSiteCluster, error) {
+	condition, args := boundingBoxCondition(box, 2)
+	query := `
+		SELECT left(geohash, $1) AS cell, COUNT(*),
+		       AVG(latitude)::float8, AVG(longitude)::float8,
+		       MIN(latitude)::float8, MIN(longitude)::float8, MAX(latitude)::float8, MAX(longitude)::float8,
+		       MIN(id), MIN(name)
+		FROM dive_sites
+		WHERE geohash IS NOT NULL AND deleted_at IS NULL AND ` + condition + `
+		GROUP BY cell
+		ORDER BY cell`
+	rows, err := r.db.QueryContext(ctx, query, append([]interface{}{precision}, args...)...)
+	if err != nil {
+		utils.LogError(ctx, "Error clustering dive sites", err)
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	clusters := []models.DiveSiteCluster{}
+	for rows.Next() {
+		var cluster models.DiveSiteCluster
+		var siteID int
+		var siteName string
+		if err := rows.Scan(
+			&cluster.Geohash, &cluster.Count, &cluster.Latitude, &cluster.Longitude,
+			&cluster.Bounds.South, &cluster.Bounds.West, &cluster.Bounds.North, &cluster.Bounds.East,
+			&siteID, &siteName,
+		); err != nil {
+			utils.LogError(ctx, "Error scanning dive site cluster", err)
+			return nil, utils.ErrDatabaseError
+		}
+		if cluster.Count == 1 {
+			cluster.Site = &models.DiveSite{ID: siteID, Name: siteName, Latitude: cluster.Latitude, Longitude: cluster.Longitude}
+		}
+		clusters = append(clusters, cluster)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return clusters, nil
+}
+
+// ExportWithStats returns every located dive site with the user's dive count,
+// deepest dive and most recent dive there. divedOnly drops sites the user has
+// never dived.
+func (r *DiveSiteRepository) ExportWithStats(ctx context.Context, userID int, divedOnly bool) ([]models.DiveSiteExportEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+qualifiedDiveSiteColumns("ds")+`,
+		       COUNT(d.id), MAX(d.max_depth)::float8, MAX(d.dive_datetime)
+		FROM dive_sites ds
+		LEFT JOIN dives d ON d.dive_site_id = ds.id AND d.user_id = $1 AND d.deleted_at IS NULL
+		WHERE ds.latitude IS NOT NULL AND ds.longitude IS NOT NULL AND ds.deleted_at IS NULL
+		GROUP BY ds.id
+		HAVING NOT $2::boolean OR COUNT(d.id) > 0
+		ORDER BY ds.name, ds.id`, userID, divedOnly)
+	if err != nil {
+		utils.LogError(ctx, "Error exporting dive sites", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	entries := []models.DiveSiteExportEntry{}
+	for rows.Next() {
+		var entry models.DiveSiteExportEntry
+		var maxDepth sql.NullFloat64
+		var lastDived models.LocalTime
+		targets := append(diveSiteScanTargets(&entry.DiveSite), &entry.DiveCount, &maxDepth, &lastDived)
+		if err := rows.Scan(targets...); err != nil {
+			utils.LogError(ctx, "Error scanning exported dive site", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		if maxDepth.Valid {
+			entry.DeepestDive = &maxDepth.Float64
+		}
+		if !lastDived.IsZero() {
+			entry.LastDived = &lastDived
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return entries, nil
+}
+
+// FindSitePairsWithin returns up to limit pairs of distinct sites no more than
+// maxDistanceKM apart, closest first. Each pair is returned once, with the
+// lower ID as Site.
+func (r *DiveSiteRepository) FindSitePairsWithin(ctx context.Context, maxDistanceKM float64, limit int) ([]models.DiveSitePair, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+qualifiedDiveSiteColumns("a")+`, `+qualifiedDiveSiteColumns("b")+`, pairs.distance_km
+		FROM (
+			SELECT a.id AS site_id, b.id AS other_id,
+			       6371 * 2 * asin(least(1, sqrt(
+			           power(sin(radians(b.latitude::float8 - a.latitude::float8) / 2), 2) +
+			           cos(radians(a.latitude::float8)) * cos(radians(b.latitude::float8)) *
+			           power(sin(radians(b.longitude::float8 - a.longitude::float8) / 2), 2)
+			       ))) AS distance_km
+			FROM dive_sites a
+			JOIN dive_sites b ON b.id > a.id AND b.deleted_at IS NULL
+			     AND b.latitude BETWEEN a.latitude - $1::float8 / 111.32 AND a.latitude + $1::float8 / 111.32
+			WHERE a.deleted_at IS NULL
+		) pairs
+		JOIN dive_sites a ON a.id = pairs.site_id
+		JOIN dive_sites b ON b.id = pairs.other_id
+		WHERE pairs.distance_km <= $1::float8
+		ORDER BY pairs.distance_km, a.id, b.id
+		LIMIT $2`, maxDistanceKM, limit)
+	if err != nil {
+		utils.LogError(ctx, "Error querying dive site pairs", err)
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	pairs := []models.DiveSitePair{}
+	for rows.Next() {
+		var pair models.DiveSitePair
+		targets := append(diveSiteScanTargets(&pair.Site), diveSiteScanTargets(&pair.Other)...)
+		if err := rows.Scan(append(targets, &pair.DistanceKM)...); err != nil {
+			utils.LogError(ctx, "Error scanning dive site pair", err)
+			return nil, utils.ErrDatabaseError
+		}
+		pairs = append(pairs, pair)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return pairs, nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"divelog-backend/models"
+	"divelog-backend/utils"
 	"errors"
 	"fmt"
 	"io"
@@ -44,7 +45,14 @@ func (c *diveSiteCreateTestConn) QueryContext(_ context.Context, query string, a
 	c.driver.queries = append(c.driver.queries, query)
 	c.driver.args = append(c.driver.args, args)
 	now := time.Date(2026, time.August, 8, 12, 0, 0, 0, time.UTC)
-	columns := []string{"id", "name", "latitude", "longitude", "description", "created_at", "updated_at"}
+	columns := []string{
+		"id", "name", "latitude", "longitude", "description",
+		"max_depth", "typical_depth", "entry_type", "environment", "water_type",
+		"country_code", "country", "region", "area", "access_notes", "hazards", "created_at", "updated_at",
+	}
+	if strings.Contains(query, "SELECT EXISTS") {
+		return &diveSiteCreateTestRows{columns: []string{"exists"}, values: [][]driver.Value{{c.driver.existing}}}, nil
+	}
 	if strings.Contains(query, "WHERE LOWER(name)") {
 		if !c.driver.existing {
 			return &diveSiteCreateTestRows{columns: columns}, nil
@@ -52,18 +60,32 @@ func (c *diveSiteCreateTestConn) QueryContext(_ context.Context, query string, a
 		description := "Existing description"
 		return &diveSiteCreateTestRows{
 			columns: columns,
-			values:  [][]driver.Value{{int64(12), "Test Site", 36.61, -121.89, description, now, now}},
+			values: [][]driver.Value{{
+				int64(12), "Test Site", 36.61, -121.89, description,
+				nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, now, now,
+			}},
 		}, nil
 	}
 	if strings.Contains(query, "INSERT INTO dive_sites") {
 		return &diveSiteCreateTestRows{
 			columns: columns,
-			values:  [][]driver.Value{{int64(13), args[0].Value, args[1].Value, args[2].Value, args[3].Value, now, now}},
+			values: [][]driver.Value{append(
+				[]driver.Value{int64(13)},
+				append(namedValues(args), now, now)...,
+			)},
 		}, nil
 	}
 	return nil, fmt.Errorf("unexpected query: %s", query)
 }
 
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
 type diveSiteCreateTestRows struct {
 	columns []string
 	values  [][]driver.Value
@@ -102,7 +124,7 @@ func TestDiveSiteRepository_CreateDiveSite(t *testing.T) {
 	ctx := context.Background()
 
 	// Test creating a new dive site
-	site, err := repo.CreateDiveSite(ctx, "Test Site", 40.7128, -74.0060, nil)
+	site, err := repo.CreateDiveSite(ctx, &models.DiveSiteRequest{Name: "Test Site", Latitude: 40.7128, Longitude: -74.0060})
 	assert.NoError(t, err)
 	assert.NotNil(t, site)
 	assert.Equal(t, "Test Site", site.Name)
@@ -121,7 +143,7 @@ func TestDiveSiteRepository_GetAll(t *testing.T) {
 	repo := NewDiveSiteRepository(db)
 	ctx := context.Background()
 
-	sites, err := repo.GetAll(ctx)
+	sites, err := repo.GetAll(ctx, models.DiveSiteFilter{})
 	assert.NoError(t, err)
 	assert.NotNil(t, sites)
 	// Should return empty slice, not nil
@@ -177,7 +199,7 @@ func TestDiveSiteRepository_CreateWithDescription(t *testing.T) {
 		Description: &description,
 	}
 
-	site, err := repo.CreateDiveSite(ctx, siteReq.Name, siteReq.Latitude, siteReq.Longitude, siteReq.Description)
+	site, err := repo.CreateDiveSite(ctx, siteReq)
 	assert.NoError(t, err)
 	assert.NotNil(t, site)
 	assert.Equal(t, siteReq.Name, site.Name)
@@ -194,9 +216,7 @@ func TestDiveSiteRepositoryCreatePreservesDescription(t *testing.T) {
 		Longitude:   -121.89,
 		Description: &description,
 	}
-	site, err := NewDiveSiteRepository(db).CreateDiveSite(
-		context.Background(), request.Name, request.Latitude, request.Longitude, request.Description,
-	)
+	site, err := NewDiveSiteRepository(db).CreateDiveSite(context.Background(), request)
 
 	assert.NoError(t, err)
 	assert.Equal(t, description, *site.Description)
@@ -247,13 +267,38 @@ func TestDiveSiteRepository_Delete(t *testing.T) {
 	defer db.Close()
 
 	repo := NewDiveSiteRepository(db)
-	ctx := context.Background()
+	ctx := utils.WithActor(context.Background(), utils.Actor{UserID: 1})
 
 	// Test deleting non-existent site
 	err := repo.DeleteDiveSite(ctx, 999999)
 	assert.Error(t, err)
 }
 
+func TestDiveSiteRepositoryDeleteRequiresActor(t *testing.T) {
+	// Refused before the database is touched: the site would be in nobody's
+	// trash and could only be purged.
+	repo := NewDiveSiteRepository(nil)
+
+	err := repo.DeleteDiveSite(context.Background(), 4)
+
+	assert.ErrorIs(t, err, utils.ErrMissingUserID)
+}
+
+func TestDiveSiteRepositoryReassignRefusesDuplicateDives(t *testing.T) {
+	testDriver := &diveSiteCreateTestDriver{existing: true}
+	repo := NewDiveSiteRepository(openDiveSiteCreateTestDB(t, testDriver))
+
+	moved, err := repo.ReassignDives(context.Background(), []int{4, 9}, 3)
+
+	assert.ErrorIs(t, err, utils.ErrDuplicateDive)
+	assert.Zero(t, moved)
+	// Only the duplicate check ran; no dive was moved.
+	if assert.Len(t, testDriver.queries, 1) {
+		assert.Contains(t, testDriver.queries[0], "dive_site_id = ANY($1)")
+		assert.Equal(t, "{3,4,9}", testDriver.args[0][0].Value)
+	}
+}
+
 func TestDiveSiteRepository_GetDiveSiteByDiveID(t *testing.T) {
 	db := setupTestDB(t)
 	if db == nil {
@@ -269,3 +314,43 @@ func TestDiveSiteRepository_GetDiveSiteByDiveID(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, siteID)
 }
+
+func TestBoundingBoxConditionSplitsAntimeridian(t *testing.T) {
+	condition, args := boundingBoxCondition(models.BoundingBox{South: -20, West: 170, North: -10, East: -170}, 2)
+
+	assert.Equal(t, "latitude BETWEEN $2 AND $3 AND (longitude >= $4 OR longitude <= $5)", condition)
+	assert.Equal(t, []interface{}{-20.0, -10.0, 170.0, -170.0}, args)
+
+	condition, _ = boundingBoxCondition(models.BoundingBox{South: 30, West: -125, North: 40, East: -115}, 1)
+	assert.Equal(t, "latitude BETWEEN $1 AND $2 AND longitude BETWEEN $3 AND $4", condition)
+}
+
+func TestDiveSiteRepositoryCreatePersistsAttributes(t *testing.T) {
+	testDriver := &diveSiteCreateTestDriver{}
+	db := openDiveSiteCreateTestDB(t, testDriver)
+	maxDepth, environment, country := 30.0, "reef", "Egypt"
+	request := &models.DiveSiteRequest{
+		Name: "Lighthouse", Latitude: 28.5, Longitude: 34.5,
+		DiveSiteAttributes: models.DiveSiteAttributes{MaxDepth: &maxDepth, Environment: &environment, Country: &country},
+	}
+
+	site, err := NewDiveSiteRepository(db).CreateDiveSite(context.Background(), request)
+
+	assert.NoError(t, err)
+	assert.Equal(t, request.DiveSiteAttributes, site.DiveSiteAttributes)
+	assert.Len(t, testDriver.args[0], 15)
+}
+
+func TestDiveSiteFilterConditionNumbersParameters(t *testing.T) {
+	minDepth := 10.0
+	condition, args := diveSiteFilterCondition(models.DiveSiteFilter{
+		Country: "egypt", Region: "South Sinai", Environment: "wreck", MinDepth: &minDepth,
+	})
+
+	assert.Equal(t, "TRUE AND LOWER(country) = LOWER($1) AND LOWER(region) = LOWER($2) AND environment = $3 AND max_depth >= $4", condition)
+	assert.Equal(t, []interface{}{"egypt", "South Sinai", "wreck", 10.0}, args)
+
+	condition, args = diveSiteFilterCondition(models.DiveSiteFilter{})
+	assert.Equal(t, "TRUE", condition)
+	assert.Empty(t, args)
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"time"
+)
+
+// idempotencyLease is how long a request may hold an unfinished key. After
+// that, the request is assumed to have died with its server and a retry may
+// claim the key again.
+const idempotencyLease = 5 * time.Minute
+
+// IdempotencyRepository stores the responses replayed for retried requests
+// that carry an Idempotency-Key.
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Claim reserves key for userID's request until ttl has passed. It returns nil
+// when the caller now holds the key, or the record left by an earlier request
+// with the same key. It also drops userID's expired and abandoned keys.
+func (r *IdempotencyRepository) Claim(ctx context.Context, userID int, key, requestHash string, ttl time.Duration) (*models.IdempotencyRecord, error) {
+	if _, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1
+		  AND (expires_at <= NOW() OR (status_code IS NULL AND created_at <= NOW() - $2 * INTERVAL '1 second'))`,
+		userID, idempotencyLease.Seconds()); err != nil {
+		utils.LogError(ctx, "Error purging idempotency keys", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, expires_at)
+		VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 second')
+		ON CONFLICT (user_id, idempotency_key) DO NOTHING`, userID, key, requestHash, ttl.Seconds())
+	if err != nil {
+		utils.LogError(ctx, "Error claiming idempotency key", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	if claimed, err := result.RowsAffected(); err == nil && claimed == 1 {
+		return nil, nil
+	}
+
+	record := &models.IdempotencyRecord{}
+	var statusCode sql.NullInt64
+	var contentType sql.NullString
+	var body []byte
+	err = r.db.QueryRowContext(ctx, `
+		SELECT request_hash, status_code, content_type, response_body
+		FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2`, userID, key).
+		Scan(&record.RequestHash, &statusCode, &contentType, &body)
+	if err != nil {
+		utils.LogError(ctx, "Error reading idempotency key", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	if statusCode.Valid {
+		record.Response = &models.IdempotentResponse{StatusCode: int(statusCode.Int64), ContentType: contentType.String, Body: body}
+	}
+	return record, nil
+}
+
+// Complete stores the response to the request holding key.
+func (r *IdempotencyRepository) Complete(ctx context.Context, userID int, key string, response models.IdempotentResponse) error {
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE idempotency_keys SET status_code = $3, content_type = $4, response_body = $5
+		WHERE user_id = $1 AND idempotency_key = $2`,
+		userID, key, response.StatusCode, response.ContentType, response.Body); err != nil {
+		utils.LogError(ctx, "Error storing idempotent response", err, utils.UserID(userID))
+		return utils.ErrDatabaseError
+	}
+	return nil
+}
+
+// Release gives up an unfinished claim so the request can be retried.
+func (r *IdempotencyRepository) Release(ctx context.Context, userID int, key string) error {
+	if _, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND status_code IS NULL`,
+		userID, key); err != nil {
+		utils.LogError(ctx, "Error releasing idempotency key", err, utils.UserID(userID))
+		return utils.ErrDatabaseError
+	}
+	return nil
+}
@@ -43,6 +43,7 @@ func hasTimestampConflict(ctx context.Context, tx *sql.Tx, userID int, diveIDs [
 			 AND existing.dive_site_id IS NOT DISTINCT FROM selected.dive_site_id
 			 AND existing.dive_datetime = selected.dive_datetime + ($3 * INTERVAL '1 minute')
 			WHERE selected.user_id = $1 AND selected.id = ANY($2) AND NOT (existing.id = ANY($2))
+			  AND existing.deleted_at IS NULL
 		)`, userID, pq.Array(diveIDs), offsetMinutes).Scan(&conflict)
 	if err != nil {
 		return false, utils.ErrDatabaseError
@@ -51,7 +52,7 @@ func hasTimestampConflict(ctx context.Context, tx *sql.Tx, userID int, diveIDs [
 }
 
 func (r *LogbookRepository) ShiftDiveTimes(ctx context.Context, userID int, request models.ShiftDiveTimesRequest) (*models.BulkOperation, error) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	tx, err := beginAudited(ctx, r.db, serializable)
 	if err != nil {
 		return nil, utils.ErrDatabaseError
 	}
@@ -97,11 +98,21 @@ func (r *LogbookRepository) ShiftDiveTimes(ctx context.Context, userID int, requ
 		VALUES ($1, $2, $3, $4, $5, $6)`, operation.ID, userID, operation.OperationType, beforeState, operation.AffectedCount, operation.CreatedAt); err != nil {
 		return nil, utils.ErrDatabaseError
 	}
+	if err := recordDiveRevisions(ctx, tx, userID, request.DiveIDs, models.DiveRevisionSourceTimeShift); err != nil {
+		return nil, err
+	}
 	if _, err := tx.ExecContext(ctx, `
 		UPDATE dives SET dive_datetime = dive_datetime + ($1 * INTERVAL '1 minute'), updated_at = NOW()
 		WHERE user_id = $2 AND id = ANY($3)`, request.OffsetMinutes, userID, pq.Array(request.DiveIDs)); err != nil {
 		return nil, utils.ErrDatabaseError
 	}
+	if operation.AffectedCount > 0 {
+		if err := enqueueWebhookEvent(ctx, tx, userID, models.WebhookEventDivesUpdated, models.DiveChangeSummary{
+			Operation: operation.OperationType, DiveIDs: request.DiveIDs, AffectedCount: int64(operation.AffectedCount),
+		}); err != nil {
+			return nil, err
+		}
+	}
 	if err := tx.Commit(); err != nil {
 		return nil, utils.ErrDatabaseError
 	}
@@ -126,7 +137,7 @@ func (r *LogbookRepository) LatestUndoableOperation(ctx context.Context, userID
 }
 
 func (r *LogbookRepository) UndoBulkOperation(ctx context.Context, userID int, operationID string) (*models.BulkOperation, error) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	tx, err := beginAudited(ctx, r.db, serializable)
 	if err != nil {
 		return nil, utils.ErrDatabaseError
 	}
@@ -161,6 +172,9 @@ func (r *LogbookRepository) UndoBulkOperation(ctx context.Context, userID int, o
 	if err := ensureOwnedDives(ctx, tx, userID, ids); err != nil {
 		return nil, err
 	}
+	if err := recordDiveRevisions(ctx, tx, userID, ids, models.DiveRevisionSourceUndo); err != nil {
+		return nil, err
+	}
 	for _, state := range states {
 		var conflict bool
 		if err := tx.QueryRowContext(ctx, `
@@ -168,7 +182,7 @@ func (r *LogbookRepository) UndoBulkOperation(ctx context.Context, userID int, o
 			  ON existing.user_id = target.user_id
 			 AND existing.dive_site_id IS NOT DISTINCT FROM target.dive_site_id
 			 AND existing.dive_datetime = $1
-			 WHERE target.id = $2 AND target.user_id = $3 AND NOT (existing.id = ANY($4)))`,
+			 WHERE target.id = $2 AND target.user_id = $3 AND NOT (existing.id = ANY($4)) AND existing.deleted_at IS NULL)`,
 			state.DateTime, state.ID, userID, pq.Array(ids)).Scan(&conflict); err != nil {
 			return nil, utils.ErrDatabaseError
 		}
@@ -184,6 +198,13 @@ func (r *LogbookRepository) UndoBulkOperation(ctx context.Context, userID int, o
 		return nil, utils.ErrDatabaseError
 	}
 	operation.UndoneAt = &now
+	if operation.AffectedCount > 0 {
+		if err := enqueueWebhookEvent(ctx, tx, userID, models.WebhookEventDivesUpdated, models.DiveChangeSummary{
+			Operation: "undo_" + operation.OperationType, AffectedCount: int64(operation.AffectedCount),
+		}); err != nil {
+			return nil, err
+		}
+	}
 	if err := tx.Commit(); err != nil {
 		return nil, utils.ErrDatabaseError
 	}
@@ -193,7 +214,7 @@ func (r *LogbookRepository) UndoBulkOperation(ctx context.Context, userID int, o
 func ensureOwnedDives(ctx context.Context, tx *sql.Tx, userID int, diveIDs []int) error {
 	var count int
 	if err := tx.QueryRowContext(ctx,
-		`SELECT COUNT(*) FROM dives WHERE user_id = $1 AND id = ANY($2)`,
+		`SELECT COUNT(*) FROM dives WHERE user_id = $1 AND id = ANY($2) AND deleted_at IS NULL`,
 		userID, pq.Array(diveIDs),
 	).Scan(&count); err != nil {
 		return utils.ErrDatabaseError
@@ -205,9 +226,11 @@ func ensureOwnedDives(ctx context.Context, tx *sql.Tx, userID int, diveIDs []int
 }
 
 // BulkUpdateDives applies one partial update to every selected dive in a
-// serializable transaction. Ownership is checked before any mutation.
+// serializable transaction. Ownership is checked before any mutation, each
+// dive's previous version is kept as a revision, and a dives.updated webhook
+// event is queued with the change.
 func (r *LogbookRepository) BulkUpdateDives(ctx context.Context, userID int, request models.BulkDiveUpdateRequest) (int64, error) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	tx, err := beginAudited(ctx, r.db, serializable)
 	if err != nil {
 		return 0, utils.ErrDatabaseError
 	}
@@ -215,10 +238,13 @@ func (r *LogbookRepository) BulkUpdateDives(ctx context.Context, userID int, req
 	if err := ensureOwnedDives(ctx, tx, userID, request.DiveIDs); err != nil {
 		return 0, err
 	}
+	if err := recordDiveRevisions(ctx, tx, userID, request.DiveIDs, models.DiveRevisionSourceBulkUpdate); err != nil {
+		return 0, err
+	}
 	if request.TripID != nil {
 		var exists bool
 		if err := tx.QueryRowContext(ctx,
-			`SELECT EXISTS(SELECT 1 FROM trips WHERE id = $1 AND user_id = $2)`,
+			`SELECT EXISTS(SELECT 1 FROM trips WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`,
 			*request.TripID, userID,
 		).Scan(&exists); err != nil {
 			return 0, utils.ErrDatabaseError
@@ -244,10 +270,15 @@ func (r *LogbookRepository) BulkUpdateDives(ctx context.Context, userID int, req
 	} else if request.ClearBuddy {
 		sets = append(sets, "buddy = NULL")
 	}
+	// Only training dives count towards a course, so changing the type away
+	// from training also drops the certification link.
 	if request.DiveType != nil {
 		addSet("dive_type", *request.DiveType)
+		if *request.DiveType != "training" {
+			sets = append(sets, "certification_id = NULL")
+		}
 	} else if request.ClearDiveType {
-		sets = append(sets, "dive_type = NULL")
+		sets = append(sets, "dive_type = NULL", "certification_id = NULL")
 	}
 	if request.Rating != nil {
 		addSet("rating", *request.Rating)
@@ -270,13 +301,13 @@ func (r *LogbookRepository) BulkUpdateDives(ctx context.Context, userID int, req
 		var tagID int
 		if err := tx.QueryRowContext(ctx, `
 			INSERT INTO tags (user_id, name) VALUES ($1, $2)
-			ON CONFLICT (user_id, lower(name)) DO UPDATE SET name = tags.name
+			ON CONFLICT (user_id, lower(name)) WHERE deleted_at IS NULL DO UPDATE SET name = tags.name
 			RETURNING id`, userID, name).Scan(&tagID); err != nil {
 			return 0, utils.ErrDatabaseError
 		}
 		if _, err := tx.ExecContext(ctx, `
 			INSERT INTO dive_tags (dive_id, tag_id)
-			SELECT id, $1 FROM dives WHERE user_id = $2 AND id = ANY($3)
+			SELECT id, $1 FROM dives WHERE user_id = $2 AND id = ANY($3) AND deleted_at IS NULL
 			ON CONFLICT DO NOTHING`, tagID, userID, pq.Array(request.DiveIDs)); err != nil {
 			return 0, utils.ErrDatabaseError
 		}
@@ -290,19 +321,26 @@ func (r *LogbookRepository) BulkUpdateDives(ctx context.Context, userID int, req
 			DELETE FROM dive_tags dt USING tags t, dives d
 			WHERE dt.tag_id = t.id AND dt.dive_id = d.id
 			  AND t.user_id = $1 AND d.user_id = $1 AND d.id = ANY($2)
-			  AND lower(t.name) = ANY($3)`,
+			  AND lower(t.name) = ANY($3) AND t.deleted_at IS NULL AND d.deleted_at IS NULL`,
 			userID, pq.Array(request.DiveIDs), pq.Array(trimmed)); err != nil {
 			return 0, utils.ErrDatabaseError
 		}
 	}
+	if err := enqueueWebhookEvent(ctx, tx, userID, models.WebhookEventDivesUpdated, models.DiveChangeSummary{
+		Operation: "bulk_update", DiveIDs: request.DiveIDs, AffectedCount: int64(len(request.DiveIDs)),
+	}); err != nil {
+		return 0, err
+	}
 	if err := tx.Commit(); err != nil {
 		return 0, utils.ErrDatabaseError
 	}
 	return int64(len(request.DiveIDs)), nil
 }
 
+// BulkDeleteDives moves the selected dives to the trash and queues a
+// dives.deleted webhook event with the change.
 func (r *LogbookRepository) BulkDeleteDives(ctx context.Context, userID int, diveIDs []int) (int64, error) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	tx, err := beginAudited(ctx, r.db, serializable)
 	if err != nil {
 		return 0, utils.ErrDatabaseError
 	}
@@ -310,7 +348,9 @@ func (r *LogbookRepository) BulkDeleteDives(ctx context.Context, userID int, div
 	if err := ensureOwnedDives(ctx, tx, userID, diveIDs); err != nil {
 		return 0, err
 	}
-	result, err := tx.ExecContext(ctx, `DELETE FROM dives WHERE user_id = $1 AND id = ANY($2)`, userID, pq.Array(diveIDs))
+	result, err := tx.ExecContext(ctx, `
+		UPDATE dives SET deleted_at = NOW(), updated_at = NOW()
+		WHERE user_id = $1 AND id = ANY($2) AND deleted_at IS NULL`, userID, pq.Array(diveIDs))
 	if err != nil {
 		return 0, utils.ErrDatabaseError
 	}
@@ -318,6 +358,13 @@ func (r *LogbookRepository) BulkDeleteDives(ctx context.Context, userID int, div
 	if err != nil {
 		return 0, utils.ErrDatabaseError
 	}
+	if deleted > 0 {
+		if err := enqueueWebhookEvent(ctx, tx, userID, models.WebhookEventDivesDeleted, models.DiveChangeSummary{
+			Operation: "bulk_delete", DiveIDs: diveIDs, AffectedCount: deleted,
+		}); err != nil {
+			return 0, err
+		}
+	}
 	if err := tx.Commit(); err != nil {
 		return 0, utils.ErrDatabaseError
 	}
@@ -328,11 +375,15 @@ func NewLogbookRepository(db *sql.DB) *LogbookRepository {
 	return &LogbookRepository{db: db}
 }
 
+// liveDiveTags selects the dive_tags links of dives that are not in the trash,
+// for counting a tag's dives.
+const liveDiveTags = `SELECT lt.dive_id, lt.tag_id FROM dive_tags lt JOIN dives ld ON ld.id = lt.dive_id WHERE ld.deleted_at IS NULL`
+
 func (r *LogbookRepository) GetTags(ctx context.Context, userID int) ([]models.TagSummary, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT t.id, t.name, COUNT(dt.dive_id)::int
-		FROM tags t LEFT JOIN dive_tags dt ON dt.tag_id = t.id
-		WHERE t.user_id = $1 GROUP BY t.id, t.name ORDER BY lower(t.name)`, userID)
+		FROM tags t LEFT JOIN (`+liveDiveTags+`) dt ON dt.tag_id = t.id
+		WHERE t.user_id = $1 AND t.deleted_at IS NULL GROUP BY t.id, t.name ORDER BY lower(t.name)`, userID)
 	if err != nil {
 		return nil, utils.ErrDatabaseError
 	}
@@ -350,52 +401,69 @@ func (r *LogbookRepository) GetTags(ctx context.Context, userID int) ([]models.T
 
 func (r *LogbookRepository) CreateTag(ctx context.Context, userID int, name string) (*models.TagSummary, error) {
 	tag := &models.TagSummary{Name: strings.TrimSpace(name)}
-	err := r.db.QueryRowContext(ctx, `INSERT INTO tags (user_id, name) VALUES ($1, $2) RETURNING id`, userID, tag.Name).Scan(&tag.ID)
-	if isUniqueViolation(err) {
-		return nil, utils.ErrOrganizationConflict
-	}
+	err := auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		err := db.QueryRowContext(ctx, `INSERT INTO tags (user_id, name) VALUES ($1, $2) RETURNING id`, userID, tag.Name).Scan(&tag.ID)
+		if isUniqueViolation(err) {
+			return utils.ErrOrganizationConflict
+		}
+		if err != nil {
+			return utils.ErrDatabaseError
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, utils.ErrDatabaseError
+		return nil, err
 	}
 	return tag, nil
 }
 
 func (r *LogbookRepository) UpdateTag(ctx context.Context, userID, tagID int, name string) (*models.TagSummary, error) {
 	tag := &models.TagSummary{ID: tagID, Name: strings.TrimSpace(name)}
-	err := r.db.QueryRowContext(ctx, `
-		UPDATE tags SET name = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3
-		RETURNING (SELECT COUNT(*)::int FROM dive_tags WHERE tag_id = tags.id)`,
-		tag.Name, tagID, userID).Scan(&tag.DiveCount)
-	if err == sql.ErrNoRows {
-		return nil, utils.ErrTagNotFound
-	}
-	if isUniqueViolation(err) {
-		return nil, utils.ErrOrganizationConflict
-	}
+	err := auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		err := db.QueryRowContext(ctx, `
+			UPDATE tags SET name = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+			RETURNING (SELECT COUNT(*)::int FROM (`+liveDiveTags+`) dt WHERE dt.tag_id = tags.id)`,
+			tag.Name, tagID, userID).Scan(&tag.DiveCount)
+		if err == sql.ErrNoRows {
+			return utils.ErrTagNotFound
+		}
+		if isUniqueViolation(err) {
+			return utils.ErrOrganizationConflict
+		}
+		if err != nil {
+			return utils.ErrDatabaseError
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, utils.ErrDatabaseError
+		return nil, err
 	}
 	return tag, nil
 }
 
+// DeleteTag moves a tag to the trash. Its dives keep their link to it, which
+// reads as untagged until the tag is restored.
 func (r *LogbookRepository) DeleteTag(ctx context.Context, userID, tagID int) error {
-	result, err := r.db.ExecContext(ctx, `DELETE FROM tags WHERE id = $1 AND user_id = $2`, tagID, userID)
-	if err != nil {
-		return utils.ErrDatabaseError
-	}
-	count, _ := result.RowsAffected()
-	if count == 0 {
-		return utils.ErrTagNotFound
-	}
-	return nil
+	return auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		result, err := db.ExecContext(ctx, `
+			UPDATE tags SET deleted_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`, tagID, userID)
+		if err != nil {
+			return utils.ErrDatabaseError
+		}
+		count, _ := result.RowsAffected()
+		if count == 0 {
+			return utils.ErrTagNotFound
+		}
+		return nil
+	})
 }
 
 func (r *LogbookRepository) GetTrips(ctx context.Context, userID int) ([]models.Trip, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT tr.id, tr.user_id, tr.name, tr.location, tr.start_date::text, tr.end_date::text, tr.notes,
-		       COUNT(d.id)::int
-		FROM trips tr LEFT JOIN dives d ON d.trip_id = tr.id
-		WHERE tr.user_id = $1 GROUP BY tr.id ORDER BY tr.start_date DESC NULLS LAST, lower(tr.name)`, userID)
+		SELECT `+tripColumns+`
+		FROM trips tr LEFT JOIN dives d ON d.trip_id = tr.id AND d.deleted_at IS NULL
+		WHERE tr.user_id = $1 AND tr.deleted_at IS NULL GROUP BY tr.id ORDER BY tr.start_date DESC NULLS LAST, lower(tr.name)`, userID)
 	if err != nil {
 		return nil, utils.ErrDatabaseError
 	}
@@ -415,10 +483,15 @@ type rowScanner interface {
 	Scan(...interface{}) error
 }
 
+// tripColumns lists the columns scanTrip reads, for queries over trips tr
+// grouped with their live dives d.
+const tripColumns = `tr.id, tr.user_id, tr.name, tr.location, tr.start_date::text, tr.end_date::text, tr.notes,
+		       COUNT(d.id)::int, tr.version`
+
 func scanTrip(row rowScanner) (*models.Trip, error) {
 	var trip models.Trip
 	var location, start, end, notes sql.NullString
-	if err := row.Scan(&trip.ID, &trip.UserID, &trip.Name, &location, &start, &end, &notes, &trip.DiveCount); err != nil {
+	if err := row.Scan(&trip.ID, &trip.UserID, &trip.Name, &location, &start, &end, &notes, &trip.DiveCount, &trip.Version); err != nil {
 		return nil, err
 	}
 	trip.Location = nullStringPointer(location)
@@ -431,65 +504,110 @@ func scanTrip(row rowScanner) (*models.Trip, error) {
 func (r *LogbookRepository) CreateTrip(ctx context.Context, userID int, request models.TripRequest) (*models.Trip, error) {
 	trip := &models.Trip{UserID: userID, Name: strings.TrimSpace(request.Name), Location: request.Location, StartDate: request.StartDate, EndDate: request.EndDate, Notes: request.Notes}
 	var location, start, end, notes sql.NullString
-	err := r.db.QueryRowContext(ctx, `
-		INSERT INTO trips (user_id, name, location, start_date, end_date, notes)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, user_id, name, location, start_date::text, end_date::text, notes, 0`,
-		userID, trip.Name, optionalText(request.Location), optionalText(request.StartDate), optionalText(request.EndDate), optionalText(request.Notes),
-	).Scan(&trip.ID, &trip.UserID, &trip.Name, &location, &start, &end, &notes, &trip.DiveCount)
-	if isUniqueViolation(err) {
-		return nil, utils.ErrOrganizationConflict
-	}
+	err := auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		err := db.QueryRowContext(ctx, `
+			INSERT INTO trips (user_id, name, location, start_date, end_date, notes)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, user_id, name, location, start_date::text, end_date::text, notes, 0, version`,
+			userID, trip.Name, optionalText(request.Location), optionalText(request.StartDate), optionalText(request.EndDate), optionalText(request.Notes),
+		).Scan(&trip.ID, &trip.UserID, &trip.Name, &location, &start, &end, &notes, &trip.DiveCount, &trip.Version)
+		if isUniqueViolation(err) {
+			return utils.ErrOrganizationConflict
+		}
+		if err != nil {
+			return utils.ErrDatabaseError
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, utils.ErrDatabaseError
+		return nil, err
 	}
 	trip.Location, trip.StartDate, trip.EndDate, trip.Notes = nullStringPointer(location), nullStringPointer(start), nullStringPointer(end), nullStringPointer(notes)
 	return trip, nil
 }
 
-func (r *LogbookRepository) UpdateTrip(ctx context.Context, userID, tripID int, request models.TripRequest) (*models.Trip, error) {
-	trip := &models.Trip{}
-	var location, start, end, notes sql.NullString
-	err := r.db.QueryRowContext(ctx, `
-		UPDATE trips SET name = $1, location = $2, start_date = $3, end_date = $4, notes = $5, updated_at = NOW()
-		WHERE id = $6 AND user_id = $7
-		RETURNING id, user_id, name, location, start_date::text, end_date::text, notes,
-		          (SELECT COUNT(*)::int FROM dives WHERE trip_id = trips.id)`,
-		strings.TrimSpace(request.Name), optionalText(request.Location), optionalText(request.StartDate), optionalText(request.EndDate), optionalText(request.Notes), tripID, userID,
-	).Scan(&trip.ID, &trip.UserID, &trip.Name, &location, &start, &end, &notes, &trip.DiveCount)
+// GetTrip retrieves one of a user's trips.
+func (r *LogbookRepository) GetTrip(ctx context.Context, userID, tripID int) (*models.Trip, error) {
+	trip, err := scanTrip(r.db.QueryRowContext(ctx, `
+		SELECT `+tripColumns+`
+		FROM trips tr LEFT JOIN dives d ON d.trip_id = tr.id AND d.deleted_at IS NULL
+		WHERE tr.user_id = $1 AND tr.id = $2 AND tr.deleted_at IS NULL GROUP BY tr.id`, userID, tripID))
 	if err == sql.ErrNoRows {
 		return nil, utils.ErrTripNotFound
 	}
-	if isUniqueViolation(err) {
-		return nil, utils.ErrOrganizationConflict
-	}
 	if err != nil {
 		return nil, utils.ErrDatabaseError
 	}
-	trip.Location, trip.StartDate, trip.EndDate, trip.Notes = nullStringPointer(location), nullStringPointer(start), nullStringPointer(end), nullStringPointer(notes)
 	return trip, nil
 }
 
-func (r *LogbookRepository) DeleteTrip(ctx context.Context, userID, tripID int) error {
-	result, err := r.db.ExecContext(ctx, `DELETE FROM trips WHERE id = $1 AND user_id = $2`, tripID, userID)
+// UpdateTrip overwrites a trip. With an expected version it only applies while
+// the trip is still at that version and returns ErrVersionConflict otherwise.
+func (r *LogbookRepository) UpdateTrip(ctx context.Context, userID, tripID int, request models.TripRequest, expectedVersion *int) (*models.Trip, error) {
+	trip := &models.Trip{}
+	var location, start, end, notes sql.NullString
+	err := auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		err := db.QueryRowContext(ctx, `
+			UPDATE trips SET name = $1, location = $2, start_date = $3, end_date = $4, notes = $5, updated_at = NOW()
+			WHERE id = $6 AND user_id = $7 AND deleted_at IS NULL AND ($8::int IS NULL OR version = $8)
+			RETURNING id, user_id, name, location, start_date::text, end_date::text, notes,
+			          (SELECT COUNT(*)::int FROM dives WHERE trip_id = trips.id AND deleted_at IS NULL), version`,
+			strings.TrimSpace(request.Name), optionalText(request.Location), optionalText(request.StartDate), optionalText(request.EndDate), optionalText(request.Notes), tripID, userID, expectedVersion,
+		).Scan(&trip.ID, &trip.UserID, &trip.Name, &location, &start, &end, &notes, &trip.DiveCount, &trip.Version)
+		if err == sql.ErrNoRows {
+			if expectedVersion == nil {
+				return utils.ErrTripNotFound
+			}
+			var exists bool
+			if err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM trips WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`, tripID, userID).Scan(&exists); err != nil {
+				return utils.ErrDatabaseError
+			}
+			if exists {
+				return utils.ErrVersionConflict
+			}
+			return utils.ErrTripNotFound
+		}
+		if isUniqueViolation(err) {
+			return utils.ErrOrganizationConflict
+		}
+		if err != nil {
+			return utils.ErrDatabaseError
+		}
+		return nil
+	})
 	if err != nil {
-		return utils.ErrDatabaseError
-	}
-	count, _ := result.RowsAffected()
-	if count == 0 {
-		return utils.ErrTripNotFound
+		return nil, err
 	}
-	return nil
+	trip.Location, trip.StartDate, trip.EndDate, trip.Notes = nullStringPointer(location), nullStringPointer(start), nullStringPointer(end), nullStringPointer(notes)
+	return trip, nil
+}
+
+// DeleteTrip moves a trip to the trash. Its dives keep their link to it,
+// which reads as no trip until the trip is restored.
+func (r *LogbookRepository) DeleteTrip(ctx context.Context, userID, tripID int) error {
+	return auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		result, err := db.ExecContext(ctx, `
+			UPDATE trips SET deleted_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`, tripID, userID)
+		if err != nil {
+			return utils.ErrDatabaseError
+		}
+		count, _ := result.RowsAffected()
+		if count == 0 {
+			return utils.ErrTripNotFound
+		}
+		return nil
+	})
 }
 
 func (r *LogbookRepository) MergeTrips(ctx context.Context, userID, targetID int, sourceIDs []int) error {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	tx, err := beginAudited(ctx, r.db, serializable)
 	if err != nil {
 		return utils.ErrDatabaseError
 	}
 	defer tx.Rollback()
 	var targetExists bool
-	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM trips WHERE id = $1 AND user_id = $2)`, targetID, userID).Scan(&targetExists); err != nil || !targetExists {
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM trips WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`, targetID, userID).Scan(&targetExists); err != nil || !targetExists {
 		return utils.ErrTripNotFound
 	}
 	filtered := []int{}
@@ -502,12 +620,13 @@ func (r *LogbookRepository) MergeTrips(ctx context.Context, userID, targetID int
 		return utils.ErrInvalidInput
 	}
 	var sourceCount int
-	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM trips WHERE user_id = $1 AND id = ANY($2)`, userID, pq.Array(filtered)).Scan(&sourceCount); err != nil {
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM trips WHERE user_id = $1 AND id = ANY($2) AND deleted_at IS NULL`, userID, pq.Array(filtered)).Scan(&sourceCount); err != nil {
 		return utils.ErrDatabaseError
 	}
 	if sourceCount != len(filtered) {
 		return utils.ErrTripNotFound
 	}
+	// Trashed dives move too, so they are in the merged trip if restored.
 	if _, err := tx.ExecContext(ctx, `UPDATE dives SET trip_id = $1, updated_at = NOW() WHERE user_id = $2 AND trip_id = ANY($3)`, targetID, userID, pq.Array(filtered)); err != nil {
 		return utils.ErrDatabaseError
 	}
@@ -521,7 +640,7 @@ func (r *LogbookRepository) MergeTrips(ctx context.Context, userID, targetID int
 }
 
 func (r *LogbookRepository) SplitTrip(ctx context.Context, userID, sourceID int, request models.SplitTripRequest) (*models.Trip, error) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	tx, err := beginAudited(ctx, r.db, serializable)
 	if err != nil {
 		return nil, utils.ErrDatabaseError
 	}
@@ -530,10 +649,10 @@ func (r *LogbookRepository) SplitTrip(ctx context.Context, userID, sourceID int,
 	var location, start, end, notes sql.NullString
 	err = tx.QueryRowContext(ctx, `
 		INSERT INTO trips (user_id, name, location, start_date, end_date, notes)
-		SELECT $1, $2, $3, $4, $5, $6 WHERE EXISTS (SELECT 1 FROM trips WHERE id = $7 AND user_id = $1)
-		RETURNING id, user_id, name, location, start_date::text, end_date::text, notes, 0`,
+		SELECT $1, $2, $3, $4, $5, $6 WHERE EXISTS (SELECT 1 FROM trips WHERE id = $7 AND user_id = $1 AND deleted_at IS NULL)
+		RETURNING id, user_id, name, location, start_date::text, end_date::text, notes, 0, version`,
 		userID, strings.TrimSpace(request.Trip.Name), optionalText(request.Trip.Location), optionalText(request.Trip.StartDate), optionalText(request.Trip.EndDate), optionalText(request.Trip.Notes), sourceID,
-	).Scan(&trip.ID, &trip.UserID, &trip.Name, &location, &start, &end, &notes, &trip.DiveCount)
+	).Scan(&trip.ID, &trip.UserID, &trip.Name, &location, &start, &end, &notes, &trip.DiveCount, &trip.Version)
 	if err == sql.ErrNoRows {
 		return nil, utils.ErrTripNotFound
 	}
@@ -545,7 +664,7 @@ func (r *LogbookRepository) SplitTrip(ctx context.Context, userID, sourceID int,
 	}
 	result, err := tx.ExecContext(ctx, `
 		UPDATE dives SET trip_id = $1, updated_at = NOW()
-		WHERE user_id = $2 AND trip_id = $3 AND id = ANY($4)`, trip.ID, userID, sourceID, pq.Array(request.DiveIDs))
+		WHERE user_id = $2 AND trip_id = $3 AND id = ANY($4) AND deleted_at IS NULL`, trip.ID, userID, sourceID, pq.Array(request.DiveIDs))
 	if err != nil {
 		return nil, utils.ErrDatabaseError
 	}
@@ -565,22 +684,29 @@ func (r *LogbookRepository) RenumberDives(ctx context.Context, userID int, reque
 	query := `
 		WITH numbered AS (
 			SELECT id, ($2 + (ROW_NUMBER() OVER (ORDER BY dive_datetime, id) - 1) * $3)::INTEGER AS next_number
-			FROM dives WHERE user_id = $1`
+			FROM dives WHERE user_id = $1 AND deleted_at IS NULL`
 	args := []interface{}{userID, request.StartNumber, request.Increment}
 	if request.Scope == "range" {
 		query += ` AND dive_datetime::date BETWEEN $4::date AND $5::date`
 		args = append(args, *request.FromDate, *request.ToDate)
 	}
 	query += `) UPDATE dives d SET dive_number = numbered.next_number, updated_at = NOW() FROM numbered WHERE d.id = numbered.id`
-	result, err := r.db.ExecContext(ctx, query, args...)
-	if err != nil {
-		return 0, utils.ErrDatabaseError
-	}
-	count, err := result.RowsAffected()
-	if err != nil {
-		return 0, utils.ErrDatabaseError
-	}
-	return count, nil
+	var count int64
+	err := auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		result, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return utils.ErrDatabaseError
+		}
+		if count, err = result.RowsAffected(); err != nil {
+			return utils.ErrDatabaseError
+		}
+		if count == 0 {
+			return nil
+		}
+		return enqueueWebhookEvent(ctx, db, userID, models.WebhookEventDivesUpdated,
+			models.DiveChangeSummary{Operation: "renumber", AffectedCount: count})
+	})
+	return count, err
 }
 
 func isUniqueViolation(err error) bool {
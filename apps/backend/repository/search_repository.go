@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"html"
+	"strings"
+)
+
+// Sentinels passed to ts_headline so the excerpt can be HTML-escaped before
+// the highlight markup is added. A stray control character in user text can at
+// worst produce an unbalanced <mark>, never unescaped markup.
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+const headlineOptions = "StartSel=" + headlineStart + ", StopSel=" + headlineStop +
+	", MaxFragments=2, MaxWords=20, MinWords=5, FragmentDelimiter=\" … \""
+
+// SearchRepository runs full-text queries against the search_vector columns
+// maintained by the full-text search migration.
+type SearchRepository struct {
+	db dbExecutor
+}
+
+func NewSearchRepository(db *sql.DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+// SearchDives matches the user's dives by notes, location, buddy, tags, trip
+// name and dive site.
+func (r *SearchRepository) SearchDives(ctx context.Context, userID int, query string, limit int) ([]models.SearchHit, error) {
+	return r.search(ctx, `
+		WITH q AS (SELECT websearch_to_tsquery('english', $1) AS query)
+		SELECT d.id, COALESCE(d.location, ''), d.dive_datetime, ts_rank(d.search_vector, q.query) AS rank,
+		       ts_headline('english', concat_ws(' · ', d.location, d.buddy, d.notes,
+		                   (SELECT string_agg(t.name, ', ') FROM dive_tags dt JOIN tags t ON t.id = dt.tag_id WHERE dt.dive_id = d.id AND t.deleted_at IS NULL),
+		                   tr.name, ds.name, ds.description), q.query, $2)
+		FROM q, dives d
+		LEFT JOIN trips tr ON tr.id = d.trip_id AND tr.deleted_at IS NULL
+		LEFT JOIN dive_sites ds ON ds.id = d.dive_site_id AND ds.deleted_at IS NULL
+		WHERE d.user_id = $3 AND d.deleted_at IS NULL AND d.search_vector @@ q.query
+		ORDER BY rank DESC, d.dive_datetime DESC
+		LIMIT $4`, query, headlineOptions, userID, limit)
+}
+
+// SearchDiveSites matches shared dive sites by name and description.
+func (r *SearchRepository) SearchDiveSites(ctx context.Context, query string, limit int) ([]models.SearchHit, error) {
+	return r.search(ctx, `
+		WITH q AS (SELECT websearch_to_tsquery('english', $1) AS query)
+		SELECT ds.id, ds.name, NULL::timestamp, ts_rank(ds.search_vector, q.query) AS rank,
+		       ts_headline('english', concat_ws(' · ', ds.name, ds.description), q.query, $2)
+		FROM q, dive_sites ds
+		WHERE ds.deleted_at IS NULL AND ds.search_vector @@ q.query
+		ORDER BY rank DESC, ds.name
+		LIMIT $3`, query, headlineOptions, limit)
+}
+
+// SearchTrips matches the user's trips by name, location and notes.
+func (r *SearchRepository) SearchTrips(ctx context.Context, userID int, query string, limit int) ([]models.SearchHit, error) {
+	return r.search(ctx, `
+		WITH q AS (SELECT websearch_to_tsquery('english', $1) AS query)
+		SELECT tr.id, tr.name, tr.start_date::timestamp, ts_rank(tr.search_vector, q.query) AS rank,
+		       ts_headline('english', concat_ws(' · ', tr.name, tr.location, tr.notes), q.query, $2)
+		FROM q, trips tr
+		WHERE tr.user_id = $3 AND tr.deleted_at IS NULL AND tr.search_vector @@ q.query
+		ORDER BY rank DESC, tr.start_date DESC NULLS LAST
+		LIMIT $4`, query, headlineOptions, userID, limit)
+}
+
+func (r *SearchRepository) search(ctx context.Context, query string, args ...interface{}) ([]models.SearchHit, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		utils.LogError(ctx, "Error running search", err)
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	hits := []models.SearchHit{}
+	for rows.Next() {
+		var hit models.SearchHit
+		var date models.LocalTime
+		var headline string
+		if err := rows.Scan(&hit.ID, &hit.Title, &date, &hit.Rank, &headline); err != nil {
+			utils.LogError(ctx, "Error scanning search hit", err)
+			return nil, utils.ErrDatabaseError
+		}
+		if !date.IsZero() {
+			hit.Date = &date
+		}
+		hit.Headline = highlightHeadline(headline)
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return hits, nil
+}
+
+// highlightHeadline escapes a ts_headline excerpt and turns the sentinel
+// delimiters into <mark> elements.
+func highlightHeadline(headline string) string {
+	escaped := html.EscapeString(headline)
+	return strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>").Replace(escaped)
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlightHeadlineEscapesTextAndMarksMatches(t *testing.T) {
+	headline := "Saw a " + headlineStart + "manta" + headlineStop + " near <script>the reef</script> & wall"
+
+	assert.Equal(t,
+		"Saw a <mark>manta</mark> near &lt;script&gt;the reef&lt;/script&gt; &amp; wall",
+		highlightHeadline(headline))
+}
@@ -9,7 +9,7 @@ import (
 )
 
 type SettingsRepository struct {
-	db *sql.DB
+	db dbExecutor
 }
 
 func NewSettingsRepository(db *sql.DB) *SettingsRepository {
@@ -21,7 +21,7 @@ func (r *SettingsRepository) GetByUserID(ctx context.Context, userID int) (*mode
 	query := `
 		SELECT id, user_id, unit_preference, depth_unit, temperature_unit, distance_unit, weight_unit, pressure_unit, volume_unit,
 		       date_format, time_format, default_visibility, show_buddy_reminders, auto_calculate_nitrox,
-		       default_gas_mix, max_depth_warning, created_at, updated_at
+		       default_gas_mix, max_depth_warning, created_at, updated_at, version
 		FROM user_settings WHERE user_id = $1
 	`
 
@@ -34,7 +34,7 @@ func (r *SettingsRepository) GetByUserID(ctx context.Context, userID int) (*mode
 		&settings.DateFormat, &settings.TimeFormat, &settings.DefaultVisibility,
 		&settings.ShowBuddyReminders, &settings.AutoCalculateNitrox,
 		&settings.DefaultGasMix, &settings.MaxDepthWarning,
-		&settings.CreatedAt, &settings.UpdatedAt,
+		&settings.CreatedAt, &settings.UpdatedAt, &settings.Version,
 	)
 
 	if err != nil {
@@ -55,7 +55,7 @@ func (r *SettingsRepository) CreateDefault(ctx context.Context, userID int) (*mo
 		                          date_format, time_format, default_visibility, show_buddy_reminders, auto_calculate_nitrox,
 		                          default_gas_mix, max_depth_warning)
 		VALUES ($1, 'metric', 'meters', 'celsius', 'kilometers', 'kilograms', 'bar', 'liters', 'ISO', '24h', 'private', true, false, 'Air (21% O₂)', 40)
-		RETURNING id, created_at, updated_at
+		RETURNING id, created_at, updated_at, version
 	`
 
 	settings := &models.UserSettings{
@@ -76,40 +76,54 @@ func (r *SettingsRepository) CreateDefault(ctx context.Context, userID int) (*mo
 		MaxDepthWarning:     40,
 	}
 
-	row := r.db.QueryRow(query, userID)
-	err := row.Scan(&settings.ID, &settings.CreatedAt, &settings.UpdatedAt)
-
+	err := auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		row := db.QueryRowContext(ctx, query, userID)
+		if err := row.Scan(&settings.ID, &settings.CreatedAt, &settings.UpdatedAt, &settings.Version); err != nil {
+			utils.LogError(ctx, "Error creating default settings", err, utils.UserID(userID))
+			return utils.ErrDatabaseError
+		}
+		return nil
+	})
 	if err != nil {
-		utils.LogError(ctx, "Error creating default settings", err, utils.UserID(userID))
-		return nil, utils.ErrDatabaseError
+		return nil, err
 	}
 
 	return settings, nil
 }
 
-// Update updates settings in the database
-func (r *SettingsRepository) Update(ctx context.Context, settings *models.UserSettings) error {
+// Update updates settings in the database. With an expected version the update
+// only applies while the settings are still at that version, and returns
+// ErrVersionConflict otherwise.
+func (r *SettingsRepository) Update(ctx context.Context, settings *models.UserSettings, expectedVersion *int) error {
 	query := `
 		UPDATE user_settings SET
 			unit_preference = $2, depth_unit = $3, temperature_unit = $4, distance_unit = $5, weight_unit = $6, pressure_unit = $7, volume_unit = $8,
 			date_format = $9, time_format = $10, default_visibility = $11, show_buddy_reminders = $12,
 			auto_calculate_nitrox = $13, default_gas_mix = $14, max_depth_warning = $15, updated_at = $16
-		WHERE user_id = $1
+		WHERE user_id = $1 AND ($17::int IS NULL OR version = $17)
 	`
 
-	_, err := r.db.Exec(query,
-		settings.UserID, settings.UnitPreference, settings.DepthUnit, settings.TemperatureUnit, settings.DistanceUnit,
-		settings.WeightUnit, settings.PressureUnit, settings.VolumeUnit, settings.DateFormat, settings.TimeFormat,
-		settings.DefaultVisibility, settings.ShowBuddyReminders, settings.AutoCalculateNitrox,
-		settings.DefaultGasMix, settings.MaxDepthWarning, time.Now(),
-	)
+	return auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		result, err := db.ExecContext(ctx, query,
+			settings.UserID, settings.UnitPreference, settings.DepthUnit, settings.TemperatureUnit, settings.DistanceUnit,
+			settings.WeightUnit, settings.PressureUnit, settings.VolumeUnit, settings.DateFormat, settings.TimeFormat,
+			settings.DefaultVisibility, settings.ShowBuddyReminders, settings.AutoCalculateNitrox,
+			settings.DefaultGasMix, settings.MaxDepthWarning, time.Now(), expectedVersion,
+		)
+
+		if err != nil {
+			utils.LogError(ctx, "Error updating settings", err, utils.UserID(settings.UserID))
+			return utils.ErrDatabaseError
+		}
 
-	if err != nil {
-		utils.LogError(ctx, "Error updating settings", err, utils.UserID(settings.UserID))
-		return utils.ErrDatabaseError
-	}
+		if expectedVersion != nil {
+			if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+				return utils.ErrVersionConflict
+			}
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // GetOrCreateDefault gets settings for a user, creating defaults if they don't exist
@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// SpeciesRepository owns the shared species catalog and the sightings logged
+// on each user's dives.
+type SpeciesRepository struct {
+	db *sql.DB
+}
+
+func NewSpeciesRepository(db *sql.DB) *SpeciesRepository {
+	return &SpeciesRepository{db: db}
+}
+
+const speciesColumns = `s.id, s.common_name, s.scientific_name, s.category`
+
+func speciesScanTargets(species *models.Species) []interface{} {
+	return []interface{}{&species.ID, &species.CommonName, &species.ScientificName, &species.Category}
+}
+
+// speciesFilterCondition turns filter into a WHERE condition on the species
+// table aliased s, with parameters numbered from first.
+func speciesFilterCondition(filter models.SpeciesFilter, first int) (string, []interface{}) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	if filter.Query != "" {
+		conditions = append(conditions, fmt.Sprintf(
+			"(s.common_name ILIKE '%%' || $%d || '%%' OR s.scientific_name ILIKE '%%' || $%d || '%%')", first, first))
+		args = append(args, filter.Query)
+		first++
+	}
+	if filter.Category != "" {
+		conditions = append(conditions, fmt.Sprintf("s.category = $%d", first))
+		args = append(args, filter.Category)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// ListSpecies returns the catalog entries matching filter, ordered by common
+// name.
+func (r *SpeciesRepository) ListSpecies(ctx context.Context, filter models.SpeciesFilter) ([]models.Species, error) {
+	condition, args := speciesFilterCondition(filter, 1)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+speciesColumns+`
+		FROM species s
+		WHERE `+condition+`
+		ORDER BY lower(s.common_name)`, args...)
+	if err != nil {
+		utils.LogError(ctx, "Error listing species", err)
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	catalog := []models.Species{}
+	for rows.Next() {
+		var species models.Species
+		if err := rows.Scan(speciesScanTargets(&species)...); err != nil {
+			utils.LogError(ctx, "Error scanning species", err)
+			return nil, utils.ErrDatabaseError
+		}
+		catalog = append(catalog, species)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return catalog, nil
+}
+
+// GetSpecies returns one catalog entry.
+func (r *SpeciesRepository) GetSpecies(ctx context.Context, id int) (*models.Species, error) {
+	var species models.Species
+	err := r.db.QueryRowContext(ctx, `SELECT `+speciesColumns+` FROM species s WHERE s.id = $1`, id).
+		Scan(speciesScanTargets(&species)...)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrSpeciesNotFound
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error getting species", err)
+		return nil, utils.ErrDatabaseError
+	}
+	return &species, nil
+}
+
+// CreateSpecies adds a catalog entry. Common and scientific names are unique
+// regardless of case.
+func (r *SpeciesRepository) CreateSpecies(ctx context.Context, request models.SpeciesRequest) (*models.Species, error) {
+	species := models.Species{CommonName: request.CommonName, Category: request.Category}
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO species (common_name, scientific_name, category)
+		VALUES ($1, $2, $3)
+		RETURNING id, scientific_name`,
+		request.CommonName, optionalText(request.ScientificName), request.Category,
+	).Scan(&species.ID, &species.ScientificName)
+	if isUniqueViolation(err) {
+		return nil, utils.ErrDuplicateSpecies
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error creating species", err)
+		return nil, utils.ErrDatabaseError
+	}
+	return &species, nil
+}
+
+// GetDiveSightings returns the sightings logged on one of userID's dives.
+func (r *SpeciesRepository) GetDiveSightings(ctx context.Context, userID, diveID int) ([]models.Sighting, error) {
+	var owned bool
+	if err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM dives WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`, diveID, userID,
+	).Scan(&owned); err != nil {
+		utils.LogError(ctx, "Error checking dive ownership", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	if !owned {
+		return nil, utils.ErrDiveNotFound
+	}
+	return querySightings(ctx, r.db, diveID)
+}
+
+// ReplaceDiveSightings swaps every sighting on one of userID's dives for
+// sightings in a single transaction.
+func (r *SpeciesRepository) ReplaceDiveSightings(ctx context.Context, userID, diveID int, sightings []models.SightingRequest) ([]models.Sighting, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	defer tx.Rollback()
+	if err := ensureOwnedDives(ctx, tx, userID, []int{diveID}); err != nil {
+		return nil, err
+	}
+
+	speciesIDs := make([]int, len(sightings))
+	for i, sighting := range sightings {
+		speciesIDs[i] = sighting.SpeciesID
+	}
+	var known int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM species WHERE id = ANY($1)`, pq.Array(speciesIDs),
+	).Scan(&known); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	if known != len(speciesIDs) {
+		return nil, utils.ErrSpeciesNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM dive_sightings WHERE dive_id = $1`, diveID); err != nil {
+		utils.LogError(ctx, "Error clearing dive sightings", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	for _, sighting := range sightings {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO dive_sightings (dive_id, species_id, count, notes) VALUES ($1, $2, $3, $4)`,
+			diveID, sighting.SpeciesID, sighting.Count, optionalText(sighting.Notes)); err != nil {
+			utils.LogError(ctx, "Error recording dive sighting", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+	}
+	result, err := querySightings(ctx, tx, diveID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return result, nil
+}
+
+func querySightings(ctx context.Context, db dbExecutor, diveID int) ([]models.Sighting, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+speciesColumns+`, sg.count, sg.notes
+		FROM dive_sightings sg
+		JOIN species s ON s.id = sg.species_id
+		WHERE sg.dive_id = $1
+		ORDER BY lower(s.common_name)`, diveID)
+	if err != nil {
+		utils.LogError(ctx, "Error querying dive sightings", err)
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	sightings := []models.Sighting{}
+	for rows.Next() {
+		var sighting models.Sighting
+		if err := rows.Scan(append(speciesScanTargets(&sighting.Species), &sighting.Count, &sighting.Notes)...); err != nil {
+			utils.LogError(ctx, "Error scanning dive sighting", err)
+			return nil, utils.ErrDatabaseError
+		}
+		sightings = append(sightings, sighting)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return sightings, nil
+}
+
+// SightingSites groups userID's sightings of a species by dive site, most
+// dived site first.
+func (r *SpeciesRepository) SightingSites(ctx context.Context, userID, speciesID int) ([]models.SpeciesSightingSite, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT ds.id, COALESCE(MIN(ds.name), MIN(d.location), ''), MIN(ds.latitude)::float8, MIN(ds.longitude)::float8,
+		       MIN(ds.country), COUNT(*), SUM(sg.count), MIN(d.dive_datetime), MAX(d.dive_datetime)
+		FROM dive_sightings sg
+		JOIN dives d ON d.id = sg.dive_id
+		LEFT JOIN dive_sites ds ON ds.id = d.dive_site_id AND ds.deleted_at IS NULL
+		WHERE d.user_id = $1 AND d.deleted_at IS NULL AND sg.species_id = $2
+		GROUP BY ds.id
+		ORDER BY COUNT(*) DESC, MAX(d.dive_datetime) DESC`, userID, speciesID)
+	if err != nil {
+		utils.LogError(ctx, "Error querying species sighting sites", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	sites := []models.SpeciesSightingSite{}
+	for rows.Next() {
+		var site models.SpeciesSightingSite
+		if err := rows.Scan(&site.DiveSiteID, &site.Name, &site.Latitude, &site.Longitude, &site.Country,
+			&site.DiveCount, &site.TotalCount, &site.FirstSeen, &site.LastSeen); err != nil {
+			utils.LogError(ctx, "Error scanning species sighting site", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		sites = append(sites, site)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return sites, nil
+}
+
+// LifeList returns every species userID has logged, in the order they were
+// first seen.
+func (r *SpeciesRepository) LifeList(ctx context.Context, userID int, filter models.SpeciesFilter) ([]models.LifeListEntry, error) {
+	condition, args := speciesFilterCondition(filter, 2)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+speciesColumns+`, COUNT(*), SUM(sg.count), COUNT(DISTINCT d.dive_site_id),
+		       MIN(d.dive_datetime), MAX(d.dive_datetime),
+		       (array_agg(d.id ORDER BY d.dive_datetime, d.id))[1],
+		       (array_agg(COALESCE(ds.name, d.location, '') ORDER BY d.dive_datetime, d.id))[1]
+		FROM dive_sightings sg
+		JOIN dives d ON d.id = sg.dive_id
+		JOIN species s ON s.id = sg.species_id
+		LEFT JOIN dive_sites ds ON ds.id = d.dive_site_id AND ds.deleted_at IS NULL
+		WHERE d.user_id = $1 AND d.deleted_at IS NULL AND `+condition+`
+		GROUP BY s.id
+		ORDER BY MIN(d.dive_datetime), s.id`, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		utils.LogError(ctx, "Error querying life list", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	entries := []models.LifeListEntry{}
+	for rows.Next() {
+		var entry models.LifeListEntry
+		targets := append(speciesScanTargets(&entry.Species),
+			&entry.DiveCount, &entry.TotalCount, &entry.SiteCount, &entry.FirstSeen, &entry.LastSeen,
+			&entry.FirstDiveID, &entry.FirstSiteName)
+		if err := rows.Scan(targets...); err != nil {
+			utils.LogError(ctx, "Error scanning life list entry", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return entries, nil
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"divelog-backend/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpeciesFilterConditionNumbersParameters(t *testing.T) {
+	condition, args := speciesFilterCondition(models.SpeciesFilter{Query: "manta", Category: "ray"}, 2)
+
+	assert.Equal(t, "TRUE AND (s.common_name ILIKE '%' || $2 || '%' OR s.scientific_name ILIKE '%' || $2 || '%') AND s.category = $3", condition)
+	assert.Equal(t, []interface{}{"manta", "ray"}, args)
+
+	condition, args = speciesFilterCondition(models.SpeciesFilter{Category: "shark"}, 1)
+	assert.Equal(t, "TRUE AND s.category = $1", condition)
+	assert.Equal(t, []interface{}{"shark"}, args)
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+
+	"github.com/lib/pq"
+)
+
+// SyncRepository reads the per-user change feed maintained by the
+// change_log triggers, together with the current state of changed entities.
+// SQLTransactor.WithinSyncSnapshot binds it to one read-only snapshot.
+type SyncRepository struct {
+	db dbExecutor
+}
+
+func newSyncRepository(db dbExecutor) *SyncRepository {
+	return &SyncRepository{db: db}
+}
+
+// LatestSeq returns the newest sequence number issued to userID, or 0 before
+// their first change.
+func (r *SyncRepository) LatestSeq(ctx context.Context, userID int) (int64, error) {
+	var seq int64
+	if err := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE((SELECT last_seq FROM change_sequences WHERE user_id = $1), 0)`, userID).Scan(&seq); err != nil {
+		utils.LogError(ctx, "Error reading change sequence", err, utils.UserID(userID))
+		return 0, utils.ErrDatabaseError
+	}
+	return seq, nil
+}
+
+// ChangesSince returns up to limit of userID's change_log entries after since,
+// oldest first, without entity data.
+func (r *SyncRepository) ChangesSince(ctx context.Context, userID int, since int64, limit int) ([]models.SyncChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT seq, entity_type, entity_id, operation, changed_at
+		FROM change_log WHERE user_id = $1 AND seq > $2
+		ORDER BY seq LIMIT $3`, userID, since, limit)
+	if err != nil {
+		utils.LogError(ctx, "Error reading change log", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	changes := []models.SyncChange{}
+	for rows.Next() {
+		var change models.SyncChange
+		if err := rows.Scan(&change.Seq, &change.EntityType, &change.EntityID, &change.Operation, &change.ChangedAt); err != nil {
+			utils.LogError(ctx, "Error scanning change log entry", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return changes, nil
+}
+
+// Dives returns userID's dives among ids. Surface intervals depend on the
+// neighbouring dives, which a page need not contain, so they are left unset.
+func (r *SyncRepository) Dives(ctx context.Context, userID int, ids []int) ([]models.Dive, error) {
+	dives, err := newDiveRepository(r.db).queryDives(ctx, userID, ` AND d.id = ANY($2)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	for i := range dives {
+		dives[i].SurfaceInterval = nil
+	}
+	return dives, nil
+}
+
+// DiveSites returns the dive sites among ids.
+func (r *SyncRepository) DiveSites(ctx context.Context, ids []int) ([]models.DiveSite, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+diveSiteColumns+` FROM dive_sites WHERE id = ANY($1) AND deleted_at IS NULL`, pq.Array(ids))
+	if err != nil {
+		utils.LogError(ctx, "Error reading changed dive sites", err)
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	sites := []models.DiveSite{}
+	for rows.Next() {
+		var site models.DiveSite
+		if err := rows.Scan(diveSiteScanTargets(&site)...); err != nil {
+			utils.LogError(ctx, "Error scanning changed dive site", err)
+			return nil, utils.ErrDatabaseError
+		}
+		sites = append(sites, site)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return sites, nil
+}
+
+// Trips returns userID's trips among ids.
+func (r *SyncRepository) Trips(ctx context.Context, userID int, ids []int) ([]models.Trip, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+tripColumns+`
+		FROM trips tr LEFT JOIN dives d ON d.trip_id = tr.id AND d.deleted_at IS NULL
+		WHERE tr.user_id = $1 AND tr.id = ANY($2) AND tr.deleted_at IS NULL GROUP BY tr.id`, userID, pq.Array(ids))
+	if err != nil {
+		utils.LogError(ctx, "Error reading changed trips", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	trips := []models.Trip{}
+	for rows.Next() {
+		trip, err := scanTrip(rows)
+		if err != nil {
+			utils.LogError(ctx, "Error scanning changed trip", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		trips = append(trips, *trip)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return trips, nil
+}
+
+// Tags returns userID's tags among ids.
+func (r *SyncRepository) Tags(ctx context.Context, userID int, ids []int) ([]models.TagSummary, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.id, t.name, COUNT(dt.dive_id)::int
+		FROM tags t LEFT JOIN (`+liveDiveTags+`) dt ON dt.tag_id = t.id
+		WHERE t.user_id = $1 AND t.id = ANY($2) AND t.deleted_at IS NULL GROUP BY t.id, t.name`, userID, pq.Array(ids))
+	if err != nil {
+		utils.LogError(ctx, "Error reading changed tags", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	tags := []models.TagSummary{}
+	for rows.Next() {
+		var tag models.TagSummary
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.DiveCount); err != nil {
+			utils.LogError(ctx, "Error scanning changed tag", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return tags, nil
+}
+
+// Settings returns userID's settings.
+func (r *SyncRepository) Settings(ctx context.Context, userID int) (*models.UserSettings, error) {
+	return (&SettingsRepository{db: r.db}).GetByUserID(ctx, userID)
+}
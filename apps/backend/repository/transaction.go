@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"divelog-backend/services"
 	"divelog-backend/utils"
+	"strconv"
 )
 
 // dbExecutor is implemented by both sql.DB and sql.Tx.
@@ -13,6 +14,8 @@ type dbExecutor interface {
 	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
 	Query(string, ...interface{}) (*sql.Rows, error)
 	QueryRow(string, ...interface{}) *sql.Row
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
 }
 
 // SQLTransactor creates transaction-bound repository instances for a service
@@ -22,6 +25,8 @@ type SQLTransactor struct {
 	db *sql.DB
 }
 
+var serializable = &sql.TxOptions{Isolation: sql.LevelSerializable}
+
 func NewSQLTransactor(db *sql.DB) *SQLTransactor {
 	return &SQLTransactor{db: db}
 }
@@ -30,7 +35,40 @@ func (t *SQLTransactor) WithinTransaction(
 	ctx context.Context,
 	operation func(services.DiveRepository, services.DiveSiteRepository) error,
 ) error {
-	tx, err := t.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	return t.within(ctx, serializable, func(tx *sql.Tx) error {
+		return operation(newDiveRepository(tx), newDiveSiteRepository(tx))
+	})
+}
+
+// WithinBackupTransaction runs a backup export or restore against a single
+// serializable snapshot, so an archive never mixes states and a restore is
+// applied completely or not at all.
+func (t *SQLTransactor) WithinBackupTransaction(
+	ctx context.Context,
+	operation func(services.BackupRepository, services.DiveRepository, services.DiveSiteRepository) error,
+) error {
+	return t.within(ctx, serializable, func(tx *sql.Tx) error {
+		return operation(newBackupRepository(tx), newDiveRepository(tx), newDiveSiteRepository(tx))
+	})
+}
+
+// WithinSyncSnapshot reads a page of the change feed and the entities it names
+// from one read-only snapshot, so the data always matches the cursor.
+func (t *SQLTransactor) WithinSyncSnapshot(ctx context.Context, operation func(services.SyncRepository) error) error {
+	options := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	return t.within(ctx, options, func(tx *sql.Tx) error {
+		return operation(newSyncRepository(tx))
+	})
+}
+
+func (t *SQLTransactor) within(ctx context.Context, options *sql.TxOptions, operation func(*sql.Tx) error) error {
+	var tx *sql.Tx
+	var err error
+	if options.ReadOnly {
+		tx, err = t.db.BeginTx(ctx, options)
+	} else {
+		tx, err = beginAudited(ctx, t.db, options)
+	}
 	if err != nil {
 		return utils.ErrDatabaseError
 	}
@@ -42,7 +80,7 @@ func (t *SQLTransactor) WithinTransaction(
 		}
 	}()
 
-	if err := operation(newDiveRepository(tx), newDiveSiteRepository(tx)); err != nil {
+	if err := operation(tx); err != nil {
 		return err
 	}
 	if err := tx.Commit(); err != nil {
@@ -51,3 +89,54 @@ func (t *SQLTransactor) WithinTransaction(
 	committed = true
 	return nil
 }
+
+// beginAudited starts a transaction tagged with the actor and request ID from
+// ctx, which the audit log triggers record with every row the transaction
+// changes. The tags are local to the transaction.
+func beginAudited(ctx context.Context, db *sql.DB, options *sql.TxOptions) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	actor := utils.ActorFromContext(ctx)
+	if actor == (utils.Actor{}) {
+		return tx, nil
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('divelog.actor_user_id', $1, true),
+		set_config('divelog.actor_token_id', $2, true), set_config('divelog.request_id', $3, true)`,
+		optionalID(actor.UserID), optionalID(actor.TokenID), actor.RequestID); err != nil {
+		_ = tx.Rollback()
+		utils.LogError(ctx, "Error tagging transaction for the audit log", err)
+		return nil, err
+	}
+	return tx, nil
+}
+
+// auditedWrite runs write in a transaction from beginAudited. A repository
+// bound to a transaction by SQLTransactor is already tagged and writes to it
+// directly.
+func auditedWrite(ctx context.Context, db dbExecutor, write func(dbExecutor) error) error {
+	database, ok := db.(*sql.DB)
+	if !ok {
+		return write(db)
+	}
+	tx, err := beginAudited(ctx, database, nil)
+	if err != nil {
+		return utils.ErrDatabaseError
+	}
+	defer tx.Rollback()
+	if err := write(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.ErrDatabaseError
+	}
+	return nil
+}
+
+func optionalID(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
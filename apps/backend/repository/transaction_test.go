@@ -86,3 +86,19 @@ func TestSQLTransactorRollsBackFailedWorkflow(t *testing.T) {
 	assert.False(t, testDriver.committed)
 	assert.True(t, testDriver.rolledBack)
 }
+
+func TestSQLTransactorRunsBackupWorkflowInOneTransaction(t *testing.T) {
+	testDriver := new(transactionTestDriver)
+	transactor := NewSQLTransactor(openTransactionTestDB(t, testDriver))
+
+	err := transactor.WithinBackupTransaction(context.Background(), func(backups services.BackupRepository, dives services.DiveRepository, sites services.DiveSiteRepository) error {
+		assert.NotNil(t, backups)
+		assert.NotNil(t, dives)
+		assert.NotNil(t, sites)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, driver.IsolationLevel(sql.LevelSerializable), testDriver.options.Isolation)
+	assert.True(t, testDriver.committed)
+}
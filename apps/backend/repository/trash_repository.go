@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"fmt"
+	"time"
+)
+
+// TrashRepository lists, restores and purges soft-deleted dives, trips, tags
+// and dive sites.
+type TrashRepository struct {
+	db *sql.DB
+}
+
+func NewTrashRepository(db *sql.DB) *TrashRepository {
+	return &TrashRepository{db: db}
+}
+
+// trashTables names the table behind each trash item type and the column
+// holding the user whose trash a row is in. Dive sites are shared, so theirs
+// is the user who deleted them.
+var trashTables = map[string]struct{ table, owner string }{
+	models.AuditEntityDive:     {"dives", "user_id"},
+	models.AuditEntityTrip:     {"trips", "user_id"},
+	models.AuditEntityTag:      {"tags", "user_id"},
+	models.AuditEntityDiveSite: {"dive_sites", "deleted_by"},
+}
+
+// ListTrash returns userID's trashed items, most recently deleted first.
+// An empty itemType lists every type.
+func (r *TrashRepository) ListTrash(ctx context.Context, userID int, itemType string) ([]models.TrashItem, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT type, id, name, deleted_at FROM (
+			SELECT 'dive'::text AS type, d.id,
+			       COALESCE(NULLIF(d.location, ''), ds.name, to_char(d.dive_datetime, 'YYYY-MM-DD HH24:MI')) AS name,
+			       d.deleted_at
+			FROM dives d LEFT JOIN dive_sites ds ON ds.id = d.dive_site_id
+			WHERE d.user_id = $1 AND d.deleted_at IS NOT NULL
+			UNION ALL
+			SELECT 'trip', id, name, deleted_at FROM trips WHERE user_id = $1 AND deleted_at IS NOT NULL
+			UNION ALL
+			SELECT 'tag', id, name, deleted_at FROM tags WHERE user_id = $1 AND deleted_at IS NOT NULL
+			UNION ALL
+			SELECT 'dive_site', id, name, deleted_at FROM dive_sites WHERE deleted_by = $1 AND deleted_at IS NOT NULL
+		) trash
+		WHERE $2 = '' OR type = $2
+		ORDER BY deleted_at DESC, type, id`, userID, itemType)
+	if err != nil {
+		utils.LogError(ctx, "Error querying trash", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	items := []models.TrashItem{}
+	for rows.Next() {
+		var item models.TrashItem
+		if err := rows.Scan(&item.Type, &item.ID, &item.Name, &item.DeletedAt); err != nil {
+			utils.LogError(ctx, "Error scanning trash item", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return items, nil
+}
+
+// RestoreDive takes one of userID's dives out of the trash, together with its
+// dive site if that was trashed too. It fails with ErrDuplicateDive when a
+// live dive has since taken its site and start time. Webhook subscribers,
+// who were told the dive was deleted, are sent it as dive.created.
+func (r *TrashRepository) RestoreDive(ctx context.Context, userID, diveID int) error {
+	tx, err := beginAudited(ctx, r.db, serializable)
+	if err != nil {
+		return utils.ErrDatabaseError
+	}
+	defer tx.Rollback()
+
+	var siteID sql.NullInt64
+	err = tx.QueryRowContext(ctx, `
+		UPDATE dives SET deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		RETURNING dive_site_id`, diveID, userID).Scan(&siteID)
+	if err == sql.ErrNoRows {
+		return utils.ErrTrashItemNotFound
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error restoring dive", err, utils.UserID(userID), utils.DiveID(diveID))
+		return utils.ErrDatabaseError
+	}
+	if siteID.Valid {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE dive_sites SET deleted_at = NULL, updated_at = NOW()
+			WHERE id = $1 AND deleted_at IS NOT NULL`, siteID.Int64); err != nil {
+			utils.LogError(ctx, "Error restoring dive site of dive", err, utils.UserID(userID), utils.DiveID(diveID))
+			return utils.ErrDatabaseError
+		}
+	}
+	if err := ensureNoDuplicateDive(ctx, tx, diveID); err != nil {
+		return err
+	}
+
+	dive, err := newDiveRepository(tx).GetDive(ctx, userID, diveID)
+	if err != nil {
+		return err
+	}
+	if err := enqueueWebhookEvent(ctx, tx, userID, models.WebhookEventDiveCreated, dive); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return utils.ErrDatabaseError
+	}
+	return nil
+}
+
+// RestoreItem takes a trip, tag or dive site out of userID's trash. A trip or
+// tag whose name has since been reused fails with ErrOrganizationConflict.
+func (r *TrashRepository) RestoreItem(ctx context.Context, userID int, itemType string, id int) error {
+	trash, ok := trashTables[itemType]
+	if !ok || itemType == models.AuditEntityDive {
+		return utils.ErrTrashItemNotFound
+	}
+	return auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		result, err := db.ExecContext(ctx, fmt.Sprintf(`
+			UPDATE %s SET deleted_at = NULL, updated_at = NOW()
+			WHERE id = $1 AND %s = $2 AND deleted_at IS NOT NULL`, trash.table, trash.owner), id, userID)
+		if isUniqueViolation(err) {
+			return utils.ErrOrganizationConflict
+		}
+		if err != nil {
+			utils.LogError(ctx, "Error restoring trash item", err, utils.UserID(userID))
+			return utils.ErrDatabaseError
+		}
+		if restored, _ := result.RowsAffected(); restored == 0 {
+			return utils.ErrTrashItemNotFound
+		}
+		return nil
+	})
+}
+
+// Purge permanently deletes one item from userID's trash. Purging a tag drops
+// it from its dives, and purging a trip or site leaves its dives without one.
+func (r *TrashRepository) Purge(ctx context.Context, userID int, itemType string, id int) error {
+	trash, ok := trashTables[itemType]
+	if !ok {
+		return utils.ErrTrashItemNotFound
+	}
+	return auditedWrite(ctx, r.db, func(db dbExecutor) error {
+		result, err := db.ExecContext(ctx, fmt.Sprintf(
+			`DELETE FROM %s WHERE id = $1 AND %s = $2 AND deleted_at IS NOT NULL`, trash.table, trash.owner), id, userID)
+		if err != nil {
+			utils.LogError(ctx, "Error purging trash item", err, utils.UserID(userID))
+			return utils.ErrDatabaseError
+		}
+		if purged, _ := result.RowsAffected(); purged == 0 {
+			return utils.ErrTrashItemNotFound
+		}
+		return nil
+	})
+}
+
+// EmptyTrash permanently deletes everything in userID's trash and returns how
+// many items went.
+func (r *TrashRepository) EmptyTrash(ctx context.Context, userID int) (int64, error) {
+	return r.purgeWhere(ctx, func(owner string) string { return owner + " = $1" }, userID)
+}
+
+// PurgeDeletedBefore permanently deletes every item, in any user's trash,
+// that was deleted before cutoff.
+func (r *TrashRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.purgeWhere(ctx, func(string) string { return "deleted_at < $1" }, cutoff)
+}
+
+// purgeWhere deletes the trashed rows of every type matching condition, which
+// is given the type's owner column, in one transaction. Dives go first so the
+// trips, tags and sites after them only lose links to surviving dives.
+func (r *TrashRepository) purgeWhere(ctx context.Context, condition func(owner string) string, arg interface{}) (int64, error) {
+	tx, err := beginAudited(ctx, r.db, nil)
+	if err != nil {
+		return 0, utils.ErrDatabaseError
+	}
+	defer tx.Rollback()
+
+	var purged int64
+	for _, itemType := range models.TrashItemTypes {
+		trash := trashTables[itemType]
+		result, err := tx.ExecContext(ctx,
+			fmt.Sprintf(`DELETE FROM %s WHERE deleted_at IS NOT NULL AND %s`, trash.table, condition(trash.owner)), arg)
+		if err != nil {
+			utils.LogError(ctx, "Error purging trash", err)
+			return 0, utils.ErrDatabaseError
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return 0, utils.ErrDatabaseError
+		}
+		purged += count
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, utils.ErrDatabaseError
+	}
+	return purged, nil
+}
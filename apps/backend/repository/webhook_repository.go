@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WebhookRepository stores webhook subscriptions and the delivery outbox the
+// dispatcher drains.
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const webhookSubscriptionColumns = `id, user_id, url, description, events, active, created_at, updated_at`
+
+func scanWebhookSubscription(row interface{ Scan(...interface{}) error }) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	var description sql.NullString
+	if err := row.Scan(&subscription.ID, &subscription.UserID, &subscription.URL, &description,
+		pq.Array(&subscription.Events), &subscription.Active, &subscription.CreatedAt, &subscription.UpdatedAt); err != nil {
+		return nil, err
+	}
+	subscription.Description = nullStringPointer(description)
+	return &subscription, nil
+}
+
+// ListSubscriptions returns userID's webhook subscriptions, oldest first.
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context, userID int) ([]models.WebhookSubscription, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error listing webhook subscriptions", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	subscriptions := []models.WebhookSubscription{}
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			utils.LogError(ctx, "Error scanning webhook subscription", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return subscriptions, nil
+}
+
+// GetSubscription returns one of userID's webhook subscriptions.
+func (r *WebhookRepository) GetSubscription(ctx context.Context, userID, id int) (*models.WebhookSubscription, error) {
+	subscription, err := scanWebhookSubscription(r.db.QueryRowContext(ctx, `
+		SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE id = $1 AND user_id = $2`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrWebhookNotFound
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error getting webhook subscription", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	return subscription, nil
+}
+
+// CreateSubscription stores a validated subscription with its signing secret.
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, userID int, request models.WebhookSubscriptionRequest, secret string) (*models.WebhookSubscription, error) {
+	subscription, err := scanWebhookSubscription(r.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_subscriptions (user_id, url, description, events, active, secret)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+webhookSubscriptionColumns,
+		userID, request.URL, optionalText(request.Description), pq.Array(request.Events), *request.Active, secret))
+	if err != nil {
+		utils.LogError(ctx, "Error creating webhook subscription", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	return subscription, nil
+}
+
+// UpdateSubscription replaces the URL, description, events and active flag of
+// one of userID's subscriptions. The secret is unchanged.
+func (r *WebhookRepository) UpdateSubscription(ctx context.Context, userID, id int, request models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	subscription, err := scanWebhookSubscription(r.db.QueryRowContext(ctx, `
+		UPDATE webhook_subscriptions
+		SET url = $1, description = $2, events = $3, active = $4, updated_at = NOW()
+		WHERE id = $5 AND user_id = $6
+		RETURNING `+webhookSubscriptionColumns,
+		request.URL, optionalText(request.Description), pq.Array(request.Events), *request.Active, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrWebhookNotFound
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error updating webhook subscription", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	return subscription, nil
+}
+
+// RotateSecret replaces the signing secret of one of userID's subscriptions.
+// Deliveries not yet sent are signed with the new secret.
+func (r *WebhookRepository) RotateSecret(ctx context.Context, userID, id int, secret string) (*models.WebhookSubscription, error) {
+	subscription, err := scanWebhookSubscription(r.db.QueryRowContext(ctx, `
+		UPDATE webhook_subscriptions SET secret = $1, updated_at = NOW()
+		WHERE id = $2 AND user_id = $3
+		RETURNING `+webhookSubscriptionColumns, secret, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrWebhookNotFound
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error rotating webhook secret", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	return subscription, nil
+}
+
+// DeleteSubscription removes a subscription together with its delivery log.
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, userID, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		utils.LogError(ctx, "Error deleting webhook subscription", err, utils.UserID(userID))
+		return utils.ErrDatabaseError
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return utils.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// ListDeliveries returns the delivery log of one of userID's subscriptions,
+// newest first.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, userID, subscriptionID int, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	if _, err := r.GetSubscription(ctx, userID, subscriptionID); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, subscription_id, event_id, event_type, payload, status, attempts,
+		       CASE WHEN status IN ('pending', 'delivering') THEN next_attempt_at END,
+		       last_attempt_at, last_status_code, last_error, delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3`, subscriptionID, filter.Status, filter.Limit)
+	if err != nil {
+		utils.LogError(ctx, "Error listing webhook deliveries", err, utils.UserID(userID))
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var payload []byte
+		var statusCode sql.NullInt64
+		var lastError sql.NullString
+		if err := rows.Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.EventID, &delivery.EventType,
+			&payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastAttemptAt,
+			&statusCode, &lastError, &delivery.DeliveredAt, &delivery.CreatedAt); err != nil {
+			utils.LogError(ctx, "Error scanning webhook delivery", err, utils.UserID(userID))
+			return nil, utils.ErrDatabaseError
+		}
+		delivery.Payload = payload
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			delivery.LastStatusCode = &code
+		}
+		delivery.LastError = nullStringPointer(lastError)
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return deliveries, nil
+}
+
+// enqueueWebhookEvent queues eventType for every active subscription of
+// userID that selected it. Callers pass the transaction making the change, so
+// the event is queued exactly when the change commits and a failure here rolls
+// the change back.
+func enqueueWebhookEvent(ctx context.Context, db dbExecutor, userID int, eventType string, data interface{}) error {
+	eventID, err := newOperationID()
+	if err != nil {
+		return utils.ErrProcessingFailed
+	}
+	event := models.WebhookEvent{
+		ID:         "evt_" + eventID,
+		Type:       eventType,
+		UserID:     userID,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+	payload, err := utils.MarshalJSON(event)
+	if err != nil {
+		utils.LogError(ctx, "Error encoding webhook event", err, utils.UserID(userID))
+		return utils.ErrProcessingFailed
+	}
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, user_id, event_id, event_type, payload)
+		SELECT id, user_id, $2, $3, $4 FROM webhook_subscriptions
+		WHERE user_id = $1 AND active AND $3 = ANY(events)`,
+		userID, event.ID, eventType, payload); err != nil {
+		utils.LogError(ctx, "Error enqueueing webhook event", err, utils.UserID(userID))
+		return utils.ErrDatabaseError
+	}
+	return nil
+}
+
+// ClaimDueDeliveries marks up to limit due deliveries of active subscriptions
+// as delivering and returns them. A claim lasts for lease: a delivery whose
+// dispatcher stops before recording an attempt becomes due again afterwards.
+// SKIP LOCKED lets several server instances drain the outbox together.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingWebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE webhook_deliveries d
+		SET status = 'delivering', next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT wd.id FROM webhook_deliveries wd
+			JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id
+			WHERE wd.status IN ('pending', 'delivering') AND wd.next_attempt_at <= NOW() AND ws.active
+			ORDER BY wd.next_attempt_at, wd.id
+			LIMIT $1
+			FOR UPDATE OF wd SKIP LOCKED)
+		RETURNING d.id, d.event_id, d.event_type, d.payload, d.attempts, s.url, s.secret`,
+		limit, lease.Seconds())
+	if err != nil {
+		utils.LogError(ctx, "Error claiming webhook deliveries", err)
+		return nil, utils.ErrDatabaseError
+	}
+	defer rows.Close()
+
+	deliveries := []models.PendingWebhookDelivery{}
+	for rows.Next() {
+		var delivery models.PendingWebhookDelivery
+		if err := rows.Scan(&delivery.ID, &delivery.EventID, &delivery.EventType, &delivery.Payload,
+			&delivery.Attempts, &delivery.URL, &delivery.Secret); err != nil {
+			utils.LogError(ctx, "Error scanning claimed webhook delivery", err)
+			return nil, utils.ErrDatabaseError
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, utils.ErrDatabaseError
+	}
+	return deliveries, nil
+}
+
+// RecordDeliveryAttempt stores the outcome of one attempt. Failed attempts
+// with a next attempt time go back to pending; those without one fail for
+// good.
+func (r *WebhookRepository) RecordDeliveryAttempt(ctx context.Context, id int64, attempt models.WebhookAttempt) error {
+	status := "failed"
+	switch {
+	case attempt.Succeeded:
+		status = "succeeded"
+	case attempt.NextAttemptAt != nil:
+		status = "pending"
+	}
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, last_attempt_at = NOW(),
+		    last_status_code = $3, last_error = $4,
+		    next_attempt_at = COALESCE($5, next_attempt_at),
+		    delivered_at = CASE WHEN $2 = 'succeeded' THEN NOW() END
+		WHERE id = $1`,
+		id, status, attempt.StatusCode, attempt.Error, attempt.NextAttemptAt); err != nil {
+		utils.LogError(ctx, "Error recording webhook delivery attempt", err)
+		return utils.ErrDatabaseError
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"divelog-backend/models"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openWebhookTestDB(t *testing.T, testDriver *deleteAllTestDriver) *sql.DB {
+	driverName := fmt.Sprintf("webhook-events-%s-%d", t.Name(), time.Now().UnixNano())
+	sql.Register(driverName, testDriver)
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+	return db
+}
+
+func TestEnqueueWebhookEventWritesEnvelope(t *testing.T) {
+	testDriver := &deleteAllTestDriver{result: driver.RowsAffected(1)}
+	db := openWebhookTestDB(t, testDriver)
+
+	require.NoError(t, enqueueWebhookEvent(context.Background(), db, 4, models.WebhookEventDiveDeleted, models.DeletedDive{ID: 12}))
+
+	assert.Contains(t, testDriver.query, "INSERT INTO webhook_deliveries")
+	require.Len(t, testDriver.args, 4)
+	assert.Equal(t, int64(4), testDriver.args[0].Value)
+	assert.Equal(t, models.WebhookEventDiveDeleted, testDriver.args[2].Value)
+	var event struct {
+		ID         string    `json:"id"`
+		Type       string    `json:"type"`
+		UserID     int       `json:"user_id"`
+		OccurredAt time.Time `json:"occurred_at"`
+		Data       struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(testDriver.args[3].Value.([]byte), &event))
+	assert.Regexp(t, `^evt_[0-9a-f]{32}$`, event.ID)
+	assert.Equal(t, testDriver.args[1].Value, event.ID)
+	assert.Equal(t, models.WebhookEventDiveDeleted, event.Type)
+	assert.Equal(t, 4, event.UserID)
+	assert.WithinDuration(t, time.Now(), event.OccurredAt, time.Minute)
+	assert.Equal(t, 12, event.Data.ID)
+}
+
+func TestLogbookRepositoryRenumberQueuesEventInSameTransaction(t *testing.T) {
+	testDriver := &deleteAllTestDriver{result: driver.RowsAffected(3)}
+	db := openWebhookTestDB(t, testDriver)
+
+	count, err := NewLogbookRepository(db).RenumberDives(context.Background(), 4,
+		models.RenumberDivesRequest{Scope: "all", StartNumber: 1, Increment: 1})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	require.Len(t, testDriver.queries, 2)
+	assert.Contains(t, testDriver.queries[0], "UPDATE dives d SET dive_number")
+	assert.Contains(t, testDriver.queries[1], "INSERT INTO webhook_deliveries")
+	assert.True(t, testDriver.committed)
+}
+
+func TestLogbookRepositoryRenumberSkipsEventWhenNothingChanged(t *testing.T) {
+	testDriver := &deleteAllTestDriver{result: driver.RowsAffected(0)}
+	db := openWebhookTestDB(t, testDriver)
+
+	count, err := NewLogbookRepository(db).RenumberDives(context.Background(), 4,
+		models.RenumberDivesRequest{Scope: "all", StartNumber: 1, Increment: 1})
+
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	require.Len(t, testDriver.queries, 1)
+	assert.NotContains(t, testDriver.queries[0], "webhook_deliveries")
+}
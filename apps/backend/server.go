@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"divelog-backend/config"
+	"divelog-backend/utils"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// newHTTPServer applies the configured timeouts to handler. Read and write
+// timeouts stop slow clients from holding connections; the write timeout
+// also caps how long a batch import or restore may run.
+func newHTTPServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.Server.ReadTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+}
+
+// serve runs server until ctx is cancelled, then calls drain and waits up to
+// cfg.Server.ShutdownTimeout for in-flight requests to finish. It returns once no
+// handler is running any more, so the caller can close the database safely.
+func serve(ctx context.Context, cfg *config.Config, server *http.Server, drain func()) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	utils.LogInfo(nil, "Shutting down; draining in-flight requests",
+		slog.Duration("timeout", cfg.Server.ShutdownTimeout))
+	drain()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// Requests still running past the deadline are cut off
+		server.Close()
+		return err
+	}
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
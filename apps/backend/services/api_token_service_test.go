@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAPITokenRepository struct {
+	mock.Mock
+}
+
+func (m *mockAPITokenRepository) ListTokens(ctx context.Context, userID int) ([]models.APIToken, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.APIToken), args.Error(1)
+}
+func (m *mockAPITokenRepository) CreateToken(ctx context.Context, userID int, request models.APITokenRequest, hash, prefix string, expiresAt time.Time) (*models.APIToken, error) {
+	args := m.Called(ctx, userID, request, hash, prefix, expiresAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIToken), args.Error(1)
+}
+func (m *mockAPITokenRepository) DeleteToken(ctx context.Context, userID, id int) error {
+	return m.Called(ctx, userID, id).Error(0)
+}
+func (m *mockAPITokenRepository) Authenticate(ctx context.Context, hash string) (*models.APIToken, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.APIToken), args.Error(1)
+}
+
+func TestAPITokenServiceCreateStoresOnlyTheHash(t *testing.T) {
+	repository := new(mockAPITokenRepository)
+	service := NewAPITokenService(repository)
+	now := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	days := 30
+	request := models.APITokenRequest{Name: "Nightly export", Scopes: []string{models.ScopeExport}, ExpiresInDays: &days}
+	var hash, prefix string
+	repository.On("CreateToken", mock.Anything, 4, request, mock.Anything, mock.Anything, now.AddDate(0, 0, 30)).
+		Run(func(args mock.Arguments) {
+			hash = args.String(3)
+			prefix = args.String(4)
+		}).Return(&models.APIToken{ID: 2, Name: request.Name}, nil).Once()
+
+	token, err := service.Create(context.Background(), 4, request)
+
+	require.NoError(t, err)
+	assert.Regexp(t, `^dlp_[0-9a-f]{64}$`, token.Token)
+	sum := sha256.Sum256([]byte(token.Token))
+	assert.Equal(t, hex.EncodeToString(sum[:]), hash)
+	assert.Equal(t, token.Token[:12], prefix)
+	repository.AssertExpectations(t)
+}
+
+func TestAPITokenServiceAuthenticateLooksUpHash(t *testing.T) {
+	repository := new(mockAPITokenRepository)
+	service := NewAPITokenService(repository)
+	secret := "dlp_" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	sum := sha256.Sum256([]byte(secret))
+	repository.On("Authenticate", mock.Anything, hex.EncodeToString(sum[:])).
+		Return(&models.APIToken{ID: 2, UserID: 4}, nil).Once()
+
+	token, err := service.Authenticate(context.Background(), secret)
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, token.UserID)
+	repository.AssertExpectations(t)
+}
+
+func TestAPITokenServiceAuthenticateRejectsMalformedTokens(t *testing.T) {
+	repository := new(mockAPITokenRepository)
+	service := NewAPITokenService(repository)
+
+	for _, secret := range []string{"", "dlp_", "whsec_0123456789abcdef"} {
+		_, err := service.Authenticate(context.Background(), secret)
+		assert.ErrorIs(t, err, utils.ErrInvalidAPIToken, secret)
+	}
+	repository.AssertNotCalled(t, "Authenticate", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/metrics"
+	"divelog-backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BackupRepository is the persistence contract for logbook data that the dive
+// and dive-site repositories do not cover.
+type BackupRepository interface {
+	ExportSettings(context.Context, int) (*models.SettingsRequest, error)
+	ExportDiveSites(context.Context, int) ([]models.BackupDiveSite, error)
+	ExportTrips(context.Context, int) ([]models.BackupTrip, error)
+	ExportCertifications(context.Context, int) ([]models.BackupCertification, error)
+	ExportTags(context.Context, int) ([]string, error)
+	ExportBulkOperations(context.Context, int) ([]models.BackupBulkOperation, error)
+	ExportSightings(context.Context, int) (map[int][]models.BackupSighting, error)
+	ExportDives(context.Context, int, func(models.BackupDive) error) error
+	DeleteUserData(context.Context, int) (int, int, int, int, error)
+	UpsertSettings(context.Context, int, models.SettingsRequest) (bool, error)
+	UpsertTrip(context.Context, int, models.TripRequest) (int, bool, error)
+	UpsertCertification(context.Context, int, models.CertificationRequest) (int, bool, error)
+	UpsertTag(context.Context, int, string) (bool, error)
+	InsertBulkOperation(context.Context, int, models.BackupBulkOperation) (bool, error)
+	UpsertSpecies(context.Context, models.SpeciesRequest) (int, bool, error)
+	InsertSighting(context.Context, int, int, models.BackupSighting) (bool, error)
+}
+
+// BackupTransactor supplies transaction-bound repositories to export and
+// restore so each runs against a single consistent snapshot.
+type BackupTransactor interface {
+	WithinBackupTransaction(context.Context, func(BackupRepository, DiveRepository, DiveSiteRepository) error) error
+}
+
+// BackupSink receives an archive as it is read. WriteHeader is called once with
+// every section except dives, then WriteDive once per dive, so large logbooks
+// can be streamed to the client.
+type BackupSink interface {
+	WriteHeader(models.BackupArchive) error
+	WriteDive(models.BackupDive) error
+}
+
+// errRestoreDryRun rolls back a dry-run restore after its summary is built.
+var errRestoreDryRun = errors.New("restore dry run")
+
+type BackupService struct {
+	transactor BackupTransactor
+	geocoder   ReverseGeocoder
+}
+
+func NewBackupService(transactor BackupTransactor, geocoder ReverseGeocoder) *BackupService {
+	return &BackupService{transactor: transactor, geocoder: geocoder}
+}
+
+// Export writes everything the user owns to sink.
+func (s *BackupService) Export(ctx context.Context, userID int, sink BackupSink) error {
+	return s.transactor.WithinBackupTransaction(ctx, func(backups BackupRepository, _ DiveRepository, _ DiveSiteRepository) error {
+		archive := models.BackupArchive{Format: models.BackupFormat, Version: models.BackupVersion, CreatedAt: time.Now().UTC()}
+		var err error
+		if archive.Settings, err = backups.ExportSettings(ctx, userID); err != nil {
+			return err
+		}
+		if archive.DiveSites, err = backups.ExportDiveSites(ctx, userID); err != nil {
+			return err
+		}
+		if archive.Trips, err = backups.ExportTrips(ctx, userID); err != nil {
+			return err
+		}
+		if archive.Certifications, err = backups.ExportCertifications(ctx, userID); err != nil {
+			return err
+		}
+		if archive.Tags, err = backups.ExportTags(ctx, userID); err != nil {
+			return err
+		}
+		if archive.BulkOperations, err = backups.ExportBulkOperations(ctx, userID); err != nil {
+			return err
+		}
+		sightings, err := backups.ExportSightings(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := sink.WriteHeader(archive); err != nil {
+			return err
+		}
+		return backups.ExportDives(ctx, userID, func(dive models.BackupDive) error {
+			dive.Sightings = sightings[dive.ID]
+			return sink.WriteDive(dive)
+		})
+	})
+}
+
+// Restore applies a validated archive. Merge keeps existing data and skips
+// dives that already exist at the same site and time; replace first removes the
+// user's dives, trips, tags and bulk-operation history but still merges
+// certifications, so their card images survive. A dry run performs the
+// same work and rolls it back, so the summary matches what a real restore does.
+func (s *BackupService) Restore(ctx context.Context, userID int, archive *models.BackupArchive, options models.RestoreOptions) (*models.RestoreSummary, error) {
+	var summary *models.RestoreSummary
+	err := s.transactor.WithinBackupTransaction(ctx, func(backups BackupRepository, dives DiveRepository, sites DiveSiteRepository) error {
+		summary = &models.RestoreSummary{Mode: options.Mode, DryRun: options.DryRun}
+		if err := restoreArchive(ctx, userID, archive, options.Mode, summary, backups, dives, sites, s.geocoder); err != nil {
+			return err
+		}
+		if options.DryRun {
+			return errRestoreDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errRestoreDryRun) {
+		return nil, err
+	}
+	if !options.DryRun {
+		metrics.DivesCreated.Add(float64(summary.Dives.Created), "restore")
+		metrics.ImportDuplicatesSkipped.Add(float64(summary.Dives.Skipped), "restore")
+	}
+	return summary, nil
+}
+
+func restoreArchive(
+	ctx context.Context,
+	userID int,
+	archive *models.BackupArchive,
+	mode string,
+	summary *models.RestoreSummary,
+	backups BackupRepository,
+	dives DiveRepository,
+	sites DiveSiteRepository,
+	geocoder ReverseGeocoder,
+) error {
+	if mode == "replace" {
+		deletedDives, deletedTrips, deletedTags, deletedOperations, err := backups.DeleteUserData(ctx, userID)
+		if err != nil {
+			return err
+		}
+		summary.Dives.Deleted = deletedDives
+		summary.Trips.Deleted = deletedTrips
+		summary.Tags.Deleted = deletedTags
+		summary.BulkOperations.Deleted = deletedOperations
+	}
+
+	if archive.Settings != nil {
+		created, err := backups.UpsertSettings(ctx, userID, *archive.Settings)
+		if err != nil {
+			return err
+		}
+		countRestore(&summary.Settings, created)
+	}
+
+	siteIDs := make(map[int]int, len(archive.DiveSites))
+	for _, site := range archive.DiveSites {
+		existing, err := findNearbyDiveSite(ctx, sites, site.Name, site.Latitude, site.Longitude, 0)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			siteIDs[site.ID] = existing.ID
+			summary.DiveSites.Skipped++
+			continue
+		}
+		fillPlace(geocoder, &site.DiveSiteRequest)
+		created, err := sites.CreateDiveSite(ctx, &site.DiveSiteRequest)
+		if err != nil {
+			return err
+		}
+		siteIDs[site.ID] = created.ID
+		summary.DiveSites.Created++
+	}
+
+	tripIDs := make(map[int]int, len(archive.Trips))
+	for _, trip := range archive.Trips {
+		id, created, err := backups.UpsertTrip(ctx, userID, trip.TripRequest)
+		if err != nil {
+			return err
+		}
+		tripIDs[trip.ID] = id
+		countRestore(&summary.Trips, created)
+	}
+
+	certificationIDs := make(map[int]int, len(archive.Certifications))
+	for _, certification := range archive.Certifications {
+		id, created, err := backups.UpsertCertification(ctx, userID, certification.CertificationRequest)
+		if err != nil {
+			return err
+		}
+		certificationIDs[certification.ID] = id
+		countRestore(&summary.Certifications, created)
+	}
+
+	for _, tag := range archive.Tags {
+		created, err := backups.UpsertTag(ctx, userID, tag)
+		if err != nil {
+			return err
+		}
+		if created {
+			summary.Tags.Created++
+		} else {
+			summary.Tags.Skipped++
+		}
+	}
+
+	diveIDs := make(map[int]int, len(archive.Dives))
+	for _, entry := range archive.Dives {
+		request := entry.DiveRequest
+		request.Trip = nil
+		request.TripID = nil
+		if entry.TripID != nil {
+			tripID := tripIDs[*entry.TripID]
+			request.TripID = &tripID
+		}
+		if entry.CertificationID != nil {
+			certificationID := certificationIDs[*entry.CertificationID]
+			request.CertificationID = &certificationID
+		}
+
+		var siteID int
+		if entry.DiveSiteID != nil {
+			siteID = siteIDs[*entry.DiveSiteID]
+		} else {
+			site, err := findOrCreateDiveSite(ctx, sites, geocoder, request.Location, request.Lat, request.Lng)
+			if err != nil {
+				return err
+			}
+			siteID = site.ID
+		}
+
+		duplicate, err := dives.CheckDuplicateDive(ctx, userID, siteID, request.DateTime)
+		if err != nil {
+			return err
+		}
+		if duplicate {
+			summary.Dives.Skipped++
+			summary.Sightings.Skipped += len(entry.Sightings)
+			continue
+		}
+
+		dive := request.ToDive(userID)
+		dive.DiveSiteID = &siteID
+		dive.CreatedAt = entry.CreatedAt
+		dive.UpdatedAt = entry.UpdatedAt
+		if err := dives.CreateDive(ctx, dive); err != nil {
+			return err
+		}
+		diveIDs[entry.ID] = dive.ID
+		summary.Dives.Created++
+
+		for _, sighting := range entry.Sightings {
+			speciesID, created, err := backups.UpsertSpecies(ctx, sighting.SpeciesRequest)
+			if err != nil {
+				return err
+			}
+			if created {
+				summary.Species.Created++
+			}
+			inserted, err := backups.InsertSighting(ctx, dive.ID, speciesID, sighting)
+			if err != nil {
+				return err
+			}
+			if inserted {
+				summary.Sightings.Created++
+			} else {
+				summary.Sightings.Skipped++
+			}
+		}
+	}
+
+	for _, operation := range archive.BulkOperations {
+		beforeState, ok := remapBulkOperationDives(operation.BeforeState, diveIDs)
+		if !ok {
+			summary.BulkOperations.Skipped++
+			continue
+		}
+		operation.BeforeState = beforeState
+		created, err := backups.InsertBulkOperation(ctx, userID, operation)
+		if err != nil {
+			return err
+		}
+		if created {
+			summary.BulkOperations.Created++
+		} else {
+			summary.BulkOperations.Skipped++
+		}
+	}
+	return nil
+}
+
+func countRestore(counts *models.RestoreCounts, created bool) {
+	if created {
+		counts.Created++
+	} else {
+		counts.Updated++
+	}
+}
+
+// remapBulkOperationDives rewrites archive dive IDs in a before_state list to
+// the restored IDs. It reports false when any dive was not restored, because
+// undoing such an operation would touch the wrong rows.
+func remapBulkOperationDives(beforeState json.RawMessage, diveIDs map[int]int) (json.RawMessage, bool) {
+	var states []map[string]json.RawMessage
+	if err := json.Unmarshal(beforeState, &states); err != nil {
+		return nil, false
+	}
+	for _, state := range states {
+		var archiveID int
+		if err := json.Unmarshal(state["id"], &archiveID); err != nil {
+			return nil, false
+		}
+		restoredID, ok := diveIDs[archiveID]
+		if !ok {
+			return nil, false
+		}
+		state["id"] = json.RawMessage(fmt.Sprint(restoredID))
+	}
+	remapped, err := json.Marshal(states)
+	if err != nil {
+		return nil, false
+	}
+	return remapped, true
+}
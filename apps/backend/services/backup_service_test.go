@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockBackupRepository struct{ mock.Mock }
+
+func (m *mockBackupRepository) ExportSettings(ctx context.Context, userID int) (*models.SettingsRequest, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SettingsRequest), args.Error(1)
+}
+func (m *mockBackupRepository) ExportDiveSites(ctx context.Context, userID int) ([]models.BackupDiveSite, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.BackupDiveSite), args.Error(1)
+}
+func (m *mockBackupRepository) ExportTrips(ctx context.Context, userID int) ([]models.BackupTrip, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.BackupTrip), args.Error(1)
+}
+func (m *mockBackupRepository) ExportCertifications(ctx context.Context, userID int) ([]models.BackupCertification, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.BackupCertification), args.Error(1)
+}
+func (m *mockBackupRepository) ExportTags(ctx context.Context, userID int) ([]string, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]string), args.Error(1)
+}
+func (m *mockBackupRepository) ExportBulkOperations(ctx context.Context, userID int) ([]models.BackupBulkOperation, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.BackupBulkOperation), args.Error(1)
+}
+func (m *mockBackupRepository) ExportSightings(ctx context.Context, userID int) (map[int][]models.BackupSighting, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(map[int][]models.BackupSighting), args.Error(1)
+}
+func (m *mockBackupRepository) ExportDives(ctx context.Context, userID int, emit func(models.BackupDive) error) error {
+	args := m.Called(ctx, userID)
+	for _, dive := range args.Get(0).([]models.BackupDive) {
+		if err := emit(dive); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+func (m *mockBackupRepository) DeleteUserData(ctx context.Context, userID int) (int, int, int, int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Int(1), args.Int(2), args.Int(3), args.Error(4)
+}
+func (m *mockBackupRepository) UpsertSettings(ctx context.Context, userID int, request models.SettingsRequest) (bool, error) {
+	args := m.Called(ctx, userID, request)
+	return args.Bool(0), args.Error(1)
+}
+func (m *mockBackupRepository) UpsertTrip(ctx context.Context, userID int, request models.TripRequest) (int, bool, error) {
+	args := m.Called(ctx, userID, request)
+	return args.Int(0), args.Bool(1), args.Error(2)
+}
+func (m *mockBackupRepository) UpsertCertification(ctx context.Context, userID int, request models.CertificationRequest) (int, bool, error) {
+	args := m.Called(ctx, userID, request)
+	return args.Int(0), args.Bool(1), args.Error(2)
+}
+func (m *mockBackupRepository) UpsertTag(ctx context.Context, userID int, name string) (bool, error) {
+	args := m.Called(ctx, userID, name)
+	return args.Bool(0), args.Error(1)
+}
+func (m *mockBackupRepository) InsertBulkOperation(ctx context.Context, userID int, operation models.BackupBulkOperation) (bool, error) {
+	args := m.Called(ctx, userID, operation)
+	return args.Bool(0), args.Error(1)
+}
+func (m *mockBackupRepository) UpsertSpecies(ctx context.Context, request models.SpeciesRequest) (int, bool, error) {
+	args := m.Called(ctx, request)
+	return args.Int(0), args.Bool(1), args.Error(2)
+}
+func (m *mockBackupRepository) InsertSighting(ctx context.Context, diveID, speciesID int, sighting models.BackupSighting) (bool, error) {
+	args := m.Called(ctx, diveID, speciesID, sighting)
+	return args.Bool(0), args.Error(1)
+}
+
+type recordingBackupTransactor struct {
+	backups BackupRepository
+	dives   DiveRepository
+	sites   DiveSiteRepository
+	err     error
+}
+
+func (t *recordingBackupTransactor) WithinBackupTransaction(ctx context.Context, operation func(BackupRepository, DiveRepository, DiveSiteRepository) error) error {
+	t.err = operation(t.backups, t.dives, t.sites)
+	return t.err
+}
+
+type recordingBackupSink struct {
+	header models.BackupArchive
+	dives  []models.BackupDive
+}
+
+func (s *recordingBackupSink) WriteHeader(archive models.BackupArchive) error {
+	s.header = archive
+	return nil
+}
+func (s *recordingBackupSink) WriteDive(dive models.BackupDive) error {
+	s.dives = append(s.dives, dive)
+	return nil
+}
+
+func newBackupTestHarness() (*BackupService, *mockBackupRepository, *mockDiveRepository, *mockDiveSiteRepository, *recordingBackupTransactor) {
+	backups := new(mockBackupRepository)
+	dives := new(mockDiveRepository)
+	sites := new(mockDiveSiteRepository)
+	tx := &recordingBackupTransactor{backups: backups, dives: dives, sites: sites}
+	return NewBackupService(tx, nil), backups, dives, sites, tx
+}
+
+func backupTestArchive() *models.BackupArchive {
+	siteID, tripID := 5, 9
+	dive := models.BackupDive{ID: 100, DiveSiteID: &siteID, DiveRequest: serviceTestRequest()}
+	dive.TripID = &tripID
+	return &models.BackupArchive{
+		Format: models.BackupFormat, Version: models.BackupVersion,
+		DiveSites: []models.BackupDiveSite{{ID: siteID, DiveSiteRequest: models.DiveSiteRequest{
+			Name: "Monterey Bay", Latitude: 36.6002, Longitude: -121.8947,
+		}}},
+		Trips: []models.BackupTrip{{ID: tripID, TripRequest: models.TripRequest{Name: "California"}}},
+		Tags:  []string{"kelp"},
+		Dives: []models.BackupDive{dive},
+		BulkOperations: []models.BackupBulkOperation{{
+			ID: "0123456789abcdef0123456789abcdef", OperationType: "timestamp_shift",
+			BeforeState: json.RawMessage(`[{"id":100,"datetime":"2026-08-10T09:30:00"}]`), AffectedCount: 1,
+		}},
+	}
+}
+
+func TestBackupServiceExportWritesHeaderThenDives(t *testing.T) {
+	service, backups, _, _, _ := newBackupTestHarness()
+	backups.On("ExportSettings", mock.Anything, 42).Return(nil, nil).Once()
+	backups.On("ExportDiveSites", mock.Anything, 42).Return([]models.BackupDiveSite{{ID: 1}}, nil).Once()
+	backups.On("ExportTrips", mock.Anything, 42).Return([]models.BackupTrip{}, nil).Once()
+	backups.On("ExportCertifications", mock.Anything, 42).Return([]models.BackupCertification{}, nil).Once()
+	backups.On("ExportTags", mock.Anything, 42).Return([]string{"night"}, nil).Once()
+	backups.On("ExportBulkOperations", mock.Anything, 42).Return([]models.BackupBulkOperation{}, nil).Once()
+	backups.On("ExportDives", mock.Anything, 42).Return([]models.BackupDive{{ID: 3}, {ID: 4}}, nil).Once()
+	sighting := models.BackupSighting{SpeciesRequest: models.SpeciesRequest{CommonName: "Garibaldi", Category: "fish"}, Count: 3}
+	backups.On("ExportSightings", mock.Anything, 42).Return(map[int][]models.BackupSighting{4: {sighting}}, nil).Once()
+	sink := new(recordingBackupSink)
+
+	require.NoError(t, service.Export(context.Background(), 42, sink))
+
+	assert.Equal(t, models.BackupFormat, sink.header.Format)
+	assert.Equal(t, []string{"night"}, sink.header.Tags)
+	assert.Len(t, sink.dives, 2)
+	assert.Empty(t, sink.dives[0].Sightings)
+	assert.Equal(t, []models.BackupSighting{sighting}, sink.dives[1].Sightings)
+	backups.AssertExpectations(t)
+}
+
+func TestBackupServiceRestoreMergeRemapsArchiveIDs(t *testing.T) {
+	service, backups, dives, sites, _ := newBackupTestHarness()
+	archive := backupTestArchive()
+	sites.On("FindDiveSitesByName", mock.Anything, "Monterey Bay").Return([]models.DiveSite{{ID: 71, Name: "Monterey Bay", Latitude: 36.6002, Longitude: -121.8947}}, nil).Once()
+	backups.On("UpsertTrip", mock.Anything, 42, archive.Trips[0].TripRequest).Return(31, true, nil).Once()
+	backups.On("UpsertTag", mock.Anything, 42, "kelp").Return(false, nil).Once()
+	dives.On("CheckDuplicateDive", mock.Anything, 42, 71, archive.Dives[0].DateTime).Return(false, nil).Once()
+	dives.On("CreateDive", mock.Anything, mock.MatchedBy(func(dive *models.Dive) bool {
+		return *dive.DiveSiteID == 71 && *dive.TripID == 31
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Dive).ID = 900
+	}).Return(nil).Once()
+	backups.On("InsertBulkOperation", mock.Anything, 42, mock.MatchedBy(func(operation models.BackupBulkOperation) bool {
+		return string(operation.BeforeState) == `[{"datetime":"2026-08-10T09:30:00","id":900}]`
+	})).Return(true, nil).Once()
+
+	summary, err := service.Restore(context.Background(), 42, archive, models.RestoreOptions{Mode: "merge"})
+
+	require.NoError(t, err)
+	assert.Equal(t, models.RestoreCounts{Skipped: 1}, summary.DiveSites)
+	assert.Equal(t, models.RestoreCounts{Created: 1}, summary.Trips)
+	assert.Equal(t, models.RestoreCounts{Skipped: 1}, summary.Tags)
+	assert.Equal(t, models.RestoreCounts{Created: 1}, summary.Dives)
+	assert.Equal(t, models.RestoreCounts{Created: 1}, summary.BulkOperations)
+	backups.AssertNotCalled(t, "DeleteUserData", mock.Anything, mock.Anything)
+	backups.AssertExpectations(t)
+	dives.AssertExpectations(t)
+}
+
+func TestBackupServiceRestoreSkipsHistoryForDuplicateDives(t *testing.T) {
+	service, backups, dives, sites, _ := newBackupTestHarness()
+	archive := backupTestArchive()
+	sites.On("FindDiveSitesByName", mock.Anything, "Monterey Bay").Return([]models.DiveSite{}, nil).Once()
+	sites.On("CreateDiveSite", mock.Anything, &archive.DiveSites[0].DiveSiteRequest).Return(&models.DiveSite{ID: 72}, nil).Once()
+	backups.On("UpsertTrip", mock.Anything, 42, mock.Anything).Return(31, false, nil).Once()
+	backups.On("UpsertTag", mock.Anything, 42, "kelp").Return(true, nil).Once()
+	dives.On("CheckDuplicateDive", mock.Anything, 42, 72, archive.Dives[0].DateTime).Return(true, nil).Once()
+
+	summary, err := service.Restore(context.Background(), 42, archive, models.RestoreOptions{Mode: "merge"})
+
+	require.NoError(t, err)
+	assert.Equal(t, models.RestoreCounts{Created: 1}, summary.DiveSites)
+	assert.Equal(t, models.RestoreCounts{Updated: 1}, summary.Trips)
+	assert.Equal(t, models.RestoreCounts{Skipped: 1}, summary.Dives)
+	assert.Equal(t, models.RestoreCounts{Skipped: 1}, summary.BulkOperations)
+	dives.AssertNotCalled(t, "CreateDive", mock.Anything, mock.Anything)
+	backups.AssertNotCalled(t, "InsertBulkOperation", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBackupServiceRestoreDryRunRollsBackReplace(t *testing.T) {
+	service, backups, _, _, tx := newBackupTestHarness()
+	archive := &models.BackupArchive{Format: models.BackupFormat, Version: models.BackupVersion, Tags: []string{"wreck"}}
+	backups.On("DeleteUserData", mock.Anything, 42).Return(12, 2, 3, 1, nil).Once()
+	backups.On("UpsertTag", mock.Anything, 42, "wreck").Return(true, nil).Once()
+
+	summary, err := service.Restore(context.Background(), 42, archive, models.RestoreOptions{Mode: "replace", DryRun: true})
+
+	require.NoError(t, err)
+	assert.True(t, errors.Is(tx.err, errRestoreDryRun), "dry run must fail the transaction so it rolls back")
+	assert.True(t, summary.DryRun)
+	assert.Equal(t, models.RestoreCounts{Deleted: 12}, summary.Dives)
+	assert.Equal(t, models.RestoreCounts{Created: 1, Deleted: 3}, summary.Tags)
+	backups.AssertExpectations(t)
+}
+
+func TestBackupServiceRestoreMatchesSightingSpecies(t *testing.T) {
+	service, backups, dives, sites, _ := newBackupTestHarness()
+	archive := backupTestArchive()
+	archive.BulkOperations = nil
+	garibaldi := models.BackupSighting{SpeciesRequest: models.SpeciesRequest{CommonName: "Garibaldi", Category: "fish"}, Count: 3}
+	custom := models.BackupSighting{SpeciesRequest: models.SpeciesRequest{CommonName: "Kelp nudibranch", Category: "mollusc"}, Count: 1}
+	archive.Dives[0].Sightings = []models.BackupSighting{garibaldi, custom}
+	sites.On("FindDiveSitesByName", mock.Anything, "Monterey Bay").Return([]models.DiveSite{{ID: 71, Name: "Monterey Bay", Latitude: 36.6002, Longitude: -121.8947}}, nil).Once()
+	backups.On("UpsertTrip", mock.Anything, 42, mock.Anything).Return(31, true, nil).Once()
+	backups.On("UpsertTag", mock.Anything, 42, "kelp").Return(false, nil).Once()
+	dives.On("CheckDuplicateDive", mock.Anything, 42, 71, archive.Dives[0].DateTime).Return(false, nil).Once()
+	dives.On("CreateDive", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Dive).ID = 900
+	}).Return(nil).Once()
+	backups.On("UpsertSpecies", mock.Anything, garibaldi.SpeciesRequest).Return(52, false, nil).Once()
+	backups.On("UpsertSpecies", mock.Anything, custom.SpeciesRequest).Return(80, true, nil).Once()
+	backups.On("InsertSighting", mock.Anything, 900, 52, garibaldi).Return(true, nil).Once()
+	backups.On("InsertSighting", mock.Anything, 900, 80, custom).Return(true, nil).Once()
+
+	summary, err := service.Restore(context.Background(), 42, archive, models.RestoreOptions{Mode: "merge"})
+
+	require.NoError(t, err)
+	assert.Equal(t, models.RestoreCounts{Created: 1}, summary.Species)
+	assert.Equal(t, models.RestoreCounts{Created: 2}, summary.Sightings)
+	backups.AssertExpectations(t)
+}
+
+func TestBackupServiceRestoreRemapsCertificationLinks(t *testing.T) {
+	service, backups, dives, sites, _ := newBackupTestHarness()
+	archive := backupTestArchive()
+	archive.BulkOperations = nil
+	course := models.BackupCertification{ID: 4, CertificationRequest: models.CertificationRequest{Agency: "PADI", Level: "Advanced Open Water"}}
+	archive.Certifications = []models.BackupCertification{course}
+	training := "training"
+	archive.Dives[0].DiveType = &training
+	archive.Dives[0].CertificationID = &course.ID
+	sites.On("FindDiveSitesByName", mock.Anything, "Monterey Bay").Return([]models.DiveSite{{ID: 71, Name: "Monterey Bay", Latitude: 36.6002, Longitude: -121.8947}}, nil).Once()
+	backups.On("UpsertTrip", mock.Anything, 42, mock.Anything).Return(31, true, nil).Once()
+	backups.On("UpsertCertification", mock.Anything, 42, course.CertificationRequest).Return(17, true, nil).Once()
+	backups.On("UpsertTag", mock.Anything, 42, "kelp").Return(false, nil).Once()
+	dives.On("CheckDuplicateDive", mock.Anything, 42, 71, archive.Dives[0].DateTime).Return(false, nil).Once()
+	dives.On("CreateDive", mock.Anything, mock.MatchedBy(func(dive *models.Dive) bool {
+		return dive.CertificationID != nil && *dive.CertificationID == 17
+	})).Return(nil).Once()
+
+	summary, err := service.Restore(context.Background(), 42, archive, models.RestoreOptions{Mode: "merge"})
+
+	require.NoError(t, err)
+	assert.Equal(t, models.RestoreCounts{Created: 1}, summary.Certifications)
+	backups.AssertExpectations(t)
+	dives.AssertExpectations(t)
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"divelog-backend/media"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// cardImageExtensions lists the accepted card image types, detected from the
+// uploaded bytes rather than the client's Content-Type header.
+var cardImageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// CertificationRepository is the persistence contract used by
+// CertificationService.
+type CertificationRepository interface {
+	List(context.Context, int) ([]models.Certification, error)
+	Get(context.Context, int, int) (*models.Certification, error)
+	Create(context.Context, int, models.CertificationRequest) (*models.Certification, error)
+	Update(context.Context, int, int, models.CertificationRequest) (*models.Certification, error)
+	Delete(context.Context, int, int) (*string, error)
+	CardImage(context.Context, int, int) (string, string, error)
+	SetCardImage(context.Context, int, int, *string, *string) (*string, error)
+	CountMatchingDives(context.Context, int, int, models.CertificationRequirement) (int, error)
+}
+
+type CertificationService struct {
+	repository CertificationRepository
+	store      media.Store
+}
+
+func NewCertificationService(repository CertificationRepository, store media.Store) *CertificationService {
+	return &CertificationService{repository: repository, store: store}
+}
+
+func (s *CertificationService) List(ctx context.Context, userID int) ([]models.Certification, error) {
+	return s.repository.List(ctx, userID)
+}
+
+func (s *CertificationService) Get(ctx context.Context, userID, id int) (*models.Certification, error) {
+	return s.repository.Get(ctx, userID, id)
+}
+
+func (s *CertificationService) Create(ctx context.Context, userID int, request models.CertificationRequest) (*models.Certification, error) {
+	return s.repository.Create(ctx, userID, request)
+}
+
+func (s *CertificationService) Update(ctx context.Context, userID, id int, request models.CertificationRequest) (*models.Certification, error) {
+	return s.repository.Update(ctx, userID, id, request)
+}
+
+// Delete removes a certification and then its card image.
+func (s *CertificationService) Delete(ctx context.Context, userID, id int) error {
+	cardKey, err := s.repository.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	s.removeCardImage(ctx, cardKey)
+	return nil
+}
+
+// PutCardImage stores image as the certification's card, replacing any
+// earlier picture. The type is detected from the image bytes.
+func (s *CertificationService) PutCardImage(ctx context.Context, userID, id int, image io.Reader) (*models.Certification, error) {
+	if _, err := s.repository.Get(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewReaderSize(image, 512)
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, utils.ErrProcessingFailed
+	}
+	contentType := http.DetectContentType(head)
+	extension, ok := cardImageExtensions[contentType]
+	if !ok {
+		return nil, utils.ErrUnsupportedImage
+	}
+
+	key, err := media.NewKey("certifications", extension)
+	if err != nil {
+		return nil, utils.ErrProcessingFailed
+	}
+	if err := s.store.Put(ctx, key, buffered); err != nil {
+		utils.LogError(ctx, "Error storing certification card image", err, utils.UserID(userID))
+		return nil, utils.ErrProcessingFailed
+	}
+	previous, err := s.repository.SetCardImage(ctx, userID, id, &key, &contentType)
+	if err != nil {
+		s.removeCardImage(ctx, &key)
+		return nil, err
+	}
+	s.removeCardImage(ctx, previous)
+	return s.repository.Get(ctx, userID, id)
+}
+
+// OpenCardImage returns the certification's card image and its content type.
+// The caller closes the reader.
+func (s *CertificationService) OpenCardImage(ctx context.Context, userID, id int) (io.ReadCloser, string, error) {
+	key, contentType, err := s.repository.CardImage(ctx, userID, id)
+	if err != nil {
+		return nil, "", err
+	}
+	file, err := s.store.Open(ctx, key)
+	if errors.Is(err, media.ErrNotFound) {
+		return nil, "", utils.ErrCardImageNotFound
+	}
+	if err != nil {
+		utils.LogError(ctx, "Error opening certification card image", err, utils.UserID(userID))
+		return nil, "", utils.ErrProcessingFailed
+	}
+	return file, contentType, nil
+}
+
+// DeleteCardImage removes the certification's card image.
+func (s *CertificationService) DeleteCardImage(ctx context.Context, userID, id int) error {
+	previous, err := s.repository.SetCardImage(ctx, userID, id, nil, nil)
+	if err != nil {
+		return err
+	}
+	if previous == nil {
+		return utils.ErrCardImageNotFound
+	}
+	s.removeCardImage(ctx, previous)
+	return nil
+}
+
+// Progress counts the user's logged dives against each of the certification's
+// prerequisites.
+func (s *CertificationService) Progress(ctx context.Context, userID, id int) (*models.CertificationProgress, error) {
+	certification, err := s.repository.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	progress := &models.CertificationProgress{
+		CertificationID:   certification.ID,
+		Agency:            certification.Agency,
+		Level:             certification.Level,
+		TrainingDiveCount: certification.TrainingDiveCount,
+		Requirements:      []models.RequirementProgress{},
+		Met:               true,
+	}
+	for _, requirement := range certification.Prerequisites {
+		logged, err := s.repository.CountMatchingDives(ctx, userID, certification.ID, requirement)
+		if err != nil {
+			return nil, err
+		}
+		entry := models.RequirementProgress{
+			CertificationRequirement: requirement,
+			Logged:                   logged,
+			Remaining:                max(requirement.MinDives-logged, 0),
+			Met:                      logged >= requirement.MinDives,
+		}
+		progress.Met = progress.Met && entry.Met
+		progress.Requirements = append(progress.Requirements, entry)
+	}
+	return progress, nil
+}
+
+// removeCardImage deletes a replaced or orphaned image. Failures are only
+// logged because the database no longer references the file.
+func (s *CertificationService) removeCardImage(ctx context.Context, key *string) {
+	if key == nil {
+		return
+	}
+	if err := s.store.Delete(ctx, *key); err != nil {
+		utils.LogWarn(ctx, "Failed to delete certification card image", slog.String("key", *key), slog.String("error", err.Error()))
+	}
+}
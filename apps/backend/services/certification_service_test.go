@@ -0,0 +1,198 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"divelog-backend/media"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockCertificationRepository struct {
+	mock.Mock
+}
+
+func (m *mockCertificationRepository) List(ctx context.Context, userID int) ([]models.Certification, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.Certification), args.Error(1)
+}
+func (m *mockCertificationRepository) Get(ctx context.Context, userID, id int) (*models.Certification, error) {
+	args := m.Called(ctx, userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Certification), args.Error(1)
+}
+func (m *mockCertificationRepository) Create(ctx context.Context, userID int, request models.CertificationRequest) (*models.Certification, error) {
+	args := m.Called(ctx, userID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Certification), args.Error(1)
+}
+func (m *mockCertificationRepository) Update(ctx context.Context, userID, id int, request models.CertificationRequest) (*models.Certification, error) {
+	args := m.Called(ctx, userID, id, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Certification), args.Error(1)
+}
+func (m *mockCertificationRepository) Delete(ctx context.Context, userID, id int) (*string, error) {
+	args := m.Called(ctx, userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*string), args.Error(1)
+}
+func (m *mockCertificationRepository) CardImage(ctx context.Context, userID, id int) (string, string, error) {
+	args := m.Called(ctx, userID, id)
+	return args.String(0), args.String(1), args.Error(2)
+}
+func (m *mockCertificationRepository) SetCardImage(ctx context.Context, userID, id int, key, contentType *string) (*string, error) {
+	args := m.Called(ctx, userID, id, key, contentType)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*string), args.Error(1)
+}
+func (m *mockCertificationRepository) CountMatchingDives(ctx context.Context, userID, id int, requirement models.CertificationRequirement) (int, error) {
+	args := m.Called(ctx, userID, id, requirement)
+	return args.Int(0), args.Error(1)
+}
+
+// memoryMediaStore keeps stored files in a map.
+type memoryMediaStore struct {
+	files map[string][]byte
+}
+
+func newMemoryMediaStore() *memoryMediaStore {
+	return &memoryMediaStore{files: map[string][]byte{}}
+}
+
+func (s *memoryMediaStore) Put(_ context.Context, key string, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	s.files[key] = content
+	return err
+}
+func (s *memoryMediaStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	content, ok := s.files[key]
+	if !ok {
+		return nil, media.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+func (s *memoryMediaStore) Delete(_ context.Context, key string) error {
+	delete(s.files, key)
+	return nil
+}
+
+// pngHeader is enough of a PNG file for content sniffing.
+const pngHeader = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+func TestCertificationServicePutCardImageReplacesPreviousFile(t *testing.T) {
+	repository := new(mockCertificationRepository)
+	store := newMemoryMediaStore()
+	previous := "certifications/old.jpg"
+	store.files[previous] = []byte("old")
+	service := NewCertificationService(repository, store)
+	certification := &models.Certification{ID: 3, HasCardImage: true}
+	repository.On("Get", mock.Anything, 42, 3).Return(certification, nil).Twice()
+	repository.On("SetCardImage", mock.Anything, 42, 3, mock.MatchedBy(func(key *string) bool {
+		return strings.HasPrefix(*key, "certifications/") && strings.HasSuffix(*key, ".png")
+	}), mock.MatchedBy(func(contentType *string) bool {
+		return *contentType == "image/png"
+	})).Return(&previous, nil).Once()
+
+	result, err := service.PutCardImage(context.Background(), 42, 3, strings.NewReader(pngHeader+"card"))
+
+	require.NoError(t, err)
+	assert.Equal(t, certification, result)
+	require.Len(t, store.files, 1)
+	for key, content := range store.files {
+		assert.NotEqual(t, previous, key)
+		assert.Equal(t, pngHeader+"card", string(content))
+	}
+	repository.AssertExpectations(t)
+}
+
+func TestCertificationServicePutCardImageRejectsNonImages(t *testing.T) {
+	repository := new(mockCertificationRepository)
+	store := newMemoryMediaStore()
+	service := NewCertificationService(repository, store)
+	repository.On("Get", mock.Anything, 42, 3).Return(&models.Certification{ID: 3}, nil).Once()
+
+	_, err := service.PutCardImage(context.Background(), 42, 3, strings.NewReader("%PDF-1.7"))
+
+	assert.ErrorIs(t, err, utils.ErrUnsupportedImage)
+	assert.Empty(t, store.files)
+	repository.AssertNotCalled(t, "SetCardImage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCertificationServicePutCardImageRemovesFileWhenCertificationVanishes(t *testing.T) {
+	repository := new(mockCertificationRepository)
+	store := newMemoryMediaStore()
+	service := NewCertificationService(repository, store)
+	repository.On("Get", mock.Anything, 42, 3).Return(&models.Certification{ID: 3}, nil).Once()
+	repository.On("SetCardImage", mock.Anything, 42, 3, mock.Anything, mock.Anything).Return(nil, utils.ErrCertificationNotFound).Once()
+
+	_, err := service.PutCardImage(context.Background(), 42, 3, strings.NewReader(pngHeader))
+
+	assert.ErrorIs(t, err, utils.ErrCertificationNotFound)
+	assert.Empty(t, store.files)
+}
+
+func TestCertificationServiceDeleteRemovesCardImage(t *testing.T) {
+	repository := new(mockCertificationRepository)
+	store := newMemoryMediaStore()
+	key := "certifications/card.png"
+	store.files[key] = []byte(pngHeader)
+	service := NewCertificationService(repository, store)
+	repository.On("Delete", mock.Anything, 42, 3).Return(&key, nil).Once()
+
+	require.NoError(t, service.Delete(context.Background(), 42, 3))
+
+	assert.Empty(t, store.files)
+}
+
+func TestCertificationServiceProgressReportsEachRequirement(t *testing.T) {
+	repository := new(mockCertificationRepository)
+	service := NewCertificationService(repository, newMemoryMediaStore())
+	deep := 30.0
+	logged := models.CertificationRequirement{Description: "Logged dives", MinDives: 50}
+	deepDives := models.CertificationRequirement{Description: "Dives deeper than 30 m", MinDives: 10, MinDepth: &deep}
+	repository.On("Get", mock.Anything, 42, 3).Return(&models.Certification{
+		ID: 3, Agency: "PADI", Level: "Divemaster", TrainingDiveCount: 2,
+		Prerequisites: []models.CertificationRequirement{logged, deepDives},
+	}, nil).Once()
+	repository.On("CountMatchingDives", mock.Anything, 42, 3, logged).Return(64, nil).Once()
+	repository.On("CountMatchingDives", mock.Anything, 42, 3, deepDives).Return(7, nil).Once()
+
+	progress, err := service.Progress(context.Background(), 42, 3)
+
+	require.NoError(t, err)
+	assert.False(t, progress.Met)
+	assert.Equal(t, 2, progress.TrainingDiveCount)
+	assert.Equal(t, []models.RequirementProgress{
+		{CertificationRequirement: logged, Logged: 64, Remaining: 0, Met: true},
+		{CertificationRequirement: deepDives, Logged: 7, Remaining: 3, Met: false},
+	}, progress.Requirements)
+}
+
+func TestCertificationServiceProgressWithoutPrerequisitesIsMet(t *testing.T) {
+	repository := new(mockCertificationRepository)
+	service := NewCertificationService(repository, newMemoryMediaStore())
+	repository.On("Get", mock.Anything, 42, 3).Return(&models.Certification{ID: 3}, nil).Once()
+
+	progress, err := service.Progress(context.Background(), 42, 3)
+
+	require.NoError(t, err)
+	assert.True(t, progress.Met)
+	assert.Empty(t, progress.Requirements)
+}
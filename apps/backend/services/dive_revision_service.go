@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/tracing"
+)
+
+// DiveRevisionRepository is the persistence contract used by
+// DiveRevisionService.
+type DiveRevisionRepository interface {
+	ListRevisions(context.Context, int, int) ([]models.DiveRevision, error)
+	RestoreRevision(context.Context, int, int, int) (*models.Dive, error)
+}
+
+type DiveRevisionService struct {
+	repository DiveRevisionRepository
+}
+
+func NewDiveRevisionService(repository DiveRevisionRepository) *DiveRevisionService {
+	return &DiveRevisionService{repository: repository}
+}
+
+func (s *DiveRevisionService) List(ctx context.Context, userID, diveID int) ([]models.DiveRevision, error) {
+	ctx, span := startSpan(ctx, "DiveRevisionService.List", userID, tracing.Int("dive_id", diveID))
+	revisions, err := s.repository.ListRevisions(ctx, userID, diveID)
+	span.End(err)
+	return revisions, err
+}
+
+// Restore puts a dive back to an earlier revision and reports it as updated.
+func (s *DiveRevisionService) Restore(ctx context.Context, userID, diveID, revision int) (*models.Dive, error) {
+	ctx, span := startSpan(ctx, "DiveRevisionService.Restore", userID, tracing.Int("dive_id", diveID), tracing.Int("revision", revision))
+	dive, err := s.repository.RestoreRevision(ctx, userID, diveID, revision)
+	span.End(err)
+	return dive, err
+}
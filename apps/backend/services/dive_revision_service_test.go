@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockDiveRevisionRepository struct {
+	mock.Mock
+}
+
+func (m *mockDiveRevisionRepository) ListRevisions(ctx context.Context, userID, diveID int) ([]models.DiveRevision, error) {
+	args := m.Called(ctx, userID, diveID)
+	revisions, _ := args.Get(0).([]models.DiveRevision)
+	return revisions, args.Error(1)
+}
+
+func (m *mockDiveRevisionRepository) RestoreRevision(ctx context.Context, userID, diveID, revision int) (*models.Dive, error) {
+	args := m.Called(ctx, userID, diveID, revision)
+	dive, _ := args.Get(0).(*models.Dive)
+	return dive, args.Error(1)
+}
+
+func TestDiveRevisionServiceRestoreReturnsRestoredDive(t *testing.T) {
+	repository := new(mockDiveRevisionRepository)
+	service := NewDiveRevisionService(repository)
+	restored := &models.Dive{ID: 12, UserID: 4, Version: 7}
+	repository.On("RestoreRevision", mock.Anything, 4, 12, 2).Return(restored, nil).Once()
+
+	dive, err := service.Restore(context.Background(), 4, 12, 2)
+
+	require.NoError(t, err)
+	assert.Same(t, restored, dive)
+	repository.AssertExpectations(t)
+}
+
+func TestDiveRevisionServiceRestoreReportsMissingRevision(t *testing.T) {
+	repository := new(mockDiveRevisionRepository)
+	service := NewDiveRevisionService(repository)
+	repository.On("RestoreRevision", mock.Anything, 4, 12, 9).Return(nil, utils.ErrDiveRevisionNotFound).Once()
+
+	dive, err := service.Restore(context.Background(), 4, 12, 9)
+
+	assert.ErrorIs(t, err, utils.ErrDiveRevisionNotFound)
+	assert.Nil(t, dive)
+}
@@ -2,13 +2,17 @@ package services
 
 import (
 	"context"
+	"divelog-backend/metrics"
 	"divelog-backend/models"
+	"divelog-backend/tracing"
 	"divelog-backend/utils"
 )
 
 // DiveRepository is the persistence contract used by DiveService.
 type DiveRepository interface {
 	GetDivesByUserID(context.Context, int) ([]models.Dive, error)
+	GetDive(context.Context, int, int) (*models.Dive, error)
+	GetDivesByExtraData(context.Context, int, string, *string) ([]models.Dive, error)
 	CreateDive(context.Context, *models.Dive) error
 	UpdateDive(context.Context, int, int, *models.Dive) error
 	DeleteDive(context.Context, int, int) error
@@ -16,6 +20,7 @@ type DiveRepository interface {
 	GetCurrentDive(context.Context, int, int) (*models.Dive, error)
 	CheckDuplicateDive(context.Context, int, int, string) (bool, error)
 	CheckDuplicateDiveForUpdate(context.Context, int, int, string, int) (bool, error)
+	EnqueueWebhookEvent(context.Context, int, string, interface{}) error
 }
 
 // DiveSiteRepository is the persistence contract used by dive write workflows.
@@ -23,9 +28,10 @@ type DiveSiteRepository interface {
 	GetByID(context.Context, int) (*models.DiveSite, error)
 	GetDiveSiteByDiveID(context.Context, int) (*int, error)
 	FindDiveSitesByName(context.Context, string) ([]models.DiveSite, error)
-	CreateDiveSite(context.Context, string, float64, float64, *string) (*models.DiveSite, error)
+	CreateDiveSite(context.Context, *models.DiveSiteRequest) (*models.DiveSite, error)
 	UpdateDiveSite(context.Context, int, *models.DiveSiteRequest) (*models.DiveSite, error)
 	CountDivesBySiteID(context.Context, int) (int, error)
+	ReassignDives(context.Context, []int, int) (int64, error)
 	DeleteDiveSite(context.Context, int) error
 }
 
@@ -34,23 +40,48 @@ type Transactor interface {
 	WithinTransaction(context.Context, func(DiveRepository, DiveSiteRepository) error) error
 }
 
+// DiveService runs the dive workflows. Every write queues its webhook event in
+// the transaction that makes the change.
 type DiveService struct {
 	diveRepo   DiveRepository
 	transactor Transactor
+	geocoder   ReverseGeocoder
 }
 
-func NewDiveService(diveRepo DiveRepository, transactor Transactor) *DiveService {
-	return &DiveService{diveRepo: diveRepo, transactor: transactor}
+// NewDiveService wires the dive workflows. geocoder may be nil.
+func NewDiveService(diveRepo DiveRepository, transactor Transactor, geocoder ReverseGeocoder) *DiveService {
+	return &DiveService{diveRepo: diveRepo, transactor: transactor, geocoder: geocoder}
 }
 
 func (s *DiveService) GetDives(ctx context.Context, userID int) ([]models.Dive, error) {
-	return s.diveRepo.GetDivesByUserID(ctx, userID)
+	ctx, span := startSpan(ctx, "DiveService.GetDives", userID)
+	dives, err := s.diveRepo.GetDivesByUserID(ctx, userID)
+	span.End(err)
+	return dives, err
+}
+
+func (s *DiveService) GetDive(ctx context.Context, userID, diveID int) (*models.Dive, error) {
+	ctx, span := startSpan(ctx, "DiveService.GetDive", userID, tracing.Int("dive_id", diveID))
+	dive, err := s.diveRepo.GetDive(ctx, userID, diveID)
+	span.End(err)
+	return dive, err
+}
+
+// GetDivesByExtraData returns dives that preserved a vendor-specific field
+// under key, optionally restricted to one value.
+func (s *DiveService) GetDivesByExtraData(ctx context.Context, userID int, key string, value *string) ([]models.Dive, error) {
+	ctx, span := startSpan(ctx, "DiveService.GetDivesByExtraData", userID, tracing.String("extra_key", key))
+	dives, err := s.diveRepo.GetDivesByExtraData(ctx, userID, key, value)
+	span.End(err)
+	return dives, err
 }
 
 func (s *DiveService) CreateDive(ctx context.Context, userID int, request models.DiveRequest) (*models.Dive, error) {
+	ctx, span := startSpan(ctx, "DiveService.CreateDive", userID)
+	defer span.End(nil)
 	dive := request.ToDive(userID)
 	err := s.transactor.WithinTransaction(ctx, func(dives DiveRepository, sites DiveSiteRepository) error {
-		site, err := findOrCreateDiveSite(ctx, sites, request.Location, request.Lat, request.Lng)
+		site, err := findOrCreateDiveSite(ctx, sites, s.geocoder, request.Location, request.Lat, request.Lng)
 		if err != nil {
 			return err
 		}
@@ -64,13 +95,18 @@ func (s *DiveService) CreateDive(ctx context.Context, userID int, request models
 			return utils.ErrDuplicateDive
 		}
 
-		return dives.CreateDive(ctx, dive)
+		if err := dives.CreateDive(ctx, dive); err != nil {
+			return err
+		}
+		setDiveLocation(dive, request)
+		return dives.EnqueueWebhookEvent(ctx, userID, models.WebhookEventDiveCreated, dive)
 	})
 	if err != nil {
+		span.End(err)
 		return nil, err
 	}
 
-	setDiveLocation(dive, request)
+	metrics.DivesCreated.Inc("single")
 	return dive, nil
 }
 
@@ -86,11 +122,13 @@ type BatchCreateResult struct {
 }
 
 func (s *DiveService) CreateMultipleDives(ctx context.Context, userID int, requests []models.DiveRequest) (*BatchCreateResult, error) {
+	ctx, span := startSpan(ctx, "DiveService.CreateMultipleDives", userID, tracing.Int("dive_count", len(requests)))
+	defer span.End(nil)
 	result := &BatchCreateResult{}
 	err := s.transactor.WithinTransaction(ctx, func(dives DiveRepository, sites DiveSiteRepository) error {
 		for _, request := range requests {
 			dive := request.ToDive(userID)
-			site, err := findOrCreateDiveSite(ctx, sites, request.Location, request.Lat, request.Lng)
+			site, err := findOrCreateDiveSite(ctx, sites, s.geocoder, request.Location, request.Lat, request.Lng)
 			if err != nil {
 				return err
 			}
@@ -113,33 +151,61 @@ func (s *DiveService) CreateMultipleDives(ctx context.Context, userID int, reque
 			setDiveLocation(dive, request)
 			result.Created = append(result.Created, *dive)
 		}
-		return nil
+		if len(result.Created) == 0 {
+			return nil
+		}
+		summary := models.DiveChangeSummary{
+			Operation:     "import",
+			AffectedCount: int64(len(result.Created)),
+			SkippedCount:  len(result.Skipped),
+		}
+		for _, dive := range result.Created {
+			summary.DiveIDs = append(summary.DiveIDs, dive.ID)
+		}
+		return dives.EnqueueWebhookEvent(ctx, userID, models.WebhookEventDivesImported, summary)
 	})
 	if err != nil {
+		span.End(err)
 		return nil, err
 	}
+	span.SetAttributes(tracing.Int("created_count", len(result.Created)), tracing.Int("skipped_count", len(result.Skipped)))
+	metrics.DivesCreated.Add(float64(len(result.Created)), "import")
+	metrics.ImportDuplicatesSkipped.Add(float64(len(result.Skipped)), "dives")
 	return result, nil
 }
 
-func (s *DiveService) UpdateDive(ctx context.Context, diveID, userID int, request models.DiveRequest) (*models.Dive, error) {
+// UpdateDive overwrites a dive. When expectedVersion is set and the dive has
+// moved on since, nothing is written and a *models.VersionConflict describes
+// the current dive and how the request differs from it.
+func (s *DiveService) UpdateDive(ctx context.Context, diveID, userID int, request models.DiveRequest, expectedVersion *int) (*models.Dive, error) {
+	ctx, span := startSpan(ctx, "DiveService.UpdateDive", userID, tracing.Int("dive_id", diveID))
+	defer span.End(nil)
 	dive := request.ToDive(userID)
 	err := s.transactor.WithinTransaction(ctx, func(dives DiveRepository, sites DiveSiteRepository) error {
 		current, err := dives.GetCurrentDive(ctx, diveID, userID)
 		if err != nil {
 			return err
 		}
+		if expectedVersion != nil && *expectedVersion != current.Version {
+			stored, err := dives.GetDive(ctx, userID, diveID)
+			if err != nil {
+				return err
+			}
+			// Round-trip the request so both sides use the stored formats.
+			return models.NewVersionConflict(stored.Version, stored, stored.ToRequest(), dive.ToRequest())
+		}
 
 		siteChanged := current.Location != request.Location || current.Latitude != request.Lat || current.Longitude != request.Lng
 		dateTimeChanged := !current.DateTime.Time.Equal(dive.DateTime.Time)
 
 		var site *models.DiveSite
 		if siteChanged {
-			site, err = findOrCreateDiveSite(ctx, sites, request.Location, request.Lat, request.Lng)
+			site, err = findOrCreateDiveSite(ctx, sites, s.geocoder, request.Location, request.Lat, request.Lng)
 			if err != nil {
 				return err
 			}
 		} else {
-			site, err = existingOrResolvedSite(ctx, sites, diveID, request)
+			site, err = existingOrResolvedSite(ctx, sites, s.geocoder, diveID, request)
 			if err != nil {
 				return err
 			}
@@ -155,25 +221,50 @@ func (s *DiveService) UpdateDive(ctx context.Context, diveID, userID int, reques
 		}
 
 		dive.DiveSiteID = &site.ID
-		return dives.UpdateDive(ctx, diveID, userID, dive)
+		if err := dives.UpdateDive(ctx, diveID, userID, dive); err != nil {
+			return err
+		}
+		setDiveLocation(dive, request)
+		return dives.EnqueueWebhookEvent(ctx, userID, models.WebhookEventDiveUpdated, dive)
 	})
 	if err != nil {
+		span.End(err)
 		return nil, err
 	}
-
-	setDiveLocation(dive, request)
 	return dive, nil
 }
 
 func (s *DiveService) DeleteDive(ctx context.Context, diveID, userID int) error {
-	return s.diveRepo.DeleteDive(ctx, diveID, userID)
+	ctx, span := startSpan(ctx, "DiveService.DeleteDive", userID, tracing.Int("dive_id", diveID))
+	err := s.transactor.WithinTransaction(ctx, func(dives DiveRepository, _ DiveSiteRepository) error {
+		if err := dives.DeleteDive(ctx, diveID, userID); err != nil {
+			return err
+		}
+		return dives.EnqueueWebhookEvent(ctx, userID, models.WebhookEventDiveDeleted, models.DeletedDive{ID: diveID})
+	})
+	span.End(err)
+	return err
 }
 
 func (s *DiveService) DeleteAllDives(ctx context.Context, userID int) (int64, error) {
-	return s.diveRepo.DeleteAllDives(ctx, userID)
+	ctx, span := startSpan(ctx, "DiveService.DeleteAllDives", userID)
+	var deleted int64
+	err := s.transactor.WithinTransaction(ctx, func(dives DiveRepository, _ DiveSiteRepository) error {
+		var err error
+		if deleted, err = dives.DeleteAllDives(ctx, userID); err != nil || deleted == 0 {
+			return err
+		}
+		return dives.EnqueueWebhookEvent(ctx, userID, models.WebhookEventDivesDeleted,
+			models.DiveChangeSummary{Operation: "delete_all", AffectedCount: deleted})
+	})
+	span.End(err)
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
 }
 
-func existingOrResolvedSite(ctx context.Context, sites DiveSiteRepository, diveID int, request models.DiveRequest) (*models.DiveSite, error) {
+func existingOrResolvedSite(ctx context.Context, sites DiveSiteRepository, geocoder ReverseGeocoder, diveID int, request models.DiveRequest) (*models.DiveSite, error) {
 	siteID, err := sites.GetDiveSiteByDiveID(ctx, diveID)
 	if err != nil {
 		return nil, err
@@ -187,7 +278,7 @@ func existingOrResolvedSite(ctx context.Context, sites DiveSiteRepository, diveI
 			return nil, getErr
 		}
 	}
-	return findOrCreateDiveSite(ctx, sites, request.Location, request.Lat, request.Lng)
+	return findOrCreateDiveSite(ctx, sites, geocoder, request.Location, request.Lat, request.Lng)
 }
 
 func setDiveLocation(dive *models.Dive, request models.DiveRequest) {
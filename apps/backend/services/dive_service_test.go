@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"divelog-backend/metrics"
 	"divelog-backend/models"
 	"divelog-backend/utils"
 	"testing"
@@ -18,6 +19,17 @@ func (m *mockDiveRepository) GetDivesByUserID(ctx context.Context, userID int) (
 	args := m.Called(ctx, userID)
 	return args.Get(0).([]models.Dive), args.Error(1)
 }
+func (m *mockDiveRepository) GetDive(ctx context.Context, userID, diveID int) (*models.Dive, error) {
+	args := m.Called(ctx, userID, diveID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Dive), args.Error(1)
+}
+func (m *mockDiveRepository) GetDivesByExtraData(ctx context.Context, userID int, key string, value *string) ([]models.Dive, error) {
+	args := m.Called(ctx, userID, key, value)
+	return args.Get(0).([]models.Dive), args.Error(1)
+}
 func (m *mockDiveRepository) CreateDive(ctx context.Context, dive *models.Dive) error {
 	return m.Called(ctx, dive).Error(0)
 }
@@ -46,13 +58,23 @@ func (m *mockDiveRepository) CheckDuplicateDiveForUpdate(ctx context.Context, us
 	args := m.Called(ctx, userID, siteID, dateTime, excludeID)
 	return args.Bool(0), args.Error(1)
 }
+func (m *mockDiveRepository) EnqueueWebhookEvent(ctx context.Context, userID int, eventType string, data interface{}) error {
+	return m.Called(ctx, userID, eventType, data).Error(0)
+}
 
 type mockDiveSiteRepository struct{ mock.Mock }
 
-func (m *mockDiveSiteRepository) GetAll(ctx context.Context) ([]models.DiveSite, error) {
-	args := m.Called(ctx)
+func (m *mockDiveSiteRepository) GetAll(ctx context.Context, filter models.DiveSiteFilter) ([]models.DiveSite, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]models.DiveSite), args.Error(1)
 }
+func (m *mockDiveSiteRepository) GetHistory(ctx context.Context, siteID, userID int) (*models.DiveSiteHistory, error) {
+	args := m.Called(ctx, siteID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DiveSiteHistory), args.Error(1)
+}
 func (m *mockDiveSiteRepository) Search(ctx context.Context, query string) ([]models.DiveSite, error) {
 	args := m.Called(ctx, query)
 	return args.Get(0).([]models.DiveSite), args.Error(1)
@@ -76,8 +98,8 @@ func (m *mockDiveSiteRepository) FindDiveSitesByName(ctx context.Context, name s
 	args := m.Called(ctx, name)
 	return args.Get(0).([]models.DiveSite), args.Error(1)
 }
-func (m *mockDiveSiteRepository) CreateDiveSite(ctx context.Context, name string, lat, lng float64, description *string) (*models.DiveSite, error) {
-	args := m.Called(ctx, name, lat, lng, description)
+func (m *mockDiveSiteRepository) CreateDiveSite(ctx context.Context, request *models.DiveSiteRequest) (*models.DiveSite, error) {
+	args := m.Called(ctx, request)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -97,6 +119,48 @@ func (m *mockDiveSiteRepository) CountDivesBySiteID(ctx context.Context, id int)
 func (m *mockDiveSiteRepository) DeleteDiveSite(ctx context.Context, id int) error {
 	return m.Called(ctx, id).Error(0)
 }
+func (m *mockDiveSiteRepository) FindNearby(ctx context.Context, lat, lng, radiusKM float64, box models.BoundingBox, limit int) ([]models.NearbyDiveSite, error) {
+	args := m.Called(ctx, lat, lng, radiusKM, box, limit)
+	return args.Get(0).([]models.NearbyDiveSite), args.Error(1)
+}
+func (m *mockDiveSiteRepository) FindInBoundingBox(ctx context.Context, box models.BoundingBox, limit int) ([]models.DiveSite, error) {
+	args := m.Called(ctx, box, limit)
+	return args.Get(0).([]models.DiveSite), args.Error(1)
+}
+func (m *mockDiveSiteRepository) ClusterInBoundingBox(ctx context.Context, box models.BoundingBox, precision int) ([]models.DiveSiteCluster, error) {
+	args := m.Called(ctx, box, precision)
+	return args.Get(0).([]models.DiveSiteCluster), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) FindSitePairsWithin(ctx context.Context, maxDistanceKM float64, limit int) ([]models.DiveSitePair, error) {
+	args := m.Called(ctx, maxDistanceKM, limit)
+	return args.Get(0).([]models.DiveSitePair), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) ReassignDives(ctx context.Context, sourceIDs []int, targetID int) (int64, error) {
+	args := m.Called(ctx, sourceIDs, targetID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) ExportWithStats(ctx context.Context, userID int, divedOnly bool) ([]models.DiveSiteExportEntry, error) {
+	args := m.Called(ctx, userID, divedOnly)
+	return args.Get(0).([]models.DiveSiteExportEntry), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) FindSitesForGeocoding(ctx context.Context, afterID, limit int, overwrite bool) ([]models.DiveSite, error) {
+	args := m.Called(ctx, afterID, limit, overwrite)
+	return args.Get(0).([]models.DiveSite), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) SetDiveSitePlace(ctx context.Context, id int, place models.Place, overwrite bool) (bool, error) {
+	args := m.Called(ctx, id, place, overwrite)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockDiveSiteRepository) CountryStatistics(ctx context.Context, userID int) ([]models.CountryStatistics, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.CountryStatistics), args.Error(1)
+}
 
 type recordingTransactor struct {
 	dives DiveRepository
@@ -120,7 +184,8 @@ func newServiceTestHarness() (*DiveService, *mockDiveRepository, *mockDiveSiteRe
 	dives := new(mockDiveRepository)
 	sites := new(mockDiveSiteRepository)
 	tx := &recordingTransactor{dives: dives, sites: sites}
-	return NewDiveService(dives, tx), dives, sites, tx
+	dives.On("EnqueueWebhookEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	return NewDiveService(dives, tx, nil), dives, sites, tx
 }
 
 func TestDiveServiceCreateDiveRunsWorkflowInTransaction(t *testing.T) {
@@ -129,7 +194,7 @@ func TestDiveServiceCreateDiveRunsWorkflowInTransaction(t *testing.T) {
 	site := &models.DiveSite{ID: 17, Name: request.Location}
 
 	sites.On("FindDiveSitesByName", mock.Anything, request.Location).Return([]models.DiveSite{}, nil).Once()
-	sites.On("CreateDiveSite", mock.Anything, request.Location, request.Lat, request.Lng, (*string)(nil)).Return(site, nil).Once()
+	sites.On("CreateDiveSite", mock.Anything, &models.DiveSiteRequest{Name: request.Location, Latitude: request.Lat, Longitude: request.Lng}).Return(site, nil).Once()
 	dives.On("CheckDuplicateDive", mock.Anything, 42, site.ID, request.DateTime).Return(false, nil).Once()
 	dives.On("CreateDive", mock.Anything, mock.MatchedBy(func(dive *models.Dive) bool {
 		return dive.UserID == 42 && dive.DiveSiteID != nil && *dive.DiveSiteID == site.ID
@@ -172,14 +237,16 @@ func TestDiveServiceBatchCreatesAndSkipsInOneTransaction(t *testing.T) {
 	secondSite := &models.DiveSite{ID: 5}
 
 	sites.On("FindDiveSitesByName", mock.Anything, first.Location).Return([]models.DiveSite{}, nil).Once()
-	sites.On("CreateDiveSite", mock.Anything, first.Location, first.Lat, first.Lng, (*string)(nil)).Return(firstSite, nil).Once()
+	sites.On("CreateDiveSite", mock.Anything, &models.DiveSiteRequest{Name: first.Location, Latitude: first.Lat, Longitude: first.Lng}).Return(firstSite, nil).Once()
 	dives.On("CheckDuplicateDive", mock.Anything, 8, firstSite.ID, first.DateTime).Return(true, nil).Once()
 	sites.On("FindDiveSitesByName", mock.Anything, second.Location).Return([]models.DiveSite{}, nil).Once()
-	sites.On("CreateDiveSite", mock.Anything, second.Location, second.Lat, second.Lng, (*string)(nil)).Return(secondSite, nil).Once()
+	sites.On("CreateDiveSite", mock.Anything, &models.DiveSiteRequest{Name: second.Location, Latitude: second.Lat, Longitude: second.Lng}).Return(secondSite, nil).Once()
 	dives.On("CheckDuplicateDive", mock.Anything, 8, secondSite.ID, second.DateTime).Return(false, nil).Once()
 	dives.On("CreateDive", mock.Anything, mock.AnythingOfType("*models.Dive")).Run(func(args mock.Arguments) {
 		args.Get(1).(*models.Dive).ID = 23
 	}).Return(nil).Once()
+	created := metrics.DivesCreated.Value("import")
+	skipped := metrics.ImportDuplicatesSkipped.Value("dives")
 
 	result, err := service.CreateMultipleDives(context.Background(), 8, []models.DiveRequest{first, second})
 
@@ -190,6 +257,8 @@ func TestDiveServiceBatchCreatesAndSkipsInOneTransaction(t *testing.T) {
 	require.Len(t, result.Skipped, 1)
 	assert.Equal(t, first.Location, result.Skipped[0].Location)
 	assert.Equal(t, "duplicate", result.Skipped[0].Reason)
+	assert.Equal(t, created+1, metrics.DivesCreated.Value("import"))
+	assert.Equal(t, skipped+1, metrics.ImportDuplicatesSkipped.Value("dives"))
 }
 
 func TestDiveServiceUpdateReusesExistingSiteWhenLocationAndDateAreUnchanged(t *testing.T) {
@@ -209,7 +278,7 @@ func TestDiveServiceUpdateReusesExistingSiteWhenLocationAndDateAreUnchanged(t *t
 		return dive.DiveSiteID != nil && *dive.DiveSiteID == siteID
 	})).Return(nil).Once()
 
-	updated, err := service.UpdateDive(context.Background(), 30, 42, request)
+	updated, err := service.UpdateDive(context.Background(), 30, 42, request, nil)
 
 	require.NoError(t, err)
 	assert.Equal(t, siteID, *updated.DiveSiteID)
@@ -232,9 +301,59 @@ func TestDiveServiceUpdateChecksExactTimestampWhenTimeChanges(t *testing.T) {
 	sites.On("GetByID", mock.Anything, siteID).Return(site, nil).Once()
 	dives.On("CheckDuplicateDiveForUpdate", mock.Anything, 42, siteID, request.DateTime, 30).Return(true, nil).Once()
 
-	updated, err := service.UpdateDive(context.Background(), 30, 42, request)
+	updated, err := service.UpdateDive(context.Background(), 30, 42, request, nil)
 
 	assert.Nil(t, updated)
 	assert.ErrorIs(t, err, utils.ErrDuplicateDive)
 	dives.AssertNotCalled(t, "UpdateDive", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
+
+func TestDiveServiceUpdateRejectsStaleVersionWithDiff(t *testing.T) {
+	service, dives, _, _ := newServiceTestHarness()
+	request := serviceTestRequest()
+	buddy := "Ana"
+	stored := &models.Dive{
+		ID: 30, UserID: 42, Version: 4, DateTime: models.LocalTime{Time: time.Date(2026, 8, 10, 9, 30, 0, 0, time.UTC)},
+		Location: request.Location, MaxDepth: 30, Duration: request.Duration, Latitude: request.Lat, Longitude: request.Lng, Buddy: &buddy,
+	}
+	dives.On("GetCurrentDive", mock.Anything, 30, 42).Return(&models.Dive{Version: 4}, nil).Once()
+	dives.On("GetDive", mock.Anything, 42, 30).Return(stored, nil).Once()
+	expected := 3
+
+	updated, err := service.UpdateDive(context.Background(), 30, 42, request, &expected)
+
+	assert.Nil(t, updated)
+	assert.ErrorIs(t, err, utils.ErrVersionConflict)
+	var conflict *models.VersionConflict
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, 4, conflict.Version)
+	assert.Same(t, stored, conflict.Current)
+	assert.Equal(t, []models.FieldDiff{
+		{Field: "buddy", Server: "Ana", Client: nil},
+		{Field: "depth", Server: 30.0, Client: 24.5},
+	}, conflict.Diff)
+	dives.AssertNotCalled(t, "UpdateDive", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDiveServiceUpdateAcceptsCurrentVersion(t *testing.T) {
+	service, dives, sites, _ := newServiceTestHarness()
+	request := serviceTestRequest()
+	current := &models.Dive{
+		Version:  3,
+		DateTime: models.LocalTime{Time: time.Date(2026, 8, 10, 9, 30, 0, 0, time.UTC)},
+		Location: request.Location, Latitude: request.Lat, Longitude: request.Lng,
+	}
+	siteID := 11
+	dives.On("GetCurrentDive", mock.Anything, 30, 42).Return(current, nil).Once()
+	sites.On("GetDiveSiteByDiveID", mock.Anything, 30).Return(&siteID, nil).Once()
+	sites.On("GetByID", mock.Anything, siteID).Return(&models.DiveSite{ID: siteID}, nil).Once()
+	dives.On("UpdateDive", mock.Anything, 30, 42, mock.AnythingOfType("*models.Dive")).Run(func(args mock.Arguments) {
+		args.Get(3).(*models.Dive).Version = 4
+	}).Return(nil).Once()
+	expected := 3
+
+	updated, err := service.UpdateDive(context.Background(), 30, 42, request, &expected)
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, updated.Version)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"divelog-backend/models"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxDuplicatePairs bounds how many nearby pairs are scored per report.
+	maxDuplicatePairs = 5000
+	// nameSimilarityWeight is the share of a duplicate score that comes from
+	// the names; the rest comes from how close the sites are.
+	nameSimilarityWeight = 0.7
+	// containedNameSimilarity scores a name whose words all appear in the
+	// other, such as "Blue Hole" and "Blue hole Dahab".
+	containedNameSimilarity = 0.9
+)
+
+// scoreDuplicateCandidates scores each pair, drops those below minScore and
+// returns the best limit candidates, most likely duplicates first.
+func scoreDuplicateCandidates(pairs []models.DiveSitePair, maxDistanceKM, minScore float64, limit int) []models.DiveSiteDuplicateCandidate {
+	candidates := []models.DiveSiteDuplicateCandidate{}
+	for _, pair := range pairs {
+		similarity := nameSimilarity(pair.Site.Name, pair.Other.Name)
+		proximity := 1 - math.Min(pair.DistanceKM/maxDistanceKM, 1)
+		score := nameSimilarityWeight*similarity + (1-nameSimilarityWeight)*proximity
+		if score < minScore {
+			continue
+		}
+		candidates = append(candidates, models.DiveSiteDuplicateCandidate{
+			DiveSitePair:   pair,
+			NameSimilarity: roundScore(similarity),
+			Score:          roundScore(score),
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].DistanceKM < candidates[j].DistanceKM
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
+
+// nameSimilarity compares two site names after folding case and punctuation.
+// It is the better of the edit-distance ratio, which catches typos, and word
+// containment, which catches a name extended with its region.
+func nameSimilarity(a, b string) float64 {
+	left, right := normalizeSiteName(a), normalizeSiteName(b)
+	if left == "" || right == "" {
+		return 0
+	}
+	if left == right {
+		return 1
+	}
+	leftRunes, rightRunes := []rune(left), []rune(right)
+	longest := math.Max(float64(len(leftRunes)), float64(len(rightRunes)))
+	similarity := 1 - float64(levenshteinDistance(leftRunes, rightRunes))/longest
+
+	leftWords, rightWords := strings.Fields(left), strings.Fields(right)
+	if len(leftWords) > len(rightWords) {
+		leftWords, rightWords = rightWords, leftWords
+	}
+	words := map[string]bool{}
+	for _, word := range rightWords {
+		words[word] = true
+	}
+	shared := 0
+	for _, word := range leftWords {
+		if words[word] {
+			shared++
+		}
+	}
+	containment := containedNameSimilarity * float64(shared) / float64(len(leftWords))
+	return math.Max(similarity, containment)
+}
+
+func normalizeSiteName(name string) string {
+	folded := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, name)
+	return strings.Join(strings.Fields(folded), " ")
+}
+
+func levenshteinDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func roundScore(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
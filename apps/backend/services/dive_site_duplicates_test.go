@@ -0,0 +1,35 @@
+package services
+
+import (
+	"divelog-backend/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, nameSimilarity("Blue Hole", "blue-hole"))
+	assert.Equal(t, containedNameSimilarity, nameSimilarity("Blue Hole", "Blue hole Dahab"))
+	assert.InDelta(t, 0.941, nameSimilarity("Shark Observatory", "Shark Obsrvatory"), 0.001)
+	assert.Less(t, nameSimilarity("Blue Hole", "Ras Mohammed"), 0.3)
+	assert.Equal(t, 0.0, nameSimilarity("", "Blue Hole"))
+}
+
+func TestScoreDuplicateCandidatesRanksAndFilters(t *testing.T) {
+	pairs := []models.DiveSitePair{
+		{Site: models.DiveSite{ID: 1, Name: "Canyon"}, Other: models.DiveSite{ID: 2, Name: "Bells"}, DistanceKM: 0.1},
+		{Site: models.DiveSite{ID: 3, Name: "Blue Hole"}, Other: models.DiveSite{ID: 4, Name: "Blue hole Dahab"}, DistanceKM: 0.5},
+		{Site: models.DiveSite{ID: 5, Name: "Lighthouse"}, Other: models.DiveSite{ID: 6, Name: "Lighthouse"}, DistanceKM: 1.5},
+	}
+
+	candidates := scoreDuplicateCandidates(pairs, 2, 0.6, 10)
+
+	require.Len(t, candidates, 2)
+	assert.Equal(t, 3, candidates[0].Site.ID)
+	assert.Equal(t, 0.855, candidates[0].Score)
+	assert.Equal(t, 5, candidates[1].Site.ID)
+	assert.Equal(t, 1.0, candidates[1].NameSimilarity)
+
+	assert.Len(t, scoreDuplicateCandidates(pairs, 2, 0.6, 1), 1)
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"divelog-backend/models"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// EncodeDiveSites writes entries in one of models.DiveSiteExportFormats.
+func EncodeDiveSites(w io.Writer, format string, entries []models.DiveSiteExportEntry) error {
+	switch format {
+	case "geojson":
+		return encodeDiveSitesGeoJSON(w, entries)
+	case "kml":
+		return encodeDiveSitesKML(w, entries)
+	case "gpx":
+		return encodeDiveSitesGPX(w, entries)
+	default:
+		return fmt.Errorf("unsupported dive site export format %q", format)
+	}
+}
+
+func encodeDiveSitesGeoJSON(w io.Writer, entries []models.DiveSiteExportEntry) error {
+	collection := models.GeoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]models.GeoJSONFeature, 0, len(entries))}
+	for _, entry := range entries {
+		properties := map[string]interface{}{
+			"id":         entry.ID,
+			"name":       entry.Name,
+			"dive_count": entry.DiveCount,
+		}
+		if entry.Description != nil {
+			properties["description"] = *entry.Description
+		}
+		if entry.DeepestDive != nil {
+			properties["max_depth"] = *entry.DeepestDive
+		}
+		if entry.LastDived != nil {
+			properties["last_dived"] = *entry.LastDived
+		}
+		collection.Features = append(collection.Features, models.GeoJSONFeature{
+			Type:       "Feature",
+			Geometry:   &models.GeoJSONGeometry{Type: "Point", Coordinates: []float64{entry.Longitude, entry.Latitude}},
+			Properties: properties,
+		})
+	}
+	return json.NewEncoder(w).Encode(collection)
+}
+
+type kmlDocument struct {
+	XMLName  xml.Name `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Document struct {
+		Name       string         `xml:"name"`
+		Placemarks []kmlPlacemark `xml:"Placemark"`
+	} `xml:"Document"`
+}
+
+type kmlPlacemark struct {
+	ID           string    `xml:"id,attr"`
+	Name         string    `xml:"name"`
+	Description  string    `xml:"description,omitempty"`
+	ExtendedData []kmlData `xml:"ExtendedData>Data"`
+	Coordinates  string    `xml:"Point>coordinates"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+func encodeDiveSitesKML(w io.Writer, entries []models.DiveSiteExportEntry) error {
+	var document kmlDocument
+	document.Document.Name = "Dive sites"
+	for _, entry := range entries {
+		placemark := kmlPlacemark{
+			ID:          "site-" + strconv.Itoa(entry.ID),
+			Name:        entry.Name,
+			Coordinates: formatCoordinate(entry.Longitude) + "," + formatCoordinate(entry.Latitude) + ",0",
+		}
+		if entry.Description != nil {
+			placemark.Description = *entry.Description
+		}
+		placemark.ExtendedData = append(placemark.ExtendedData, kmlData{Name: "dive_count", Value: strconv.Itoa(entry.DiveCount)})
+		if entry.DeepestDive != nil {
+			placemark.ExtendedData = append(placemark.ExtendedData, kmlData{Name: "max_depth", Value: strconv.FormatFloat(*entry.DeepestDive, 'f', -1, 64)})
+		}
+		if entry.LastDived != nil {
+			placemark.ExtendedData = append(placemark.ExtendedData, kmlData{Name: "last_dived", Value: entry.LastDived.Format("2006-01-02T15:04:05")})
+		}
+		document.Document.Placemarks = append(document.Document.Placemarks, placemark)
+	}
+	return writeXML(w, document)
+}
+
+type gpxDocument struct {
+	XMLName   xml.Name      `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+}
+
+type gpxWaypoint struct {
+	Latitude    string `xml:"lat,attr"`
+	Longitude   string `xml:"lon,attr"`
+	Name        string `xml:"name"`
+	Comment     string `xml:"cmt"`
+	Description string `xml:"desc,omitempty"`
+	Type        string `xml:"type"`
+}
+
+func encodeDiveSitesGPX(w io.Writer, entries []models.DiveSiteExportEntry) error {
+	document := gpxDocument{Version: "1.1", Creator: "divelog"}
+	for _, entry := range entries {
+		waypoint := gpxWaypoint{
+			Latitude:  formatCoordinate(entry.Latitude),
+			Longitude: formatCoordinate(entry.Longitude),
+			Name:      entry.Name,
+			Comment:   diveSiteStatsSummary(entry),
+			Type:      "Dive site",
+		}
+		if entry.Description != nil {
+			waypoint.Description = *entry.Description
+		}
+		document.Waypoints = append(document.Waypoints, waypoint)
+	}
+	return writeXML(w, document)
+}
+
+// diveSiteStatsSummary renders the statistics for formats without structured
+// extension data.
+func diveSiteStatsSummary(entry models.DiveSiteExportEntry) string {
+	parts := []string{fmt.Sprintf("Dives: %d", entry.DiveCount)}
+	if entry.DeepestDive != nil {
+		parts = append(parts, fmt.Sprintf("max depth %s m", strconv.FormatFloat(*entry.DeepestDive, 'f', -1, 64)))
+	}
+	if entry.LastDived != nil {
+		parts = append(parts, "last dived "+entry.LastDived.Format("2006-01-02"))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func formatCoordinate(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func writeXML(w io.Writer, document interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"bytes"
+	"divelog-backend/models"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diveSiteExportTestEntries() []models.DiveSiteExportEntry {
+	description := "Kelp & sand"
+	maxDepth := 18.5
+	lastDived := models.LocalTime{Time: time.Date(2026, 8, 10, 9, 30, 0, 0, time.UTC)}
+	return []models.DiveSiteExportEntry{
+		{
+			DiveSite:  models.DiveSite{ID: 4, Name: "Lovers Point", Latitude: 36.6262, Longitude: -121.9166, Description: &description},
+			DiveCount: 3, DeepestDive: &maxDepth, LastDived: &lastDived,
+		},
+		{DiveSite: models.DiveSite{ID: 9, Name: "Breakwater", Latitude: 36.6096, Longitude: -121.8935}},
+	}
+}
+
+func TestEncodeDiveSitesGeoJSONUsesLongitudeFirst(t *testing.T) {
+	var body bytes.Buffer
+	require.NoError(t, EncodeDiveSites(&body, "geojson", diveSiteExportTestEntries()))
+
+	var collection models.GeoJSONFeatureCollection
+	require.NoError(t, json.Unmarshal(body.Bytes(), &collection))
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	require.Len(t, collection.Features, 2)
+	assert.Equal(t, []float64{-121.9166, 36.6262}, collection.Features[0].Geometry.Coordinates)
+	assert.Equal(t, float64(3), collection.Features[0].Properties["dive_count"])
+	assert.Equal(t, 18.5, collection.Features[0].Properties["max_depth"])
+	assert.Equal(t, "2026-08-10T09:30:00", collection.Features[0].Properties["last_dived"])
+	assert.NotContains(t, collection.Features[1].Properties, "max_depth")
+	assert.Empty(t, collection.Validate())
+}
+
+func TestEncodeDiveSitesKMLIncludesStatistics(t *testing.T) {
+	var body bytes.Buffer
+	require.NoError(t, EncodeDiveSites(&body, "kml", diveSiteExportTestEntries()))
+
+	kml := body.String()
+	assert.True(t, strings.HasPrefix(kml, "<?xml"))
+	assert.Contains(t, kml, `<kml xmlns="http://www.opengis.net/kml/2.2">`)
+	assert.Contains(t, kml, `<description>Kelp &amp; sand</description>`)
+	assert.Contains(t, kml, `<coordinates>-121.9166,36.6262,0</coordinates>`)
+	assert.Contains(t, kml, `<Data name="max_depth">`)
+	assert.Contains(t, kml, `<value>2026-08-10T09:30:00</value>`)
+}
+
+func TestEncodeDiveSitesGPXWritesWaypoints(t *testing.T) {
+	var body bytes.Buffer
+	require.NoError(t, EncodeDiveSites(&body, "gpx", diveSiteExportTestEntries()))
+
+	gpx := body.String()
+	assert.Contains(t, gpx, `<gpx xmlns="http://www.topografix.com/GPX/1/1" version="1.1" creator="divelog">`)
+	assert.Contains(t, gpx, `<wpt lat="36.6262" lon="-121.9166">`)
+	assert.Contains(t, gpx, `<cmt>Dives: 3; max depth 18.5 m; last dived 2026-08-10</cmt>`)
+	assert.Contains(t, gpx, `<cmt>Dives: 0</cmt>`)
+}
+
+func TestEncodeDiveSitesRejectsUnknownFormat(t *testing.T) {
+	assert.Error(t, EncodeDiveSites(&bytes.Buffer{}, "csv", nil))
+}
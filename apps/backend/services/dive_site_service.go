@@ -2,40 +2,130 @@ package services
 
 import (
 	"context"
+	"divelog-backend/metrics"
 	"divelog-backend/models"
+	"divelog-backend/tracing"
 	"divelog-backend/utils"
+	"errors"
+	"io"
 	"math"
 )
 
-const nearbyDiveSiteDistanceKM = 0.1
+const (
+	nearbyDiveSiteDistanceKM = 0.1
+	geocodeBackfillBatchSize = 500
+)
 
 type DiveSiteCRUDRepository interface {
 	DiveSiteRepository
-	GetAll(context.Context) ([]models.DiveSite, error)
+	GetAll(context.Context, models.DiveSiteFilter) ([]models.DiveSite, error)
+	GetHistory(context.Context, int, int) (*models.DiveSiteHistory, error)
 	Search(context.Context, string) ([]models.DiveSite, error)
+	FindNearby(context.Context, float64, float64, float64, models.BoundingBox, int) ([]models.NearbyDiveSite, error)
+	FindInBoundingBox(context.Context, models.BoundingBox, int) ([]models.DiveSite, error)
+	ClusterInBoundingBox(context.Context, models.BoundingBox, int) ([]models.DiveSiteCluster, error)
+	ExportWithStats(context.Context, int, bool) ([]models.DiveSiteExportEntry, error)
+	FindSitePairsWithin(context.Context, float64, int) ([]models.DiveSitePair, error)
+	FindSitesForGeocoding(context.Context, int, int, bool) ([]models.DiveSite, error)
+	SetDiveSitePlace(context.Context, int, models.Place, bool) (bool, error)
+	CountryStatistics(context.Context, int) ([]models.CountryStatistics, error)
 }
 
 type DiveSiteService struct {
 	repo       DiveSiteCRUDRepository
 	transactor Transactor
+	geocoder   ReverseGeocoder
 }
 
-func NewDiveSiteService(repo DiveSiteCRUDRepository, transactor Transactor) *DiveSiteService {
-	return &DiveSiteService{repo: repo, transactor: transactor}
+func NewDiveSiteService(repo DiveSiteCRUDRepository, transactor Transactor, geocoder ReverseGeocoder) *DiveSiteService {
+	return &DiveSiteService{repo: repo, transactor: transactor, geocoder: geocoder}
 }
 
-func (s *DiveSiteService) GetAll(ctx context.Context) ([]models.DiveSite, error) {
-	return s.repo.GetAll(ctx)
+func (s *DiveSiteService) GetAll(ctx context.Context, filter models.DiveSiteFilter) ([]models.DiveSite, error) {
+	return s.repo.GetAll(ctx, filter)
 }
 
 func (s *DiveSiteService) Search(ctx context.Context, query string) ([]models.DiveSite, error) {
 	return s.repo.Search(ctx, query)
 }
 
+// Nearby returns sites within the requested radius, nearest first.
+func (s *DiveSiteService) Nearby(ctx context.Context, request models.NearbyDiveSitesRequest) ([]models.NearbyDiveSite, error) {
+	box := boundingBoxAround(request.Latitude, request.Longitude, request.RadiusKM)
+	return s.repo.FindNearby(ctx, request.Latitude, request.Longitude, request.RadiusKM, box, request.Limit)
+}
+
+// InBoundingBox returns the sites inside a map viewport.
+func (s *DiveSiteService) InBoundingBox(ctx context.Context, request models.BoundingBoxDiveSitesRequest) ([]models.DiveSite, error) {
+	return s.repo.FindInBoundingBox(ctx, request.BoundingBox, request.Limit)
+}
+
+// Clusters groups the sites inside a map viewport into cells sized for the
+// requested zoom level.
+func (s *DiveSiteService) Clusters(ctx context.Context, request models.DiveSiteClustersRequest) ([]models.DiveSiteCluster, error) {
+	return s.repo.ClusterInBoundingBox(ctx, request.BoundingBox, geohashPrecisionForZoom(request.Zoom))
+}
+
+// Export writes every located site, with the user's dive statistics, to w in
+// the requested format.
+func (s *DiveSiteService) Export(ctx context.Context, userID int, request models.DiveSiteExportRequest, w io.Writer) error {
+	entries, err := s.repo.ExportWithStats(ctx, userID, request.DivedOnly)
+	if err != nil {
+		return err
+	}
+	return EncodeDiveSites(w, request.Format, entries)
+}
+
+// Import creates a site for each GeoJSON feature unless Create would reject it
+// as a duplicate, in which case the existing site is reported as skipped.
+// Features earlier in the same import count as existing sites.
+func (s *DiveSiteService) Import(ctx context.Context, collection *models.GeoJSONFeatureCollection) (*models.DiveSiteImportResult, error) {
+	var result *models.DiveSiteImportResult
+	err := s.transactor.WithinTransaction(ctx, func(_ DiveRepository, sites DiveSiteRepository) error {
+		result = &models.DiveSiteImportResult{Created: []models.DiveSite{}, Skipped: []models.SkippedDiveSiteImport{}}
+		for i := range collection.Features {
+			request := collection.Features[i].ToDiveSiteRequest()
+			existing, err := findNearbyDiveSite(ctx, sites, request.Name, request.Latitude, request.Longitude, 0)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				result.Skipped = append(result.Skipped, models.SkippedDiveSiteImport{Index: i, Name: request.Name, ExistingID: existing.ID})
+				continue
+			}
+			fillPlace(s.geocoder, &request)
+			site, err := sites.CreateDiveSite(ctx, &request)
+			if err != nil {
+				return err
+			}
+			result.Created = append(result.Created, *site)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.ImportDuplicatesSkipped.Add(float64(len(result.Skipped)), "dive_sites")
+	return result, nil
+}
+
 func (s *DiveSiteService) GetByID(ctx context.Context, id int) (*models.DiveSite, error) {
 	return s.repo.GetByID(ctx, id)
 }
 
+// GetDetail returns a site with userID's dive history there.
+func (s *DiveSiteService) GetDetail(ctx context.Context, id, userID int) (*models.DiveSiteDetail, error) {
+	site, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.repo.GetHistory(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DiveSiteDetail{DiveSite: *site, DiveHistory: history}, nil
+}
+
 func (s *DiveSiteService) Create(ctx context.Context, request *models.DiveSiteRequest) (*models.DiveSite, error) {
 	var site *models.DiveSite
 	err := s.transactor.WithinTransaction(ctx, func(_ DiveRepository, sites DiveSiteRepository) error {
@@ -47,7 +137,8 @@ func (s *DiveSiteService) Create(ctx context.Context, request *models.DiveSiteRe
 			site = existing
 			return utils.ErrDuplicateDiveSite
 		}
-		site, err = sites.CreateDiveSite(ctx, request.Name, request.Latitude, request.Longitude, request.Description)
+		fillPlace(s.geocoder, request)
+		site, err = sites.CreateDiveSite(ctx, request)
 		return err
 	})
 	return site, err
@@ -82,15 +173,121 @@ func (s *DiveSiteService) Delete(ctx context.Context, id int) error {
 	})
 }
 
-func findOrCreateDiveSite(ctx context.Context, sites DiveSiteRepository, name string, latitude, longitude float64) (*models.DiveSite, error) {
+// Merge moves every dive at the source sites to targetID and trashes the
+// sources, all in one transaction.
+func (s *DiveSiteService) Merge(ctx context.Context, targetID int, request models.MergeDiveSitesRequest) (*models.DiveSiteMergeResult, error) {
+	sourceIDs := []int{}
+	seen := map[int]bool{targetID: true}
+	for _, id := range request.SourceSiteIDs {
+		if !seen[id] {
+			seen[id] = true
+			sourceIDs = append(sourceIDs, id)
+		}
+	}
+	if len(sourceIDs) == 0 {
+		return nil, utils.ErrInvalidInput
+	}
+
+	var result *models.DiveSiteMergeResult
+	err := s.transactor.WithinTransaction(ctx, func(_ DiveRepository, sites DiveSiteRepository) error {
+		target, err := sites.GetByID(ctx, targetID)
+		if err != nil {
+			return err
+		}
+		for _, id := range sourceIDs {
+			if _, err := sites.GetByID(ctx, id); err != nil {
+				return err
+			}
+		}
+		moved, err := sites.ReassignDives(ctx, sourceIDs, targetID)
+		if err != nil {
+			return err
+		}
+		for _, id := range sourceIDs {
+			if err := sites.DeleteDiveSite(ctx, id); err != nil {
+				return err
+			}
+		}
+		result = &models.DiveSiteMergeResult{Target: *target, MergedSiteIDs: sourceIDs, ReassignedDives: moved}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Duplicates reports pairs of nearby sites whose names suggest they are the
+// same place, scored by name similarity and distance.
+func (s *DiveSiteService) Duplicates(ctx context.Context, request models.DiveSiteDuplicatesRequest) ([]models.DiveSiteDuplicateCandidate, error) {
+	pairs, err := s.repo.FindSitePairsWithin(ctx, request.MaxDistanceKM, maxDuplicatePairs)
+	if err != nil {
+		return nil, err
+	}
+	return scoreDuplicateCandidates(pairs, request.MaxDistanceKM, request.MinScore, request.Limit), nil
+}
+
+// BackfillLocations reverse-geocodes existing sites. Without overwrite it only
+// fills missing country and region values; with overwrite it replaces them
+// for every site the dataset covers.
+func (s *DiveSiteService) BackfillLocations(ctx context.Context, overwrite bool) (*models.GeocodeBackfillResult, error) {
+	if s.geocoder == nil {
+		return nil, errors.New("no reverse geocoder configured")
+	}
+	result := &models.GeocodeBackfillResult{}
+	afterID := 0
+	for {
+		sites, err := s.repo.FindSitesForGeocoding(ctx, afterID, geocodeBackfillBatchSize, overwrite)
+		if err != nil {
+			return nil, err
+		}
+		for _, site := range sites {
+			afterID = site.ID
+			result.Scanned++
+			place, ok := s.geocoder.Lookup(site.Latitude, site.Longitude)
+			if !ok {
+				result.Unmatched++
+				continue
+			}
+			updated, err := s.repo.SetDiveSitePlace(ctx, site.ID, place, overwrite)
+			if err != nil {
+				return nil, err
+			}
+			if updated {
+				result.Updated++
+			} else {
+				result.Unmatched++
+			}
+		}
+		if len(sites) < geocodeBackfillBatchSize {
+			return result, nil
+		}
+	}
+}
+
+// CountryStatistics groups userID's dives by the country of their site.
+func (s *DiveSiteService) CountryStatistics(ctx context.Context, userID int) ([]models.CountryStatistics, error) {
+	return s.repo.CountryStatistics(ctx, userID)
+}
+
+func findOrCreateDiveSite(ctx context.Context, sites DiveSiteRepository, geocoder ReverseGeocoder, name string, latitude, longitude float64) (*models.DiveSite, error) {
+	ctx, span := tracing.Start(ctx, "findOrCreateDiveSite", tracing.String("dive_site.name", name))
 	existing, err := findNearbyDiveSite(ctx, sites, name, latitude, longitude, 0)
 	if err != nil {
+		span.End(err)
 		return nil, err
 	}
 	if existing != nil {
+		span.SetAttributes(tracing.Bool("dive_site.created", false))
+		span.End(nil)
 		return existing, nil
 	}
-	return sites.CreateDiveSite(ctx, name, latitude, longitude, nil)
+	request := &models.DiveSiteRequest{Name: name, Latitude: latitude, Longitude: longitude}
+	fillPlace(geocoder, request)
+	site, err := sites.CreateDiveSite(ctx, request)
+	span.SetAttributes(tracing.Bool("dive_site.created", err == nil))
+	span.End(err)
+	return site, err
 }
 
 func findNearbyDiveSite(ctx context.Context, sites DiveSiteRepository, name string, latitude, longitude float64, excludeID int) (*models.DiveSite, error) {
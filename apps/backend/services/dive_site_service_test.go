@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"divelog-backend/metrics"
 	"divelog-backend/models"
 	"divelog-backend/utils"
 	"testing"
@@ -15,7 +16,7 @@ func newDiveSiteServiceHarness() (*DiveSiteService, *mockDiveRepository, *mockDi
 	dives := new(mockDiveRepository)
 	sites := new(mockDiveSiteRepository)
 	tx := &recordingTransactor{dives: dives, sites: sites}
-	return NewDiveSiteService(sites, tx), dives, sites, tx
+	return NewDiveSiteService(sites, tx, nil), dives, sites, tx
 }
 
 func TestDiveSiteServiceCreateRejectsNearbySameName(t *testing.T) {
@@ -30,7 +31,7 @@ func TestDiveSiteServiceCreateRejectsNearbySameName(t *testing.T) {
 	require.NotNil(t, site)
 	assert.Equal(t, existing.ID, site.ID)
 	assert.Equal(t, 1, tx.calls)
-	sites.AssertNotCalled(t, "CreateDiveSite", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	sites.AssertNotCalled(t, "CreateDiveSite", mock.Anything, mock.Anything)
 }
 
 func TestDiveSiteServiceCreateAllowsSameNameAtDistantLocation(t *testing.T) {
@@ -39,7 +40,7 @@ func TestDiveSiteServiceCreateAllowsSameNameAtDistantLocation(t *testing.T) {
 	distant := models.DiveSite{ID: 3, Name: request.Name, Latitude: 28.5721, Longitude: -80.6480}
 	created := &models.DiveSite{ID: 8, Name: request.Name, Latitude: request.Latitude, Longitude: request.Longitude}
 	sites.On("FindDiveSitesByName", mock.Anything, request.Name).Return([]models.DiveSite{distant}, nil).Once()
-	sites.On("CreateDiveSite", mock.Anything, request.Name, request.Latitude, request.Longitude, request.Description).Return(created, nil).Once()
+	sites.On("CreateDiveSite", mock.Anything, request).Return(created, nil).Once()
 
 	site, err := service.Create(context.Background(), request)
 
@@ -77,3 +78,90 @@ func TestCalculateDistance(t *testing.T) {
 	assert.Zero(t, calculateDistance(40.7128, -74.0060, 40.7128, -74.0060))
 	assert.Less(t, calculateDistance(40.7128, -74.0060, 40.7129, -74.0061), nearbyDiveSiteDistanceKM)
 }
+
+func TestDiveSiteServiceImportSkipsDuplicatesWithinAndAcrossImports(t *testing.T) {
+	service, _, sites, tx := newDiveSiteServiceHarness()
+	collection := &models.GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []models.GeoJSONFeature{
+		{Type: "Feature", Geometry: &models.GeoJSONGeometry{Type: "Point", Coordinates: []float64{-121.8947, 36.6002}}, Properties: map[string]interface{}{"name": "Monterey Bay"}},
+		{Type: "Feature", Geometry: &models.GeoJSONGeometry{Type: "Point", Coordinates: []float64{-87.5346, 17.3156}}, Properties: map[string]interface{}{"name": "Blue Hole"}},
+	}}
+	existing := models.DiveSite{ID: 7, Name: "Monterey Bay", Latitude: 36.6003, Longitude: -121.8948}
+	created := &models.DiveSite{ID: 8, Name: "Blue Hole", Latitude: 17.3156, Longitude: -87.5346}
+	sites.On("FindDiveSitesByName", mock.Anything, "Monterey Bay").Return([]models.DiveSite{existing}, nil).Once()
+	sites.On("FindDiveSitesByName", mock.Anything, "Blue Hole").Return([]models.DiveSite{}, nil).Once()
+	sites.On("CreateDiveSite", mock.Anything, &models.DiveSiteRequest{Name: "Blue Hole", Latitude: 17.3156, Longitude: -87.5346}).Return(created, nil).Once()
+	skipped := metrics.ImportDuplicatesSkipped.Value("dive_sites")
+
+	result, err := service.Import(context.Background(), collection)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, tx.calls)
+	assert.Equal(t, []models.DiveSite{*created}, result.Created)
+	assert.Equal(t, []models.SkippedDiveSiteImport{{Index: 0, Name: "Monterey Bay", ExistingID: 7}}, result.Skipped)
+	assert.Equal(t, skipped+1, metrics.ImportDuplicatesSkipped.Value("dive_sites"))
+	sites.AssertExpectations(t)
+}
+
+func TestDiveSiteServiceMergeReassignsDivesAndDeletesSources(t *testing.T) {
+	service, _, sites, tx := newDiveSiteServiceHarness()
+	target := &models.DiveSite{ID: 3, Name: "Blue Hole"}
+	sites.On("GetByID", mock.Anything, 3).Return(target, nil).Once()
+	sites.On("GetByID", mock.Anything, 4).Return(&models.DiveSite{ID: 4}, nil).Once()
+	sites.On("GetByID", mock.Anything, 9).Return(&models.DiveSite{ID: 9}, nil).Once()
+	sites.On("ReassignDives", mock.Anything, []int{4, 9}, 3).Return(int64(7), nil).Once()
+	sites.On("DeleteDiveSite", mock.Anything, 4).Return(nil).Once()
+	sites.On("DeleteDiveSite", mock.Anything, 9).Return(nil).Once()
+
+	result, err := service.Merge(context.Background(), 3, models.MergeDiveSitesRequest{SourceSiteIDs: []int{4, 3, 9, 4}})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, tx.calls)
+	assert.Equal(t, &models.DiveSiteMergeResult{Target: *target, MergedSiteIDs: []int{4, 9}, ReassignedDives: 7}, result)
+	sites.AssertExpectations(t)
+}
+
+func TestDiveSiteServiceMergeStopsWhenSourceIsMissing(t *testing.T) {
+	service, _, sites, _ := newDiveSiteServiceHarness()
+	sites.On("GetByID", mock.Anything, 3).Return(&models.DiveSite{ID: 3}, nil).Once()
+	sites.On("GetByID", mock.Anything, 4).Return(nil, utils.ErrDiveSiteNotFound).Once()
+
+	_, err := service.Merge(context.Background(), 3, models.MergeDiveSitesRequest{SourceSiteIDs: []int{4}})
+
+	assert.ErrorIs(t, err, utils.ErrDiveSiteNotFound)
+	sites.AssertNotCalled(t, "ReassignDives", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDiveSiteServiceMergeStopsOnDuplicateDives(t *testing.T) {
+	service, _, sites, tx := newDiveSiteServiceHarness()
+	sites.On("GetByID", mock.Anything, 3).Return(&models.DiveSite{ID: 3}, nil).Once()
+	sites.On("GetByID", mock.Anything, 4).Return(&models.DiveSite{ID: 4}, nil).Once()
+	sites.On("ReassignDives", mock.Anything, []int{4}, 3).Return(int64(0), utils.ErrDuplicateDive).Once()
+
+	_, err := service.Merge(context.Background(), 3, models.MergeDiveSitesRequest{SourceSiteIDs: []int{4}})
+
+	assert.ErrorIs(t, err, utils.ErrDuplicateDive)
+	assert.Equal(t, 1, tx.calls)
+	sites.AssertNotCalled(t, "DeleteDiveSite", mock.Anything, mock.Anything)
+}
+
+func TestDiveSiteServiceMergeRejectsSelfMerge(t *testing.T) {
+	service, _, _, tx := newDiveSiteServiceHarness()
+
+	_, err := service.Merge(context.Background(), 3, models.MergeDiveSitesRequest{SourceSiteIDs: []int{3}})
+
+	assert.ErrorIs(t, err, utils.ErrInvalidInput)
+	assert.Equal(t, 0, tx.calls)
+}
+
+func TestDiveSiteServiceGetDetailAddsUserHistory(t *testing.T) {
+	service, _, sites, _ := newDiveSiteServiceHarness()
+	site := &models.DiveSite{ID: 4, Name: "Lighthouse"}
+	history := &models.DiveSiteHistory{DiveCount: 2, TotalDuration: 95}
+	sites.On("GetByID", mock.Anything, 4).Return(site, nil).Once()
+	sites.On("GetHistory", mock.Anything, 4, 7).Return(history, nil).Once()
+
+	detail, err := service.GetDetail(context.Background(), 4, 7)
+
+	require.NoError(t, err)
+	assert.Equal(t, &models.DiveSiteDetail{DiveSite: *site, DiveHistory: history}, detail)
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"divelog-backend/models"
+	"math"
+)
+
+const (
+	kilometersPerDegreeLatitude = 111.32
+	maxGeohashPrecision         = 12
+)
+
+// boundingBoxAround returns the smallest latitude/longitude box containing the
+// circle of radiusKM around a point. Boxes that reach a pole span every
+// longitude; boxes that cross the antimeridian have West > East.
+func boundingBoxAround(latitude, longitude, radiusKM float64) models.BoundingBox {
+	deltaLatitude := radiusKM / kilometersPerDegreeLatitude
+	box := models.BoundingBox{
+		South: math.Max(-90, latitude-deltaLatitude),
+		North: math.Min(90, latitude+deltaLatitude),
+		West:  -180,
+		East:  180,
+	}
+	if box.South == -90 || box.North == 90 {
+		return box
+	}
+
+	deltaLongitude := radiusKM / (kilometersPerDegreeLatitude * math.Cos(latitude*math.Pi/180))
+	if deltaLongitude >= 180 {
+		return box
+	}
+	box.West = normalizeLongitude(longitude - deltaLongitude)
+	box.East = normalizeLongitude(longitude + deltaLongitude)
+	return box
+}
+
+func normalizeLongitude(longitude float64) float64 {
+	if longitude < -180 {
+		return longitude + 360
+	}
+	if longitude > 180 {
+		return longitude - 360
+	}
+	return longitude
+}
+
+// geohashPrecisionForZoom picks the geohash length whose cells are no wider
+// than a quarter of a 256 px map tile at the given web-map zoom level, which
+// keeps clusters roughly 64 px apart on screen.
+func geohashPrecisionForZoom(zoom int) int {
+	target := 90 / math.Pow(2, float64(zoom))
+	for precision := 1; precision < maxGeohashPrecision; precision++ {
+		longitudeBits := (5*precision + 1) / 2
+		if 360/math.Pow(2, float64(longitudeBits)) <= target {
+			return precision
+		}
+	}
+	return maxGeohashPrecision
+}
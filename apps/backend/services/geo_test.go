@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundingBoxAroundEnclosesRadius(t *testing.T) {
+	box := boundingBoxAround(36.6, -121.9, 11.132)
+
+	assert.InDelta(t, 36.5, box.South, 0.0001)
+	assert.InDelta(t, 36.7, box.North, 0.0001)
+	assert.Less(t, box.West, -121.9-0.1)
+	assert.Greater(t, box.East, -121.9+0.1)
+	assert.False(t, box.CrossesAntimeridian())
+}
+
+func TestBoundingBoxAroundWrapsAntimeridian(t *testing.T) {
+	box := boundingBoxAround(-17.8, 179.9, 50)
+
+	assert.True(t, box.CrossesAntimeridian())
+	assert.Greater(t, box.West, 179.0)
+	assert.Less(t, box.East, -179.0)
+}
+
+func TestBoundingBoxAroundPoleSpansAllLongitudes(t *testing.T) {
+	box := boundingBoxAround(89.9, 10, 50)
+
+	assert.Equal(t, 90.0, box.North)
+	assert.Equal(t, -180.0, box.West)
+	assert.Equal(t, 180.0, box.East)
+}
+
+func TestGeohashPrecisionForZoom(t *testing.T) {
+	for zoom, expected := range map[int]int{0: 1, 3: 2, 5: 3, 8: 4, 10: 5, 13: 6, 22: 10} {
+		assert.Equal(t, expected, geohashPrecisionForZoom(zoom), "zoom %d", zoom)
+	}
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"divelog-backend/models"
+	"strings"
+)
+
+// ReverseGeocoder resolves coordinates to the country and region containing
+// them. geocoding.Geocoder implements it offline.
+type ReverseGeocoder interface {
+	Lookup(latitude, longitude float64) (models.Place, bool)
+}
+
+// fillPlace completes the country fields of a new site from its coordinates.
+// Values the caller supplied are kept, and the region is only filled when it
+// cannot contradict a caller-supplied country. geocoder may be nil.
+func fillPlace(geocoder ReverseGeocoder, request *models.DiveSiteRequest) {
+	if geocoder == nil {
+		return
+	}
+	place, ok := geocoder.Lookup(request.Latitude, request.Longitude)
+	if !ok {
+		return
+	}
+	if request.Country != nil && !strings.EqualFold(*request.Country, place.Country) {
+		return
+	}
+	if request.CountryCode == nil {
+		request.CountryCode = &place.CountryCode
+	}
+	if request.Country == nil {
+		request.Country = &place.Country
+	}
+	if request.Region == nil && place.Region != "" {
+		request.Region = &place.Region
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type stubGeocoder map[[2]float64]models.Place
+
+func (g stubGeocoder) Lookup(latitude, longitude float64) (models.Place, bool) {
+	place, ok := g[[2]float64{latitude, longitude}]
+	return place, ok
+}
+
+var dahab = models.Place{CountryCode: "EG", Country: "Egypt", Region: "South Sinai"}
+
+func TestFillPlaceKeepsCallerValues(t *testing.T) {
+	geocoder := stubGeocoder{{28.57, 34.54}: dahab}
+	region := "Dahab coast"
+	request := &models.DiveSiteRequest{Name: "Canyon", Latitude: 28.57, Longitude: 34.54,
+		DiveSiteAttributes: models.DiveSiteAttributes{Region: &region}}
+
+	fillPlace(geocoder, request)
+
+	assert.Equal(t, "EG", *request.CountryCode)
+	assert.Equal(t, "Egypt", *request.Country)
+	assert.Equal(t, "Dahab coast", *request.Region)
+}
+
+func TestFillPlaceSkipsConflictingCountry(t *testing.T) {
+	geocoder := stubGeocoder{{28.57, 34.54}: dahab}
+	country := "Israel"
+	request := &models.DiveSiteRequest{Name: "Border reef", Latitude: 28.57, Longitude: 34.54,
+		DiveSiteAttributes: models.DiveSiteAttributes{Country: &country}}
+
+	fillPlace(geocoder, request)
+	fillPlace(nil, request)
+
+	assert.Nil(t, request.CountryCode)
+	assert.Nil(t, request.Region)
+}
+
+func TestDiveSiteServiceCreateGeocodesNewSite(t *testing.T) {
+	sites := new(mockDiveSiteRepository)
+	service := NewDiveSiteService(sites, &recordingTransactor{sites: sites}, stubGeocoder{{28.57, 34.54}: dahab})
+	request := &models.DiveSiteRequest{Name: "Canyon", Latitude: 28.57, Longitude: 34.54}
+	sites.On("FindDiveSitesByName", mock.Anything, "Canyon").Return([]models.DiveSite{}, nil).Once()
+	sites.On("CreateDiveSite", mock.Anything, mock.MatchedBy(func(request *models.DiveSiteRequest) bool {
+		return request.CountryCode != nil && *request.CountryCode == "EG" && *request.Region == "South Sinai"
+	})).Return(&models.DiveSite{ID: 4}, nil).Once()
+
+	_, err := service.Create(context.Background(), request)
+
+	require.NoError(t, err)
+	sites.AssertExpectations(t)
+}
+
+func TestDiveSiteServiceBackfillLocationsPagesAndCounts(t *testing.T) {
+	sites := new(mockDiveSiteRepository)
+	service := NewDiveSiteService(sites, &recordingTransactor{sites: sites}, stubGeocoder{{28.57, 34.54}: dahab, {28.49, 34.51}: dahab})
+	page := make([]models.DiveSite, geocodeBackfillBatchSize)
+	for i := range page {
+		page[i] = models.DiveSite{ID: i + 1, Latitude: 0, Longitude: -140}
+	}
+	page[0] = models.DiveSite{ID: 1, Latitude: 28.57, Longitude: 34.54}
+	sites.On("FindSitesForGeocoding", mock.Anything, 0, geocodeBackfillBatchSize, false).Return(page, nil).Once()
+	sites.On("FindSitesForGeocoding", mock.Anything, geocodeBackfillBatchSize, geocodeBackfillBatchSize, false).
+		Return([]models.DiveSite{{ID: 900, Latitude: 28.49, Longitude: 34.51}}, nil).Once()
+	sites.On("SetDiveSitePlace", mock.Anything, 1, dahab, false).Return(true, nil).Once()
+	sites.On("SetDiveSitePlace", mock.Anything, 900, dahab, false).Return(false, nil).Once()
+
+	result, err := service.BackfillLocations(context.Background(), false)
+
+	require.NoError(t, err)
+	assert.Equal(t, &models.GeocodeBackfillResult{Scanned: geocodeBackfillBatchSize + 1, Updated: 1, Unmatched: geocodeBackfillBatchSize}, result)
+	sites.AssertExpectations(t)
+}
+
+func TestDiveSiteServiceBackfillLocationsRequiresGeocoder(t *testing.T) {
+	service, _, sites, _ := newDiveSiteServiceHarness()
+
+	_, err := service.BackfillLocations(context.Background(), true)
+
+	assert.Error(t, err)
+	sites.AssertNotCalled(t, "FindSitesForGeocoding", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -2,7 +2,12 @@ package services
 
 import (
 	"context"
+	"divelog-backend/metrics"
 	"divelog-backend/models"
+	"divelog-backend/tracing"
+	"divelog-backend/utils"
+	"errors"
+	"strings"
 )
 
 type LogbookRepository interface {
@@ -12,7 +17,8 @@ type LogbookRepository interface {
 	DeleteTag(context.Context, int, int) error
 	GetTrips(context.Context, int) ([]models.Trip, error)
 	CreateTrip(context.Context, int, models.TripRequest) (*models.Trip, error)
-	UpdateTrip(context.Context, int, int, models.TripRequest) (*models.Trip, error)
+	GetTrip(context.Context, int, int) (*models.Trip, error)
+	UpdateTrip(context.Context, int, int, models.TripRequest, *int) (*models.Trip, error)
 	DeleteTrip(context.Context, int, int) error
 	MergeTrips(context.Context, int, int, []int) error
 	SplitTrip(context.Context, int, int, models.SplitTripRequest) (*models.Trip, error)
@@ -33,50 +39,118 @@ func NewLogbookService(repository LogbookRepository) *LogbookService {
 }
 
 func (s *LogbookService) GetTags(ctx context.Context, userID int) ([]models.TagSummary, error) {
-	return s.repository.GetTags(ctx, userID)
+	ctx, span := startSpan(ctx, "LogbookService.GetTags", userID)
+	tags, err := s.repository.GetTags(ctx, userID)
+	span.End(err)
+	return tags, err
 }
 func (s *LogbookService) CreateTag(ctx context.Context, userID int, request models.TagRequest) (*models.TagSummary, error) {
-	return s.repository.CreateTag(ctx, userID, request.Name)
+	ctx, span := startSpan(ctx, "LogbookService.CreateTag", userID)
+	tag, err := s.repository.CreateTag(ctx, userID, request.Name)
+	span.End(err)
+	return tag, err
 }
 func (s *LogbookService) UpdateTag(ctx context.Context, userID, id int, request models.TagRequest) (*models.TagSummary, error) {
-	return s.repository.UpdateTag(ctx, userID, id, request.Name)
+	ctx, span := startSpan(ctx, "LogbookService.UpdateTag", userID, tracing.Int("tag_id", id))
+	tag, err := s.repository.UpdateTag(ctx, userID, id, request.Name)
+	span.End(err)
+	return tag, err
 }
 func (s *LogbookService) DeleteTag(ctx context.Context, userID, id int) error {
-	return s.repository.DeleteTag(ctx, userID, id)
+	ctx, span := startSpan(ctx, "LogbookService.DeleteTag", userID, tracing.Int("tag_id", id))
+	err := s.repository.DeleteTag(ctx, userID, id)
+	span.End(err)
+	return err
 }
 func (s *LogbookService) GetTrips(ctx context.Context, userID int) ([]models.Trip, error) {
-	return s.repository.GetTrips(ctx, userID)
+	ctx, span := startSpan(ctx, "LogbookService.GetTrips", userID)
+	trips, err := s.repository.GetTrips(ctx, userID)
+	span.End(err)
+	return trips, err
 }
 func (s *LogbookService) CreateTrip(ctx context.Context, userID int, request models.TripRequest) (*models.Trip, error) {
-	return s.repository.CreateTrip(ctx, userID, request)
+	ctx, span := startSpan(ctx, "LogbookService.CreateTrip", userID)
+	trip, err := s.repository.CreateTrip(ctx, userID, request)
+	span.End(err)
+	return trip, err
 }
-func (s *LogbookService) UpdateTrip(ctx context.Context, userID, id int, request models.TripRequest) (*models.Trip, error) {
-	return s.repository.UpdateTrip(ctx, userID, id, request)
+
+// UpdateTrip overwrites a trip. When expectedVersion is set and the trip has
+// moved on since, nothing is written and a *models.VersionConflict describes
+// the current trip and how the request differs from it.
+func (s *LogbookService) UpdateTrip(ctx context.Context, userID, id int, request models.TripRequest, expectedVersion *int) (*models.Trip, error) {
+	ctx, span := startSpan(ctx, "LogbookService.UpdateTrip", userID, tracing.Int("trip_id", id))
+	trip, err := s.updateTrip(ctx, userID, id, request, expectedVersion)
+	span.End(err)
+	return trip, err
+}
+
+func (s *LogbookService) updateTrip(ctx context.Context, userID, id int, request models.TripRequest, expectedVersion *int) (*models.Trip, error) {
+	trip, err := s.repository.UpdateTrip(ctx, userID, id, request, expectedVersion)
+	if !errors.Is(err, utils.ErrVersionConflict) {
+		return trip, err
+	}
+	current, err := s.repository.GetTrip(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	return nil, models.NewVersionConflict(current.Version, current, current.ToRequest(), request)
 }
 func (s *LogbookService) DeleteTrip(ctx context.Context, userID, id int) error {
-	return s.repository.DeleteTrip(ctx, userID, id)
+	ctx, span := startSpan(ctx, "LogbookService.DeleteTrip", userID, tracing.Int("trip_id", id))
+	err := s.repository.DeleteTrip(ctx, userID, id)
+	span.End(err)
+	return err
 }
 func (s *LogbookService) MergeTrips(ctx context.Context, userID, targetID int, request models.MergeTripsRequest) error {
-	return s.repository.MergeTrips(ctx, userID, targetID, request.SourceTripIDs)
+	ctx, span := startSpan(ctx, "LogbookService.MergeTrips", userID, tracing.Int("trip_id", targetID))
+	err := s.repository.MergeTrips(ctx, userID, targetID, request.SourceTripIDs)
+	span.End(err)
+	return err
 }
 func (s *LogbookService) SplitTrip(ctx context.Context, userID, sourceID int, request models.SplitTripRequest) (*models.Trip, error) {
-	return s.repository.SplitTrip(ctx, userID, sourceID, request)
+	ctx, span := startSpan(ctx, "LogbookService.SplitTrip", userID, tracing.Int("trip_id", sourceID))
+	trip, err := s.repository.SplitTrip(ctx, userID, sourceID, request)
+	span.End(err)
+	return trip, err
 }
 func (s *LogbookService) RenumberDives(ctx context.Context, userID int, request models.RenumberDivesRequest) (int64, error) {
-	return s.repository.RenumberDives(ctx, userID, request)
+	ctx, span := startSpan(ctx, "LogbookService.RenumberDives", userID)
+	affected, err := s.repository.RenumberDives(ctx, userID, request)
+	span.End(err)
+	return affected, err
 }
 func (s *LogbookService) BulkUpdateDives(ctx context.Context, userID int, request models.BulkDiveUpdateRequest) (int64, error) {
-	return s.repository.BulkUpdateDives(ctx, userID, request)
+	ctx, span := startSpan(ctx, "LogbookService.BulkUpdateDives", userID, tracing.Int("dive_count", len(request.DiveIDs)))
+	affected, err := s.repository.BulkUpdateDives(ctx, userID, request)
+	span.End(err)
+	return affected, err
 }
 func (s *LogbookService) BulkDeleteDives(ctx context.Context, userID int, request models.BulkDiveDeleteRequest) (int64, error) {
-	return s.repository.BulkDeleteDives(ctx, userID, request.DiveIDs)
+	ctx, span := startSpan(ctx, "LogbookService.BulkDeleteDives", userID, tracing.Int("dive_count", len(request.DiveIDs)))
+	affected, err := s.repository.BulkDeleteDives(ctx, userID, request.DiveIDs)
+	span.End(err)
+	return affected, err
 }
 func (s *LogbookService) ShiftDiveTimes(ctx context.Context, userID int, request models.ShiftDiveTimesRequest) (*models.BulkOperation, error) {
-	return s.repository.ShiftDiveTimes(ctx, userID, request)
+	ctx, span := startSpan(ctx, "LogbookService.ShiftDiveTimes", userID, tracing.Int("dive_count", len(request.DiveIDs)))
+	operation, err := s.repository.ShiftDiveTimes(ctx, userID, request)
+	span.End(err)
+	return operation, err
 }
 func (s *LogbookService) LatestUndoableOperation(ctx context.Context, userID int) (*models.BulkOperation, error) {
-	return s.repository.LatestUndoableOperation(ctx, userID)
+	ctx, span := startSpan(ctx, "LogbookService.LatestUndoableOperation", userID)
+	operation, err := s.repository.LatestUndoableOperation(ctx, userID)
+	span.End(err)
+	return operation, err
 }
 func (s *LogbookService) UndoBulkOperation(ctx context.Context, userID int, operationID string) (*models.BulkOperation, error) {
-	return s.repository.UndoBulkOperation(ctx, userID, operationID)
+	ctx, span := startSpan(ctx, "LogbookService.UndoBulkOperation", userID, tracing.String("operation_id", operationID))
+	operation, err := s.repository.UndoBulkOperation(ctx, userID, operationID)
+	if err == nil {
+		metrics.UndoOperations.Inc(operation.OperationType)
+	}
+	span.End(err)
+	return operation, err
 }
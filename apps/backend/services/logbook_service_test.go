@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tripLogbookRepository stubs the trip reads and writes of LogbookRepository.
+type tripLogbookRepository struct {
+	LogbookRepository
+	trip      *models.Trip
+	updateErr error
+	expected  *int
+}
+
+func (r *tripLogbookRepository) GetTrip(ctx context.Context, userID, tripID int) (*models.Trip, error) {
+	if r.trip == nil {
+		return nil, utils.ErrTripNotFound
+	}
+	return r.trip, nil
+}
+
+func (r *tripLogbookRepository) UpdateTrip(ctx context.Context, userID, tripID int, request models.TripRequest, expectedVersion *int) (*models.Trip, error) {
+	r.expected = expectedVersion
+	if r.updateErr != nil {
+		return nil, r.updateErr
+	}
+	return &models.Trip{ID: tripID, Name: request.Name, Version: 2}, nil
+}
+
+func TestLogbookServiceUpdateTripPassesExpectedVersion(t *testing.T) {
+	repository := &tripLogbookRepository{}
+	service := NewLogbookService(repository)
+	expected := 1
+
+	trip, err := service.UpdateTrip(context.Background(), 3, 8, models.TripRequest{Name: "Red Sea"}, &expected)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, trip.Version)
+	assert.Equal(t, &expected, repository.expected)
+}
+
+func TestLogbookServiceUpdateTripReportsConflictWithDiff(t *testing.T) {
+	location := "Hurghada"
+	current := &models.Trip{ID: 8, Name: "Red Sea 2026", Location: &location, Version: 5}
+	service := NewLogbookService(&tripLogbookRepository{trip: current, updateErr: utils.ErrVersionConflict})
+	expected := 4
+
+	_, err := service.UpdateTrip(context.Background(), 3, 8, models.TripRequest{Name: " Red Sea "}, &expected)
+
+	var conflict *models.VersionConflict
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, 5, conflict.Version)
+	assert.Same(t, current, conflict.Current)
+	assert.Equal(t, []models.FieldDiff{
+		{Field: "location", Server: "Hurghada", Client: nil},
+		{Field: "name", Server: "Red Sea 2026", Client: "Red Sea"},
+	}, conflict.Diff)
+}
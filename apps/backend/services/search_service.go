@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+)
+
+// SearchRepository is the persistence contract used by SearchService.
+type SearchRepository interface {
+	SearchDives(context.Context, int, string, int) ([]models.SearchHit, error)
+	SearchDiveSites(context.Context, string, int) ([]models.SearchHit, error)
+	SearchTrips(context.Context, int, string, int) ([]models.SearchHit, error)
+}
+
+type SearchService struct {
+	repository SearchRepository
+}
+
+func NewSearchService(repository SearchRepository) *SearchService {
+	return &SearchService{repository: repository}
+}
+
+// Search returns up to request.Limit ranked hits for each entity type.
+func (s *SearchService) Search(ctx context.Context, userID int, request models.SearchRequest) (*models.SearchResults, error) {
+	results := &models.SearchResults{Query: request.Query}
+	var err error
+	if results.Dives, err = s.repository.SearchDives(ctx, userID, request.Query, request.Limit); err != nil {
+		return nil, err
+	}
+	if results.DiveSites, err = s.repository.SearchDiveSites(ctx, request.Query, request.Limit); err != nil {
+		return nil, err
+	}
+	if results.Trips, err = s.repository.SearchTrips(ctx, userID, request.Query, request.Limit); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
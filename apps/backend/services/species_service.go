@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+)
+
+// SpeciesRepository is the persistence contract used by SpeciesService.
+type SpeciesRepository interface {
+	ListSpecies(context.Context, models.SpeciesFilter) ([]models.Species, error)
+	GetSpecies(context.Context, int) (*models.Species, error)
+	CreateSpecies(context.Context, models.SpeciesRequest) (*models.Species, error)
+	GetDiveSightings(context.Context, int, int) ([]models.Sighting, error)
+	ReplaceDiveSightings(context.Context, int, int, []models.SightingRequest) ([]models.Sighting, error)
+	SightingSites(context.Context, int, int) ([]models.SpeciesSightingSite, error)
+	LifeList(context.Context, int, models.SpeciesFilter) ([]models.LifeListEntry, error)
+}
+
+type SpeciesService struct {
+	repository SpeciesRepository
+}
+
+func NewSpeciesService(repository SpeciesRepository) *SpeciesService {
+	return &SpeciesService{repository: repository}
+}
+
+func (s *SpeciesService) ListSpecies(ctx context.Context, filter models.SpeciesFilter) ([]models.Species, error) {
+	return s.repository.ListSpecies(ctx, filter)
+}
+
+func (s *SpeciesService) CreateSpecies(ctx context.Context, request models.SpeciesRequest) (*models.Species, error) {
+	return s.repository.CreateSpecies(ctx, request)
+}
+
+func (s *SpeciesService) GetDiveSightings(ctx context.Context, userID, diveID int) ([]models.Sighting, error) {
+	return s.repository.GetDiveSightings(ctx, userID, diveID)
+}
+
+func (s *SpeciesService) ReplaceDiveSightings(ctx context.Context, userID, diveID int, request models.DiveSightingsRequest) ([]models.Sighting, error) {
+	return s.repository.ReplaceDiveSightings(ctx, userID, diveID, request.Sightings)
+}
+
+// Sightings reports where userID has seen a species, grouped by site, with
+// totals across all sites.
+func (s *SpeciesService) Sightings(ctx context.Context, userID, speciesID int) (*models.SpeciesSightings, error) {
+	species, err := s.repository.GetSpecies(ctx, speciesID)
+	if err != nil {
+		return nil, err
+	}
+	sites, err := s.repository.SightingSites(ctx, userID, speciesID)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.SpeciesSightings{Species: *species, Sites: sites}
+	for _, site := range sites {
+		report.DiveCount += site.DiveCount
+		report.TotalCount += site.TotalCount
+	}
+	return report, nil
+}
+
+// LifeList returns every species userID has logged, first sighting first.
+func (s *SpeciesService) LifeList(ctx context.Context, userID int, filter models.SpeciesFilter) ([]models.LifeListEntry, error) {
+	return s.repository.LifeList(ctx, userID, filter)
+}
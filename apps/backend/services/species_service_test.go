@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSpeciesRepository struct{ mock.Mock }
+
+func (m *mockSpeciesRepository) ListSpecies(ctx context.Context, filter models.SpeciesFilter) ([]models.Species, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]models.Species), args.Error(1)
+}
+func (m *mockSpeciesRepository) GetSpecies(ctx context.Context, id int) (*models.Species, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Species), args.Error(1)
+}
+func (m *mockSpeciesRepository) CreateSpecies(ctx context.Context, request models.SpeciesRequest) (*models.Species, error) {
+	args := m.Called(ctx, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Species), args.Error(1)
+}
+func (m *mockSpeciesRepository) GetDiveSightings(ctx context.Context, userID, diveID int) ([]models.Sighting, error) {
+	args := m.Called(ctx, userID, diveID)
+	return args.Get(0).([]models.Sighting), args.Error(1)
+}
+func (m *mockSpeciesRepository) ReplaceDiveSightings(ctx context.Context, userID, diveID int, sightings []models.SightingRequest) ([]models.Sighting, error) {
+	args := m.Called(ctx, userID, diveID, sightings)
+	return args.Get(0).([]models.Sighting), args.Error(1)
+}
+func (m *mockSpeciesRepository) SightingSites(ctx context.Context, userID, speciesID int) ([]models.SpeciesSightingSite, error) {
+	args := m.Called(ctx, userID, speciesID)
+	return args.Get(0).([]models.SpeciesSightingSite), args.Error(1)
+}
+func (m *mockSpeciesRepository) LifeList(ctx context.Context, userID int, filter models.SpeciesFilter) ([]models.LifeListEntry, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).([]models.LifeListEntry), args.Error(1)
+}
+
+func TestSpeciesServiceSightingsTotalsSites(t *testing.T) {
+	repository := new(mockSpeciesRepository)
+	service := NewSpeciesService(repository)
+	species := &models.Species{ID: 17, CommonName: "Reef manta ray", Category: "ray"}
+	siteID := 4
+	repository.On("GetSpecies", mock.Anything, 17).Return(species, nil).Once()
+	repository.On("SightingSites", mock.Anything, 42, 17).Return([]models.SpeciesSightingSite{
+		{DiveSiteID: &siteID, Name: "Manta Point", DiveCount: 3, TotalCount: 11},
+		{Name: "Unnamed drift", DiveCount: 1, TotalCount: 2},
+	}, nil).Once()
+
+	report, err := service.Sightings(context.Background(), 42, 17)
+
+	require.NoError(t, err)
+	assert.Equal(t, *species, report.Species)
+	assert.Equal(t, 4, report.DiveCount)
+	assert.Equal(t, 13, report.TotalCount)
+	assert.Len(t, report.Sites, 2)
+}
+
+func TestSpeciesServiceSightingsReportsUnknownSpecies(t *testing.T) {
+	repository := new(mockSpeciesRepository)
+	service := NewSpeciesService(repository)
+	repository.On("GetSpecies", mock.Anything, 999).Return(nil, utils.ErrSpeciesNotFound).Once()
+
+	_, err := service.Sightings(context.Background(), 42, 999)
+
+	assert.ErrorIs(t, err, utils.ErrSpeciesNotFound)
+	repository.AssertNotCalled(t, "SightingSites", mock.Anything, mock.Anything, mock.Anything)
+}
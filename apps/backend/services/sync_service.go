@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+)
+
+// SyncRepository reads the change feed and the current state of the entities
+// it names.
+type SyncRepository interface {
+	LatestSeq(context.Context, int) (int64, error)
+	ChangesSince(context.Context, int, int64, int) ([]models.SyncChange, error)
+	Dives(context.Context, int, []int) ([]models.Dive, error)
+	DiveSites(context.Context, []int) ([]models.DiveSite, error)
+	Trips(context.Context, int, []int) ([]models.Trip, error)
+	Tags(context.Context, int, []int) ([]models.TagSummary, error)
+	Settings(context.Context, int) (*models.UserSettings, error)
+}
+
+// SyncTransactor runs a sync read against one consistent snapshot.
+type SyncTransactor interface {
+	WithinSyncSnapshot(context.Context, func(SyncRepository) error) error
+}
+
+type SyncService struct {
+	transactor SyncTransactor
+}
+
+func NewSyncService(transactor SyncTransactor) *SyncService {
+	return &SyncService{transactor: transactor}
+}
+
+// Changes returns the next page of userID's change feed after request.Since.
+// The feed keeps only the latest change per entity, so each entity appears at
+// most once per page with its current state.
+func (s *SyncService) Changes(ctx context.Context, userID int, request models.SyncRequest) (*models.SyncResponse, error) {
+	var response *models.SyncResponse
+	err := s.transactor.WithinSyncSnapshot(ctx, func(feed SyncRepository) error {
+		latest, err := feed.LatestSeq(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if request.Since > latest {
+			return utils.ErrSyncCursorUnknown
+		}
+		changes, err := feed.ChangesSince(ctx, userID, request.Since, request.Limit+1)
+		if err != nil {
+			return err
+		}
+		response = &models.SyncResponse{Changes: changes, Cursor: latest}
+		if len(changes) > request.Limit {
+			response.Changes = changes[:request.Limit]
+			response.Cursor = response.Changes[request.Limit-1].Seq
+			response.HasMore = true
+		}
+		return attachSyncData(ctx, feed, userID, response.Changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// attachSyncData loads the current state of every upserted entity. An entity
+// that no longer exists, or that the user can no longer see, is reported as
+// deleted.
+func attachSyncData(ctx context.Context, feed SyncRepository, userID int, changes []models.SyncChange) error {
+	upserted := map[string][]int{}
+	for _, change := range changes {
+		if change.Operation == models.SyncOperationUpsert {
+			upserted[change.EntityType] = append(upserted[change.EntityType], change.EntityID)
+		}
+	}
+
+	data := map[string]map[int]interface{}{}
+	for entityType, ids := range upserted {
+		entities := map[int]interface{}{}
+		switch entityType {
+		case models.SyncEntityDive:
+			dives, err := feed.Dives(ctx, userID, ids)
+			if err != nil {
+				return err
+			}
+			for i := range dives {
+				entities[dives[i].ID] = &dives[i]
+			}
+		case models.SyncEntityDiveSite:
+			sites, err := feed.DiveSites(ctx, ids)
+			if err != nil {
+				return err
+			}
+			for i := range sites {
+				entities[sites[i].ID] = &sites[i]
+			}
+		case models.SyncEntityTrip:
+			trips, err := feed.Trips(ctx, userID, ids)
+			if err != nil {
+				return err
+			}
+			for i := range trips {
+				entities[trips[i].ID] = &trips[i]
+			}
+		case models.SyncEntityTag:
+			tags, err := feed.Tags(ctx, userID, ids)
+			if err != nil {
+				return err
+			}
+			for i := range tags {
+				entities[tags[i].ID] = &tags[i]
+			}
+		case models.SyncEntitySettings:
+			settings, err := feed.Settings(ctx, userID)
+			if err != nil {
+				return err
+			}
+			entities[settings.ID] = settings
+		}
+		data[entityType] = entities
+	}
+
+	for i := range changes {
+		if changes[i].Operation != models.SyncOperationUpsert {
+			continue
+		}
+		entity, ok := data[changes[i].EntityType][changes[i].EntityID]
+		if !ok {
+			changes[i].Operation = models.SyncOperationDelete
+			continue
+		}
+		changes[i].Data = entity
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSyncRepository struct {
+	mock.Mock
+}
+
+func (m *mockSyncRepository) LatestSeq(ctx context.Context, userID int) (int64, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *mockSyncRepository) ChangesSince(ctx context.Context, userID int, since int64, limit int) ([]models.SyncChange, error) {
+	args := m.Called(ctx, userID, since, limit)
+	return args.Get(0).([]models.SyncChange), args.Error(1)
+}
+func (m *mockSyncRepository) Dives(ctx context.Context, userID int, ids []int) ([]models.Dive, error) {
+	args := m.Called(ctx, userID, ids)
+	return args.Get(0).([]models.Dive), args.Error(1)
+}
+func (m *mockSyncRepository) DiveSites(ctx context.Context, ids []int) ([]models.DiveSite, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]models.DiveSite), args.Error(1)
+}
+func (m *mockSyncRepository) Trips(ctx context.Context, userID int, ids []int) ([]models.Trip, error) {
+	args := m.Called(ctx, userID, ids)
+	return args.Get(0).([]models.Trip), args.Error(1)
+}
+func (m *mockSyncRepository) Tags(ctx context.Context, userID int, ids []int) ([]models.TagSummary, error) {
+	args := m.Called(ctx, userID, ids)
+	return args.Get(0).([]models.TagSummary), args.Error(1)
+}
+func (m *mockSyncRepository) Settings(ctx context.Context, userID int) (*models.UserSettings, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UserSettings), args.Error(1)
+}
+
+type snapshotTransactor struct {
+	feed  SyncRepository
+	calls int
+}
+
+func (t *snapshotTransactor) WithinSyncSnapshot(ctx context.Context, operation func(SyncRepository) error) error {
+	t.calls++
+	return operation(t.feed)
+}
+
+func syncChange(seq int64, entityType string, id int, operation string) models.SyncChange {
+	return models.SyncChange{Seq: seq, EntityType: entityType, EntityID: id, Operation: operation,
+		ChangedAt: time.Date(2026, 8, 10, 9, 0, 0, 0, time.UTC)}
+}
+
+func TestSyncServiceAttachesCurrentStateAndTombstones(t *testing.T) {
+	feed := new(mockSyncRepository)
+	tx := &snapshotTransactor{feed: feed}
+	service := NewSyncService(tx)
+	feed.On("LatestSeq", mock.Anything, 3).Return(int64(12), nil)
+	feed.On("ChangesSince", mock.Anything, 3, int64(4), 101).Return([]models.SyncChange{
+		syncChange(5, models.SyncEntityDiveSite, 9, models.SyncOperationUpsert),
+		syncChange(6, models.SyncEntityDive, 40, models.SyncOperationUpsert),
+		syncChange(8, models.SyncEntityDive, 41, models.SyncOperationDelete),
+		syncChange(10, models.SyncEntityTag, 2, models.SyncOperationUpsert),
+		syncChange(12, models.SyncEntitySettings, 1, models.SyncOperationUpsert),
+	}, nil)
+	feed.On("DiveSites", mock.Anything, []int{9}).Return([]models.DiveSite{{ID: 9, Name: "Breakwater"}}, nil)
+	feed.On("Dives", mock.Anything, 3, []int{40}).Return([]models.Dive{{ID: 40, UserID: 3}}, nil)
+	feed.On("Tags", mock.Anything, 3, []int{2}).Return([]models.TagSummary{}, nil)
+	feed.On("Settings", mock.Anything, 3).Return(&models.UserSettings{ID: 1, UserID: 3}, nil)
+
+	response, err := service.Changes(context.Background(), 3, models.SyncRequest{Since: 4, Limit: 100})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, tx.calls)
+	assert.Equal(t, int64(12), response.Cursor)
+	assert.False(t, response.HasMore)
+	require.Len(t, response.Changes, 5)
+	assert.Equal(t, "Breakwater", response.Changes[0].Data.(*models.DiveSite).Name)
+	assert.Equal(t, 40, response.Changes[1].Data.(*models.Dive).ID)
+	assert.Nil(t, response.Changes[2].Data)
+	assert.Equal(t, models.SyncOperationDelete, response.Changes[3].Operation, "a vanished tag becomes a tombstone")
+	assert.Nil(t, response.Changes[3].Data)
+	assert.Equal(t, 3, response.Changes[4].Data.(*models.UserSettings).UserID)
+	feed.AssertNotCalled(t, "Trips", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSyncServicePagesWithCursorOfLastChange(t *testing.T) {
+	feed := new(mockSyncRepository)
+	service := NewSyncService(&snapshotTransactor{feed: feed})
+	feed.On("LatestSeq", mock.Anything, 3).Return(int64(30), nil)
+	feed.On("ChangesSince", mock.Anything, 3, int64(0), 3).Return([]models.SyncChange{
+		syncChange(2, models.SyncEntityTrip, 1, models.SyncOperationDelete),
+		syncChange(7, models.SyncEntityTrip, 2, models.SyncOperationDelete),
+		syncChange(9, models.SyncEntityTrip, 3, models.SyncOperationDelete),
+	}, nil)
+
+	response, err := service.Changes(context.Background(), 3, models.SyncRequest{Limit: 2})
+
+	require.NoError(t, err)
+	assert.True(t, response.HasMore)
+	assert.Equal(t, int64(7), response.Cursor)
+	assert.Len(t, response.Changes, 2)
+}
+
+func TestSyncServiceRejectsCursorAheadOfFeed(t *testing.T) {
+	feed := new(mockSyncRepository)
+	service := NewSyncService(&snapshotTransactor{feed: feed})
+	feed.On("LatestSeq", mock.Anything, 3).Return(int64(5), nil)
+
+	response, err := service.Changes(context.Background(), 3, models.SyncRequest{Since: 6, Limit: 10})
+
+	assert.Nil(t, response)
+	assert.ErrorIs(t, err, utils.ErrSyncCursorUnknown)
+	feed.AssertNotCalled(t, "ChangesSince", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSyncServiceEmptyFeedReturnsLatestCursor(t *testing.T) {
+	feed := new(mockSyncRepository)
+	service := NewSyncService(&snapshotTransactor{feed: feed})
+	feed.On("LatestSeq", mock.Anything, 3).Return(int64(5), nil)
+	feed.On("ChangesSince", mock.Anything, 3, int64(5), 11).Return([]models.SyncChange{}, nil)
+
+	response, err := service.Changes(context.Background(), 3, models.SyncRequest{Since: 5, Limit: 10})
+
+	require.NoError(t, err)
+	assert.Empty(t, response.Changes)
+	assert.Equal(t, int64(5), response.Cursor)
+}
@@ -0,0 +1,11 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/tracing"
+)
+
+// startSpan begins a span for a service operation on behalf of userID.
+func startSpan(ctx context.Context, name string, userID int, attributes ...tracing.Attribute) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, name, append([]tracing.Attribute{tracing.Int("user_id", userID)}, attributes...)...)
+}
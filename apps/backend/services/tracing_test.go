@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/tracing"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSpanExporter struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (e *recordingSpanExporter) Export(_ context.Context, _ string, spans []tracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestDiveServiceBatchTracesSiteResolution(t *testing.T) {
+	exporter := &recordingSpanExporter{}
+	provider := tracing.NewProvider("divelog-test", exporter, nil)
+	tracing.SetProvider(provider)
+	defer tracing.SetProvider(nil)
+
+	service, dives, sites, _ := newServiceTestHarness()
+	request := serviceTestRequest()
+	site := &models.DiveSite{ID: 4}
+	sites.On("FindDiveSitesByName", mock.Anything, request.Location).Return([]models.DiveSite{}, nil).Once()
+	sites.On("CreateDiveSite", mock.Anything, mock.Anything).Return(site, nil).Once()
+	dives.On("CheckDuplicateDive", mock.Anything, 8, site.ID, request.DateTime).Return(true, nil).Once()
+
+	_, err := service.CreateMultipleDives(context.Background(), 8, []models.DiveRequest{request})
+	require.NoError(t, err)
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	require.Len(t, exporter.spans, 2)
+	resolve, batch := exporter.spans[0], exporter.spans[1]
+	assert.Equal(t, "findOrCreateDiveSite", resolve.Name)
+	assert.Equal(t, batch.SpanID, resolve.ParentSpanID)
+	assert.Contains(t, resolve.Attributes, tracing.Bool("dive_site.created", true))
+	assert.Equal(t, "DiveService.CreateMultipleDives", batch.Name)
+	assert.Equal(t, []tracing.Attribute{
+		tracing.Int("user_id", 8),
+		tracing.Int("dive_count", 1),
+		tracing.Int("created_count", 0),
+		tracing.Int("skipped_count", 1),
+	}, batch.Attributes)
+}
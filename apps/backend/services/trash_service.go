@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/tracing"
+	"divelog-backend/utils"
+	"log/slog"
+	"time"
+)
+
+// TrashRepository is the persistence contract used by TrashService.
+type TrashRepository interface {
+	ListTrash(context.Context, int, string) ([]models.TrashItem, error)
+	RestoreDive(context.Context, int, int) error
+	RestoreItem(context.Context, int, string, int) error
+	Purge(context.Context, int, string, int) error
+	EmptyTrash(context.Context, int) (int64, error)
+	PurgeDeletedBefore(context.Context, time.Time) (int64, error)
+}
+
+// TrashService restores and purges deleted items, and purges them for good
+// once they have been in the trash for the retention period.
+type TrashService struct {
+	repository TrashRepository
+	retention  time.Duration
+	now        func() time.Time
+}
+
+func NewTrashService(repository TrashRepository, retention time.Duration) *TrashService {
+	return &TrashService{repository: repository, retention: retention, now: time.Now}
+}
+
+// List returns the user's trash with the time each item will be purged.
+func (s *TrashService) List(ctx context.Context, userID int, filter models.TrashFilter) ([]models.TrashItem, error) {
+	ctx, span := startSpan(ctx, "TrashService.List", userID)
+	items, err := s.repository.ListTrash(ctx, userID, filter.Type)
+	for i := range items {
+		items[i].PurgeAt = items[i].DeletedAt.Add(s.retention)
+	}
+	span.End(err)
+	return items, err
+}
+
+// Restore takes an item out of the trash.
+func (s *TrashService) Restore(ctx context.Context, userID int, itemType string, id int) error {
+	ctx, span := startSpan(ctx, "TrashService.Restore", userID, tracing.String("item_type", itemType), tracing.Int("item_id", id))
+	var err error
+	if itemType == models.AuditEntityDive {
+		err = s.repository.RestoreDive(ctx, userID, id)
+	} else {
+		err = s.repository.RestoreItem(ctx, userID, itemType, id)
+	}
+	span.End(err)
+	return err
+}
+
+func (s *TrashService) Purge(ctx context.Context, userID int, itemType string, id int) error {
+	ctx, span := startSpan(ctx, "TrashService.Purge", userID, tracing.String("item_type", itemType), tracing.Int("item_id", id))
+	err := s.repository.Purge(ctx, userID, itemType, id)
+	span.End(err)
+	return err
+}
+
+func (s *TrashService) Empty(ctx context.Context, userID int) (*models.TrashPurgeResult, error) {
+	ctx, span := startSpan(ctx, "TrashService.Empty", userID)
+	purged, err := s.repository.EmptyTrash(ctx, userID)
+	span.End(err)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TrashPurgeResult{Purged: purged}, nil
+}
+
+// PurgeExpired deletes every item that has been in the trash for longer than
+// the retention period and returns how many went.
+func (s *TrashService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.repository.PurgeDeletedBefore(ctx, s.now().Add(-s.retention))
+}
+
+// RunRetention purges expired items every interval until ctx is cancelled.
+func (s *TrashService) RunRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if purged, err := s.PurgeExpired(ctx); err != nil {
+			utils.LogError(ctx, "Failed to purge expired trash", err)
+		} else if purged > 0 {
+			utils.LogInfo(ctx, "Purged expired trash", slog.Int64("purged", purged))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockTrashRepository struct {
+	mock.Mock
+}
+
+func (m *mockTrashRepository) ListTrash(ctx context.Context, userID int, itemType string) ([]models.TrashItem, error) {
+	args := m.Called(ctx, userID, itemType)
+	items, _ := args.Get(0).([]models.TrashItem)
+	return items, args.Error(1)
+}
+
+func (m *mockTrashRepository) RestoreDive(ctx context.Context, userID, diveID int) error {
+	return m.Called(ctx, userID, diveID).Error(0)
+}
+
+func (m *mockTrashRepository) RestoreItem(ctx context.Context, userID int, itemType string, id int) error {
+	return m.Called(ctx, userID, itemType, id).Error(0)
+}
+
+func (m *mockTrashRepository) Purge(ctx context.Context, userID int, itemType string, id int) error {
+	return m.Called(ctx, userID, itemType, id).Error(0)
+}
+
+func (m *mockTrashRepository) EmptyTrash(ctx context.Context, userID int) (int64, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockTrashRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestTrashServiceListReportsPurgeTime(t *testing.T) {
+	repository := new(mockTrashRepository)
+	service := NewTrashService(repository, 30*24*time.Hour)
+	deletedAt := time.Date(2026, time.March, 1, 9, 30, 0, 0, time.UTC)
+	repository.On("ListTrash", mock.Anything, 4, models.AuditEntityTrip).
+		Return([]models.TrashItem{{Type: models.AuditEntityTrip, ID: 3, Name: "Red Sea", DeletedAt: deletedAt}}, nil)
+
+	items, err := service.List(context.Background(), 4, models.TrashFilter{Type: models.AuditEntityTrip})
+
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, time.Date(2026, time.March, 31, 9, 30, 0, 0, time.UTC), items[0].PurgeAt)
+}
+
+func TestTrashServiceRestoreDiveUsesDiveRestore(t *testing.T) {
+	repository := new(mockTrashRepository)
+	service := NewTrashService(repository, time.Hour)
+	repository.On("RestoreDive", mock.Anything, 4, 12).Return(nil).Once()
+
+	require.NoError(t, service.Restore(context.Background(), 4, models.AuditEntityDive, 12))
+
+	repository.AssertExpectations(t)
+	repository.AssertNotCalled(t, "RestoreItem", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTrashServiceRestoreTagReportsConflict(t *testing.T) {
+	repository := new(mockTrashRepository)
+	service := NewTrashService(repository, time.Hour)
+	repository.On("RestoreItem", mock.Anything, 4, models.AuditEntityTag, 8).Return(utils.ErrOrganizationConflict).Once()
+
+	err := service.Restore(context.Background(), 4, models.AuditEntityTag, 8)
+
+	assert.ErrorIs(t, err, utils.ErrOrganizationConflict)
+	repository.AssertExpectations(t)
+	repository.AssertNotCalled(t, "RestoreDive", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTrashServicePurgeExpiredUsesRetentionCutoff(t *testing.T) {
+	repository := new(mockTrashRepository)
+	service := NewTrashService(repository, 7*24*time.Hour)
+	service.now = func() time.Time { return time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC) }
+	repository.On("PurgeDeletedBefore", mock.Anything, time.Date(2026, time.October, 11, 12, 0, 0, 0, time.UTC)).
+		Return(int64(3), nil).Once()
+
+	purged, err := service.PurgeExpired(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	repository.AssertExpectations(t)
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// webhookMaxAttempts bounds how often a delivery is tried before it fails
+	// for good; with the backoff below the last retry comes roughly eight and a
+	// half hours after the first attempt.
+	webhookMaxAttempts = 10
+	webhookBaseBackoff = time.Minute
+	webhookMaxBackoff  = 6 * time.Hour
+	webhookBatchSize   = 20
+	webhookTimeout     = 10 * time.Second
+	// webhookLease must outlast a batch of timed-out requests so a delivery
+	// is not claimed twice while it is still being sent.
+	webhookLease = webhookBatchSize*webhookTimeout + time.Minute
+	// webhookMaxErrorLength bounds the stored error message.
+	webhookMaxErrorLength = 500
+	// webhookMaxDrainLength bounds how much of a response body is read, and
+	// discarded, so the connection can be reused.
+	webhookMaxDrainLength = 64 << 10
+)
+
+// WebhookOutbox is the delivery queue drained by WebhookDispatcher.
+type WebhookOutbox interface {
+	ClaimDueDeliveries(context.Context, int, time.Duration) ([]models.PendingWebhookDelivery, error)
+	RecordDeliveryAttempt(context.Context, int64, models.WebhookAttempt) error
+}
+
+// WebhookDispatcher posts queued webhook deliveries and schedules retries with
+// exponential backoff.
+type WebhookDispatcher struct {
+	outbox       WebhookOutbox
+	client       *http.Client
+	interval     time.Duration
+	allowAddress func(netip.Addr) bool
+	now          func() time.Time
+}
+
+// NewWebhookDispatcher returns a dispatcher that polls outbox every interval.
+// Redirects are not followed so a receiver cannot bounce payloads elsewhere,
+// and connections are only opened to public addresses. That check runs on
+// the address actually dialled, so a host that resolved to a public address
+// when it was subscribed cannot later be rebound to an internal one.
+func NewWebhookDispatcher(outbox WebhookOutbox, interval time.Duration) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		outbox:       outbox,
+		interval:     interval,
+		allowAddress: utils.IsPublicAddress,
+		now:          time.Now,
+	}
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: d.controlDial}
+	d.client = &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: webhookTimeout},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return d
+}
+
+// controlDial refuses to connect to an address the dispatcher may not reach.
+// It runs after DNS resolution, for every address tried.
+func (d *WebhookDispatcher) controlDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !d.allowAddress(addrPort.Addr()) {
+		return fmt.Errorf("refusing to connect to non-public address %s", addrPort.Addr())
+	}
+	return nil
+}
+
+// Run dispatches due deliveries until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		d.DispatchDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue sends every delivery that is currently due and returns how many
+// were attempted.
+func (d *WebhookDispatcher) DispatchDue(ctx context.Context) int {
+	attempted := 0
+	for ctx.Err() == nil {
+		deliveries, err := d.outbox.ClaimDueDeliveries(ctx, webhookBatchSize, webhookLease)
+		if err != nil || len(deliveries) == 0 {
+			return attempted
+		}
+		for _, delivery := range deliveries {
+			attempt := d.send(ctx, delivery)
+			if err := d.outbox.RecordDeliveryAttempt(ctx, delivery.ID, attempt); err != nil {
+				return attempted
+			}
+			attempted++
+		}
+		if len(deliveries) < webhookBatchSize {
+			return attempted
+		}
+	}
+	return attempted
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, delivery models.PendingWebhookDelivery) models.WebhookAttempt {
+	timestamp := d.now()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return d.failedAttempt(ctx, delivery, nil, err.Error())
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "divelog-webhooks/1")
+	request.Header.Set("X-Divelog-Event", delivery.EventType)
+	request.Header.Set("X-Divelog-Delivery", delivery.EventID)
+	request.Header.Set("X-Divelog-Signature", SignWebhookPayload(delivery.Secret, timestamp, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return d.failedAttempt(ctx, delivery, nil, err.Error())
+	}
+	defer response.Body.Close()
+	// The body is never stored: it could echo back whatever the receiver's
+	// network exposes, and the status code says all subscribers need.
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, webhookMaxDrainLength))
+	statusCode := response.StatusCode
+	if statusCode >= 200 && statusCode < 300 {
+		return models.WebhookAttempt{Succeeded: true, StatusCode: &statusCode}
+	}
+	return d.failedAttempt(ctx, delivery, &statusCode, fmt.Sprintf("receiver responded with status %d", statusCode))
+}
+
+// failedAttempt schedules the next try, or none once the delivery has used up
+// its attempts.
+func (d *WebhookDispatcher) failedAttempt(ctx context.Context, delivery models.PendingWebhookDelivery, statusCode *int, message string) models.WebhookAttempt {
+	if len(message) > webhookMaxErrorLength {
+		message = message[:webhookMaxErrorLength]
+	}
+	attempt := models.WebhookAttempt{StatusCode: statusCode, Error: &message}
+	attempts := delivery.Attempts + 1
+	if attempts < webhookMaxAttempts {
+		next := d.now().Add(webhookBackoff(attempts))
+		attempt.NextAttemptAt = &next
+	} else {
+		utils.LogWarn(ctx, "Webhook delivery failed permanently",
+			slog.Int64("delivery_id", delivery.ID), slog.String("event_type", delivery.EventType))
+	}
+	return attempt
+}
+
+// webhookBackoff returns the wait after the given number of failed attempts:
+// one minute, doubling each time, capped at six hours.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// SignWebhookPayload returns the X-Divelog-Signature header value for body:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<body>">". Receivers
+// recompute the HMAC with their secret and reject stale timestamps.
+func SignWebhookPayload(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + unix + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
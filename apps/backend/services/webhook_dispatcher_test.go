@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"divelog-backend/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryOutbox hands out queued deliveries once and records the attempts.
+type memoryOutbox struct {
+	mu       sync.Mutex
+	queued   []models.PendingWebhookDelivery
+	attempts map[int64]models.WebhookAttempt
+}
+
+func (o *memoryOutbox) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.PendingWebhookDelivery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	claimed := o.queued[:min(limit, len(o.queued))]
+	o.queued = o.queued[len(claimed):]
+	return claimed, nil
+}
+
+func (o *memoryOutbox) RecordDeliveryAttempt(ctx context.Context, id int64, attempt models.WebhookAttempt) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.attempts == nil {
+		o.attempts = map[int64]models.WebhookAttempt{}
+	}
+	o.attempts[id] = attempt
+	return nil
+}
+
+// webhookReceiver is a stand-in subscriber that checks signatures the way the
+// README tells integrators to.
+type webhookReceiver struct {
+	secret   string
+	status   int
+	mu       sync.Mutex
+	bodies   []string
+	headers  []http.Header
+	verified []bool
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	body, _ := io.ReadAll(request.Body)
+	signature := request.Header.Get("X-Divelog-Signature")
+	valid := false
+	if parts := strings.Split(signature, ","); len(parts) == 2 && strings.HasPrefix(parts[0], "t=") {
+		unix, err := strconv.ParseInt(strings.TrimPrefix(parts[0], "t="), 10, 64)
+		expected := SignWebhookPayload(r.secret, time.Unix(unix, 0), body)
+		valid = err == nil && hmac.Equal([]byte(expected), []byte(signature))
+	}
+	r.mu.Lock()
+	r.bodies = append(r.bodies, string(body))
+	r.headers = append(r.headers, request.Header.Clone())
+	r.verified = append(r.verified, valid)
+	r.mu.Unlock()
+	w.WriteHeader(r.status)
+	_, _ = w.Write([]byte("receiver says hi"))
+}
+
+func newDispatcherHarness(t *testing.T, status int) (*WebhookDispatcher, *memoryOutbox, *webhookReceiver, string) {
+	receiver := &webhookReceiver{secret: "whsec_test", status: status}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	outbox := &memoryOutbox{}
+	dispatcher := NewWebhookDispatcher(outbox, time.Second)
+	// httptest listens on loopback, which the dispatcher refuses by default
+	dispatcher.allowAddress = func(netip.Addr) bool { return true }
+	dispatcher.now = func() time.Time { return time.Date(2026, 8, 10, 9, 0, 0, 0, time.UTC) }
+	return dispatcher, outbox, receiver, server.URL
+}
+
+func TestWebhookDispatcherDeliversSignedPayload(t *testing.T) {
+	dispatcher, outbox, receiver, url := newDispatcherHarness(t, http.StatusNoContent)
+	outbox.queued = []models.PendingWebhookDelivery{{
+		ID: 7, EventID: "evt_1", EventType: models.WebhookEventDiveCreated,
+		Payload: []byte(`{"id":"evt_1","type":"dive.created"}`), URL: url + "/hooks", Secret: "whsec_test",
+	}}
+
+	attempted := dispatcher.DispatchDue(context.Background())
+
+	assert.Equal(t, 1, attempted)
+	require.Len(t, receiver.bodies, 1)
+	assert.True(t, receiver.verified[0], "signature should verify with the subscription secret")
+	assert.Equal(t, `{"id":"evt_1","type":"dive.created"}`, receiver.bodies[0])
+	assert.Equal(t, "dive.created", receiver.headers[0].Get("X-Divelog-Event"))
+	assert.Equal(t, "evt_1", receiver.headers[0].Get("X-Divelog-Delivery"))
+	assert.Equal(t, "application/json", receiver.headers[0].Get("Content-Type"))
+	attempt := outbox.attempts[7]
+	assert.True(t, attempt.Succeeded)
+	require.NotNil(t, attempt.StatusCode)
+	assert.Equal(t, http.StatusNoContent, *attempt.StatusCode)
+}
+
+func TestWebhookDispatcherSignatureFailsWithOtherSecret(t *testing.T) {
+	dispatcher, outbox, receiver, url := newDispatcherHarness(t, http.StatusOK)
+	outbox.queued = []models.PendingWebhookDelivery{{ID: 1, Payload: []byte(`{}`), URL: url, Secret: "whsec_rotated"}}
+
+	dispatcher.DispatchDue(context.Background())
+
+	require.Len(t, receiver.verified, 1)
+	assert.False(t, receiver.verified[0])
+}
+
+func TestWebhookDispatcherSchedulesRetryOnReceiverError(t *testing.T) {
+	dispatcher, outbox, _, url := newDispatcherHarness(t, http.StatusServiceUnavailable)
+	outbox.queued = []models.PendingWebhookDelivery{{ID: 3, Payload: []byte(`{}`), Attempts: 2, URL: url, Secret: "whsec_test"}}
+
+	dispatcher.DispatchDue(context.Background())
+
+	attempt := outbox.attempts[3]
+	assert.False(t, attempt.Succeeded)
+	require.NotNil(t, attempt.StatusCode)
+	assert.Equal(t, http.StatusServiceUnavailable, *attempt.StatusCode)
+	require.NotNil(t, attempt.Error)
+	assert.Equal(t, "receiver responded with status 503", *attempt.Error)
+	require.NotNil(t, attempt.NextAttemptAt)
+	assert.Equal(t, dispatcher.now().Add(4*time.Minute), *attempt.NextAttemptAt)
+}
+
+func TestWebhookDispatcherRefusesNonPublicAddresses(t *testing.T) {
+	receiver := &webhookReceiver{secret: "whsec_test", status: http.StatusOK}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	outbox := &memoryOutbox{}
+	outbox.queued = []models.PendingWebhookDelivery{{ID: 6, Payload: []byte(`{}`), URL: server.URL, Secret: "whsec_test"}}
+
+	NewWebhookDispatcher(outbox, time.Second).DispatchDue(context.Background())
+
+	assert.Empty(t, receiver.bodies)
+	attempt := outbox.attempts[6]
+	assert.False(t, attempt.Succeeded)
+	require.NotNil(t, attempt.Error)
+	assert.Contains(t, *attempt.Error, "non-public address 127.0.0.1")
+}
+
+func TestWebhookDispatcherDoesNotFollowRedirects(t *testing.T) {
+	dispatcher, outbox, _, url := newDispatcherHarness(t, http.StatusFound)
+	outbox.queued = []models.PendingWebhookDelivery{{ID: 4, Payload: []byte(`{}`), URL: url, Secret: "whsec_test"}}
+
+	dispatcher.DispatchDue(context.Background())
+
+	attempt := outbox.attempts[4]
+	assert.False(t, attempt.Succeeded)
+	require.NotNil(t, attempt.StatusCode)
+	assert.Equal(t, http.StatusFound, *attempt.StatusCode)
+}
+
+func TestWebhookDispatcherGivesUpAfterLastAttempt(t *testing.T) {
+	dispatcher, outbox, _, _ := newDispatcherHarness(t, http.StatusOK)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	outbox.queued = []models.PendingWebhookDelivery{{
+		ID: 5, Payload: []byte(`{}`), Attempts: webhookMaxAttempts - 1, URL: unreachable.URL, Secret: "whsec_test",
+	}}
+
+	dispatcher.DispatchDue(context.Background())
+
+	attempt := outbox.attempts[5]
+	assert.False(t, attempt.Succeeded)
+	assert.Nil(t, attempt.StatusCode)
+	require.NotNil(t, attempt.Error)
+	assert.Nil(t, attempt.NextAttemptAt)
+}
+
+func TestWebhookBackoffDoublesUpToCap(t *testing.T) {
+	assert.Equal(t, time.Minute, webhookBackoff(1))
+	assert.Equal(t, 2*time.Minute, webhookBackoff(2))
+	assert.Equal(t, 256*time.Minute, webhookBackoff(9))
+	assert.Equal(t, webhookMaxBackoff, webhookBackoff(10))
+	assert.Equal(t, webhookMaxBackoff, webhookBackoff(40))
+}
+
+func TestSignWebhookPayloadFormat(t *testing.T) {
+	signature := SignWebhookPayload("secret", time.Unix(1700000000, 0), []byte(`{"a":1}`))
+
+	assert.Regexp(t, `^t=1700000000,v1=[0-9a-f]{64}$`, signature)
+	assert.NotEqual(t, signature, SignWebhookPayload("secret", time.Unix(1700000001, 0), []byte(`{"a":1}`)))
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"net/url"
+)
+
+// WebhookRepository is the persistence contract used by WebhookService.
+type WebhookRepository interface {
+	ListSubscriptions(context.Context, int) ([]models.WebhookSubscription, error)
+	GetSubscription(context.Context, int, int) (*models.WebhookSubscription, error)
+	CreateSubscription(context.Context, int, models.WebhookSubscriptionRequest, string) (*models.WebhookSubscription, error)
+	UpdateSubscription(context.Context, int, int, models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error)
+	RotateSecret(context.Context, int, int, string) (*models.WebhookSubscription, error)
+	DeleteSubscription(context.Context, int, int) error
+	ListDeliveries(context.Context, int, int, models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+}
+
+// HostResolver looks up the addresses of a webhook host. *net.Resolver
+// implements it.
+type HostResolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+type WebhookService struct {
+	repository WebhookRepository
+	resolver   HostResolver
+}
+
+func NewWebhookService(repository WebhookRepository) *WebhookService {
+	return &WebhookService{repository: repository, resolver: net.DefaultResolver}
+}
+
+func (s *WebhookService) List(ctx context.Context, userID int) ([]models.WebhookSubscription, error) {
+	return s.repository.ListSubscriptions(ctx, userID)
+}
+
+func (s *WebhookService) Get(ctx context.Context, userID, id int) (*models.WebhookSubscription, error) {
+	return s.repository.GetSubscription(ctx, userID, id)
+}
+
+// Create stores a subscription with a new signing secret. The secret is only
+// returned here and by RotateSecret.
+func (s *WebhookService) Create(ctx context.Context, userID int, request models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	if err := s.checkURL(ctx, request.URL); err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, utils.ErrProcessingFailed
+	}
+	subscription, err := s.repository.CreateSubscription(ctx, userID, request, secret)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = secret
+	return subscription, nil
+}
+
+func (s *WebhookService) Update(ctx context.Context, userID, id int, request models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	if err := s.checkURL(ctx, request.URL); err != nil {
+		return nil, err
+	}
+	return s.repository.UpdateSubscription(ctx, userID, id, request)
+}
+
+// checkURL refuses a webhook URL whose host does not resolve, or resolves to
+// any loopback, private, link-local or unspecified address. The dispatcher
+// checks the address again when it connects, since DNS answers can change.
+func (s *WebhookService) checkURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return utils.ErrWebhookURLNotAllowed
+	}
+	addresses, err := s.resolver.LookupNetIP(ctx, "ip", parsed.Hostname())
+	if err != nil || len(addresses) == 0 {
+		return utils.ErrWebhookURLNotAllowed
+	}
+	for _, address := range addresses {
+		if !utils.IsPublicAddress(address) {
+			return utils.ErrWebhookURLNotAllowed
+		}
+	}
+	return nil
+}
+
+// RotateSecret replaces a subscription's signing secret and returns the new one.
+func (s *WebhookService) RotateSecret(ctx context.Context, userID, id int) (*models.WebhookSubscription, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, utils.ErrProcessingFailed
+	}
+	subscription, err := s.repository.RotateSecret(ctx, userID, id, secret)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = secret
+	return subscription, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, userID, id int) error {
+	return s.repository.DeleteSubscription(ctx, userID, id)
+}
+
+func (s *WebhookService) Deliveries(ctx context.Context, userID, id int, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	return s.repository.ListDeliveries(ctx, userID, id, filter)
+}
+
+func newWebhookSecret() (string, error) {
+	value, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + value, nil
+}
+
+func randomHex(size int) (string, error) {
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(value), nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"divelog-backend/models"
+	"divelog-backend/utils"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockWebhookRepository struct {
+	mock.Mock
+}
+
+func (m *mockWebhookRepository) ListSubscriptions(ctx context.Context, userID int) ([]models.WebhookSubscription, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.WebhookSubscription), args.Error(1)
+}
+func (m *mockWebhookRepository) GetSubscription(ctx context.Context, userID, id int) (*models.WebhookSubscription, error) {
+	args := m.Called(ctx, userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSubscription), args.Error(1)
+}
+func (m *mockWebhookRepository) CreateSubscription(ctx context.Context, userID int, request models.WebhookSubscriptionRequest, secret string) (*models.WebhookSubscription, error) {
+	args := m.Called(ctx, userID, request, secret)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSubscription), args.Error(1)
+}
+func (m *mockWebhookRepository) UpdateSubscription(ctx context.Context, userID, id int, request models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	args := m.Called(ctx, userID, id, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSubscription), args.Error(1)
+}
+func (m *mockWebhookRepository) RotateSecret(ctx context.Context, userID, id int, secret string) (*models.WebhookSubscription, error) {
+	args := m.Called(ctx, userID, id, secret)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookSubscription), args.Error(1)
+}
+func (m *mockWebhookRepository) DeleteSubscription(ctx context.Context, userID, id int) error {
+	return m.Called(ctx, userID, id).Error(0)
+}
+func (m *mockWebhookRepository) ListDeliveries(ctx context.Context, userID, id int, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	args := m.Called(ctx, userID, id, filter)
+	return args.Get(0).([]models.WebhookDelivery), args.Error(1)
+}
+
+// staticResolver answers every lookup with the same addresses.
+type staticResolver []netip.Addr
+
+func (r staticResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	return r, nil
+}
+
+func TestWebhookServiceCreateReturnsGeneratedSecret(t *testing.T) {
+	repository := new(mockWebhookRepository)
+	service := NewWebhookService(repository)
+	service.resolver = staticResolver{netip.MustParseAddr("93.184.216.34")}
+	request := models.WebhookSubscriptionRequest{URL: "https://example.com/hook", Events: []string{models.WebhookEventDiveCreated}}
+	repository.On("CreateSubscription", mock.Anything, 4, request, mock.MatchedBy(func(secret string) bool {
+		return len(secret) == len("whsec_")+64 && secret[:6] == "whsec_"
+	})).Return(&models.WebhookSubscription{ID: 2, URL: request.URL}, nil).Once()
+
+	subscription, err := service.Create(context.Background(), 4, request)
+
+	require.NoError(t, err)
+	assert.Regexp(t, `^whsec_[0-9a-f]{64}$`, subscription.Secret)
+	repository.AssertExpectations(t)
+}
+
+func TestWebhookServiceRejectsHostsResolvingToPrivateAddresses(t *testing.T) {
+	repository := new(mockWebhookRepository)
+	service := NewWebhookService(repository)
+	service.resolver = staticResolver{netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.0.0.7")}
+	request := models.WebhookSubscriptionRequest{URL: "https://internal.example.com/hook", Events: []string{models.WebhookEventDiveCreated}}
+
+	_, err := service.Create(context.Background(), 4, request)
+	assert.ErrorIs(t, err, utils.ErrWebhookURLNotAllowed)
+	_, err = service.Update(context.Background(), 4, 2, request)
+	assert.ErrorIs(t, err, utils.ErrWebhookURLNotAllowed)
+
+	repository.AssertNotCalled(t, "CreateSubscription", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	repository.AssertNotCalled(t, "UpdateSubscription", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDiveServiceQueuesCreatedDiveInTransaction(t *testing.T) {
+	service, dives, sites, tx := newServiceTestHarness()
+	request := serviceTestRequest()
+	site := &models.DiveSite{ID: 17, Latitude: request.Lat, Longitude: request.Lng}
+	sites.On("FindDiveSitesByName", mock.Anything, request.Location).Return([]models.DiveSite{*site}, nil)
+	dives.On("CheckDuplicateDive", mock.Anything, 42, site.ID, request.DateTime).Return(false, nil).Once()
+	dives.On("CreateDive", mock.Anything, mock.AnythingOfType("*models.Dive")).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Dive).ID = 99
+	}).Return(nil).Once()
+
+	_, err := service.CreateDive(context.Background(), 42, request)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, tx.calls)
+	dives.AssertCalled(t, "EnqueueWebhookEvent", mock.Anything, 42, models.WebhookEventDiveCreated,
+		mock.MatchedBy(func(dive *models.Dive) bool { return dive.ID == 99 }))
+}
+
+func TestDiveServiceDoesNotQueueFailedChanges(t *testing.T) {
+	service, dives, sites, _ := newServiceTestHarness()
+	request := serviceTestRequest()
+	site := &models.DiveSite{ID: 17, Latitude: request.Lat, Longitude: request.Lng}
+	sites.On("FindDiveSitesByName", mock.Anything, request.Location).Return([]models.DiveSite{*site}, nil)
+	dives.On("CheckDuplicateDive", mock.Anything, 42, site.ID, request.DateTime).Return(true, nil).Once()
+	dives.On("DeleteDive", mock.Anything, 5, 42).Return(utils.ErrDiveNotFound).Once()
+
+	_, createErr := service.CreateDive(context.Background(), 42, request)
+	deleteErr := service.DeleteDive(context.Background(), 5, 42)
+
+	assert.ErrorIs(t, createErr, utils.ErrDuplicateDive)
+	assert.ErrorIs(t, deleteErr, utils.ErrDiveNotFound)
+	dives.AssertNotCalled(t, "EnqueueWebhookEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDiveServiceQueuesDeletedDiveInTransaction(t *testing.T) {
+	service, dives, _, tx := newServiceTestHarness()
+	dives.On("DeleteDive", mock.Anything, 5, 42).Return(nil).Once()
+
+	require.NoError(t, service.DeleteDive(context.Background(), 5, 42))
+
+	assert.Equal(t, 1, tx.calls)
+	dives.AssertCalled(t, "EnqueueWebhookEvent", mock.Anything, 42, models.WebhookEventDiveDeleted, models.DeletedDive{ID: 5})
+}
+
+func TestDiveServiceDeleteFailsWhenEventCannotBeQueued(t *testing.T) {
+	dives := new(mockDiveRepository)
+	tx := &recordingTransactor{dives: dives}
+	service := NewDiveService(dives, tx, nil)
+	dives.On("DeleteDive", mock.Anything, 5, 42).Return(nil).Once()
+	dives.On("EnqueueWebhookEvent", mock.Anything, 42, models.WebhookEventDiveDeleted, models.DeletedDive{ID: 5}).
+		Return(utils.ErrDatabaseError).Once()
+
+	// the transactor rolls the delete back when the operation returns an error
+	err := service.DeleteDive(context.Background(), 5, 42)
+
+	assert.ErrorIs(t, err, utils.ErrDatabaseError)
+	dives.AssertExpectations(t)
+}
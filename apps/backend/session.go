@@ -0,0 +1,21 @@
+package main
+
+import (
+	"crypto/rand"
+	"divelog-backend/config"
+	"divelog-backend/utils"
+)
+
+// sessionSecret returns the configured SESSION_SECRET, or a random one for
+// this process. Tokens signed with a random secret stop working on restart
+// and are not accepted by other replicas.
+func sessionSecret(cfg *config.Config) []byte {
+	if cfg.Auth.SessionSecret != "" {
+		return []byte(cfg.Auth.SessionSecret)
+	}
+	utils.LogWarn(nil, "SESSION_SECRET is not set; using a random secret, so CSRF tokens will not survive a restart")
+	secret := make([]byte, 32)
+	// rand.Read never returns an error; it crashes the process instead
+	_, _ = rand.Read(secret)
+	return secret
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"divelog-backend/config"
+	"divelog-backend/tracing"
+	"divelog-backend/utils"
+	"fmt"
+	"os"
+)
+
+// newTraceProvider builds the span exporter named by OTEL_TRACES_EXPORTER.
+// It returns nil when tracing is disabled.
+func newTraceProvider(cfg *config.Config) (*tracing.Provider, error) {
+	var exporter tracing.Exporter
+	switch cfg.Tracing.Exporter {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+		exporter = tracing.NewOTLPExporter(cfg.Tracing.OTLPEndpoint, cfg.Tracing.OTLPHeaders)
+	case "console", "stdout":
+		exporter = tracing.NewStdoutExporter(os.Stdout)
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q; use otlp, console or none", cfg.Tracing.Exporter)
+	}
+	return tracing.NewProvider(cfg.Tracing.ServiceName, exporter, func(err error) {
+		utils.LogError(nil, "Failed to export traces", err)
+	}), nil
+}
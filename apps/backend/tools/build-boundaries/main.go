@@ -0,0 +1,143 @@
+// Command build-boundaries converts the Natural Earth admin-1 layer, exported
+// as GeoJSON, into the compact boundary file embedded by the geocoding
+// package. See geocoding/data/README.md.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// coordinatePrecision rounds to roughly 100 m, far finer than the offshore
+// matching distance.
+const coordinatePrecision = 1000
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+type sourceCollection struct {
+	Features []struct {
+		Properties map[string]interface{} `json:"properties"`
+		Geometry   struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+type outputFeature struct {
+	Type       string            `json:"type"`
+	Properties map[string]string `json:"properties"`
+	Geometry   outputGeometry    `json:"geometry"`
+}
+
+type outputGeometry struct {
+	Type        string           `json:"type"`
+	Coordinates [][][][2]float64 `json:"coordinates"`
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: build-boundaries <natural-earth-admin1.geojson>")
+		os.Exit(2)
+	}
+	input, err := os.Open(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer input.Close()
+	if err := convert(input, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func convert(r io.Reader, w io.Writer) error {
+	var source sourceCollection
+	if err := json.NewDecoder(r).Decode(&source); err != nil {
+		return fmt.Errorf("decode input: %w", err)
+	}
+
+	features := []outputFeature{}
+	for i, feature := range source.Features {
+		code := strings.ToUpper(stringProperty(feature.Properties, "iso_a2"))
+		if !countryCodePattern.MatchString(code) {
+			continue
+		}
+		var polygons [][][][2]float64
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var single [][][2]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &single); err != nil {
+				return fmt.Errorf("feature %d: %w", i, err)
+			}
+			polygons = [][][][2]float64{single}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygons); err != nil {
+				return fmt.Errorf("feature %d: %w", i, err)
+			}
+		default:
+			continue
+		}
+
+		simplified := [][][][2]float64{}
+		for _, polygon := range polygons {
+			rings := [][][2]float64{}
+			for _, ring := range polygon {
+				if rounded := roundRing(ring); len(rounded) >= 4 {
+					rings = append(rings, rounded)
+				}
+			}
+			if len(rings) > 0 {
+				simplified = append(simplified, rings)
+			}
+		}
+		if len(simplified) == 0 {
+			continue
+		}
+		features = append(features, outputFeature{
+			Type: "Feature",
+			Properties: map[string]string{
+				"country_code": code,
+				"country":      stringProperty(feature.Properties, "admin"),
+				"region":       stringProperty(feature.Properties, "name"),
+			},
+			Geometry: outputGeometry{Type: "MultiPolygon", Coordinates: simplified},
+		})
+	}
+
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
+
+func stringProperty(properties map[string]interface{}, name string) string {
+	value, _ := properties[name].(string)
+	return strings.TrimSpace(value)
+}
+
+// roundRing rounds each coordinate and drops points that collapse onto their
+// predecessor, keeping the ring closed.
+func roundRing(ring [][2]float64) [][2]float64 {
+	rounded := make([][2]float64, 0, len(ring))
+	for _, coordinate := range ring {
+		next := [2]float64{
+			math.Round(coordinate[0]*coordinatePrecision) / coordinatePrecision,
+			math.Round(coordinate[1]*coordinatePrecision) / coordinatePrecision,
+		}
+		if len(rounded) > 0 && rounded[len(rounded)-1] == next {
+			continue
+		}
+		rounded = append(rounded, next)
+	}
+	if len(rounded) > 0 && rounded[0] != rounded[len(rounded)-1] {
+		rounded = append(rounded, rounded[0])
+	}
+	return rounded
+}
@@ -0,0 +1,210 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// OTLP/HTTP JSON encoding of a batch of spans. IDs are hex strings and 64-bit
+// integers are decimal strings, as the OTLP JSON mapping requires.
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+const instrumentationScope = "divelog-backend"
+
+func encodeOTLP(serviceName string, spans []SpanData) otlpTraces {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		item := otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        encodeAttributes(span.Attributes),
+			Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+		}
+		if span.ParentSpanID.IsValid() {
+			item.ParentSpanID = span.ParentSpanID.String()
+		}
+		encoded = append(encoded, item)
+	}
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttributes([]Attribute{String("service.name", serviceName)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: instrumentationScope}, Spans: encoded}},
+	}}}
+}
+
+func encodeAttributes(attributes []Attribute) []otlpKeyValue {
+	encoded := make([]otlpKeyValue, 0, len(attributes))
+	for _, attribute := range attributes {
+		var value otlpAnyValue
+		switch v := attribute.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case int64:
+			text := strconv.FormatInt(v, 10)
+			value.IntValue = &text
+		case float64:
+			value.DoubleValue = &v
+		case bool:
+			value.BoolValue = &v
+		default:
+			text := fmt.Sprint(v)
+			value.StringValue = &text
+		}
+		encoded = append(encoded, otlpKeyValue{Key: attribute.Key, Value: value})
+	}
+	return encoded
+}
+
+// OTLPExporter posts spans to an OpenTelemetry collector using OTLP over HTTP
+// with JSON encoding.
+type OTLPExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// NewOTLPExporter sends spans to endpoint, the full traces URL such as
+// http://localhost:4318/v1/traces, adding headers to every request.
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	return &OTLPExporter{endpoint: endpoint, headers: headers, client: &http.Client{}}
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, serviceName string, spans []SpanData) error {
+	body, err := json.Marshal(encodeOTLP(serviceName, spans))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		request.Header.Set(name, value)
+	}
+	response, err := e.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("export spans: %w", err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("export spans: collector answered %s", response.Status)
+	}
+	return nil
+}
+
+// StdoutExporter writes each span as one line of JSON, for local development.
+type StdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	return &StdoutExporter{w: w}
+}
+
+type stdoutSpan struct {
+	Service    string                 `json:"service"`
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_span_id,omitempty"`
+	Name       string                 `json:"name"`
+	Start      string                 `json:"start"`
+	DurationMS float64                `json:"duration_ms"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+func (e *StdoutExporter) Export(_ context.Context, serviceName string, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	encoder := json.NewEncoder(e.w)
+	for _, span := range spans {
+		line := stdoutSpan{
+			Service:    serviceName,
+			TraceID:    span.TraceID.String(),
+			SpanID:     span.SpanID.String(),
+			Name:       span.Name,
+			Start:      span.Start.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+			DurationMS: float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+		}
+		if span.ParentSpanID.IsValid() {
+			line.ParentID = span.ParentSpanID.String()
+		}
+		if len(span.Attributes) > 0 {
+			line.Attributes = make(map[string]interface{}, len(span.Attributes))
+			for _, attribute := range span.Attributes {
+				line.Attributes[attribute.Key] = attribute.Value
+			}
+		}
+		if span.Status == StatusError {
+			line.Error = span.StatusMessage
+			if line.Error == "" {
+				line.Error = "error"
+			}
+		}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
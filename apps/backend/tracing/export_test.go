@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSpan() SpanData {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	span := SpanData{
+		Name:          "DiveRepository.CreateDive",
+		Kind:          KindClient,
+		Start:         start,
+		End:           start.Add(1500 * time.Microsecond),
+		Attributes:    []Attribute{String("db.system", "postgresql"), Int("user_id", 7), Bool("cached", false)},
+		Status:        StatusError,
+		StatusMessage: "duplicate key",
+	}
+	copy(span.TraceID[:], []byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36})
+	copy(span.SpanID[:], []byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7})
+	copy(span.ParentSpanID[:], []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01})
+	return span
+}
+
+func TestOTLPExporterPostsJSON(t *testing.T) {
+	var body []byte
+	var header http.Header
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPExporter(collector.URL+"/v1/traces", map[string]string{"api-key": "secret"})
+	require.NoError(t, exporter.Export(context.Background(), "divelog-backend", []SpanData{testSpan()}))
+
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "secret", header.Get("api-key"))
+	assert.JSONEq(t, `{"resourceSpans":[{
+		"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"divelog-backend"}}]},
+		"scopeSpans":[{"scope":{"name":"divelog-backend"},"spans":[{
+			"traceId":"4bf92f3577b34da6a3ce929d0e0e4736",
+			"spanId":"00f067aa0ba902b7",
+			"parentSpanId":"0000000000000001",
+			"name":"DiveRepository.CreateDive",
+			"kind":3,
+			"startTimeUnixNano":"1717243200000000000",
+			"endTimeUnixNano":"1717243200001500000",
+			"attributes":[
+				{"key":"db.system","value":{"stringValue":"postgresql"}},
+				{"key":"user_id","value":{"intValue":"7"}},
+				{"key":"cached","value":{"boolValue":false}}
+			],
+			"status":{"code":2,"message":"duplicate key"}
+		}]}]
+	}]}`, string(body))
+}
+
+func TestOTLPExporterReportsRejectedBatches(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer collector.Close()
+
+	err := NewOTLPExporter(collector.URL, nil).Export(context.Background(), "divelog-backend", []SpanData{testSpan()})
+
+	assert.EqualError(t, err, "export spans: collector answered 400 Bad Request")
+}
+
+func TestStdoutExporterWritesJSONLines(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, NewStdoutExporter(&out).Export(context.Background(), "divelog-backend", []SpanData{testSpan(), testSpan()}))
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[0], &line))
+	assert.Equal(t, "DiveRepository.CreateDive", line["name"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", line["trace_id"])
+	assert.Equal(t, "0000000000000001", line["parent_span_id"])
+	assert.Equal(t, 1.5, line["duration_ms"])
+	assert.Equal(t, "duplicate key", line["error"])
+	assert.Equal(t, map[string]interface{}{"db.system": "postgresql", "user_id": float64(7), "cached": false}, line["attributes"])
+}
@@ -0,0 +1,230 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"runtime"
+	"strings"
+)
+
+// WrapConnector traces every query and statement run through connections from
+// connector. Each span is named after the function that issued the query, such
+// as DiveRepository.CheckDuplicateDive, and records the SQL text. A query span
+// ends when the first result is available, not when all rows have been read.
+func WrapConnector(connector driver.Connector) driver.Connector {
+	return &tracedConnector{connector}
+}
+
+type tracedConnector struct {
+	driver.Connector
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{conn}, nil
+}
+
+// tracedConn forwards every optional driver interface that database/sql looks
+// for, so wrapping a connection never changes how it is used.
+type tracedConn struct {
+	driver.Conn
+}
+
+var (
+	_ driver.QueryerContext     = (*tracedConn)(nil)
+	_ driver.ExecerContext      = (*tracedConn)(nil)
+	_ driver.ConnPrepareContext = (*tracedConn)(nil)
+	_ driver.ConnBeginTx        = (*tracedConn)(nil)
+	_ driver.Pinger             = (*tracedConn)(nil)
+	_ driver.SessionResetter    = (*tracedConn)(nil)
+	_ driver.Validator          = (*tracedConn)(nil)
+	_ driver.NamedValueChecker  = (*tracedConn)(nil)
+)
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startQuerySpan(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	span.End(skipIgnored(err))
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startQuerySpan(ctx, query)
+	result, err := execer.ExecContext(ctx, query, args)
+	span.End(skipIgnored(err))
+	return result, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{Stmt: stmt, query: query}, nil
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, options driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, options)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type tracedStmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, span := startQuerySpan(ctx, s.query)
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	span.End(err)
+	return rows, err
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, span := startQuerySpan(ctx, s.query)
+	var result driver.Result
+	var err error
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			result, err = s.Stmt.Exec(values)
+		}
+	}
+	span.End(err)
+	return result, err
+}
+
+func (s *tracedStmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, driver.ErrSkip
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+// skipIgnored hides driver.ErrSkip, which only tells database/sql to retry
+// another way and is not a failed query.
+func skipIgnored(err error) error {
+	if err == driver.ErrSkip {
+		return nil
+	}
+	return err
+}
+
+func startQuerySpan(ctx context.Context, query string) (context.Context, *Span) {
+	if currentProvider() == nil {
+		return ctx, nil
+	}
+	caller := queryCaller()
+	operation := strings.ToUpper(firstWord(query))
+	name := caller
+	if name == "" {
+		name = operation
+	}
+	return StartWithKind(ctx, name, KindClient,
+		String("db.system", "postgresql"),
+		String("db.operation", operation),
+		String("db.statement", query),
+		String("code.function", caller),
+	)
+}
+
+// queryCaller names the first function on the stack outside database/sql and
+// this package, trimmed to Type.Method, e.g. DiveRepository.CreateDive.
+func queryCaller() string {
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		function := frame.Function
+		if function != "" && !strings.HasPrefix(function, "database/sql.") &&
+			!strings.HasPrefix(function, "divelog-backend/tracing.") && !strings.HasPrefix(function, "runtime.") {
+			if slash := strings.LastIndex(function, "/"); slash >= 0 {
+				function = function[slash+1:]
+			}
+			if dot := strings.Index(function, "."); dot >= 0 {
+				function = function[dot+1:]
+			}
+			return strings.NewReplacer("(*", "", ")", "").Replace(function)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func firstWord(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
@@ -0,0 +1,135 @@
+package tracing_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"divelog-backend/tracing"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConnector hands out connections that answer every query with one row
+// and fail the statement "fail".
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{}, nil
+}
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if query == "fail" {
+		return nil, errors.New("relation does not exist")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{ done bool }
+
+func (r *fakeRows) Columns() []string { return []string{"id"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(42)
+	return nil
+}
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (e *recordingExporter) Export(_ context.Context, _ string, spans []tracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+type diveRepository struct{ db *sql.DB }
+
+func (r *diveRepository) CreateDive(ctx context.Context) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, "INSERT INTO dives DEFAULT VALUES RETURNING id").Scan(&id)
+	return id, err
+}
+
+func (r *diveRepository) Broken(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, "fail")
+	return err
+}
+
+func attribute(span tracing.SpanData, key string) interface{} {
+	for _, attribute := range span.Attributes {
+		if attribute.Key == key {
+			return attribute.Value
+		}
+	}
+	return nil
+}
+
+func TestWrapConnectorTracesQueriesByCaller(t *testing.T) {
+	exporter := &recordingExporter{}
+	provider := tracing.NewProvider("divelog-test", exporter, nil)
+	tracing.SetProvider(provider)
+	defer tracing.SetProvider(nil)
+	db := sql.OpenDB(tracing.WrapConnector(fakeConnector{}))
+	defer db.Close()
+	repository := &diveRepository{db: db}
+
+	ctx, parent := tracing.Start(context.Background(), "DiveService.CreateDive")
+	id, err := repository.CreateDive(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 42, id)
+	assert.Error(t, repository.Broken(ctx))
+	parent.End(nil)
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	require.Len(t, exporter.spans, 3)
+	insert, broken, service := exporter.spans[0], exporter.spans[1], exporter.spans[2]
+	assert.Equal(t, "diveRepository.CreateDive", insert.Name)
+	assert.Equal(t, tracing.KindClient, insert.Kind)
+	assert.Equal(t, service.SpanID, insert.ParentSpanID)
+	assert.Equal(t, "INSERT", attribute(insert, "db.operation"))
+	assert.Equal(t, "INSERT INTO dives DEFAULT VALUES RETURNING id", attribute(insert, "db.statement"))
+	assert.Equal(t, "postgresql", attribute(insert, "db.system"))
+	assert.Equal(t, "diveRepository.Broken", broken.Name)
+	assert.Equal(t, tracing.StatusError, broken.Status)
+	assert.Equal(t, "relation does not exist", broken.StatusMessage)
+}
+
+func TestWrapConnectorWithoutProvider(t *testing.T) {
+	tracing.SetProvider(nil)
+	db := sql.OpenDB(tracing.WrapConnector(fakeConnector{}))
+	defer db.Close()
+
+	id, err := (&diveRepository{db: db}).CreateDive(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 42, id)
+	tx, err := db.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+}
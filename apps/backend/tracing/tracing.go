@@ -0,0 +1,394 @@
+// Package tracing records OpenTelemetry-compatible spans. Traces join callers
+// through the W3C traceparent header and are exported in batches, either to an
+// OTLP/HTTP collector or as JSON lines for local use. Until SetProvider is
+// called, Start hands out spans that record nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind follows the OTLP enumeration.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// StatusCode follows the OTLP enumeration.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attribute is a span attribute; Value is a string, int64, float64 or bool.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute      { return Attribute{key, value} }
+func Int(key string, value int) Attribute     { return Attribute{key, int64(value)} }
+func Int64(key string, value int64) Attribute { return Attribute{key, value} }
+func Bool(key string, value bool) Attribute   { return Attribute{key, value} }
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+func (id TraceID) IsValid() bool  { return id != TraceID{} }
+func (id SpanID) IsValid() bool   { return id != SpanID{} }
+
+// SpanData is a finished span as handed to an Exporter.
+type SpanData struct {
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID
+	Name          string
+	Kind          SpanKind
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span is an operation being timed. A nil *Span is valid and records nothing,
+// so callers never need to check whether tracing is enabled.
+type Span struct {
+	provider *Provider
+	mu       sync.Mutex
+	data     SpanData
+	ended    bool
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attributes...)
+}
+
+// SetStatus sets the span status; an error status keeps its message.
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status = code
+	if code == StatusError {
+		s.data.StatusMessage = message
+	}
+}
+
+// End finishes the span, marking it failed when err is not nil. Only the first
+// call has an effect.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	if err != nil {
+		s.data.Status = StatusError
+		s.data.StatusMessage = err.Error()
+	}
+	data := s.data
+	s.mu.Unlock()
+	s.provider.enqueue(data)
+}
+
+// TraceID returns the ID of the trace the span belongs to.
+func (s *Span) TraceID() TraceID {
+	if s == nil {
+		return TraceID{}
+	}
+	return s.data.TraceID
+}
+
+// spanContext identifies a span, local or remote, that new spans descend from.
+type spanContext struct {
+	traceID TraceID
+	spanID  SpanID
+	sampled bool
+}
+
+type spanContextKey struct{}
+type requestIDKey struct{}
+
+// Start begins a span as a child of the span in ctx, or of the remote parent
+// extracted by Extract, and returns a context carrying it.
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return StartWithKind(ctx, name, KindInternal, attributes...)
+}
+
+// StartWithKind is Start for spans that are not internal operations.
+func StartWithKind(ctx context.Context, name string, kind SpanKind, attributes ...Attribute) (context.Context, *Span) {
+	provider := currentProvider()
+	if provider == nil {
+		return ctx, nil
+	}
+	parent, hasParent := ctx.Value(spanContextKey{}).(spanContext)
+	if hasParent && !parent.sampled {
+		return ctx, nil
+	}
+
+	span := &Span{provider: provider, data: SpanData{
+		SpanID: newSpanID(),
+		Name:   name,
+		Kind:   kind,
+		Start:  time.Now(),
+	}}
+	if hasParent {
+		span.data.TraceID = parent.traceID
+		span.data.ParentSpanID = parent.spanID
+	} else {
+		span.data.TraceID = newTraceID()
+	}
+	if requestID, ok := ctx.Value(requestIDKey{}).(string); ok {
+		span.data.Attributes = append(span.data.Attributes, String("request_id", requestID))
+	}
+	span.data.Attributes = append(span.data.Attributes, attributes...)
+
+	ctx = context.WithValue(ctx, spanContextKey{}, spanContext{
+		traceID: span.data.TraceID, spanID: span.data.SpanID, sampled: true,
+	})
+	return ctx, span
+}
+
+// WithRequestID tags every span started under ctx with the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// TraceparentHeader carries the trace context between services.
+const TraceparentHeader = "traceparent"
+
+// Extract returns ctx with the remote parent named by a W3C traceparent value,
+// or ctx unchanged when the value is missing or malformed.
+func Extract(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return ctx
+	}
+	var parent spanContext
+	var flags [1]byte
+	if !decodeHex(parent.traceID[:], parts[1]) || !decodeHex(parent.spanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) {
+		return ctx
+	}
+	if !parent.traceID.IsValid() || !parent.spanID.IsValid() {
+		return ctx
+	}
+	parent.sampled = flags[0]&1 == 1
+	return context.WithValue(ctx, spanContextKey{}, parent)
+}
+
+// Inject returns the traceparent value for the span in ctx, or "" if there is
+// none.
+func Inject(ctx context.Context) string {
+	current, ok := ctx.Value(spanContextKey{}).(spanContext)
+	if !ok {
+		return ""
+	}
+	flags := "00"
+	if current.sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", current.traceID, current.spanID, flags)
+}
+
+func decodeHex(dst []byte, value string) bool {
+	if len(value) != 2*len(dst) || strings.ToLower(value) != value {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(value))
+	return err == nil
+}
+
+func newTraceID() (id TraceID) {
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+// Exporter sends finished spans somewhere.
+type Exporter interface {
+	Export(ctx context.Context, serviceName string, spans []SpanData) error
+}
+
+const (
+	maxQueuedSpans = 2048
+	maxExportBatch = 512
+	exportInterval = 5 * time.Second
+)
+
+// Provider batches finished spans and hands them to an exporter in the
+// background. Spans that arrive while the queue is full are dropped rather
+// than slowing requests down.
+type Provider struct {
+	serviceName string
+	exporter    Exporter
+	queue       chan SpanData
+	flush       chan chan struct{}
+	done        chan struct{}
+	stopped     chan struct{}
+	dropped     atomic.Int64
+	onError     func(error)
+}
+
+// NewProvider starts exporting spans for serviceName. onError, which may be
+// nil, is told about failed exports.
+func NewProvider(serviceName string, exporter Exporter, onError func(error)) *Provider {
+	p := &Provider{
+		serviceName: serviceName,
+		exporter:    exporter,
+		queue:       make(chan SpanData, maxQueuedSpans),
+		flush:       make(chan chan struct{}),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+		onError:     onError,
+	}
+	go p.run()
+	return p
+}
+
+// Dropped returns how many spans were discarded because the queue was full.
+func (p *Provider) Dropped() int64 { return p.dropped.Load() }
+
+func (p *Provider) enqueue(span SpanData) {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+	select {
+	case p.queue <- span:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+func (p *Provider) run() {
+	defer close(p.stopped)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, maxExportBatch)
+
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := p.exporter.Export(ctx, p.serviceName, batch)
+		cancel()
+		if err != nil && p.onError != nil {
+			p.onError(err)
+		}
+		batch = make([]SpanData, 0, maxExportBatch)
+	}
+	drain := func() {
+		for {
+			select {
+			case span := <-p.queue:
+				batch = append(batch, span)
+				if len(batch) == maxExportBatch {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) == maxExportBatch {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-p.flush:
+			drain()
+			close(flushed)
+		case <-p.done:
+			drain()
+			return
+		}
+	}
+}
+
+// ForceFlush exports every span finished so far.
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case p.flush <- flushed:
+	case <-p.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the remaining spans and stops the provider.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	select {
+	case <-p.done:
+	default:
+		close(p.done)
+	}
+	select {
+	case <-p.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var globalProvider atomic.Pointer[Provider]
+
+// SetProvider makes p record every span started from now on; nil disables
+// tracing.
+func SetProvider(p *Provider) {
+	globalProvider.Store(p)
+}
+
+func currentProvider() *Provider {
+	return globalProvider.Load()
+}
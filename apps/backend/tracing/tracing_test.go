@@ -0,0 +1,190 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingExporter keeps every exported span.
+type recordingExporter struct {
+	mu      sync.Mutex
+	service string
+	spans   []SpanData
+}
+
+func (e *recordingExporter) Export(_ context.Context, serviceName string, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.service = serviceName
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) byName() map[string]SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := map[string]SpanData{}
+	for _, span := range e.spans {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func useRecordingProvider(t *testing.T) (*Provider, *recordingExporter) {
+	exporter := &recordingExporter{}
+	provider := NewProvider("divelog-test", exporter, nil)
+	SetProvider(provider)
+	t.Cleanup(func() {
+		SetProvider(nil)
+		provider.Shutdown(context.Background())
+	})
+	return provider, exporter
+}
+
+func TestSpansFormOneTraceWithRequestID(t *testing.T) {
+	provider, exporter := useRecordingProvider(t)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx, parent := StartWithKind(ctx, "POST /dives", KindServer)
+	_, child := Start(ctx, "DiveService.CreateDive", Int("user_id", 7))
+	child.End(errors.New("duplicate dive"))
+	parent.End(nil)
+	require.NoError(t, provider.ForceFlush(context.Background()))
+
+	spans := exporter.byName()
+	require.Len(t, spans, 2)
+	server, service := spans["POST /dives"], spans["DiveService.CreateDive"]
+	assert.Equal(t, "divelog-test", exporter.service)
+	assert.Equal(t, server.TraceID, service.TraceID)
+	assert.Equal(t, server.SpanID, service.ParentSpanID)
+	assert.False(t, server.ParentSpanID.IsValid())
+	assert.Equal(t, KindServer, server.Kind)
+	assert.Equal(t, []Attribute{String("request_id", "req-1"), Int("user_id", 7)}, service.Attributes)
+	assert.Equal(t, StatusError, service.Status)
+	assert.Equal(t, "duplicate dive", service.StatusMessage)
+	assert.Equal(t, StatusUnset, server.Status)
+	assert.False(t, service.End.Before(service.Start))
+}
+
+func TestSpanEndsOnce(t *testing.T) {
+	provider, exporter := useRecordingProvider(t)
+
+	_, span := Start(context.Background(), "once")
+	span.End(errors.New("first"))
+	span.End(nil)
+	require.NoError(t, provider.ForceFlush(context.Background()))
+
+	require.Len(t, exporter.spans, 1)
+	assert.Equal(t, "first", exporter.spans[0].StatusMessage)
+}
+
+func TestStartWithoutProviderRecordsNothing(t *testing.T) {
+	SetProvider(nil)
+	ctx := context.Background()
+
+	spanCtx, span := Start(ctx, "ignored")
+
+	assert.Nil(t, span)
+	assert.Equal(t, ctx, spanCtx)
+	span.SetAttributes(String("key", "value"))
+	span.SetStatus(StatusError, "ignored")
+	span.End(errors.New("ignored"))
+	assert.Empty(t, Inject(spanCtx))
+}
+
+func TestExtractContinuesRemoteTrace(t *testing.T) {
+	provider, exporter := useRecordingProvider(t)
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	ctx, span := Start(Extract(context.Background(), traceparent), "continued")
+	injected := Inject(ctx)
+	span.End(nil)
+	require.NoError(t, provider.ForceFlush(context.Background()))
+
+	require.Len(t, exporter.spans, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", exporter.spans[0].TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", exporter.spans[0].ParentSpanID.String())
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+exporter.spans[0].SpanID.String()+"-01", injected)
+}
+
+func TestExtractHonoursUnsampledParent(t *testing.T) {
+	useRecordingProvider(t)
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+
+	ctx := Extract(context.Background(), traceparent)
+	_, span := Start(ctx, "not sampled")
+
+	assert.Nil(t, span)
+	assert.Equal(t, traceparent, Inject(ctx))
+}
+
+func TestExtractIgnoresMalformedValues(t *testing.T) {
+	ctx := context.Background()
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		assert.Empty(t, Inject(Extract(ctx, value)), value)
+	}
+	// Later versions may append fields.
+	assert.NotEmpty(t, Inject(Extract(ctx, "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")))
+}
+
+func TestProviderShutdownExportsQueuedSpans(t *testing.T) {
+	exporter := &recordingExporter{}
+	provider := NewProvider("divelog-test", exporter, nil)
+	SetProvider(provider)
+	defer SetProvider(nil)
+
+	for i := 0; i < 3; i++ {
+		_, span := Start(context.Background(), "queued")
+		span.End(nil)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, provider.Shutdown(ctx))
+
+	assert.Len(t, exporter.spans, 3)
+	// Spans ended after shutdown are dropped quietly.
+	_, late := Start(context.Background(), "late")
+	late.End(nil)
+	assert.NoError(t, provider.ForceFlush(ctx))
+	assert.Len(t, exporter.spans, 3)
+}
+
+func TestProviderReportsExportErrors(t *testing.T) {
+	var reported []error
+	var mu sync.Mutex
+	provider := NewProvider("divelog-test", failingExporter{}, func(err error) {
+		mu.Lock()
+		reported = append(reported, err)
+		mu.Unlock()
+	})
+	SetProvider(provider)
+	defer SetProvider(nil)
+
+	_, span := Start(context.Background(), "lost")
+	span.End(nil)
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, reported, 1)
+	assert.EqualError(t, reported[0], "collector unavailable")
+}
+
+type failingExporter struct{}
+
+func (failingExporter) Export(context.Context, string, []SpanData) error {
+	return errors.New("collector unavailable")
+}
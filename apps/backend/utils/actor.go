@@ -0,0 +1,28 @@
+package utils
+
+import "context"
+
+// Actor identifies who a request acts for, so the audit log can attribute the
+// changes it makes.
+type Actor struct {
+	UserID    int // 0 when the request names no user
+	TokenID   int // 0 unless the request used an API token
+	RequestID string
+}
+
+type actorKey struct{}
+
+// WithActor returns ctx carrying actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor in ctx, or the zero Actor for work that
+// does not come from a request.
+func ActorFromContext(ctx context.Context) Actor {
+	if ctx == nil {
+		return Actor{}
+	}
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
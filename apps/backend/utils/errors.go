@@ -12,6 +12,7 @@ var (
 // Database errors
 var (
 	ErrDiveNotFound          = errors.New("dive not found")
+	ErrDiveRevisionNotFound  = errors.New("dive revision not found")
 	ErrDiveSiteNotFound      = errors.New("dive site not found")
 	ErrDuplicateDive         = errors.New("duplicate dive exists")
 	ErrDuplicateDiveSite     = errors.New("duplicate dive site exists")
@@ -22,6 +23,18 @@ var (
 	ErrBulkOperationNotFound = errors.New("bulk operation not found")
 	ErrBulkOperationUndone   = errors.New("bulk operation was already undone")
 	ErrTimestampConflict     = errors.New("timestamp change would create a duplicate dive")
+	ErrSpeciesNotFound       = errors.New("species not found")
+	ErrDuplicateSpecies      = errors.New("species already exists in the catalog")
+	ErrCertificationNotFound = errors.New("certification not found")
+	ErrCertificationExists   = errors.New("certification already exists for this agency and level")
+	ErrCardImageNotFound     = errors.New("certification has no card image")
+	ErrWebhookNotFound       = errors.New("webhook subscription not found")
+	ErrWebhookURLNotAllowed  = errors.New("webhook URL must resolve to public addresses only")
+	ErrAPITokenNotFound      = errors.New("API token not found")
+	ErrInvalidAPIToken       = errors.New("API token is invalid or expired")
+	ErrSyncCursorUnknown     = errors.New("sync cursor is ahead of the change feed; start again from 0")
+	ErrVersionConflict       = errors.New("record was changed since the version given in If-Match")
+	ErrTrashItemNotFound     = errors.New("item not found in the trash")
 	ErrDatabaseError         = errors.New("database error")
 )
 
@@ -29,4 +42,5 @@ var (
 var (
 	ErrInvalidInput     = errors.New("invalid input data")
 	ErrProcessingFailed = errors.New("processing failed")
+	ErrUnsupportedImage = errors.New("image must be a JPEG, PNG or WebP picture")
 )
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// IsPublicAddress reports whether the server may connect to ip on a user's
+// behalf. Loopback, private, link-local and unspecified addresses are
+// refused, including their IPv4-mapped IPv6 forms, so user-supplied URLs
+// cannot reach internal services or cloud metadata endpoints.
+func IsPublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+// IsLocalHostname reports whether host names this machine without needing a
+// DNS lookup: "localhost" and its subdomains.
+func IsLocalHostname(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	return host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
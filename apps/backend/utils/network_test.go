@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	for _, address := range []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.10", "169.254.169.254",
+		"0.0.0.0", "::1", "::", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "::ffff:10.0.0.1",
+	} {
+		assert.False(t, IsPublicAddress(netip.MustParseAddr(address)), address)
+	}
+	for _, address := range []string{"93.184.216.34", "8.8.8.8", "2606:4700::1111"} {
+		assert.True(t, IsPublicAddress(netip.MustParseAddr(address)), address)
+	}
+	assert.False(t, IsPublicAddress(netip.Addr{}))
+}
+
+func TestIsLocalHostname(t *testing.T) {
+	assert.True(t, IsLocalHostname("localhost"))
+	assert.True(t, IsLocalHostname("LocalHost."))
+	assert.True(t, IsLocalHostname("api.localhost"))
+	assert.False(t, IsLocalHostname("localhost.example.com"))
+	assert.False(t, IsLocalHostname("hooks.example.com"))
+}